@@ -0,0 +1,22 @@
+#!/usr/bin/bash
+
+# Cold cache runs of each -advise mode with each strategy that takes hints. Dropping the page cache
+# needs root, so run with sudo. Use a file of a few GB so reading it off the drive dominates the run.
+set -e
+
+file=${1:-measurements.txt}
+runs=${2:-3}
+TIMEFORMAT=%R
+
+mkdir -p dist
+go build -o dist/brc .
+for strategy in streaming mmap; do
+    for mode in off sequential window; do
+        for run in $(seq "$runs"); do
+            sync
+            echo 3 > /proc/sys/vm/drop_caches
+            seconds=$( { time dist/brc -strategy "$strategy" -advise "$mode" -timing-format none "$file" > /dev/null; } 2>&1 )
+            echo "$strategy $mode $seconds"
+        done
+    done
+done
@@ -0,0 +1,109 @@
+package brc
+
+import (
+	"io"
+	"os"
+)
+
+var advise = commandLine.String("advise", "sequential", "read-ahead `hints` for the kernel (64 bit Linux only): off, sequential (FADV_ and MADV_SEQUENTIAL, a bigger read-ahead) or window (also WILLNEED on the next few MiB and DONTNEED on what has been read, so a big file doesn't fill the page cache)")
+
+var adviseModes = []string{"off", "sequential", "window"}
+
+// The advice numbers, the same for posix_fadvise and madvise on Linux.
+const (
+	ADVISE_SEQUENTIAL = 2
+	ADVISE_WILLNEED   = 3
+	ADVISE_DONTNEED   = 4
+)
+
+// ADVISE_WINDOW is how far ahead -advise=window asks for pages, and how much is read before dropping it.
+const ADVISE_WINDOW = 8 * BUFFER_SIZE
+
+// ADVISE_PAGE is what madvise ranges are rounded to, the smallest page size in use.
+const ADVISE_PAGE = 4096
+
+// adviseFile tells the kernel filePtr is read front to back. Hints are only hints, errors are ignored.
+func adviseFile(filePtr *os.File) {
+	if *advise != "off" {
+		fadvise(filePtr.Fd(), 0, 0, ADVISE_SEQUENTIAL)
+	}
+}
+
+// adviseReader keeps a window of filePtr asked for ahead of the reads and drops what they have passed.
+type adviseReader struct {
+	r                   io.Reader
+	fd                  uintptr
+	off, ahead, dropped int64
+}
+
+// adviseStream wraps filePtr in an adviseReader under -advise=window.
+func adviseStream(filePtr *os.File) io.Reader {
+	if *advise != "window" {
+		return filePtr
+	}
+	return &adviseReader{r: filePtr, fd: filePtr.Fd()}
+}
+
+func (r *adviseReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.off += int64(n)
+
+	for r.ahead <= r.off+ADVISE_WINDOW {
+		fadvise(r.fd, r.ahead, ADVISE_WINDOW, ADVISE_WILLNEED)
+		r.ahead += ADVISE_WINDOW
+	}
+
+	//Read is done with its pages once it has copied them out
+	done := r.off &^ (ADVISE_PAGE - 1)
+	if err == io.EOF {
+		done = r.off
+	}
+	if done-r.dropped >= ADVISE_WINDOW || err == io.EOF && done > r.dropped {
+		fadvise(r.fd, r.dropped, done-r.dropped, ADVISE_DONTNEED)
+		r.dropped = done
+	}
+
+	return n, err
+}
+
+// mappingAdvice does for a mapped file what adviseReader does for a read one: WILLNEED ahead of the chunk
+// being handed out and, under -advise=window, DONTNEED on each chunk once it has been parsed. Dropping
+// pages of a read only file mapping only unmaps them, a neighbouring chunk straddling a page faults it back in.
+type mappingAdvice struct {
+	data  []byte
+	mode  string
+	ahead int
+}
+
+// newMappingAdvice advises data under mode, one of adviseModes.
+func newMappingAdvice(data []byte, mode string) *mappingAdvice {
+	if mode != "off" {
+		madvise(data, ADVISE_SEQUENTIAL)
+	}
+	return &mappingAdvice{data: data, mode: mode}
+}
+
+// reading is called as the chunk at offset is handed out.
+func (m *mappingAdvice) reading(offset int64) {
+	if m.mode != "window" {
+		return
+	}
+
+	for m.ahead <= int(offset)+ADVISE_WINDOW && m.ahead < len(m.data) {
+		madvise(m.data[m.ahead:min(m.ahead+ADVISE_WINDOW, len(m.data))], ADVISE_WILLNEED)
+		m.ahead += ADVISE_WINDOW
+	}
+}
+
+// consumed is called once chunk has been parsed, dropping the pages wholly inside it.
+func (m *mappingAdvice) consumed(chunk Chunk) {
+	if m.mode != "window" {
+		return
+	}
+
+	start := (int(chunk.offset) + ADVISE_PAGE - 1) &^ (ADVISE_PAGE - 1)
+	end := (int(chunk.offset) + len(chunk.data)) &^ (ADVISE_PAGE - 1)
+	if end > start {
+		madvise(m.data[start:end], ADVISE_DONTNEED)
+	}
+}
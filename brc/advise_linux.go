@@ -0,0 +1,16 @@
+//go:build linux && !386 && !arm && !mips && !mipsle && !s390x
+
+package brc
+
+import "syscall"
+
+const adviseSupported = true
+
+// fadvise is posix_fadvise, whose offset and length are plain registers on 64 bit Linux.
+func fadvise(fd uintptr, offset, length int64, advice int) {
+	syscall.Syscall6(syscall.SYS_FADVISE64, fd, uintptr(offset), uintptr(length), uintptr(advice), 0, 0)
+}
+
+func madvise(data []byte, advice int) {
+	syscall.Madvise(data, advice)
+}
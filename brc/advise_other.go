@@ -0,0 +1,9 @@
+//go:build !linux || 386 || arm || mips || mipsle || s390x
+
+package brc
+
+const adviseSupported = false
+
+func fadvise(fd uintptr, offset, length int64, advice int) {}
+
+func madvise(data []byte, advice int) {}
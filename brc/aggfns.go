@@ -0,0 +1,81 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var aggFnsFlag = commandLine.String("agg-fns", "min,mean,max", "comma separated `aggregates` printed per station, in order: min, max, mean, sum, count, geomean, or with -histogram median and percentiles p1 to p99")
+
+var statsFlag = commandLine.String("stats", "", "comma separated `stats` appended to every station after -agg-fns: count (the other output formats always have it)")
+
+// extraStats are what -stats may ask for.
+var extraStats = []string{"count"}
+
+// AGG_FNS_DEFAULT is the challenge's triple, which Print formats directly.
+const AGG_FNS_DEFAULT = "min,mean,max"
+
+// aggFns format one aggregate of a station for the text output.
+var aggFns = map[string]func(r *StationResult) string{
+	"min":  func(r *StationResult) string { return formatValue(outputUnit().value(r.min)) },
+	"max":  func(r *StationResult) string { return formatValue(outputUnit().value(r.max)) },
+	"mean": func(r *StationResult) string { return formatValue(outputUnit().mean(r.sum, r.count)) },
+	"sum": func(r *StationResult) string {
+		return formatValue(outputUnit().sum(estimate(r.sum), estimate(r.count)))
+	},
+	"count": func(r *StationResult) string { return strconv.Itoa(estimate(r.count)) },
+	"geomean": func(r *StationResult) string {
+		geomean := math.Exp(r.sumLog / float64(r.count))
+		if math.IsNaN(geomean) {
+			return fmt.Sprint(geomean)
+		}
+		return formatValue(int(math.Floor(geomean/float64(outputStep()) + 0.5)))
+	},
+}
+
+// activeAggFns is -agg-fns resolved in main, nil for the default so the challenge output is untouched.
+var activeAggFns []func(r *StationResult) string
+
+// trackLogs makes AddAt accumulate the log sum geomean needs. Only runs asking for geomean pay for a log per reading.
+var trackLogs bool
+
+// parseAggFns resolves a comma separated list of aggFns names, reporting whether geomean is among them.
+func parseAggFns(s string) ([]func(r *StationResult) string, bool, error) {
+	var fns []func(r *StationResult) string
+	geomean := false
+
+	for _, name := range strings.Split(s, ",") {
+		fn, ok := aggFns[strings.TrimSpace(name)]
+		if p, isPercentile := parsePercentile(strings.TrimSpace(name)); isPercentile {
+			fn, ok = func(r *StationResult) string { return percentile(r, p) }, true
+		}
+		if !ok {
+			return nil, false, fmt.Errorf("-agg-fns: %q is unknown, want some of %s, median or p1 to p99", name, strings.Join(sortedKeys(aggFns), ", "))
+		}
+
+		fns = append(fns, fn)
+		geomean = geomean || strings.TrimSpace(name) == "geomean"
+	}
+
+	return fns, geomean, nil
+}
+
+// aggFnsList is the aggregates printed for -agg-fns=fns -stats=stats.
+func aggFnsList(fns, stats string) string {
+	if stats == "" {
+		return fns
+	}
+	return fns + "," + stats
+}
+
+// resolveAggFns sets activeAggFns and trackLogs from -agg-fns and -stats.
+func resolveAggFns() {
+	fns := aggFnsList(*aggFnsFlag, *statsFlag)
+	if fns == AGG_FNS_DEFAULT {
+		return
+	}
+
+	activeAggFns, trackLogs, _ = parseAggFns(fns)
+}
@@ -0,0 +1,38 @@
+package brc
+
+import (
+	"testing"
+)
+
+// aggInput is the two station fixture of aggCases and TestSummary.
+const aggInput = "Warm;2.0\nCold;-2.0\nWarm;4.0\nCold;0.0\nWarm;4.0\nCold;-1.0\nWarm;8.0\n"
+
+// aggCases are -agg-fns and -stats lists and the text output they must produce from aggInput.
+var aggCases = []struct {
+	fns, stats, want string
+}{
+	{"min,mean,max", "", "{Cold=-2.0/-1.0/0.0, Warm=2.0/4.5/8.0}\n"},
+	{"count,sum", "", "{Cold=3/-3.0, Warm=4/18.0}\n"},
+	{"max,min", "", "{Cold=0.0/-2.0, Warm=8.0/2.0}\n"},
+	{"geomean,mean", "", "{Cold=NaN/-1.0, Warm=4.0/4.5}\n"},
+	{"min,mean,max", "count", "{Cold=-2.0/-1.0/0.0/3, Warm=2.0/4.5/8.0/4}\n"},
+	{"mean", "count", "{Cold=-1.0/3, Warm=4.5/4}\n"},
+}
+
+// TestAggFns runs aggCases through every strategy and checks the default stays the challenge triple.
+func TestAggFns(t *testing.T) {
+	if *aggFnsFlag != AGG_FNS_DEFAULT {
+		t.Errorf("-agg-fns defaults to %s, want %s", *aggFnsFlag, AGG_FNS_DEFAULT)
+	}
+	defer func(fns []func(r *StationResult) string, logs bool) { activeAggFns, trackLogs = fns, logs }(activeAggFns, trackLogs)
+
+	for _, c := range aggCases {
+		t.Run("-agg-fns="+c.fns+" -stats="+c.stats, func(t *testing.T) {
+			var err error
+			if activeAggFns, trackLogs, err = parseAggFns(aggFnsList(c.fns, c.stats)); err != nil {
+				t.Fatal(err)
+			}
+			expectEveryStrategy(t, aggInput, c.want)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package brc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+var aliasesFile = commandLine.String("aliases", "", "CSV `file` of raw,canonical station names, e.g. NYC,New York, aggregating every raw name under its canonical one before -nfc, -fold-case and -key")
+
+// readAliases reads the CSV at r, a raw and a canonical name a row and # starting a comment, into a map
+// from raw to canonical name. A raw name given two canonical names is an error, as is a canonical name that
+// is itself an alias, which would be aggregated under a name the file says isn't canonical.
+func readAliases(r io.Reader) (map[string]string, error) {
+	records := csv.NewReader(r)
+	records.FieldsPerRecord = 2
+	records.Comment = '#'
+
+	aliases := map[string]string{}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		raw, canonical := record[0], record[1]
+		if raw == "" || canonical == "" {
+			line, _ := records.FieldPos(0)
+			return nil, fmt.Errorf("line %d: empty station name", line)
+		}
+		if previous, ok := aliases[raw]; ok && previous != canonical {
+			return nil, fmt.Errorf("%q is an alias of both %q and %q", raw, previous, canonical)
+		}
+		aliases[raw] = canonical
+	}
+
+	for raw, canonical := range aliases {
+		if next, ok := aliases[canonical]; ok && next != canonical {
+			return nil, fmt.Errorf("%q is an alias of %q, itself an alias of %q", raw, canonical, next)
+		}
+	}
+	return aliases, nil
+}
+
+func readAliasesFile(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	aliases, err := readAliases(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return aliases, nil
+}
+
+// AliasKey wraps key so it sees the canonical name of every station aliases lists, and other stations as they are.
+func AliasKey(aliases map[string]string, key KeyFunc) KeyFunc {
+	return func(dst, station []byte) []byte {
+		if canonical, ok := aliases[string(station)]; ok {
+			renamed := append(dst, canonical...)
+			station, dst = renamed, renamed[len(renamed):]
+		}
+
+		if key == nil {
+			return station
+		}
+		return key(dst, station)
+	}
+}
@@ -0,0 +1,49 @@
+package brc
+
+import (
+	"strings"
+	"testing"
+)
+
+const stationAliases = `# raw,canonical
+NYC,New York
+"New York City",New York
+Big Apple,New York
+"Lagos, NG",Lagos
+`
+
+var badAliases = []string{
+	"NYC,New York\nNYC,Newark\n",
+	"NYC,New York City\nNew York City,New York\n",
+	"NYC\n",
+	"NYC,\n",
+}
+
+// TestAliases runs -aliases through every strategy, on its own and under -fold-case, which it comes before
+// so nyc isn't an alias of New York, and checks readAliases rejects badAliases.
+func TestAliases(t *testing.T) {
+	aliases, err := readAliases(strings.NewReader(stationAliases))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "NYC;1.0\nNew York;3.0\nBig Apple;5.0\nLagos, NG;30.0\nnyc;-4.0\nNew York City;-1.0\n"
+	keys := map[string]struct {
+		key  KeyFunc
+		want string
+	}{
+		"-aliases":            {AliasKey(aliases, nil), "{Lagos=30.0/30.0/30.0, New York=-1.0/2.0/5.0, nyc=-4.0/-4.0/-4.0}\n"},
+		"-aliases -fold-case": {AliasKey(aliases, FoldCaseKey(nil)), "{lagos=30.0/30.0/30.0, new york=-1.0/2.0/5.0, nyc=-4.0/-4.0/-4.0}\n"},
+	}
+	for flags, c := range keys {
+		t.Run(flags, func(t *testing.T) {
+			expectEveryStrategy(t, input, c.want, WithKey(c.key))
+		})
+	}
+
+	for _, bad := range badAliases {
+		if _, err := readAliases(strings.NewReader(bad)); err == nil {
+			t.Errorf("-aliases accepted %q", bad)
+		}
+	}
+}
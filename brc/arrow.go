@@ -0,0 +1,294 @@
+package brc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// The Arrow IPC file format, as read by pyarrow.ipc.open_file, pandas.read_feather and polars.read_ipc:
+//
+//	"ARROW1\0\0"                 magic, padded to 8
+//	schema message                the columns
+//	record batch message          every station
+//	0xFFFFFFFF 0                  end of stream
+//	footer length "ARROW1"        the footer repeats the schema and points at the batch
+//
+// A message is 0xFFFFFFFF, the length of its flatbuffer metadata padded to 8, the metadata, then the body
+// of 8 aligned buffers.
+const ARROW_MAGIC = "ARROW1"
+
+// Arrow's flatbuffer enums, as in Schema.fbs and Message.fbs.
+const (
+	ARROW_METADATA_V5   = 4
+	ARROW_SCHEMA        = 1
+	ARROW_RECORD_BATCH  = 3
+	ARROW_INT           = 2
+	ARROW_FLOATING      = 3
+	ARROW_UTF8          = 5
+	ARROW_DOUBLE        = 2
+	ARROW_CONTINUATION  = 0xFFFFFFFF
+	ARROW_LITTLE_ENDIAN = 0
+)
+
+// arrowSchema is station utf8, min, mean and max float64 in degrees, count int64 and stddev float64,
+// null where a merged partial didn't record it.
+func arrowSchema() fbTable {
+	field := func(name string, nullable bool, typeType uint8, typ fbTable) fbTable {
+		return fbTable{fbString(name), fbBool(nullable), fbUint8(typeType), typ, nil, fbVector{}}
+	}
+	double := fbTable{fbInt16(ARROW_DOUBLE)}
+
+	return fbTable{fbInt16(ARROW_LITTLE_ENDIAN), fbVector{
+		field("station", false, ARROW_UTF8, fbTable{}),
+		field("min", false, ARROW_FLOATING, double),
+		field("mean", false, ARROW_FLOATING, double),
+		field("max", false, ARROW_FLOATING, double),
+		field("count", false, ARROW_INT, fbTable{fbInt32(64), fbBool(true)}),
+		field("stddev", true, ARROW_FLOATING, double),
+	}}
+}
+
+// arrowBody gathers a record batch's buffers, each padded to 8, and the nodes and buffers describing them.
+type arrowBody struct {
+	data    []byte
+	nodes   []byte
+	buffers []byte
+}
+
+func (b *arrowBody) node(length, nulls int) {
+	b.nodes = binary.LittleEndian.AppendUint64(b.nodes, uint64(length))
+	b.nodes = binary.LittleEndian.AppendUint64(b.nodes, uint64(nulls))
+}
+
+func (b *arrowBody) buffer(data []byte) {
+	b.buffers = binary.LittleEndian.AppendUint64(b.buffers, uint64(len(b.data)))
+	b.buffers = binary.LittleEndian.AppendUint64(b.buffers, uint64(len(data)))
+
+	b.data = append(b.data, data...)
+	for len(b.data)%8 != 0 {
+		b.data = append(b.data, 0)
+	}
+}
+
+// doubles adds a float64 column, values that don't parse being null.
+func (b *arrowBody) doubles(values []string) {
+	var data, validity []byte
+	nulls := 0
+
+	validity = make([]byte, (len(values)+7)/8)
+	for i, s := range values {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			nulls++
+		} else {
+			validity[i/8] |= 1 << (i % 8)
+		}
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+
+	//A column without nulls may leave its validity bitmap out
+	if nulls == 0 {
+		validity = nil
+	}
+
+	b.node(len(values), nulls)
+	b.buffer(validity)
+	b.buffer(data)
+}
+
+// arrowMessage frames a message's metadata and body, returning the length of the metadata with its framing.
+func arrowMessage(w io.Writer, header uint8, table fbTable, body []byte) (int, error) {
+	meta := fbFinish(fbTable{fbInt16(ARROW_METADATA_V5), fbUint8(header), table, fbInt64(int64(len(body)))})
+	for len(meta)%8 != 0 {
+		meta = append(meta, 0)
+	}
+
+	frame := binary.LittleEndian.AppendUint32(nil, ARROW_CONTINUATION)
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(meta)))
+	if _, err := w.Write(append(frame, meta...)); err != nil {
+		return 0, err
+	}
+
+	_, err := w.Write(body)
+	return len(frame) + len(meta), err
+}
+
+// writeArrowReport writes the stations as one record batch of an Arrow IPC file. An Arrow file ends in its
+// footer so can't be appended to, validate keeps -append-run-id away from it.
+func writeArrowReport(w io.Writer, r Report, header bool) error {
+	rows := timedRows(r)
+	defer timePhase(&phaseTimes.output)()
+
+	body := arrowBody{}
+
+	offsets := binary.LittleEndian.AppendUint32(nil, 0)
+	var names []byte
+	for _, row := range rows {
+		names = append(names, row.Station...)
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(names)))
+	}
+	body.node(len(rows), 0)
+	body.buffer(nil)
+	body.buffer(offsets)
+	body.buffer(names)
+
+	columns := make([][]string, 4)
+	var counts []byte
+	for _, row := range rows {
+		columns[0] = append(columns[0], row.Min.String())
+		columns[1] = append(columns[1], row.Mean.String())
+		columns[2] = append(columns[2], row.Max.String())
+		columns[3] = append(columns[3], row.Stddev.String())
+		counts = binary.LittleEndian.AppendUint64(counts, uint64(row.Count))
+	}
+
+	body.doubles(columns[0])
+	body.doubles(columns[1])
+	body.doubles(columns[2])
+	body.node(len(rows), 0)
+	body.buffer(nil)
+	body.buffer(counts)
+	body.doubles(columns[3])
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(ARROW_MAGIC + "\x00\x00")
+	offset := int64(len(ARROW_MAGIC) + 2)
+
+	n, err := arrowMessage(bw, ARROW_SCHEMA, arrowSchema(), nil)
+	if err != nil {
+		return err
+	}
+	offset += int64(n)
+
+	//FieldNode and Buffer are both two int64s
+	batch := fbTable{fbInt64(int64(len(rows))), fbStructs{len(body.nodes) / 16, 8, body.nodes}, fbStructs{len(body.buffers) / 16, 8, body.buffers}}
+	n, err = arrowMessage(bw, ARROW_RECORD_BATCH, batch, body.data)
+	if err != nil {
+		return err
+	}
+
+	//A Block is the message's offset, metadata length and body length, padded to 24 bytes
+	block := binary.LittleEndian.AppendUint64(nil, uint64(offset))
+	block = binary.LittleEndian.AppendUint32(block, uint32(n))
+	block = binary.LittleEndian.AppendUint32(block, 0)
+	block = binary.LittleEndian.AppendUint64(block, uint64(len(body.data)))
+
+	eos := binary.LittleEndian.AppendUint32(nil, ARROW_CONTINUATION)
+	bw.Write(binary.LittleEndian.AppendUint32(eos, 0))
+
+	footer := fbFinish(fbTable{fbInt16(ARROW_METADATA_V5), arrowSchema(), fbStructs{0, 8, nil}, fbStructs{1, 8, block}})
+	bw.Write(footer)
+	binary.Write(bw, binary.LittleEndian, uint32(len(footer)))
+	bw.WriteString(ARROW_MAGIC)
+
+	return bw.Flush()
+}
+
+// readArrowResults reads the station, min, mean and max columns of every record batch of an Arrow IPC file,
+// such as writeArrowReport's or one pandas wrote back out with the same columns.
+func readArrowResults(data []byte) (map[string]result, error) {
+	tail := len(data) - 4 - len(ARROW_MAGIC)
+	if tail < 8 || string(data[tail+4:]) != ARROW_MAGIC {
+		return nil, errors.New("arrow file has no footer")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[tail:]))
+	if footerLength > tail-8 {
+		return nil, errors.New("arrow footer is longer than the file")
+	}
+
+	footer := &fbReader{buf: data[tail-footerLength : tail]}
+	schema := footer.deref(footer.field(footer.root(), 1))
+
+	//Which buffers of each record batch hold the columns, Int and FloatingPoint have validity and values,
+	//Utf8 offsets too
+	fieldsAt, fields := footer.vector(footer.field(schema, 1))
+	columns := map[string]int{}
+	types := make([]uint8, fields)
+	buffer := 0
+	firstBuffer := make([]int, fields)
+	for i := range fields {
+		field := footer.deref(fieldsAt + 4*i)
+		columns[footer.string(footer.field(field, 0))] = i
+		types[i] = footer.u8(footer.field(field, 2))
+
+		firstBuffer[i] = buffer
+		switch types[i] {
+		case ARROW_INT, ARROW_FLOATING:
+			buffer += 2
+		case ARROW_UTF8:
+			buffer += 3
+		default:
+			return nil, fmt.Errorf("arrow column %d has type %d, only utf8, int and floating point are read", i, types[i])
+		}
+	}
+
+	for _, name := range []string{"station", "min", "mean", "max"} {
+		i, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("arrow file has no %s column", name)
+		}
+		want := uint8(ARROW_FLOATING)
+		if name == "station" {
+			want = ARROW_UTF8
+		}
+		if types[i] != want {
+			return nil, fmt.Errorf("arrow %s column has type %d, want %d", name, types[i], want)
+		}
+	}
+
+	results := map[string]result{}
+	blocksAt, blocks := footer.vector(footer.field(footer.root(), 3))
+	for b := range blocks {
+		offset := int(footer.u64(blocksAt + 24*b))
+		metaLength := int(int32(footer.u32(blocksAt + 24*b + 8)))
+		bodyLength := int(footer.u64(blocksAt + 24*b + 16))
+		if footer.err != nil {
+			return nil, footer.err
+		}
+		if offset < 0 || metaLength < 8 || bodyLength < 0 || offset+metaLength+bodyLength > len(data) {
+			return nil, fmt.Errorf("arrow record batch %d is past the end of the file", b)
+		}
+
+		message := &fbReader{buf: data[offset+8 : offset+metaLength]}
+		msg := message.root()
+		if message.u8(message.field(msg, 1)) != ARROW_RECORD_BATCH {
+			return nil, fmt.Errorf("arrow block %d isn't a record batch", b)
+		}
+		batch := message.deref(message.field(msg, 2))
+		if message.field(batch, 3) != 0 {
+			return nil, errors.New("arrow record batch is compressed")
+		}
+
+		rows := int(message.u64(message.field(batch, 0)))
+		buffersAt, _ := message.vector(message.field(batch, 2))
+		body := &fbReader{buf: data[offset+metaLength : offset+metaLength+bodyLength]}
+
+		//Buffer n of the batch as its body offset and length
+		buf := func(n int) (int, int) {
+			return int(message.u64(buffersAt + 16*n)), int(message.u64(buffersAt + 16*n + 8))
+		}
+		double := func(column string, row int) float64 {
+			at, _ := buf(firstBuffer[columns[column]] + 1)
+			return math.Float64frombits(body.u64(at + 8*row))
+		}
+
+		offsetsAt, _ := buf(firstBuffer[columns["station"]] + 1)
+		namesAt, _ := buf(firstBuffer[columns["station"]] + 2)
+		for row := range rows {
+			start, end := int(int32(body.u32(offsetsAt+4*row))), int(int32(body.u32(offsetsAt+4*row+4)))
+			name := string(body.bytes(namesAt+start, end-start))
+			results[name] = result{double("min", row), double("mean", row), double("max", row)}
+
+			if err := errors.Join(message.err, body.err); err != nil {
+				return nil, fmt.Errorf("arrow record batch %d: %w", b, err)
+			}
+		}
+	}
+
+	return results, footer.err
+}
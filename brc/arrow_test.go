@@ -0,0 +1,61 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestArrow reads -output-format=arrow's file back through its footer, and makes sure a damaged one can't
+// crash diff, only fail it.
+func TestArrow(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 1, rng.Intn(50_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tally, err := Process(files, WithStrategy(StreamingStrategy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, arrow := &bytes.Buffer{}, &bytes.Buffer{}
+	tally.Print(text)
+	if err := writeArrowReport(arrow, Report{Tally: tally}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := readResults(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readResults(bytes.NewReader(arrow.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := diffResults(got, want, 0); len(diffs) > 0 || len(got) != len(want) {
+		t.Fatalf("arrow file read back differs from the text output: %v", diffs)
+	}
+
+	//Every buffer starts 8 aligned, as zero-copy readers need, so the file is a multiple of 8 up to its footer
+	data := arrow.Bytes()
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	if (len(data)-10-footerLength)%8 != 0 {
+		t.Fatalf("arrow footer starts at %d, not 8 aligned", len(data)-10-footerLength)
+	}
+
+	for range 200 {
+		damaged := slices.Clone(data)
+		if rng.Intn(2) == 0 {
+			damaged = damaged[:rng.Intn(len(damaged))]
+		} else {
+			damaged[rng.Intn(len(damaged))] ^= byte(1 + rng.Intn(255))
+		}
+		readResults(bytes.NewReader(damaged))
+	}
+}
@@ -0,0 +1,452 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROCESS_BENCH_LINES is the size of BenchmarkProcess1M's input, whatever -lines says.
+const PROCESS_BENCH_LINES = 1_000_000
+
+// BENCH_TIME is how long the bench subcommand runs each benchmark for, go test -bench's default.
+const BENCH_TIME = time.Second
+
+// runBench times the pipeline's stages on synthetic lines already in memory: parseLines on a line
+// (ParseLine), also under -key=lower and -fold-case (ParseLineKeyed), readChunks cutting the input into line
+// aligned chunks (ChunkSplit), chunks split on the scheduler and added to worker-local tallies, what parsers do, or to one
+// shared tally locked for every line, what they used to do (Aggregate), the whole streaming pipeline over a
+// million lines (Process1M), and each delimiter kernel the CPU has finding the ';' of a line (DelimiterScan).
+// With -input the whole pipeline is also timed over that file (ProcessFile).
+// They are the benchmarks of bench_test.go, timed as go test -bench would and printed as its lines with MB/s
+// and allocations, so two modes or two builds compare with benchstat, without a test binary to build.
+// -history keeps every result for -compare to hold later builds to.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	lines := fs.Int("lines", 10_000_000, "synthetic measurement lines ChunkSplit and Aggregate go over, `n`")
+	stations := fs.Int("stations", 413, "distinct stations among them, `n`")
+	benchWorkers := fs.Int("workers", *workers, "number of parser `goroutines`")
+	count := fs.Int("count", 5, "runs of each benchmark, `n`")
+	seed := fs.Int64("seed", 1, "random `seed` for the lines")
+	run := fs.String("bench", ".", "only run benchmarks matching `regexp`")
+	input := fs.String("input", "", "measurements `file` to also time the whole pipeline over with -strategy (ProcessFile)")
+	fs.StringVar(strategyName, "strategy", *strategyName, "`strategy` ProcessFile reads -input with")
+	history := fs.String("history", "", "append every result to the JSON lines `file`, keyed by input hash, strategy and git revision")
+	compare := fs.Bool("compare", false, "compare each benchmark with the latest other revision in -history, exiting 1 if one is slower by more than -threshold")
+	threshold := fs.Float64("threshold", 0.05, "`fraction` slower than its baseline's median a benchmark may run before -compare flags it")
+	fs.Parse(args)
+
+	pattern, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bad -bench pattern:", err)
+		os.Exit(2)
+	}
+	if _, ok := strategies[*strategyName]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown -strategy %q, want one of %s\n", *strategyName, strings.Join(sortedKeys(strategies), ", "))
+		os.Exit(2)
+	}
+	if *compare && *history == "" {
+		fmt.Fprintln(os.Stderr, "-compare needs a -history to compare with")
+		os.Exit(2)
+	}
+	if *threshold < 0 {
+		fmt.Fprintln(os.Stderr, "-threshold must not be negative, got", *threshold)
+		os.Exit(2)
+	}
+
+	//Synthetic lines are named by what generates them, a file by its contents
+	synthetic := fmt.Sprintf("synthetic:seed=%d,lines=%d,stations=%d", *seed, *lines, *stations)
+	inputKey := func(name string) string {
+		if name != "ProcessFile" {
+			return synthetic
+		}
+		hash, err := hashFile(*input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not hash -input:", err)
+			os.Exit(1)
+		}
+		return hash
+	}
+
+	//Lines are only generated for the benchmarks picked, then shared by every run of them
+	chunks := sync.OnceValue(func() [][]byte {
+		return benchChunks(rand.New(rand.NewSource(*seed)), *lines, *stations)
+	})
+	million := sync.OnceValue(func() []byte {
+		return bytes.Join(benchChunks(rand.New(rand.NewSource(*seed)), PROCESS_BENCH_LINES, *stations), nil)
+	})
+
+	benchmarks := []struct {
+		name string
+		op   func() (benchOp, error)
+	}{
+		{"ParseLine", func() (benchOp, error) { return benchParseLine(chunks()[0], nil) }},
+		{"ParseLineKeyed/key=lower", func() (benchOp, error) { return benchParseLine(chunks()[0], LowerASCIIKey) }},
+		{"ParseLineKeyed/fold-case", func() (benchOp, error) { return benchParseLine(chunks()[0], FoldCaseKey(nil)) }},
+		{"ChunkSplit", func() (benchOp, error) { return benchChunkSplit(chunks()) }},
+		{"Aggregate/mode=locked", func() (benchOp, error) { return benchAggregate(chunks(), *benchWorkers, benchLocked) }},
+		{"Aggregate/mode=local", func() (benchOp, error) { return benchAggregate(chunks(), *benchWorkers, benchLocal) }},
+		{"Process1M", func() (benchOp, error) { return benchProcess(million(), *benchWorkers) }},
+	}
+	for _, name := range slices.Sorted(maps.Keys(delimiterKernels)) {
+		kernel := delimiterKernels[name]
+		benchmarks = append(benchmarks, struct {
+			name string
+			op   func() (benchOp, error)
+		}{"DelimiterScan/kernel=" + name, func() (benchOp, error) { return benchDelimiterScan(chunks()[0], kernel) }})
+	}
+	if *input != "" {
+		benchmarks = append(benchmarks, struct {
+			name string
+			op   func() (benchOp, error)
+		}{"ProcessFile", func() (benchOp, error) { return benchProcessFile(*input, *benchWorkers) }})
+	}
+
+	revision := buildRevision()
+	keys := map[string]string{}
+	var records []BenchRecord
+	for i := 0; i < *count; i++ {
+		for _, bm := range benchmarks {
+			if !pattern.MatchString(bm.name) {
+				continue
+			}
+
+			r, err := timeBench(bm.op)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", bm.name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Benchmark%s-%d\t%s\n", bm.name, *benchWorkers, r)
+
+			if _, ok := keys[bm.name]; !ok {
+				keys[bm.name] = inputKey(bm.name)
+			}
+			records = append(records, BenchRecord{
+				bm.name, keys[bm.name], *strategyName, *benchWorkers, revision,
+				r.NsPerOp(), time.Now().UTC(),
+			})
+		}
+	}
+
+	if *history == "" {
+		return
+	}
+
+	previous, err := readBenchHistory(*history)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not read -history:", err)
+		os.Exit(1)
+	}
+	if err := appendBenchHistory(*history, records); err != nil {
+		fmt.Fprintln(os.Stderr, "could not write -history:", err)
+		os.Exit(1)
+	}
+
+	if !*compare {
+		return
+	}
+	regressed := false
+	for _, c := range compareBench(previous, records, *threshold) {
+		fmt.Fprintln(os.Stderr, c)
+		regressed = regressed || c.Regressed
+	}
+	if regressed {
+		os.Exit(1)
+	}
+}
+
+// benchOp is a benchmark set up and ready to time: loop does n ops, each going over size bytes. It's how
+// the bench subcommand and bench_test.go share benchmarks, the binary doesn't link testing.
+type benchOp struct {
+	size int64
+	loop func(n int) error
+}
+
+// benchResult is a benchOp timed over n ops, with what they allocated.
+type benchResult struct {
+	n             int
+	elapsed       time.Duration
+	size          int64
+	bytes, allocs uint64
+}
+
+func (r benchResult) NsPerOp() float64 {
+	return float64(r.elapsed.Nanoseconds()) / float64(r.n)
+}
+
+// String is r as the fields of a go test -bench line after the name.
+func (r benchResult) String() string {
+	//As many decimals as go test -bench gives an op this fast
+	ns := r.NsPerOp()
+	decimals := 0
+	for limit := 999.95; decimals < 4 && ns < limit; limit /= 10 {
+		decimals++
+	}
+	s := fmt.Sprintf("%8d\t%*.*f ns/op", r.n, 10+decimals, decimals, ns)
+	if r.size > 0 && r.elapsed > 0 {
+		s += fmt.Sprintf("\t%7.2f MB/s", float64(r.size)*float64(r.n)/1e6/r.elapsed.Seconds())
+	}
+	return s + fmt.Sprintf("\t%8d B/op\t%8d allocs/op", r.bytes/uint64(r.n), r.allocs/uint64(r.n))
+}
+
+// timeBench sets up a benchOp and runs it for ever more ops until a run takes BENCH_TIME, growing n the way
+// go test -bench does: by the rate of the last run, at most a hundredfold.
+func timeBench(setup func() (benchOp, error)) (benchResult, error) {
+	op, err := setup()
+	if err != nil {
+		return benchResult{}, err
+	}
+
+	var r benchResult
+	for n := 1; ; {
+		if r, err = timeBenchOps(op, n); err != nil {
+			return r, err
+		}
+		if r.elapsed >= BENCH_TIME || n >= 1e9 {
+			return r, nil
+		}
+
+		next := int64(BENCH_TIME) * int64(n) / max(int64(r.elapsed), 1)
+		n = int(min(max(next+next/5, int64(n)+1), 100*int64(n), 1e9))
+	}
+}
+
+// timeBenchOps times op doing n ops, after a collection so garbage of earlier runs isn't theirs to pay for.
+func timeBenchOps(op benchOp, n int) (benchResult, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	err := op.loop(n)
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	return benchResult{n, elapsed, op.size, after.TotalAlloc - before.TotalAlloc, after.Mallocs - before.Mallocs}, err
+}
+
+// benchParseLine times parseLines per line, with every station mapped by key unless it's nil, an op being
+// one line of chunk, which is parsed from the top again as often as n needs.
+func benchParseLine(chunk []byte, key KeyFunc) (benchOp, error) {
+	var ends []int
+	for i, c := range chunk {
+		if c == '\n' {
+			ends = append(ends, i+1)
+		}
+	}
+
+	o, err := newOptions(WithKey(key))
+	if err != nil {
+		return benchOp{}, err
+	}
+
+	tally := NewTally()
+	return benchOp{int64(len(chunk) / len(ends)), func(n int) error {
+		for done := 0; done < n; {
+			batch := min(n-done, len(ends))
+			if err := parseLines(chunk[:ends[batch-1]], -1, tally, o); err != nil {
+				return err
+			}
+			done += batch
+		}
+		return nil
+	}}, nil
+}
+
+// benchDelimiterScan times one of delimiterKernels finding the delimiter of each line of chunk, an op being a line.
+func benchDelimiterScan(chunk []byte, kernel func(b []byte, sep byte) int) (benchOp, error) {
+	lines := bytes.Split(bytes.TrimSuffix(chunk, []byte("\n")), []byte("\n"))
+	size := 0
+	for _, line := range lines {
+		size += len(line)
+	}
+
+	return benchOp{int64(size / len(lines)), func(n int) error {
+		for i := range n {
+			if kernel(lines[i%len(lines)], ';') == -1 {
+				return errors.New("a line has no delimiter")
+			}
+		}
+		return nil
+	}}, nil
+}
+
+// benchChunkSplit times readChunks over the lines, each chunk going straight back to the pool.
+func benchChunkSplit(chunks [][]byte) (benchOp, error) {
+	data := bytes.Join(chunks, nil)
+	o, err := newOptions()
+	if err != nil {
+		return benchOp{}, err
+	}
+
+	return benchOp{int64(len(data)), func(n int) error {
+		for range n {
+			readChunks(bytes.NewReader(data), o, func(chunk Chunk) {
+				o.pool.Put(chunk.data[:0])
+			})
+		}
+		return nil
+	}}, nil
+}
+
+func benchAggregate(chunks [][]byte, workers int, mode func(chunks [][]byte, workers int)) (benchOp, error) {
+	size := 0
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+
+	return benchOp{int64(size), func(n int) error {
+		for range n {
+			mode(chunks, workers)
+		}
+		return nil
+	}}, nil
+}
+
+// benchProcess times the streaming pipeline end to end, as the demo runs it, on data held in memory.
+func benchProcess(data []byte, workers int) (benchOp, error) {
+	o, err := newOptions(WithWorkers(workers))
+	if err != nil {
+		return benchOp{}, err
+	}
+
+	return benchOp{int64(len(data)), func(n int) error {
+		for range n {
+			if err := streamInto(bytes.NewReader(data), "bench", NewTally(), o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}, nil
+}
+
+// benchProcessFile times the whole pipeline over the file name with -strategy, as a plain run would.
+func benchProcessFile(name string, workers int) (benchOp, error) {
+	o, err := newOptions(WithStrategy(strategies[*strategyName]), WithWorkers(workers))
+	if err != nil {
+		return benchOp{}, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return benchOp{}, err
+	}
+
+	return benchOp{info.Size(), func(n int) error {
+		for range n {
+			if _, err := processFiles(o, []string{name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}, nil
+}
+
+var nameRunes = []rune("abcxyzABCXYZ0123456789 .'-_äöüßéøåñçÜÖ日本語中文ąę😀")
+
+// randomName builds a station name from ASCII, digits and multibyte runes, sometimes far longer than the 100 byte limit.
+func randomName(rng *rand.Rand) string {
+	n := 1 + rng.Intn(20)
+	if rng.Intn(50) == 0 {
+		n = 100 + rng.Intn(400)
+	}
+
+	name := make([]rune, n)
+	for i := range name {
+		name[i] = nameRunes[rng.Intn(len(nameRunes))]
+	}
+	return string(name)
+}
+
+// benchChunks renders lines readings over stations as chunks of at most BUFFER_SIZE whole lines.
+func benchChunks(rng *rand.Rand, lines, stations int) [][]byte {
+	names := make([]string, stations)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", randomName(rng), i)
+	}
+
+	var chunks [][]byte
+	chunk := make([]byte, 0, BUFFER_SIZE)
+
+	for i := 0; i < lines; i++ {
+		line := fmt.Sprintf("%s;%.1f\n", names[rng.Intn(stations)], float64(rng.Intn(1999)-999)/10)
+		if len(chunk)+len(line) > BUFFER_SIZE {
+			chunks = append(chunks, chunk)
+			chunk = make([]byte, 0, BUFFER_SIZE)
+		}
+		chunk = append(chunk, line...)
+	}
+
+	return append(chunks, chunk)
+}
+
+// benchRun hands chunks to a scheduler of workers, for every line calling add with the worker's index.
+func benchRun(chunks [][]byte, workers int, add func(worker int, station []byte, temp int)) {
+	in := make(chan Chunk)
+	go func() {
+		for _, chunk := range chunks {
+			in <- Chunk{chunk, -1}
+		}
+		close(in)
+	}()
+
+	NewScheduler(workers, 0).Run(in, func(worker int, chunk Chunk) {
+		for rest := chunk.data; len(rest) > 0; {
+			line, next, _ := bytes.Cut(rest, []byte{'\n'})
+			rest = next
+
+			if i := bytes.LastIndexByte(line, ';'); i != -1 {
+				add(worker, line[:i], parseTemp(line[i+1:]))
+			}
+		}
+	})
+}
+
+func benchLocal(chunks [][]byte, workers int) {
+	tally := NewTally()
+	locals := workerTallies(workers, tally)
+
+	benchRun(chunks, workers, func(worker int, station []byte, temp int) {
+		locals[worker].Station(station, -1).AddAt(temp, -1)
+	})
+	mergeTallies(tally, locals)
+}
+
+// lockedTally is the shared tally parsers used to fill, a map behind a lock and a mutex per station.
+type lockedTally struct {
+	results map[string]*lockedResult
+	m       sync.Mutex
+}
+
+type lockedResult struct {
+	StationResult
+	m sync.Mutex
+}
+
+func benchLocked(chunks [][]byte, workers int) {
+	tally := &lockedTally{results: map[string]*lockedResult{}}
+
+	benchRun(chunks, workers, func(worker int, station []byte, temp int) {
+		tally.m.Lock()
+		r, ok := tally.results[string(station)]
+		if !ok {
+			r = &lockedResult{StationResult: StationResult{min: math.MaxInt, max: math.MinInt, offset: -1}}
+			tally.results[strings.Clone(string(station))] = r
+		}
+		tally.m.Unlock()
+
+		r.m.Lock()
+		r.AddAt(temp, -1)
+		r.m.Unlock()
+	})
+}
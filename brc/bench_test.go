@@ -0,0 +1,77 @@
+package brc
+
+import (
+	"bytes"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// benchLines are the synthetic lines the benchmarks go over, the bench subcommand's by default, only
+// generated once a benchmark asks for them.
+var benchLines = sync.OnceValue(func() [][]byte {
+	return benchChunks(rand.New(rand.NewSource(1)), 10_000_000, 413)
+})
+
+// runBenchOp times op as b's benchmark, failing it if setting the op up or any op fails.
+func runBenchOp(b *testing.B, op benchOp, err error) {
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(op.size)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	if err := op.loop(b.N); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkParseLine(b *testing.B) {
+	op, err := benchParseLine(benchLines()[0], nil)
+	runBenchOp(b, op, err)
+}
+
+func BenchmarkParseLineKeyed(b *testing.B) {
+	b.Run("key=lower", func(b *testing.B) {
+		op, err := benchParseLine(benchLines()[0], LowerASCIIKey)
+		runBenchOp(b, op, err)
+	})
+	b.Run("fold-case", func(b *testing.B) {
+		op, err := benchParseLine(benchLines()[0], FoldCaseKey(nil))
+		runBenchOp(b, op, err)
+	})
+}
+
+func BenchmarkChunkSplit(b *testing.B) {
+	op, err := benchChunkSplit(benchLines())
+	runBenchOp(b, op, err)
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	modes := map[string]func(chunks [][]byte, workers int){"locked": benchLocked, "local": benchLocal}
+	for _, name := range []string{"locked", "local"} {
+		b.Run("mode="+name, func(b *testing.B) {
+			op, err := benchAggregate(benchLines(), *workers, modes[name])
+			runBenchOp(b, op, err)
+		})
+	}
+}
+
+func BenchmarkProcess1M(b *testing.B) {
+	data := bytes.Join(benchChunks(rand.New(rand.NewSource(1)), PROCESS_BENCH_LINES, 413), nil)
+	op, err := benchProcess(data, *workers)
+	runBenchOp(b, op, err)
+}
+
+func BenchmarkDelimiterScan(b *testing.B) {
+	for _, name := range slices.Sorted(maps.Keys(delimiterKernels)) {
+		b.Run("kernel="+name, func(b *testing.B) {
+			op, err := benchDelimiterScan(benchLines()[0], delimiterKernels[name])
+			runBenchOp(b, op, err)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package brc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// BenchRecord is one run of one benchmark as bench -history keeps it, a JSON object a line. Runs are only
+// compared with runs of the same key: benchmark, input, strategy and workers.
+type BenchRecord struct {
+	Benchmark string `json:"benchmark"`
+
+	//sha256 of the -input file for ProcessFile, the generator's parameters for the synthetic benchmarks
+	Input    string `json:"input"`
+	Strategy string `json:"strategy"`
+	Workers  int    `json:"workers"`
+
+	//git commit of the build, with -dirty if it had uncommitted changes
+	Revision string    `json:"revision"`
+	NsPerOp  float64   `json:"ns_per_op"`
+	Time     time.Time `json:"time"`
+}
+
+func (r BenchRecord) sameKey(other BenchRecord) bool {
+	return r.Benchmark == other.Benchmark && r.Input == other.Input && r.Strategy == other.Strategy && r.Workers == other.Workers
+}
+
+// buildRevision is the commit the binary was built from, as go build stamps it inside a git checkout.
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	revision, dirty := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+
+	if revision == "" {
+		return "unknown"
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// hashFile is the sha256 of the file name in hex, prefixed sha256:.
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readBenchHistory reads every record of the history file name, none if it doesn't exist yet.
+func readBenchHistory(name string) ([]BenchRecord, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []BenchRecord
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var r BenchRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// appendBenchHistory appends records to the history file name, creating it if need be.
+func appendBenchHistory(name string, records []BenchRecord) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return errors.Join(bw.Flush(), f.Close())
+}
+
+// BenchComparison is a benchmark's median this run against the median of its baseline, the runs of the
+// latest other revision the history holds for the same key.
+type BenchComparison struct {
+	Benchmark         string
+	Baseline          string
+	Now, Then         float64
+	Slower, Regressed bool
+}
+
+// compareBench compares every benchmark of current with history, flagging those more than threshold, a
+// fraction, slower than their baseline. Benchmarks with no baseline yet are left out.
+func compareBench(history, current []BenchRecord, threshold float64) []BenchComparison {
+	var comparisons []BenchComparison
+	seen := map[string]bool{}
+
+	for _, r := range current {
+		if seen[r.Benchmark] {
+			continue
+		}
+		seen[r.Benchmark] = true
+
+		//The latest run of another revision is the baseline, history is in the order it was written
+		baseline := ""
+		for _, old := range slices.Backward(history) {
+			if old.sameKey(r) && old.Revision != r.Revision {
+				baseline = old.Revision
+				break
+			}
+		}
+		if baseline == "" {
+			continue
+		}
+
+		now := medianNsPerOp(current, func(c BenchRecord) bool { return c.sameKey(r) })
+		then := medianNsPerOp(history, func(old BenchRecord) bool { return old.sameKey(r) && old.Revision == baseline })
+		comparisons = append(comparisons, BenchComparison{
+			Benchmark: r.Benchmark,
+			Baseline:  baseline,
+			Now:       now,
+			Then:      then,
+			Slower:    now > then,
+			Regressed: now > then*(1+threshold),
+		})
+	}
+
+	return comparisons
+}
+
+func medianNsPerOp(records []BenchRecord, keep func(r BenchRecord) bool) float64 {
+	var times []float64
+	for _, r := range records {
+		if keep(r) {
+			times = append(times, r.NsPerOp)
+		}
+	}
+	slices.Sort(times)
+
+	n := len(times)
+	if n%2 == 0 {
+		return (times[n/2-1] + times[n/2]) / 2
+	}
+	return times[n/2]
+}
+
+// String renders c as e.g.
+// ProcessFile: 1523041012.0 ns/op against 1312773405.5 ns/op at 3f2a9c1d, 16.0% slower, REGRESSION
+func (c BenchComparison) String() string {
+	change := "faster"
+	if c.Slower {
+		change = "slower"
+	}
+
+	s := fmt.Sprintf("%s: %.1f ns/op against %.1f ns/op at %.8s, %.1f%% %s", c.Benchmark, c.Now, c.Then, c.Baseline,
+		100*math.Abs(c.Now-c.Then)/c.Then, change)
+	if c.Regressed {
+		s += ", REGRESSION"
+	}
+	return s
+}
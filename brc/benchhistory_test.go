@@ -0,0 +1,54 @@
+package brc
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestBenchHistory writes a history of two revisions and reads it back, then compares runs of a third
+// with it: each benchmark against the latest other revision of the same key, and only when there is one.
+func TestBenchHistory(t *testing.T) {
+	record := func(benchmark, revision string, ns float64) BenchRecord {
+		return BenchRecord{benchmark, "synthetic:seed=1", "streaming", 4, revision, ns, time.Unix(0, 0).UTC()}
+	}
+	history := []BenchRecord{
+		record("ParseLine", "a", 50), record("ParseLine", "a", 70),
+		record("Process1M", "a", 1000),
+		record("ParseLine", "b", 100), record("ParseLine", "b", 110), record("ParseLine", "b", 90),
+	}
+	//Same benchmark, another input, so no baseline
+	other := record("ChunkSplit", "a", 10)
+	other.Input = "sha256:00"
+	history = append(history, other)
+
+	file := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := appendBenchHistory(file, history[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendBenchHistory(file, history[3:]); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readBenchHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(read, history) {
+		t.Fatalf("history read back as %+v, want %+v", read, history)
+	}
+
+	current := []BenchRecord{
+		record("ParseLine", "c", 104), record("ParseLine", "c", 106),
+		record("Process1M", "c", 1200),
+		record("ChunkSplit", "c", 10),
+	}
+	got := compareBench(read, current, 0.1)
+	want := []BenchComparison{
+		{"ParseLine", "b", 105, 100, true, false},
+		{"Process1M", "a", 1200, 1000, true, true},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("compare at 10%%: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,292 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+)
+
+// The binary format convert writes, for aggregating the same dataset over and over without parsing text:
+//
+//	"BRCB" version            magic and a version byte
+//	length records...         blocks of whole records, length a uint32 LE, a 0 length ends them
+//	count (length name)...    the station dictionary, uvarints and names in order of ID
+//
+// A record is the station's ID as a uvarint and the temperature in tenths as an int16 LE. The dictionary
+// comes last so convert can write in one pass, parsers count by ID and only need the names to merge.
+const BINARY_MAGIC = "BRCB"
+
+const BINARY_VERSION = 1
+
+// BINARY_MAX_ID bounds the station IDs a parser allocates for, well past the 10,000 the challenge allows.
+const BINARY_MAX_ID = 1 << 24
+
+// BINARY_BLOCK is the most record bytes convert puts in a block, each block is one chunk for the parsers.
+const BINARY_BLOCK = BUFFER_SIZE
+
+// isBinary reports whether filePtr starts with the binary format's magic.
+func isBinary(filePtr io.ReaderAt) bool {
+	magic := make([]byte, len(BINARY_MAGIC))
+	n, _ := filePtr.ReadAt(magic, 0)
+	return n == len(magic) && string(magic) == BINARY_MAGIC
+}
+
+// convertText transcodes station;temp lines from r into the binary format on w.
+func convertText(r io.Reader, w io.Writer) (rows, stations int, err error) {
+	out := bufio.NewWriter(w)
+	out.WriteString(BINARY_MAGIC)
+	out.WriteByte(BINARY_VERSION)
+
+	ids := map[string]int{}
+	var names []string
+	block := make([]byte, 0, BINARY_BLOCK)
+
+	flush := func() {
+		binary.Write(out, binary.LittleEndian, uint32(len(block)))
+		out.Write(block)
+		block = block[:0]
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+
+		i := bytes.LastIndexByte(line, ';')
+		if i == -1 {
+			continue
+		}
+
+		temp, ok := parseTempAny(line[i+1:])
+		if !ok {
+			continue
+		}
+		if temp < math.MinInt16 || temp > math.MaxInt16 {
+			return rows, len(names), fmt.Errorf("%q is outside the int16 tenths the binary format holds", line)
+		}
+
+		id, ok := ids[string(line[:i])]
+		if !ok {
+			id = len(names)
+			ids[string(line[:i])] = id
+			names = append(names, string(line[:i]))
+		}
+
+		if len(block)+binary.MaxVarintLen64+2 > BINARY_BLOCK {
+			flush()
+		}
+		block = binary.AppendUvarint(block, uint64(id))
+		block = binary.LittleEndian.AppendUint16(block, uint16(int16(temp)))
+		rows++
+	}
+	if err := scanner.Err(); err != nil {
+		return rows, len(names), err
+	}
+
+	if len(block) > 0 {
+		flush()
+	}
+	flush()
+
+	dict := binary.AppendUvarint(nil, uint64(len(names)))
+	for _, name := range names {
+		dict = binary.AppendUvarint(dict, uint64(len(name)))
+		dict = append(dict, name...)
+	}
+	out.Write(dict)
+
+	return rows, len(names), out.Flush()
+}
+
+// binaryCounts is one parser's results by station ID, grown as higher IDs turn up.
+type binaryCounts []StationResult
+
+func (c *binaryCounts) add(block []byte) error {
+	for len(block) > 0 {
+		id, n := binary.Uvarint(block)
+		if n <= 0 || len(block) < n+2 {
+			return errors.New("truncated record")
+		}
+		if id >= BINARY_MAX_ID {
+			return fmt.Errorf("station ID %d is past %d", id, BINARY_MAX_ID)
+		}
+
+		for uint64(len(*c)) <= id {
+			*c = append(*c, StationResult{min: math.MaxInt, max: math.MinInt, sample: newReservoir(), hist: newHistogram(), offset: -1})
+		}
+
+		//Records hold tenths whatever -precision keeps readings to
+		(*c)[id].AddAt(int(int16(binary.LittleEndian.Uint16(block[n:])))*(readingScale/10), -1)
+		block = block[n+2:]
+	}
+	return nil
+}
+
+// processBinary aggregates a file written by convert, whatever -strategy says: blocks are read in turn and
+// counted by station ID on the parser pool, the dictionary at the end then names them. -key and -filter
+// apply to the names, so several IDs may fold into one station or none.
+func processBinary(r io.Reader, tally *Tally, o *Options) error {
+	in := bufio.NewReaderSize(r, o.chunkSize)
+
+	header := make([]byte, len(BINARY_MAGIC)+1)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return err
+	}
+	if header[len(BINARY_MAGIC)] != BINARY_VERSION {
+		return fmt.Errorf("binary format version %d, want %d", header[len(BINARY_MAGIC)], BINARY_VERSION)
+	}
+
+	counts := make([]binaryCounts, o.workers)
+	errs := make([]error, o.workers)
+
+	chunks := make(chan Chunk)
+	var readErr error
+	go func() {
+		defer close(chunks)
+		offset := int64(len(header))
+
+		for {
+			var n uint32
+			if readErr = binary.Read(in, binary.LittleEndian, &n); readErr != nil || n == 0 {
+				return
+			}
+
+			buf := o.pool.Get().([]byte)
+			if cap(buf) < int(n) {
+				buf = make([]byte, n)
+			}
+			if _, readErr = io.ReadFull(in, buf[:n]); readErr != nil {
+				return
+			}
+
+			chunks <- Chunk{buf[:n], offset}
+			offset += 4 + int64(n)
+		}
+	}()
+
+	parse := func(worker int, chunk Chunk) {
+		o.counted(chunk)
+		if err := counts[worker].add(chunk.data); err != nil && errs[worker] == nil {
+			errs[worker] = fmt.Errorf("block at %d: %w", chunk.offset, err)
+		}
+		o.pool.Put(chunk.data[:0])
+	}
+
+	if o.serial {
+		for chunk := range chunks {
+			parse(0, chunk)
+		}
+	} else {
+		NewScheduler(o.workers, o.depth).Run(chunks, parse)
+	}
+
+	if err := errors.Join(append(errs, readErr)...); err != nil {
+		return err
+	}
+
+	names, err := readDictionary(in)
+	if err != nil {
+		return fmt.Errorf("station dictionary: %w", err)
+	}
+
+	key, keep := o.key, cachedFilter()
+	local := tally.Local()
+	for _, c := range counts {
+		for id := range c {
+			if c[id].count == 0 {
+				continue
+			}
+			if id >= len(names) {
+				return fmt.Errorf("station ID %d is past the dictionary's %d", id, len(names))
+			}
+
+			station := []byte(names[id])
+			if key != nil {
+				station = key(nil, station)
+			}
+			if keep == nil || keep(station) {
+				local.Get(station).Merge(&c[id])
+			}
+		}
+	}
+	tally.Merge(local)
+
+	return nil
+}
+
+func readDictionary(in *bufio.Reader) ([]string, error) {
+	count, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, min(count, BINARY_MAX_ID))
+	for i := uint64(0); i < count; i++ {
+		length, err := binary.ReadUvarint(in)
+		if err != nil {
+			return nil, err
+		}
+
+		if length > 1<<16 {
+			return nil, fmt.Errorf("station name of %d bytes", length)
+		}
+
+		name := make([]byte, length)
+		if _, err := io.ReadFull(in, name); err != nil {
+			return nil, err
+		}
+		names = append(names, string(name))
+	}
+
+	return names, nil
+}
+
+// runConvert transcodes a measurements file into the binary format, which every strategy then reads far
+// faster than the text on repeated experiments.
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	out := fs.String("o", "", "`file` to write, the input with a .brcb extension by default")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: convert [-o out.brcb] measurements.txt")
+		fs.PrintDefaults()
+	}
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	name := fs.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(name, ".txt") + ".brcb"
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("could not create binary file: ", err)
+	}
+
+	rows, stations, err := convertText(in, f)
+	if err = errors.Join(err, f.Close()); err != nil {
+		log.Fatal("could not convert: ", err)
+	}
+
+	slog.Info("converted", "file", *out, "rows", rows, "stations", stations)
+}
@@ -0,0 +1,77 @@
+package brc
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestBinary converts random files, past a block's worth, to the binary format and aggregates them with every
+// strategy, which must give what the text gives. A file cut short must fail rather than come up short.
+func TestBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 2, BINARY_BLOCK+rng.Intn(3*BINARY_BLOCK))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted := make([]string, len(files))
+	for i, file := range files {
+		converted[i] = file + ".brcb"
+		if err := convertFile(file, converted[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	text, err := runPipeline(StreamingStrategy{}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		got, err := runPipeline(strategies[strategyName], converted)
+		if err != nil {
+			t.Fatalf("-strategy=%s: %v", strategyName, err)
+		}
+		if got != text {
+			t.Fatalf("-strategy=%s: binary gave %q, text %q", strategyName, got, text)
+		}
+	}
+
+	data, err := os.ReadFile(converted[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := converted[0] + ".cut"
+	if err := os.WriteFile(cut, data[:len(BINARY_MAGIC)+1+rng.Intn(len(data)-len(BINARY_MAGIC)-2)], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process([]string{cut}); err == nil {
+		t.Fatalf("a binary file cut to %s was aggregated without an error", cut)
+	}
+}
+
+// convertFile converts the text file from to the binary file to.
+func convertFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = convertText(in, out)
+	return errors.Join(err, out.Close())
+}
@@ -0,0 +1,175 @@
+package brc
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+var timingBreakdown = commandLine.Bool("timing-breakdown", false, "report time blocked in reads vs parsing for every worker on stderr")
+
+// READER_LANE is the lane of a goroutine that only reads, like the streaming reader, rather than a parser worker.
+const READER_LANE = -1
+
+// laneTiming is where one goroutine spent its time. Reads are timed with monotonic clock readings around
+// each read syscall, parse is the time spent in parseLines, which for mmap includes faulting pages in.
+type laneTiming struct {
+	read   time.Duration
+	reads  int
+	bytes  int64
+	parse  time.Duration
+	chunks int
+	parsed int64
+}
+
+var breakdown = struct {
+	lanes map[int]*laneTiming
+	m     sync.Mutex
+}{lanes: map[int]*laneTiming{}}
+
+// timingStart returns the zero time when timing is off so the hot path never reads the clock.
+func timingStart() time.Time {
+	if !collectTiming {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func lane(id int) *laneTiming {
+	l, ok := breakdown.lanes[id]
+	if !ok {
+		l = &laneTiming{}
+		breakdown.lanes[id] = l
+	}
+	return l
+}
+
+// recordRead counts a read of n bytes that started at start against lane id.
+func recordRead(id int, start time.Time, n int) {
+	if start.IsZero() {
+		return
+	}
+	d := time.Since(start)
+
+	breakdown.m.Lock()
+	l := lane(id)
+	l.read += d
+	l.reads++
+	l.bytes += int64(n)
+	breakdown.m.Unlock()
+}
+
+// recordParse counts d spent parsing chunks chunks of n bytes in all against lane id.
+func recordParse(id int, d time.Duration, chunks int, n int64) {
+	breakdown.m.Lock()
+	l := lane(id)
+	l.parse += d
+	l.chunks += chunks
+	l.parsed += n
+	breakdown.m.Unlock()
+}
+
+// timedReader times every Read of r, for strategies that read through a bufio.Scanner.
+type timedReader struct {
+	r       io.Reader
+	id      int
+	blocked time.Duration
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := timingStart()
+	n, err := t.r.Read(p)
+	progress.Add(1)
+	if !start.IsZero() {
+		t.blocked += time.Since(start)
+		recordRead(t.id, start, n)
+	}
+	return n, err
+}
+
+// cpuSeconds samples the runtime's own CPU accounting. These are estimates the runtime keeps per scheduler state,
+// only comparable to one another, but they need no platform specific rusage calls.
+// They are only brought up to date by a GC, so one is forced first.
+func cpuSeconds() map[string]float64 {
+	runtime.GC()
+
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/user:cpu-seconds"},
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+	}
+	metrics.Read(samples)
+
+	cpu := map[string]float64{}
+	for _, s := range samples {
+		if s.Value.Kind() == metrics.KindFloat64 {
+			cpu[s.Name] = s.Value.Float64()
+		}
+	}
+	return cpu
+}
+
+func pct(d, of time.Duration) float64 {
+	if of <= 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(of)
+}
+
+// reportBreakdown answers "is this machine I/O bound?": the busiest lane bounds the wall time,
+// so whether it spent longer blocked in reads or parsing is the verdict.
+func reportBreakdown(w io.Writer, elapsed time.Duration) {
+	breakdown.m.Lock()
+	defer breakdown.m.Unlock()
+
+	ids := make([]int, 0, len(breakdown.lanes))
+	for id := range breakdown.lanes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fmt.Fprintf(w, "timing breakdown over %v wall, strategy %s, %d workers\n", elapsed, *strategyName, *workers)
+
+	var busiest *laneTiming
+	var reads int
+	for _, id := range ids {
+		l := breakdown.lanes[id]
+		reads += l.reads
+
+		name := fmt.Sprintf("worker %d", id)
+		if id == READER_LANE {
+			name = "reader"
+		}
+
+		fmt.Fprintf(w, "  %-9s read %10v (%5.1f%%) in %d reads of %.1f MiB, parse %10v (%5.1f%%) over %d chunks\n",
+			name, l.read.Round(time.Microsecond), pct(l.read, elapsed), l.reads, float64(l.bytes)/(1<<20),
+			l.parse.Round(time.Microsecond), pct(l.parse, elapsed), l.chunks)
+
+		if busiest == nil || l.read+l.parse > busiest.read+busiest.parse {
+			busiest = l
+		}
+	}
+
+	if reads == 0 {
+		fmt.Fprintln(w, "  no read syscalls, pages were faulted in during parse")
+	}
+
+	cpu := cpuSeconds()
+	fmt.Fprintf(w, "  cpu       user %.3fs, gc %.3fs, idle %.3fs (runtime estimates)\n",
+		cpu["/cpu/classes/user:cpu-seconds"], cpu["/cpu/classes/gc/total:cpu-seconds"], cpu["/cpu/classes/idle:cpu-seconds"])
+
+	if busiest == nil {
+		return
+	}
+
+	verdict := "CPU bound"
+	if busiest.read > busiest.parse {
+		verdict = "I/O bound"
+	}
+	fmt.Fprintf(w, "  likely %s: the busiest lane spent %v blocked in reads and %v parsing\n",
+		verdict, busiest.read.Round(time.Microsecond), busiest.parse.Round(time.Microsecond))
+}
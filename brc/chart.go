@@ -0,0 +1,109 @@
+package brc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var chartRows = commandLine.Int("chart-rows", 40, "most stations -output-format=chart draws, in -sort or -top order, `n` (0 draws all)")
+
+// CHART_WIDTH is the width -output-format=chart draws to when $COLUMNS doesn't say.
+const CHART_WIDTH = 100
+
+// CHART_NAME_WIDTH caps the station name column, longer names are cut with a ~.
+const CHART_NAME_WIDTH = 24
+
+// writeChart draws a row per station, its min to max as a whisker on an axis shared by every row and its
+// mean as a *, then the values:
+//
+//	          -23                                 59.2
+//	Abha    |  |----*-------|                         | -23.0/18.0/59.2
+func writeChart(w io.Writer, r Report, header bool) error {
+	rows := timedRows(r)
+	defer timePhase(&phaseTimes.output)()
+
+	hidden := 0
+	if *chartRows > 0 && len(rows) > *chartRows {
+		rows, hidden = rows[:*chartRows], len(rows)-*chartRows
+	}
+
+	bw := bufio.NewWriter(w)
+	if len(rows) == 0 {
+		bw.WriteString("no stations\n")
+		return bw.Flush()
+	}
+
+	values := make([][3]float64, len(rows))
+	lo, hi := 0.0, 0.0
+	nameWidth, valueWidth := 0, 0
+	for i, row := range rows {
+		for j, v := range []string{row.Min.String(), row.Mean.String(), row.Max.String()} {
+			values[i][j], _ = strconv.ParseFloat(v, 64)
+		}
+		if i == 0 || values[i][0] < lo {
+			lo = values[i][0]
+		}
+		if i == 0 || values[i][2] > hi {
+			hi = values[i][2]
+		}
+		nameWidth = max(nameWidth, min(utf8.RuneCountInString(row.Station), CHART_NAME_WIDTH))
+		valueWidth = max(valueWidth, len(chartValues(row)))
+	}
+
+	width := CHART_WIDTH
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		width = columns
+	}
+	//Room for the name, the two borders, the spaces around them and the values
+	axis := max(width-nameWidth-valueWidth-5, 10)
+
+	column := func(v float64) int {
+		if hi == lo {
+			return axis / 2
+		}
+		return min(int((v-lo)/(hi-lo)*float64(axis-1)+0.5), axis-1)
+	}
+
+	left, right := formatAxis(lo), formatAxis(hi)
+	fmt.Fprintf(bw, "%*s  %s%*s\n", nameWidth, "", left, max(axis-len(left), len(right)+1), right)
+	line := make([]byte, axis)
+	for i, row := range rows {
+		for j := range line {
+			line[j] = ' '
+		}
+		from, mean, to := column(values[i][0]), column(values[i][1]), column(values[i][2])
+		for j := from; j <= to; j++ {
+			line[j] = '-'
+		}
+		line[from], line[to] = '|', '|'
+		line[mean] = '*'
+
+		fmt.Fprintf(bw, "%s |%s| %s\n", padName(row.Station, nameWidth), line, chartValues(row))
+	}
+	if hidden > 0 {
+		fmt.Fprintf(bw, "... %d more stations, raise -chart-rows to draw them\n", hidden)
+	}
+
+	return bw.Flush()
+}
+
+func chartValues(row StationRow) string {
+	return row.Min.String() + "/" + row.Mean.String() + "/" + row.Max.String()
+}
+
+func formatAxis(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// padName cuts name to width runes, marking the cut with a ~, and pads it to width.
+func padName(name string, width int) string {
+	if n := utf8.RuneCountInString(name); n <= width {
+		return name + strings.Repeat(" ", width-n)
+	}
+	return string([]rune(name)[:width-1]) + "~"
+}
@@ -0,0 +1,35 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var chartWant = `                          0        8
+A                        | |-*--|   | 1.0/3.0/5.0
+Llanfairpwllgwyngyllgog~ ||--*-----|| 0.0/3.0/8.0
+... 1 more stations, raise -chart-rows to draw them
+`
+
+// TestChart draws a tally at a fixed $COLUMNS, checking the shared axis, the whiskers, a cut name and the
+// row cap.
+func TestChart(t *testing.T) {
+	defer func(n int) { *chartRows = n }(*chartRows)
+	*chartRows = 2
+	t.Setenv("COLUMNS", "32")
+
+	tally := NewTally()
+	for _, line := range []string{"A;1.0", "A;5.0", "A;3.0", "Llanfairpwllgwyngyllgogerychwyrndrobwll;0.0", "Llanfairpwllgwyngyllgogerychwyrndrobwll;8.0", "Llanfairpwllgwyngyllgogerychwyrndrobwll;1.0", "Z;4.0"} {
+		station, temp, _ := strings.Cut(line, ";")
+		tally.Get([]byte(station)).Add(parseTemp([]byte(temp)))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeChart(buf, Report{Tally: tally}, true); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != chartWant {
+		t.Fatalf("got chart\n%s\nwant\n%s", buf, chartWant)
+	}
+}
@@ -0,0 +1,216 @@
+package brc
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CHECK_EXAMPLES is how many offending lines check quotes per problem, the first ones in the file.
+const CHECK_EXAMPLES = 5
+
+// checkExample is one offending line, or the first line of a station for the casing problem.
+type checkExample struct {
+	offset int64
+	line   string
+}
+
+type checkProblem struct {
+	count    int
+	examples []checkExample
+}
+
+// qualityReport is what check found in one file. Each worker fills its own, they are merged once the file is read.
+type qualityReport struct {
+	lines    int
+	problems map[string]*checkProblem
+
+	//Every spelling of a station and the offset of its first line
+	stations map[string]int64
+}
+
+func newQualityReport() *qualityReport {
+	return &qualityReport{problems: map[string]*checkProblem{}, stations: map[string]int64{}}
+}
+
+// add counts a problem, keeping the CHECK_EXAMPLES earliest examples whatever order the chunks came in.
+func (r *qualityReport) add(problem string, example checkExample) {
+	p := r.problems[problem]
+	if p == nil {
+		p = &checkProblem{}
+		r.problems[problem] = p
+	}
+
+	p.count++
+	if len(p.examples) == CHECK_EXAMPLES && example.offset > p.examples[CHECK_EXAMPLES-1].offset {
+		return
+	}
+	p.examples = append(p.examples, example)
+	sort.Slice(p.examples, func(i, j int) bool { return p.examples[i].offset < p.examples[j].offset })
+	p.examples = p.examples[:min(len(p.examples), CHECK_EXAMPLES)]
+}
+
+// inspect checks every line of chunk on top of what lineAnomaly looks for: temperatures outside the
+// challenge's -99.9..99.9 and bytes that aren't UTF-8.
+func (r *qualityReport) inspect(chunk Chunk, sep byte) {
+	offset := chunk.offset
+
+	for rest := chunk.data; len(rest) > 0; {
+		advance, line, _ := scanLines(rest, true)
+		rest = rest[advance:]
+		r.lines++
+
+		problem := lineAnomaly(line, sep)
+		if problem == "" {
+			semiColonIdx := bytes.LastIndexByte(line, sep)
+			temp, _ := parseTempAny(line[semiColonIdx+1:])
+
+			switch {
+			case temp < -999*readingScale/10 || temp > 999*readingScale/10:
+				problem = "temperature outside -99.9..99.9"
+			case !utf8.Valid(line):
+				problem = "not UTF-8"
+			}
+
+			station := line[:semiColonIdx]
+			if first, seen := r.stations[string(station)]; !seen || offset < first {
+				r.stations[string(station)] = offset
+			}
+		}
+
+		if problem != "" {
+			r.add(problem, checkExample{offset, string(line)})
+		}
+		offset += int64(advance)
+	}
+}
+
+// merge adds other's counts and examples to r's.
+func (r *qualityReport) merge(other *qualityReport) {
+	r.lines += other.lines
+
+	for problem, p := range other.problems {
+		for _, example := range p.examples {
+			r.add(problem, example)
+		}
+		r.problems[problem].count += p.count - len(p.examples)
+	}
+
+	for station, offset := range other.stations {
+		if first, seen := r.stations[station]; !seen || offset < first {
+			r.stations[station] = offset
+		}
+	}
+}
+
+// findCasings reports every spelling of a station that only differs from another in case, Abha and ABHA
+// being aggregated as two stations.
+func (r *qualityReport) findCasings() {
+	spellings := map[string][]string{}
+	for station := range r.stations {
+		folded := strings.ToLower(station)
+		spellings[folded] = append(spellings[folded], station)
+	}
+
+	for _, names := range spellings {
+		if len(names) < 2 {
+			continue
+		}
+		for _, name := range names {
+			r.add("station spelt in several casings", checkExample{r.stations[name], name})
+		}
+	}
+}
+
+// inspectFile checks every line of the file name on o's workers.
+func inspectFile(name string, o *Options) (*qualityReport, error) {
+	filePtr, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer filePtr.Close()
+
+	reports := make([]*qualityReport, o.workers)
+	for i := range reports {
+		reports[i] = newQualityReport()
+	}
+
+	scheduler := NewScheduler(o.workers, o.depth)
+	scheduler.Run(readInFile(filePtr, o), func(worker int, chunk Chunk) {
+		reports[worker].inspect(chunk, o.delimiter)
+		o.pool.Put(chunk.data)
+	})
+
+	report := reports[0]
+	for _, other := range reports[1:] {
+		report.merge(other)
+	}
+	report.findCasings()
+
+	return report, nil
+}
+
+// write prints the file's line count, then each problem with its count and examples, reporting whether there were any.
+func (r *qualityReport) write(w io.Writer, name string) bool {
+	problems := sortedKeys(r.problems)
+	fmt.Fprintf(w, "%s: %d lines, %d problems\n", name, r.lines, len(problems))
+
+	for _, problem := range problems {
+		p := r.problems[problem]
+		fmt.Fprintf(w, "  %s: %d\n", problem, p.count)
+		for _, example := range p.examples {
+			fmt.Fprintf(w, "\toffset %d: %q\n", example.offset, example.line)
+		}
+	}
+
+	return len(problems) > 0
+}
+
+// runCheck is a data quality report on measurement files, exiting 1 when any line would be dropped or
+// aggregated oddly, like diff does for differing results.
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.StringVar(fieldDelimiter, "delimiter", ";", "`byte` separating station and temperature, e.g. , or \\t for tab")
+	fs.IntVar(workers, "workers", *workers, "number of checking `goroutines`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: check [-delimiter ;] measurements.txt...")
+		fs.PrintDefaults()
+	}
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	sep, err := parseDelimiter(*fieldDelimiter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	o, err := newOptions(WithWorkers(*workers), WithDelimiter(sep))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	found := false
+	for _, name := range fs.Args() {
+		report, err := inspectFile(name, o)
+		if err != nil {
+			log.Fatal("could not check: ", err)
+		}
+		found = report.write(os.Stdout, name) || found
+	}
+
+	if found {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,109 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestQuality checks check counts every kind of bad line planted in an otherwise clean file and quotes the
+// earliest of each, however the chunks were spread over the workers.
+func TestQuality(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	bad := map[string][]string{
+		"empty line":                       {""},
+		"empty station":                    {";1.0"},
+		"no delimiter":                     {"Station1", "Station1 12.0"},
+		"station longer than 100 bytes":    {strings.Repeat("x", 101) + ";1.0"},
+		"malformed temperature":            {"Station1;1.", "Station1;abc"},
+		"temperature outside -99.9..99.9":  {"Station1;100.0", "Station1;-99.95"},
+		"not UTF-8":                        {"Stati\xffon;1.0"},
+		"station spelt in several casings": {"STATION1;1.0"},
+	}
+	kinds := sortedKeys(bad)
+
+	var sb strings.Builder
+	counts := map[string]int{}
+	examples := map[string][]int64{}
+	firstStation1 := int64(-1)
+	lines := 20000 + rng.Intn(20000)
+	for range lines {
+		line := fmt.Sprintf("Station%d;%.1f", rng.Intn(50), float64(rng.Intn(1999)-999)/10)
+		kind := ""
+		if rng.Intn(20) == 0 {
+			kind = kinds[rng.Intn(len(kinds))]
+			line = bad[kind][rng.Intn(len(bad[kind]))]
+		}
+
+		//Only the first line of a casing counts, with Station1 the other spelling's
+		if kind == "station spelt in several casings" {
+			if counts[kind] > 0 {
+				kind = ""
+			} else {
+				counts[kind], examples[kind] = 1, []int64{-1}
+			}
+		}
+		if kind != "" && counts[kind] < CHECK_EXAMPLES {
+			examples[kind] = append(examples[kind], int64(sb.Len()))
+		}
+		if kind != "" {
+			counts[kind]++
+		}
+		if firstStation1 < 0 && strings.HasPrefix(line, "Station1;") && kind != "malformed temperature" {
+			firstStation1 = int64(sb.Len())
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	dir := t.TempDir()
+
+	file := dir + "/bad.txt"
+	data := sb.String()
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := newOptions(WithWorkers(3), WithChunkSize(MIN_CHUNK_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := inspectFile(file, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.lines != lines {
+		t.Fatalf("check counted %d lines, wrote %d", report.lines, lines)
+	}
+	for _, kind := range kinds {
+		p := report.problems[kind]
+		if p == nil {
+			p = &checkProblem{}
+		}
+
+		var got []int64
+		for _, example := range p.examples {
+			got = append(got, example.offset)
+		}
+
+		//Station1's first line is wherever it happened to land
+		want := examples[kind]
+		if kind == "station spelt in several casings" && len(want) > 0 {
+			want = append([]int64{firstStation1}, want[1:]...)
+			slices.Sort(want)
+		}
+		if p.count != counts[kind] || !slices.Equal(got, want) {
+			t.Fatalf("%s: got %d at %v, want %d at %v", kind, p.count, got, counts[kind], want)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if !report.write(out, file) || !strings.Contains(out.String(), fmt.Sprintf("%d lines, %d problems", lines, len(report.problems))) {
+		t.Fatalf("check wrote %q", out)
+	}
+}
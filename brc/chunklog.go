@@ -0,0 +1,134 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+var chunkLog = commandLine.String("chunk-log", "", "record byte range, line count, first/last station and anomalies of every chunk to `file`")
+
+// MAX_CHUNK_ANOMALIES caps how many anomalous lines are quoted per chunk, the total is always counted.
+const MAX_CHUNK_ANOMALIES = 5
+
+type ChunkReport struct {
+	file        string
+	start, end  int64
+	lines       int
+	first, last string
+	anomalies   []string
+	anomalous   int
+}
+
+// chunkReports collects every chunk seen during the run. They are sorted by file and offset
+// when written, so the log doesn't depend on which worker happened to parse which chunk.
+var chunkReports = struct {
+	reports []ChunkReport
+	m       sync.Mutex
+}{}
+
+// recordChunk inspects chunk when -chunk-log is set. This is a second pass over the bytes,
+// kept out of parseLines so the hot path pays nothing when logging is off.
+func recordChunk(file string, chunk Chunk, o *Options) {
+	if *chunkLog == "" {
+		return
+	}
+
+	report := inspectChunk(file, chunk, o.delimiter)
+
+	chunkReports.m.Lock()
+	chunkReports.reports = append(chunkReports.reports, report)
+	chunkReports.m.Unlock()
+}
+
+func inspectChunk(file string, chunk Chunk, sep byte) ChunkReport {
+	report := ChunkReport{file: file, start: chunk.offset, end: chunk.offset + int64(len(chunk.data))}
+	offset := chunk.offset
+
+	for rest := chunk.data; len(rest) > 0; {
+		advance, line, _ := scanLines(rest, true)
+		rest = rest[advance:]
+
+		report.lines++
+
+		//Lines the parsers are told to ignore aren't anomalies
+		if *skipHeader && offset == 0 || commentChar != 0 && len(line) > 0 && line[0] == commentChar {
+			offset += int64(advance)
+			continue
+		}
+
+		if problem := lineAnomaly(line, sep); problem != "" {
+			report.anomalous++
+			if len(report.anomalies) < MAX_CHUNK_ANOMALIES {
+				report.anomalies = append(report.anomalies, fmt.Sprintf("offset %d: %s %q", offset, problem, line))
+			}
+		} else {
+			station := string(line[:bytes.LastIndexByte(line, sep)])
+			if report.first == "" {
+				report.first = station
+			}
+			report.last = station
+		}
+
+		offset += int64(advance)
+	}
+
+	return report
+}
+
+// lineAnomaly describes what is wrong with line, or returns "" for a well formed station;-?d?d.d line
+// (with -delimiter in place of the ;). Other precisions are only anomalies with -strict-format.
+func lineAnomaly(line []byte, sep byte) string {
+	semiColonIdx := bytes.LastIndexByte(line, sep)
+
+	switch {
+	case len(line) == 0:
+		return "empty line"
+	case semiColonIdx == -1:
+		return "no delimiter"
+	case semiColonIdx == 0:
+		return "empty station"
+	case semiColonIdx > 100:
+		return "station longer than 100 bytes"
+	}
+
+	if _, ok := parseTempAny(line[semiColonIdx+1:]); !ok {
+		return "malformed temperature"
+	}
+
+	return ""
+}
+
+// writeChunkLog writes one line per chunk followed by its quoted anomalies.
+func writeChunkLog(name string) error {
+	reports := chunkReports.reports
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].file != reports[j].file {
+			return reports[i].file < reports[j].file
+		}
+		return reports[i].start < reports[j].start
+	})
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, r := range reports {
+		fmt.Fprintf(w, "file=%s range=%d-%d lines=%d first=%q last=%q anomalies=%d\n", r.file, r.start, r.end, r.lines, r.first, r.last, r.anomalous)
+		for _, a := range r.anomalies {
+			fmt.Fprintf(w, "\t%s\n", a)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,123 @@
+package brc
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of everything that reports or schedules by wall time: the elapsed time report,
+// serve's intervals and windows, emit's pacing and the watchdog. The per line and per chunk timing of the
+// parsers stays on time.Now, it measures the process itself and an interface call there isn't free.
+// Embedders and the tests swap clock for a FakeClock to drive those features deterministically.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+
+	// NewTimer returns a channel receiving the time once d has passed, and a func stopping it early.
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+var clock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                  { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration { return time.Since(t) }
+func (systemClock) Sleep(d time.Duration)           { time.Sleep(d) }
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// FakeClock only moves when told to. Sleep advances it by the time slept, so paced code runs at full speed
+// yet sees exactly the times it asked for.
+type FakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+	m      sync.Mutex
+	cond   *sync.Cond
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.m)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	t := &fakeTimer{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c, func() bool { return false }
+	}
+
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+
+	return t.c, func() bool { return c.remove(t) }
+}
+
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock on by d and fires every timer that has come due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// BlockUntil waits for n timers to be pending, so a test knows a goroutine is waiting before it advances.
+func (c *FakeClock) BlockUntil(n int) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,59 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestClock drives serve's interval and window and emit's pacing on a FakeClock, so they are checked to the
+// nanosecond without sleeping.
+func TestClock(t *testing.T) {
+	fake := NewFakeClock(time.Unix(0, 0))
+	defer func(c Clock) { clock = c }(clock)
+	clock = fake
+
+	out := make(chanWriter, 1)
+	server := NewServer(ServeConfig{Workers: 1, Window: Duration(2 * time.Second), Interval: Duration(time.Second)}, out)
+	defer server.Close()
+	<-server.changed
+	server.Emit()
+
+	parseLines([]byte("A;1.0\nA;3.0\n"), -1, server.current(), server.parse)
+
+	//The window closes at 2s, so the third print is of the fresh tally
+	for i, want := range []string{"{A=1.0/2.0/3.0}\n", "{A=1.0/2.0/3.0}\n", "{}\n"} {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+
+		if got := <-out; got != want {
+			t.Fatalf("serve print at %v: got %q, want %q", time.Duration(i+1)*time.Second, got, want)
+		}
+	}
+
+	input := "A;1.0\nB;2.0\nC;3.0\nD;4.0\nE;5.0\n"
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	start := fake.Now()
+
+	sent, err := emitLines(w, strings.NewReader(input), 10, start, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	//The fifth line is due 400ms in at 10 lines/s
+	if elapsed := fake.Since(start); sent != 5 || elapsed != 400*time.Millisecond || buf.String() != input {
+		t.Fatalf("emit at 10 lines/s: sent %d lines %q in %v, want 5 in 400ms", sent, buf.String(), elapsed)
+	}
+}
+
+// chanWriter hands every Write to the channel, for waiting on what another goroutine prints.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
@@ -0,0 +1,162 @@
+package brc
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var collateLocale = commandLine.String("collate", "", "order station names the way `locale` expects rather than by UTF-16 code units as the challenge does: root, de, de-phonebook, da, nb, sv, fi or es")
+
+// Collation weights are a rune shifted left, leaving a locale room to sort up to three letters straight after
+// any other, e.g. å, ä and ö after z in Swedish.
+const (
+	COLLATION_SHIFT = 2
+
+	//Level separator in a sort key, below every weight
+	COLLATION_LEVEL = 0
+
+	//The secondary weight of a letter with no accent and the tertiary of a lower case one
+	COLLATION_COMMON = 1
+)
+
+// collationTailorings map the lower case, composed letters each locale sorts differently from root to their
+// primary weights. Every other letter is decomposed and sorted by its base letter, its accents only breaking
+// ties, so in root Ölgii sorts among the Os rather than after Zürich.
+var collationTailorings = map[string]map[rune][]uint32{
+	"root":         {},
+	"de":           {},
+	"de-phonebook": {'ä': letters("ae"), 'ö': letters("oe"), 'ü': letters("ue")},
+	"da":           {'æ': after('z', 1), 'ä': after('z', 1), 'ø': after('z', 2), 'ö': after('z', 2), 'å': after('z', 3), 'ü': letters("y")},
+	"nb":           {'æ': after('z', 1), 'ä': after('z', 1), 'ø': after('z', 2), 'ö': after('z', 2), 'å': after('z', 3), 'ü': letters("y")},
+	"sv":           {'å': after('z', 1), 'ä': after('z', 2), 'æ': after('z', 2), 'ö': after('z', 3), 'ø': after('z', 3), 'ü': letters("y")},
+	"fi":           {'å': after('z', 1), 'ä': after('z', 2), 'æ': after('z', 2), 'ö': after('z', 3), 'ø': after('z', 3), 'ü': letters("y")},
+	"es":           {'ñ': after('n', 1)},
+}
+
+// rootExpansions are the letters root sorts as others that Unicode doesn't decompose them into, a locale's
+// tailoring taking precedence.
+var rootExpansions = map[rune][]uint32{
+	'ß': letters("ss"), 'æ': letters("ae"), 'œ': letters("oe"), 'ø': letters("o"), 'đ': letters("d"),
+	'ł': letters("l"), 'ħ': letters("h"), 'ı': letters("i"), 'þ': letters("th"),
+}
+
+// letters is the primary weights of s, sorting as its letters.
+func letters(s string) []uint32 {
+	var weights []uint32
+	for _, r := range s {
+		weights = append(weights, uint32(r)<<COLLATION_SHIFT)
+	}
+	return weights
+}
+
+// after is the primary weight of the nth letter sorted straight after r.
+func after(r rune, n uint32) []uint32 {
+	return []uint32{uint32(r)<<COLLATION_SHIFT + n}
+}
+
+// appendCollationKey appends the three level sort key of name under tailoring: the primary weight of every
+// letter, then its accents, then its case, each level ended by COLLATION_LEVEL so a name sorts before any
+// name it is a prefix of. Bytes that aren't UTF-8 sort as U+FFFD, sortCollated breaks the tie by bytes.
+func appendCollationKey(key []uint32, name []byte, tailoring map[rune][]uint32) []uint32 {
+	t := nfc()
+	if utf8.Valid(name) && !t.isNFC(name) {
+		name = t.appendNFC(nil, name)
+	}
+
+	var secondary, tertiary []uint32
+	var decomposed []rune
+	for _, r := range string(name) {
+		lower := foldRune(r)
+		kase := uint32(COLLATION_COMMON)
+		if lower != r {
+			kase++
+		}
+
+		weights, ok := tailoring[lower]
+		if !ok {
+			weights, ok = rootExpansions[lower]
+		}
+		if ok {
+			key = append(key, weights...)
+			//The last weight's secondary tells the letter from the ones it sorts as, ß from ss
+			for range weights {
+				secondary = append(secondary, COLLATION_COMMON)
+				tertiary = append(tertiary, kase)
+			}
+			secondary[len(secondary)-1]++
+			continue
+		}
+
+		//The base letter carries the primary weight, each accent only a secondary one
+		decomposed = t.appendDecomposed(decomposed[:0], lower)
+		key = append(key, uint32(foldRune(decomposed[0]))<<COLLATION_SHIFT)
+		secondary = append(secondary, COLLATION_COMMON)
+		tertiary = append(tertiary, kase)
+		for _, mark := range decomposed[1:] {
+			secondary = append(secondary, COLLATION_COMMON+1+uint32(mark))
+		}
+	}
+
+	key = append(key, COLLATION_LEVEL)
+	key = append(append(key, secondary...), COLLATION_LEVEL)
+	return append(key, tertiary...)
+}
+
+// sortCollated sorts names in place, by compareUTF16 or by -collate.
+func sortCollated(names []string) {
+	tailoring, ok := collationTailorings[*collateLocale]
+	if !ok {
+		slices.SortFunc(names, compareUTF16)
+		return
+	}
+
+	//Optimisation: every key is built once rather than on each comparison
+	keys := make(map[string][]uint32, len(names))
+	for _, name := range names {
+		keys[name] = appendCollationKey(nil, []byte(name), tailoring)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if c := slices.Compare(keys[names[i]], keys[names[j]]); c != 0 {
+			return c < 0
+		}
+		return compareUTF16(names[i], names[j]) < 0
+	})
+}
+
+// compareUTF16 orders a and b as the Java baseline's String.compareTo does, by UTF-16 code units. That is byte
+// order except for a rune above U+FFFF, a surrogate pair in UTF-16, which sorts before U+E000 to U+FFFF.
+func compareUTF16(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	if i == len(a) || i == len(b) {
+		return cmp.Compare(len(a), len(b))
+	}
+
+	//Back up to the start of the rune they differ in, the bytes before it are the same in both
+	for i > 0 && !utf8.RuneStart(a[i]) {
+		i--
+	}
+	ra, _ := utf8.DecodeRuneInString(a[i:])
+	rb, _ := utf8.DecodeRuneInString(b[i:])
+	if c := cmp.Compare(utf16Order(ra), utf16Order(rb)); c != 0 {
+		return c
+	}
+	return strings.Compare(a[i:], b[i:])
+}
+
+// utf16Order maps r to a weight in the order of its first UTF-16 code unit: below U+E000 as it is, then the
+// surrogate pairs, then U+E000 to U+FFFF.
+func utf16Order(r rune) rune {
+	switch {
+	case r >= 0x10000:
+		return r - 0x10000 + 0xE000
+	case r >= 0xE000:
+		return r + 0x100000
+	}
+	return r
+}
@@ -0,0 +1,49 @@
+package brc
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+var collateCases = map[string][]string{
+	"":             {"Abha", "Nuuk", "Odense", "Oslo", "Strasse", "Straße", "Zürich", "zagreb", "Äänekoski", "Åre", "Ñuñoa", "Ölgii"},
+	"root":         {"Äänekoski", "Abha", "Åre", "Ñuñoa", "Nuuk", "Odense", "Ölgii", "Oslo", "Strasse", "Straße", "zagreb", "Zürich"},
+	"de-phonebook": {"Abha", "Äänekoski", "Åre", "Ñuñoa", "Nuuk", "Odense", "Ölgii", "Oslo", "Strasse", "Straße", "zagreb", "Zürich"},
+	"sv":           {"Abha", "Ñuñoa", "Nuuk", "Odense", "Oslo", "Strasse", "Straße", "zagreb", "Zürich", "Åre", "Äänekoski", "Ölgii"},
+	"da":           {"Abha", "Ñuñoa", "Nuuk", "Odense", "Oslo", "Strasse", "Straße", "zagreb", "Zürich", "Äänekoski", "Ölgii", "Åre"},
+	"es":           {"Äänekoski", "Abha", "Åre", "Nuuk", "Ñuñoa", "Odense", "Ölgii", "Oslo", "Strasse", "Straße", "zagreb", "Zürich"},
+}
+
+// TestCollate sorts shuffled collateCases under each -collate, some names decomposed as -nfc would undo.
+func TestCollate(t *testing.T) {
+	defer func(locale string) { *collateLocale = locale }(*collateLocale)
+
+	rng := rand.New(rand.NewSource(1))
+
+	tables := nfc()
+	for locale, want := range collateCases {
+		*collateLocale = locale
+
+		names := slices.Clone(want)
+		rng.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+		sortCollated(names)
+		if !slices.Equal(names, want) {
+			t.Fatalf("-collate=%s sorted %q, want %q", locale, names, want)
+		}
+
+		if locale == "" {
+			continue
+		}
+		tailoring := collationTailorings[locale]
+		for _, name := range want {
+			var decomposed []rune
+			for _, r := range name {
+				decomposed = tables.appendDecomposed(decomposed, r)
+			}
+			if a, b := appendCollationKey(nil, []byte(name), tailoring), appendCollationKey(nil, []byte(string(decomposed)), tailoring); !slices.Equal(a, b) {
+				t.Fatalf("-collate=%s: %+q has key %v, decomposed %v", locale, name, a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package brc
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed completions
+var completionTemplates embed.FS
+
+var installCompletion = commandLine.String("install-completion", "", "install tab completion for `shell` (bash, zsh or fish) and exit")
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionData is what the completion templates are rendered with.
+type completionData struct {
+	Prog, Ident   string
+	Flags         []string
+	Subcommands   []string
+	Strategies    []string
+	TimingFormats []string
+	Keys          []string
+	Shells        []string
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newCompletionData() completionData {
+	prog := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+
+	data := completionData{
+		Prog: prog,
+		Ident: strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, prog),
+		Subcommands:   sortedKeys(subcommands),
+		Strategies:    sortedKeys(strategies),
+		TimingFormats: timingFormats,
+		Keys:          sortedKeys(keyFuncs),
+		Shells:        completionShells,
+	}
+
+	commandLine.VisitAll(func(f *flag.Flag) {
+		data.Flags = append(data.Flags, f.Name)
+	})
+
+	return data
+}
+
+// completionPath is where each shell picks up per-user completions without any extra configuration,
+// except zsh which needs ~/.zfunc on its fpath.
+func completionPath(shell, prog string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	xdg := func(env, fallback string) string {
+		if dir := os.Getenv(env); dir != "" {
+			return dir
+		}
+		return filepath.Join(home, fallback)
+	}
+
+	switch shell {
+	case "bash":
+		return filepath.Join(xdg("XDG_DATA_HOME", ".local/share"), "bash-completion", "completions", prog), nil
+	case "zsh":
+		return filepath.Join(home, ".zfunc", "_"+prog), nil
+	case "fish":
+		return filepath.Join(xdg("XDG_CONFIG_HOME", ".config"), "fish", "completions", prog+".fish"), nil
+	}
+
+	return "", fmt.Errorf("no completion for shell %q, want one of %s", shell, strings.Join(completionShells, ", "))
+}
+
+// runInstallCompletion renders the embedded template for shell and writes it where the shell looks for it.
+func runInstallCompletion(shell string) error {
+	data := newCompletionData()
+
+	path, err := completionPath(shell, data.Prog)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(shell+".tmpl").Funcs(template.FuncMap{"join": strings.Join}).
+		ParseFS(completionTemplates, "completions/"+shell+".tmpl")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpl.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "installed", shell, "completion to", path)
+	if shell == "zsh" {
+		fmt.Fprintln(os.Stderr, "add fpath=(~/.zfunc $fpath) before compinit in ~/.zshrc if it is not there already")
+	}
+
+	return nil
+}
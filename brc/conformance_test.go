@@ -0,0 +1,60 @@
+package brc
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/robert-ohurley/1-billion-row-challenge/conformance"
+)
+
+// TestConformance runs the conformance cases through Process and formats its aggregates with Print.
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+
+	conformance.Check(t, func(input []byte) (string, error) {
+		file := filepath.Join(dir, "measurements.txt")
+		if err := os.WriteFile(file, input, 0o644); err != nil {
+			return "", err
+		}
+
+		tally, err := Process([]string{file})
+		if err != nil {
+			return "", err
+		}
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		return buf.String(), nil
+	})
+
+	conformance.CheckFormat(t, func(stations []conformance.Station) string {
+		tally := NewTally()
+		for _, s := range stations {
+			tally.add(s.Name, StationResult{int(s.Min), int(s.Max), int(s.Sum), int(s.Count), -1, math.NaN(), nil, nil, "", -1})
+		}
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		return buf.String()
+	})
+}
+
+// FuzzCompareUTF16 checks compareUTF16 orders any two UTF-8 names as conformance.Less does. Invalid UTF-8 is
+// all U+FFFD to Less, compareUTF16 tells such names apart by their bytes instead.
+func FuzzCompareUTF16(f *testing.F) {
+	f.Add("�", "\U0001F600")
+	f.Add("Zürich", "Zagreb")
+	f.Add("a\U0001F600", "ab")
+	f.Add("Ab", "A")
+
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if !utf8.ValidString(a) || !utf8.ValidString(b) {
+			t.Skip()
+		}
+		if got, want := compareUTF16(a, b) < 0, conformance.Less(a, b); got != want {
+			t.Fatalf("compareUTF16(%+q, %+q) < 0 is %t, conformance.Less %t", a, b, got, want)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package brc
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// kafkaOffsetResets maps -offset to the auto.offset.reset a consumer group falls back on when it has no stored offset.
+var kafkaOffsetResets = map[string]string{"beginning": "earliest", "end": "latest", "stored": "latest"}
+
+// kcatArgs builds the kcat command line printing every message of topic as a line of its own.
+// With a group the partitions are balanced across every consume sharing it and offsets are committed.
+func kcatArgs(brokers, topic, group, offset string) []string {
+	args := []string{"-b", brokers, "-u", "-q", "-f", "%s\n"}
+
+	if group != "" {
+		return append(args, "-X", "auto.offset.reset="+kafkaOffsetResets[offset], "-G", group, topic)
+	}
+
+	return append(args, "-C", "-t", topic, "-o", offset)
+}
+
+// runConsume aggregates station;temp messages from a Kafka topic the way serve aggregates TCP connections,
+// printing the running tally every interval. It drives the kcat client rather than speaking the Kafka
+// protocol itself, which would need a third party module for the compression codecs brokers use.
+func runConsume(args []string) {
+	fs := flag.NewFlagSet("consume", flag.ExitOnError)
+	brokers := fs.String("brokers", "localhost:9092", "comma separated Kafka bootstrap `brokers`")
+	topic := fs.String("topic", "measurements", "`topic` to consume")
+	group := fs.String("group", "", "consumer `group` to join, committing offsets so a restart carries on; empty reads every partition alone")
+	offset := fs.String("offset", "end", "where to start without a committed offset: beginning, end or stored")
+	kcat := fs.String("kcat", "kcat", "kcat `path`, the Kafka client messages are read through")
+	admin := fs.String("admin", "", "`address` for the admin HTTP endpoints (/config, /dictionary, /debug/vars), empty disables them")
+	fs.IntVar(maxStations, "max-stations", 0, "abort once more than `n` unique stations are seen (0 disables)")
+
+	config := ServeConfig{}
+	fs.IntVar(&config.Workers, "workers", *workers, "number of parser `goroutines`")
+	window := fs.Duration("window", 0, "start a fresh tally every `duration`, 0 accumulates forever")
+	interval := fs.Duration("interval", 10*time.Second, "print the tally every `duration`")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	config.Window, config.Interval = Duration(*window), Duration(*interval)
+
+	if err := config.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, ok := kafkaOffsetResets[*offset]; !ok {
+		log.Fatalf("-offset=%s is unknown, want one of %s", *offset, strings.Join(sortedKeys(kafkaOffsetResets), ", "))
+	}
+
+	server := NewServer(config, os.Stdout)
+	server.Emit()
+
+	if *admin != "" {
+		go func() {
+			slog.Error("admin endpoints stopped", "err", http.ListenAndServe(*admin, server.AdminHandler()))
+		}()
+	}
+
+	cmd := exec.Command(*kcat, kcatArgs(*brokers, *topic, *group, *offset)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Fatal("could not start kcat: ", err)
+	}
+
+	slog.Info("consuming", "topic", *topic, "brokers", *brokers)
+	if err := server.Ingest(stdout); err != nil {
+		log.Fatal("could not read from kcat: ", err)
+	}
+
+	//kcat only exits on its own when it fails
+	if err := cmd.Wait(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		log.Fatalf("%s: %v", *kcat, err)
+	}
+}
@@ -0,0 +1,65 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var fieldDelimiter = commandLine.String("delimiter", ";", "`byte` separating station and temperature, e.g. , or \\t for tab")
+
+// parseDelimiter accepts a single byte, or \t and tab spelled out since a literal tab is awkward to pass in a shell.
+func parseDelimiter(s string) (byte, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	if len(s) != 1 {
+		return 0, fmt.Errorf("-delimiter must be a single byte, got %q", s)
+	}
+
+	if s[0] == '\n' || s[0] == '.' || s[0] == '-' || s[0] >= '0' && s[0] <= '9' {
+		return 0, fmt.Errorf("-delimiter %q would be confused with the line or the temperature", s)
+	}
+
+	return s[0], nil
+}
+
+var duplicateDelimiter = commandLine.String("duplicate-delimiter", "last", "which delimiter of a line with several ends the station: last (names may contain it), first (extra fields follow the temperature) or reject (skip the line)")
+
+const (
+	DELIMITER_LAST = iota
+	DELIMITER_FIRST
+	DELIMITER_REJECT
+)
+
+var delimiterModes = map[string]int{"last": DELIMITER_LAST, "first": DELIMITER_FIRST, "reject": DELIMITER_REJECT}
+
+// delimiterMode is -duplicate-delimiter resolved once, the zero value is the default for subcommands that never set it.
+var delimiterMode = DELIMITER_LAST
+
+// cutStation splits line into station and temperature at sep by delimiterMode. With first, anything after
+// a further sep is an extra field and not part of the temperature. ok is false when there is no sep or the
+// line is rejected.
+func cutStation(line []byte, sep byte) (station, temp []byte, ok bool) {
+	last := bytes.LastIndexByte(line, sep)
+	if last == -1 {
+		return nil, nil, false
+	}
+
+	if delimiterMode == DELIMITER_LAST {
+		return line[:last], line[last+1:], true
+	}
+
+	first := bytes.IndexByte(line, sep)
+	if first == last {
+		return line[:last], line[last+1:], true
+	}
+
+	if delimiterMode == DELIMITER_REJECT {
+		return nil, nil, false
+	}
+
+	temp = line[first+1:]
+	return line[:first], temp[:bytes.IndexByte(temp, sep)], true
+}
@@ -0,0 +1,38 @@
+package brc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// delimiterCases lock in what each -duplicate-delimiter mode makes of lines with more than one ;
+// Each input only holds lines that mode can make sense of, or the naive strategy would rightly reject it.
+var delimiterCases = []struct {
+	sep         byte
+	mode        string
+	input, want string
+}{
+	{';', "last", "Semi;colon;1.0\nSemi;2.0\n", "{Semi=2.0/2.0/2.0, Semi;colon=1.0/1.0/1.0}\n"},
+	{';', "first", "Extra;3.0;sensor7\nExtra;1.0\nMore;-1.5;a;b\n", "{Extra=1.0/2.0/3.0, More=-1.5/-1.5/-1.5}\n"},
+	{';', "reject", "Semi;colon;1.0\nSemi;2.0\nExtra;3.0;sensor7\n", "{Semi=2.0/2.0/2.0}\n"},
+	{',', "last", "Washington, D.C.,12.5\nHamburg,-1.0\nHamburg;x,3.0\n", "{Hamburg=-1.0/-1.0/-1.0, Hamburg;x=3.0/3.0/3.0, Washington, D.C.=12.5/12.5/12.5}\n"},
+	{'\t', "first", "Oslo\t-4.5\tsensor7\nOslo\t1.5\n", "{Oslo=-4.5/-1.5/1.5}\n"},
+}
+
+// TestDelimiter runs delimiterCases through every strategy, ; and last being the defaults.
+func TestDelimiter(t *testing.T) {
+	if delimiterModes[*duplicateDelimiter] != DELIMITER_LAST {
+		t.Errorf("-duplicate-delimiter defaults to %s, want last", *duplicateDelimiter)
+	}
+	if *fieldDelimiter != ";" {
+		t.Errorf("-delimiter defaults to %q, want \";\"", *fieldDelimiter)
+	}
+	defer func(mode int) { delimiterMode = mode }(delimiterMode)
+
+	for _, c := range delimiterCases {
+		t.Run(fmt.Sprintf("-delimiter=%q -duplicate-delimiter=%s", c.sep, c.mode), func(t *testing.T) {
+			delimiterMode = delimiterModes[c.mode]
+			expectEveryStrategy(t, c.input, c.want, WithDelimiter(c.sep))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package brc
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// demoMeasurements is 10,000 readings over 55 real cities, means as in the challenge's station list,
+// generated once with a fixed seed so the demo prints the same every time.
+//
+//go:embed demo/measurements.txt
+var demoMeasurements []byte
+
+// runDemo aggregates the embedded sample, touching nothing on disk: a smoke test of a fresh build and a
+// small input to read alongside the code.
+func runDemo(args []string) {
+	fs := flag.NewFlagSet("demo", flag.ExitOnError)
+	show := fs.Bool("show", false, "print the embedded measurements instead of their results")
+	demoWorkers := fs.Int("workers", *workers, "number of parser `goroutines`")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if *show {
+		os.Stdout.Write(demoMeasurements)
+		return
+	}
+
+	o, err := newOptions(WithWorkers(*demoWorkers))
+	if err != nil {
+		log.Fatal("could not set up the demo: ", err)
+	}
+
+	start := clock.Now()
+	tally := NewTally()
+	if err := streamInto(bytes.NewReader(demoMeasurements), "demo", tally, o); err != nil {
+		log.Fatal("could not aggregate the demo: ", err)
+	}
+	tally.Print(os.Stdout)
+
+	slog.Info("aggregated the demo", "rows", bytes.Count(demoMeasurements, []byte{'\n'}), "stations", len(tally.names),
+		"bytes", len(demoMeasurements), "elapsed", clock.Since(start))
+}
@@ -0,0 +1,25 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDemo streams the embedded sample the way demo does and matches it against every strategy reading it
+// from a file.
+func TestDemo(t *testing.T) {
+	if rows := bytes.Count(demoMeasurements, []byte{'\n'}); rows != 10_000 {
+		t.Fatalf("demo has %d rows, want 10,000", rows)
+	}
+
+	o, err := newOptions(WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tally := NewTally()
+	streamInto(bytes.NewReader(demoMeasurements), "demo", tally, o)
+
+	buf := &bytes.Buffer{}
+	tally.Print(buf)
+	expectEveryStrategy(t, string(demoMeasurements), buf.String())
+}
@@ -0,0 +1,115 @@
+package brc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+)
+
+var dumpDictionary = commandLine.String("dump-dictionary", "", "write the station dictionary (name, id, hash, first seen offset) as JSON to `file`")
+var seedDictionary = commandLine.String("seed-dictionary", "", "build the perfect hash of -official-stations from the stations of a -dump-dictionary `file` instead of the official list, so a rerun over the same data finds every station by direct index")
+
+// DictionaryEntry describes one distinct station. Hex is the raw name bytes, which is what matters when chasing
+// encoding problems or names that only look identical. ID is the dense index a serial interner would have given
+// the station, i.e. its rank by first sighting, so it is stable across runs over the same input.
+// Hash is FNV-1a 64 of the raw bytes, useful for spotting collisions in any hash keyed structure.
+type DictionaryEntry struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Hex    string `json:"hex"`
+	Hash   string `json:"hash"`
+	File   string `json:"file,omitempty"`
+	Offset int64  `json:"offset"`
+}
+
+// Dictionary lists the stations of t in first seen order, stations without a known offset last by name.
+func (t *Tally) Dictionary() []DictionaryEntry {
+	t.m.Lock()
+	entries := make([]DictionaryEntry, 0, len(t.names))
+	for id, name := range t.names {
+		r := t.stat(id)
+		entries = append(entries, DictionaryEntry{Name: name, File: r.file, Offset: r.offset})
+	}
+	t.m.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if (a.Offset < 0) != (b.Offset < 0) {
+			return b.Offset < 0
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Offset != b.Offset {
+			return a.Offset < b.Offset
+		}
+		return a.Name < b.Name
+	})
+
+	for i := range entries {
+		h := fnv.New64a()
+		h.Write([]byte(entries[i].Name))
+
+		entries[i].ID = i
+		entries[i].Hex = hex.EncodeToString([]byte(entries[i].Name))
+		entries[i].Hash = fmt.Sprintf("%016x", h.Sum64())
+	}
+
+	return entries
+}
+
+func (t *Tally) WriteDictionary(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t.Dictionary())
+}
+
+func writeDictionaryFile(name string, tally *Tally) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := tally.WriteDictionary(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readDictionaryFile reads the entries of a dictionary written by -dump-dictionary.
+func readDictionaryFile(name string) ([]DictionaryEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []DictionaryEntry
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return entries, nil
+}
+
+// buildDictionaryHash is the perfect hash of the stations of the dictionary file name, for -seed-dictionary.
+func buildDictionaryHash(name string) (*perfectHash, error) {
+	entries, err := readDictionaryFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		//An empty name marks a free slot, and is never looked up anyway
+		if e.Name != "" {
+			names = append(names, e.Name)
+		}
+	}
+	return newPerfectHash(names)
+}
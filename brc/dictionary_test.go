@@ -0,0 +1,58 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSeedDictionary dumps the dictionary of a run over random stations and checks the perfect hash seeded
+// from it finds every one of them, and that a rerun through it tallies the same as the map.
+func TestSeedDictionary(t *testing.T) {
+	defer func(p *perfectHash) { officialHash = p }(officialHash)
+	officialHash = nil
+
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	var lines strings.Builder
+	for range 5000 {
+		fmt.Fprintf(&lines, "%s%d;%.1f\n", strings.ReplaceAll(randomName(rng), ";", ""), rng.Intn(2000), float64(rng.Intn(1999)-999)/10)
+	}
+	file, dictionary := dir+"/measurements.txt", dir+"/dictionary.json"
+	if err := os.WriteFile(file, []byte(lines.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := Process([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDictionaryFile(dictionary, total); err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	total.Print(want)
+
+	p, err := buildDictionaryHash(dictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range total.names {
+		if _, ok := p.slot([]byte(name)); !ok {
+			t.Fatalf("%q from the dictionary isn't found", name)
+		}
+	}
+
+	officialHash = p
+	got, err := runPipeline(StreamingStrategy{}, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want.String() {
+		t.Fatalf("seeded from the dictionary gave %q, the map %q", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+var directIO = commandLine.Bool("direct-io", false, "read around the page cache (O_DIRECT on Linux, F_NOCACHE on macOS) for honest cold cache timings that don't evict other work's pages, needs -strategy=streaming")
+
+// DIRECT_IO_ALIGN is what direct reads align their buffers, offsets and lengths to, a page covers every
+// logical block size in use.
+const DIRECT_IO_ALIGN = 4096
+
+// directReader reads a file opened for direct I/O in whole aligned blocks, copying out what Read asks for.
+type directReader struct {
+	file *os.File
+	buf  []byte
+	rest []byte
+	err  error
+}
+
+// alignedBuffer returns size bytes starting at a multiple of DIRECT_IO_ALIGN.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+DIRECT_IO_ALIGN)
+	skip := (DIRECT_IO_ALIGN - int(uintptr(unsafe.Pointer(&buf[0]))%DIRECT_IO_ALIGN)) % DIRECT_IO_ALIGN
+	return buf[skip : skip+size]
+}
+
+func newDirectReader(file *os.File, chunkSize int) *directReader {
+	return &directReader{file: file, buf: alignedBuffer(max(chunkSize/DIRECT_IO_ALIGN, 1) * DIRECT_IO_ALIGN)}
+}
+
+func (r *directReader) Read(p []byte) (int, error) {
+	read := 0
+	for read < len(p) {
+		if len(r.rest) == 0 {
+			if r.err != nil {
+				break
+			}
+
+			//Only the read at the end of the file comes back short, so every read starts aligned
+			n, err := r.file.Read(r.buf)
+			r.rest, r.err = r.buf[:n], err
+			if n == 0 {
+				break
+			}
+		}
+
+		n := copy(p[read:], r.rest)
+		r.rest = r.rest[n:]
+		read += n
+	}
+
+	if read == 0 && r.err != nil {
+		return 0, r.err
+	}
+	return read, nil
+}
+
+// processDirect streams name through a directReader into tally.
+func processDirect(name string, tally *Tally, o *Options) error {
+	file, err := openDirect(name)
+	if err != nil {
+		return fmt.Errorf("could not open for direct I/O: %w", err)
+	}
+	defer file.Close()
+
+	r := newDirectReader(file, o.chunkSize)
+	err = streamInto(r, name, tally, o)
+	if r.err != io.EOF {
+		return errors.Join(err, r.err)
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+package brc
+
+import (
+	"os"
+	"syscall"
+)
+
+const directIOSupported = true
+
+// openDirect turns the page cache off for the file with F_NOCACHE, macOS has no O_DIRECT.
+func openDirect(name string) (*os.File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), syscall.F_NOCACHE, 1); errno != 0 {
+		file.Close()
+		return nil, errno
+	}
+	return file, nil
+}
@@ -0,0 +1,12 @@
+package brc
+
+import (
+	"os"
+	"syscall"
+)
+
+const directIOSupported = true
+
+func openDirect(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_RDONLY|syscall.O_DIRECT, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package brc
+
+import (
+	"errors"
+	"os"
+)
+
+const directIOSupported = false
+
+func openDirect(name string) (*os.File, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,40 @@
+package brc
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+// TestDirectIO reads random files of sizes that aren't block multiples with -direct-io, which must change nothing
+// but where the bytes come from. Filesystems that refuse O_DIRECT, such as some tmpfs, skip it.
+func TestDirectIO(t *testing.T) {
+	if !directIOSupported {
+		t.Skip("no direct I/O on " + runtime.GOOS)
+	}
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 2, rng.Intn(3*BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := runPipeline(StreamingStrategy{}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := runPipeline(StreamingStrategy{}, files, WithDirectIO(true))
+	if errors.Is(err, syscall.EINVAL) {
+		t.Skip("the filesystem of ", dir, " refuses O_DIRECT")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("-direct-io gave %q, cached reads %q", got, want)
+	}
+}
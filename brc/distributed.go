@@ -0,0 +1,423 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Distributed mode: a coordinator hands out byte ranges of files every worker can see
+// (a shared mount, or identical shards copied to each machine) and merges the partial tallies
+// the workers send back. Transport is gRPC, the Worker service of distributed.proto, so a worker or
+// coordinator written in any language can take part.
+// A worker only reads files under its -root, listens on loopback unless told otherwise, and with -token-file
+// only answers coordinators presenting the token over TLS.
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative distributed.proto
+
+const DEFAULT_RANGE_SIZE = 64 * 1024 * 1024
+
+// MAX_PARTIAL_SIZE is the biggest partial tally a worker sends or a coordinator takes. gRPC stops at 4MB by
+// default, a range of a few hundred thousand distinct stations is past that.
+const MAX_PARTIAL_SIZE = 1 << 30
+
+// DIAL_TIMEOUT is how long the coordinator waits for a worker to answer before skipping it.
+const DIAL_TIMEOUT = 10 * time.Second
+
+// workerServer is the Worker service as the worker subcommand serves it.
+type workerServer struct {
+	UnimplementedWorkerServer
+	segments int
+
+	//root is the directory requested paths are confined to
+	root *os.Root
+}
+
+// open opens path under the worker's root. An absolute path is taken relative to the root, so a coordinator
+// can name files of a shared mount as it sees them, and one anywhere else is refused as the root refuses
+// any that climb out of it with .. or a symlink.
+func (w *workerServer) open(path string) (*os.File, error) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(w.root.Name(), path)
+		if err != nil {
+			return nil, err
+		}
+		path = rel
+	}
+
+	return w.root.Open(path)
+}
+
+// Stat reports the size of path as the worker sees it.
+func (w *workerServer) Stat(ctx context.Context, req *StatRequest) (*StatReply, error) {
+	f, err := w.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatReply{Size: info.Size()}, nil
+}
+
+// Process aggregates one range and replies with it serialised by WritePartial.
+func (w *workerServer) Process(ctx context.Context, req *RangeRequest) (*PartialReply, error) {
+	filePtr, err := w.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer filePtr.Close()
+
+	info, err := filePtr.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := alignRange(filePtr, req.Start, req.End, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := newOptions(WithWorkers(w.segments))
+	if err != nil {
+		return nil, err
+	}
+
+	tally := NewTally()
+	if err := processRange(filePtr, start, end, w.segments, tally, o); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tally.WritePartial(buf); err != nil {
+		return nil, err
+	}
+
+	return &PartialReply{Partial: buf.Bytes()}, nil
+}
+
+// workerAuth is who may call a worker and how: a bearer token every call must carry, "" to take any
+// caller, and the TLS config it serves or dials with, nil for plaintext.
+type workerAuth struct {
+	token string
+	tls   *tls.Config
+}
+
+var errBadToken = status.Error(codes.Unauthenticated, "missing or wrong token")
+
+// checkToken rejects calls that don't carry token.
+func checkToken(token string) grpc.UnaryServerInterceptor {
+	want := []byte("Bearer " + token)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		got := md.Get("authorization")
+		if len(got) != 1 || subtle.ConstantTimeCompare([]byte(got[0]), want) != 1 {
+			return nil, errBadToken
+		}
+		return handler(ctx, req)
+	}
+}
+
+// tokenCredentials sends the token with every call, only ever over TLS.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// newWorkerServer is a gRPC server of the Worker service parsing each range with segments goroutines, reading
+// only files under root and answering only callers auth lets in.
+func newWorkerServer(segments int, root *os.Root, auth workerAuth) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.MaxSendMsgSize(MAX_PARTIAL_SIZE)}
+	if auth.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(auth.tls)))
+	}
+	if auth.token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(checkToken(auth.token)))
+	}
+
+	server := grpc.NewServer(opts...)
+	RegisterWorkerServer(server, &workerServer{segments: segments, root: root})
+	return server
+}
+
+// readToken reads the token in name, ignoring surrounding whitespace.
+func readToken(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s holds no token", name)
+	}
+	return token, nil
+}
+
+func runWorker(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:7070", "`address` to accept coordinator connections on, loopback only by default")
+	segments := fs.Int("workers", *workers, "number of parser `goroutines` per range")
+	rootDir := fs.String("root", ".", "only read files under `dir`, paths coordinators send are taken relative to it")
+	certFile := fs.String("tls-cert", "", "serve over TLS with the certificate in `file`, needs -tls-key")
+	keyFile := fs.String("tls-key", "", "private key of -tls-cert, `file`")
+	tokenFile := fs.String("token-file", "", "only answer coordinators sending the token in `file`, needs -tls-cert")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if (*certFile == "") != (*keyFile == "") || *tokenFile != "" && *certFile == "" {
+		fmt.Fprintln(os.Stderr, "-tls-cert and -tls-key go together, and -token-file needs them")
+		os.Exit(2)
+	}
+
+	var auth workerAuth
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			log.Fatal("could not load -tls-cert: ", err)
+		}
+		auth.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if *tokenFile != "" {
+		var err error
+		if auth.token, err = readToken(*tokenFile); err != nil {
+			log.Fatal("could not read -token-file: ", err)
+		}
+	}
+
+	dir, err := filepath.Abs(*rootDir)
+	if err != nil {
+		log.Fatal("-root: ", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		log.Fatal("could not open -root: ", err)
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal("could not listen: ", err)
+	}
+	if auth.token == "" && !isLoopback(listener.Addr()) {
+		slog.Warn("any host that can reach the worker may read files under its root, see -token-file", "addr", listener.Addr().String(), "root", dir)
+	}
+
+	slog.Info("worker listening", "addr", listener.Addr().String(), "root", dir, "tls", auth.tls != nil)
+	log.Fatal(newWorkerServer(*segments, root, auth).Serve(listener))
+}
+
+// isLoopback reports whether addr only takes connections from this host.
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// peer is one connected worker.
+type peer struct {
+	addr   string
+	client WorkerClient
+	conn   *grpc.ClientConn
+}
+
+// dialWorker connects to the worker at addr, failing if it isn't ready within DIAL_TIMEOUT. Connections are
+// plaintext unless auth has a TLS config, and carry auth's token when it has one.
+func dialWorker(addr string, auth workerAuth) (*peer, error) {
+	creds := insecure.NewCredentials()
+	if auth.tls != nil {
+		creds = credentials.NewTLS(auth.tls)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(MAX_PARTIAL_SIZE))}
+	if auth.token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(auth.token)))
+	}
+
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DIAL_TIMEOUT)
+	defer cancel()
+
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			conn.Close()
+			return nil, fmt.Errorf("not ready after %v, %s", DIAL_TIMEOUT, state)
+		}
+	}
+
+	return &peer{addr, NewWorkerClient(conn), conn}, nil
+}
+
+func runCoordinate(args []string) {
+	fs := flag.NewFlagSet("coordinate", flag.ExitOnError)
+	peerList := fs.String("peers", "", "comma separated worker `addresses`, e.g. host1:7070,host2:7070")
+	rangeSize := fs.Int64("range-size", DEFAULT_RANGE_SIZE, "`bytes` handed to a worker at a time")
+	out := fs.String("dump-partial", "", "also write the merged tally to `file`")
+	caFile := fs.String("tls-ca", "", "connect to workers over TLS, trusting the CA certificates in `file`")
+	tokenFile := fs.String("token-file", "", "send workers the token in `file`, needs -tls-ca")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: coordinate -peers host:port,... [-range-size bytes] [-tls-ca file [-token-file file]] file...")
+		fs.PrintDefaults()
+	}
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if *peerList == "" || fs.NArg() == 0 || *rangeSize < 1 || *tokenFile != "" && *caFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var auth workerAuth
+	if *caFile != "" {
+		pem, err := os.ReadFile(*caFile)
+		if err != nil {
+			log.Fatal("could not read -tls-ca: ", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("-tls-ca %s has no PEM certificates", *caFile)
+		}
+		auth.tls = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if *tokenFile != "" {
+		var err error
+		if auth.token, err = readToken(*tokenFile); err != nil {
+			log.Fatal("could not read -token-file: ", err)
+		}
+	}
+
+	var peers []*peer
+	for _, addr := range strings.Split(*peerList, ",") {
+		p, err := dialWorker(addr, auth)
+		if err != nil {
+			slog.Warn("skipping unreachable worker", "worker", addr, "err", err)
+			continue
+		}
+		defer p.conn.Close()
+		peers = append(peers, p)
+	}
+
+	if len(peers) == 0 {
+		log.Fatal("no workers reachable")
+	}
+
+	tally, err := coordinate(peers, fs.Args(), *rangeSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out != "" {
+		if err := writePartialFile(*out, tally); err != nil {
+			log.Fatal("could not write partial tally: ", err)
+		}
+	}
+
+	tally.Print(os.Stdout)
+}
+
+// coordinate splits every file into ranges and keeps each peer busy until all are merged.
+// A range that fails, or comes back as a partial that doesn't read, is put back for another peer and the
+// failing peer is retired.
+func coordinate(peers []*peer, files []string, rangeSize int64) (*Tally, error) {
+	var ranges []*RangeRequest
+
+	for _, path := range files {
+		stat, err := peers[0].client.Stat(context.Background(), &StatRequest{Path: path})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for start := int64(0); start < stat.Size; start += rangeSize {
+			ranges = append(ranges, &RangeRequest{Path: path, Start: start, End: start + rangeSize})
+		}
+	}
+
+	queue := make(chan *RangeRequest, len(ranges))
+	for _, r := range ranges {
+		queue <- r
+	}
+
+	tally := NewTally()
+	remaining := &sync.WaitGroup{}
+	remaining.Add(len(ranges))
+
+	alive := len(peers)
+	m := &sync.Mutex{}
+	var lastErr error
+
+	for _, p := range peers {
+		go func(p *peer) {
+			for r := range queue {
+				var result *Tally
+				reply, err := p.client.Process(context.Background(), r)
+				if err == nil {
+					if result, err = ReadPartial(bytes.NewReader(reply.Partial)); err != nil {
+						err = fmt.Errorf("bad partial: %w", err)
+					}
+				}
+				if err != nil {
+					queue <- r
+
+					m.Lock()
+					slog.Warn("worker failed, retiring it", "worker", p.addr, "file", r.Path, "start", r.Start, "end", r.End, "err", err)
+					alive--
+					lastErr = err
+					if alive == 0 {
+						//Nobody left to take the requeued ranges
+						for i := 0; i < len(queue); i++ {
+							remaining.Done()
+						}
+					}
+					m.Unlock()
+					return
+				}
+
+				tally.Merge(result)
+				remaining.Done()
+			}
+		}(p)
+	}
+
+	remaining.Wait()
+	close(queue)
+
+	if alive == 0 {
+		return nil, errors.Join(errors.New("every worker failed"), lastErr)
+	}
+
+	return tally, nil
+}
@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: distributed.proto
+
+// The coordinator and worker subcommands talk over this service. Partials are the format of
+// Tally.WritePartial, the same bytes -dump-partial writes and the merge subcommand reads.
+
+package brc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_distributed_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRequest) ProtoMessage() {}
+
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_distributed_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_distributed_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StatRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type StatReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Size          int64                  `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatReply) Reset() {
+	*x = StatReply{}
+	mi := &file_distributed_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatReply) ProtoMessage() {}
+
+func (x *StatReply) ProtoReflect() protoreflect.Message {
+	mi := &file_distributed_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatReply.ProtoReflect.Descriptor instead.
+func (*StatReply) Descriptor() ([]byte, []int) {
+	return file_distributed_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatReply) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type RangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Start         int64                  `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End           int64                  `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RangeRequest) Reset() {
+	*x = RangeRequest{}
+	mi := &file_distributed_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RangeRequest) ProtoMessage() {}
+
+func (x *RangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_distributed_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RangeRequest.ProtoReflect.Descriptor instead.
+func (*RangeRequest) Descriptor() ([]byte, []int) {
+	return file_distributed_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RangeRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *RangeRequest) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *RangeRequest) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+type PartialReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Partial       []byte                 `protobuf:"bytes,1,opt,name=partial,proto3" json:"partial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PartialReply) Reset() {
+	*x = PartialReply{}
+	mi := &file_distributed_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PartialReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PartialReply) ProtoMessage() {}
+
+func (x *PartialReply) ProtoReflect() protoreflect.Message {
+	mi := &file_distributed_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PartialReply.ProtoReflect.Descriptor instead.
+func (*PartialReply) Descriptor() ([]byte, []int) {
+	return file_distributed_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PartialReply) GetPartial() []byte {
+	if x != nil {
+		return x.Partial
+	}
+	return nil
+}
+
+var File_distributed_proto protoreflect.FileDescriptor
+
+const file_distributed_proto_rawDesc = "" +
+	"\n" +
+	"\x11distributed.proto\x12\x03brc\"!\n" +
+	"\vStatRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\x1f\n" +
+	"\tStatReply\x12\x12\n" +
+	"\x04size\x18\x01 \x01(\x03R\x04size\"J\n" +
+	"\fRangeRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x03R\x03end\"(\n" +
+	"\fPartialReply\x12\x18\n" +
+	"\apartial\x18\x01 \x01(\fR\apartial2c\n" +
+	"\x06Worker\x12(\n" +
+	"\x04Stat\x12\x10.brc.StatRequest\x1a\x0e.brc.StatReply\x12/\n" +
+	"\aProcess\x12\x11.brc.RangeRequest\x1a\x11.brc.PartialReplyB;Z9github.com/robert-ohurley/1-billion-row-challenge/brc;brcb\x06proto3"
+
+var (
+	file_distributed_proto_rawDescOnce sync.Once
+	file_distributed_proto_rawDescData []byte
+)
+
+func file_distributed_proto_rawDescGZIP() []byte {
+	file_distributed_proto_rawDescOnce.Do(func() {
+		file_distributed_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_distributed_proto_rawDesc), len(file_distributed_proto_rawDesc)))
+	})
+	return file_distributed_proto_rawDescData
+}
+
+var file_distributed_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_distributed_proto_goTypes = []any{
+	(*StatRequest)(nil),  // 0: brc.StatRequest
+	(*StatReply)(nil),    // 1: brc.StatReply
+	(*RangeRequest)(nil), // 2: brc.RangeRequest
+	(*PartialReply)(nil), // 3: brc.PartialReply
+}
+var file_distributed_proto_depIdxs = []int32{
+	0, // 0: brc.Worker.Stat:input_type -> brc.StatRequest
+	2, // 1: brc.Worker.Process:input_type -> brc.RangeRequest
+	1, // 2: brc.Worker.Stat:output_type -> brc.StatReply
+	3, // 3: brc.Worker.Process:output_type -> brc.PartialReply
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_distributed_proto_init() }
+func file_distributed_proto_init() {
+	if File_distributed_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_distributed_proto_rawDesc), len(file_distributed_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_distributed_proto_goTypes,
+		DependencyIndexes: file_distributed_proto_depIdxs,
+		MessageInfos:      file_distributed_proto_msgTypes,
+	}.Build()
+	File_distributed_proto = out.File
+	file_distributed_proto_goTypes = nil
+	file_distributed_proto_depIdxs = nil
+}
@@ -0,0 +1,230 @@
+package brc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestCoordinateOverGRPC splits a file into ranges far smaller than it over two workers served on loopback,
+// which must tally the same as processing the file in one go, then checks a worker going away mid run
+// leaves its ranges to the other.
+func TestCoordinateOverGRPC(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(1))
+	var lines bytes.Buffer
+	for range 20_000 {
+		fmt.Fprintf(&lines, "S%d;%.1f\n", rng.Intn(300), float64(rng.Intn(1999)-999)/10)
+	}
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, lines.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := Process([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	total.Print(want)
+
+	root := openRoot(t, filepath.Dir(file))
+	var peers []*peer
+	for range 2 {
+		peers = append(peers, startWorker(t, newWorkerServer(2, root, workerAuth{}), workerAuth{}))
+	}
+
+	tally, err := coordinate(peers, []string{file}, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &bytes.Buffer{}
+	tally.Print(got)
+	if got.String() != want.String() {
+		t.Fatalf("coordinated over gRPC:\n%s\nin one go:\n%s", got, want)
+	}
+
+	//A closed connection fails every call, so that worker is retired on its first range and the other takes
+	//it back. Files are only stat'ed by the first peer, which has to be the one still up.
+	peers[0].conn.Close()
+	if tally, err = coordinate([]*peer{peers[1], peers[0]}, []string{file}, 4096); err != nil {
+		t.Fatal(err)
+	}
+	got.Reset()
+	tally.Print(got)
+	if got.String() != want.String() {
+		t.Fatalf("coordinated with one worker gone:\n%s\nin one go:\n%s", got, want)
+	}
+
+	//A partial that doesn't read retires its worker the same way
+	server := grpc.NewServer()
+	RegisterWorkerServer(server, badPartialServer{})
+	bad := startWorker(t, server, workerAuth{})
+	if tally, err = coordinate([]*peer{peers[1], bad}, []string{file}, 4096); err != nil {
+		t.Fatal(err)
+	}
+	got.Reset()
+	tally.Print(got)
+	if got.String() != want.String() {
+		t.Fatalf("coordinated with a worker sending bad partials:\n%s\nin one go:\n%s", got, want)
+	}
+}
+
+// badPartialServer is a worker that replies to every range with a partial no coordinator can read.
+type badPartialServer struct {
+	UnimplementedWorkerServer
+}
+
+func (badPartialServer) Process(ctx context.Context, req *RangeRequest) (*PartialReply, error) {
+	return &PartialReply{Partial: []byte(PARTIAL_MAGIC + "junk")}, nil
+}
+
+// TestWorkerRoot checks a worker reads files under its root, by relative or absolute path, and refuses any
+// path that leads out of it.
+func TestWorkerRoot(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("A;1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "measurements.txt"), []byte("A;1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := startWorker(t, newWorkerServer(1, openRoot(t, dir), workerAuth{}), workerAuth{})
+	for path, ok := range map[string]bool{
+		"measurements.txt":                             true,
+		filepath.Join(dir, "measurements.txt"):         true,
+		"../" + filepath.Base(outside) + "/secret.txt": false,
+		filepath.Join(outside, "secret.txt"):           false,
+		"link.txt":                                     false,
+	} {
+		_, err := p.client.Stat(context.Background(), &StatRequest{Path: path})
+		if ok && err != nil {
+			t.Errorf("Stat(%s): %v", path, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("Stat(%s) outside the root was allowed", path)
+		}
+
+		_, err = p.client.Process(context.Background(), &RangeRequest{Path: path, Start: 0, End: 6})
+		if !ok && err == nil {
+			t.Errorf("Process(%s) outside the root was allowed", path)
+		}
+	}
+}
+
+// TestWorkerAuth serves a worker over TLS with a token, which only a coordinator sending that token gets
+// an answer from.
+func TestWorkerAuth(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "measurements.txt"), []byte("A;1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, pool := selfSignedCert(t)
+	server := newWorkerServer(1, openRoot(t, dir), workerAuth{"secret", &tls.Config{Certificates: []tls.Certificate{cert}}})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	for token, ok := range map[string]bool{"secret": true, "guess": false} {
+		p, err := dialWorker(listener.Addr().String(), workerAuth{token, &tls.Config{RootCAs: pool}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { p.conn.Close() })
+
+		_, err = p.client.Stat(context.Background(), &StatRequest{Path: "measurements.txt"})
+		if ok && err != nil {
+			t.Errorf("token %s: %v", token, err)
+		}
+		if !ok && status.Code(err) != codes.Unauthenticated {
+			t.Errorf("token %s: got %v, want it refused", token, err)
+		}
+	}
+}
+
+// openRoot opens dir as a worker root closed when t is done.
+func openRoot(t *testing.T, dir string) *os.Root {
+	t.Helper()
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+	return root
+}
+
+// startWorker serves server on loopback until t is done and dials it with auth.
+func startWorker(t *testing.T, server *grpc.Server, auth workerAuth) *peer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	p, err := dialWorker(listener.Addr().String(), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.conn.Close() })
+	return p
+}
+
+// selfSignedCert is a certificate for 127.0.0.1 and a pool trusting it.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
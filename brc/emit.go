@@ -0,0 +1,115 @@
+package brc
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// EMIT_MIN_SLEEP is the smallest lead the emitter sleeps off. Sleeping for less costs more in
+// syscalls and timer slack than it buys in smoothness, the lead is taken back on a later line.
+const EMIT_MIN_SLEEP = time.Millisecond
+
+// emitLines writes every line of r to w at rate lines per second, 0 meaning as fast as w accepts them.
+// The pace is kept against the start time rather than per line, so a slow write or a late wakeup is made up afterwards.
+func emitLines(w *bufio.Writer, r io.Reader, rate int, start time.Time, sent int64) (int64, error) {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		if rate > 0 {
+			due := time.Duration(float64(sent) / float64(rate) * float64(time.Second))
+			if wait := due - clock.Since(start); wait >= EMIT_MIN_SLEEP {
+				//Lines due by now go out before sleeping, not when the buffer happens to fill
+				if err := w.Flush(); err != nil {
+					return sent, err
+				}
+				clock.Sleep(wait)
+			}
+		}
+
+		w.Write(scanner.Bytes())
+		if err := w.WriteByte('\n'); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, scanner.Err()
+}
+
+// dialEmitTarget opens tcp://host:port or unix:///path, - writes to stdout.
+func dialEmitTarget(to string) (io.WriteCloser, error) {
+	if to == "-" {
+		return os.Stdout, nil
+	}
+
+	u, err := url.Parse(to)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		return net.Dial("tcp", u.Host)
+	case "unix":
+		return net.Dial("unix", u.Path)
+	}
+
+	return nil, fmt.Errorf("-to %q: want tcp://host:port, unix:///path or -", to)
+}
+
+func runEmit(args []string) {
+	fs := flag.NewFlagSet("emit", flag.ExitOnError)
+	file := fs.String("file", DEFAULT_INPUT, "measurements `file` to replay")
+	to := fs.String("to", "tcp://localhost:7000", "`destination` to send lines to, tcp://host:port, unix:///path or - for stdout")
+	rate := fs.Int("rate", 0, "lines per second to send, 0 sends as fast as the destination accepts")
+	loops := fs.Int("loop", 1, "replay the file `n` times, 0 repeats until killed")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	if *rate < 0 || *loops < 0 {
+		log.Fatal("-rate and -loop must not be negative")
+	}
+
+	filePtr, err := os.Open(*file)
+	if err != nil {
+		log.Fatal("could not open measurements: ", err)
+	}
+	defer filePtr.Close()
+
+	conn, err := dialEmitTarget(*to)
+	if err != nil {
+		log.Fatal("could not connect: ", err)
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriterSize(conn, SERVE_BATCH_SIZE)
+	start := clock.Now()
+	sent := int64(0)
+
+	for loop := 0; *loops == 0 || loop < *loops; loop++ {
+		if _, err := filePtr.Seek(0, io.SeekStart); err != nil {
+			log.Fatal(err)
+		}
+
+		if sent, err = emitLines(w, filePtr, *rate, start, sent); err != nil {
+			log.Fatal("emit stopped after ", sent, " lines: ", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Fatal("emit stopped after ", sent, " lines: ", err)
+	}
+
+	elapsed := clock.Since(start)
+	slog.Info("emitted", "lines", sent, "elapsed", elapsed.Round(time.Millisecond), "lines_per_s", math.Round(float64(sent)/elapsed.Seconds()))
+}
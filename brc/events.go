@@ -0,0 +1,71 @@
+package brc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ServeSnapshot is the current window's tally as pushed to /events subscribers.
+type ServeSnapshot struct {
+	WindowStart time.Time    `json:"window_start"`
+	Stations    []StationRow `json:"stations"`
+}
+
+// Snapshot returns the stations of the current window so far.
+func (s *Server) Snapshot() ServeSnapshot {
+	s.m.Lock()
+	tally, start := s.tally, s.windowStart
+	s.m.Unlock()
+
+	//Workers may still be merging batches into it
+	tally.m.Lock()
+	defer tally.m.Unlock()
+	return ServeSnapshot{start, tally.Rows("")}
+}
+
+// serveEvents streams a snapshot event as Server-Sent Events on connecting and then every ?every=duration, by
+// default the print interval, until the client goes away. Each is a ServeSnapshot in JSON:
+//
+//	event: snapshot
+//	data: {"window_start":"2024-01-02T03:04:05Z","stations":[{"station":"Abha","min":-23.0,...}]}
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	every := time.Duration(s.Config().Interval)
+	if param := r.URL.Query().Get("every"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("every must be a positive duration, got %q", param), http.StatusBadRequest)
+			return
+		}
+		every = d
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for {
+		data, err := json.Marshal(s.Snapshot())
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		fired, stop := clock.NewTimer(every)
+		select {
+		case <-r.Context().Done():
+			stop()
+			return
+		case <-fired:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package brc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestServeEvents subscribes to serve's /events on a FakeClock, checking the snapshot sent on connecting
+// and the one pushed ?every later once lines have arrived, then that a bad every is refused.
+func TestServeEvents(t *testing.T) {
+	fake := NewFakeClock(time.Unix(0, 0))
+	defer func(c Clock) { clock = c }(clock)
+	clock = fake
+
+	server := NewServer(ServeConfig{Workers: 1, Interval: Duration(time.Second)}, io.Discard)
+	defer server.Close()
+	<-server.changed
+	web := httptest.NewServer(server.AdminHandler())
+	defer web.Close()
+
+	if resp, err := http.Get(web.URL + "/events?every=-1s"); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("/events?every=-1s: got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, err := http.Get(web.URL + "/events?every=2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	next := func() (ServeSnapshot, error) {
+		var snapshot ServeSnapshot
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				return snapshot, err
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return snapshot, json.Unmarshal([]byte(data), &snapshot)
+			}
+		}
+	}
+
+	first, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Stations) != 0 || !first.WindowStart.Equal(fake.Now()) {
+		t.Fatalf("first snapshot: got %+v, want no stations from %v", first, fake.Now())
+	}
+
+	parseLines([]byte("A;1.0\nA;3.0\n"), -1, server.current(), server.parse)
+	fake.BlockUntil(1)
+	fake.Advance(2 * time.Second)
+
+	second, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StationRow{Station: "A", Min: "1.0", Mean: "2.0", Max: "3.0", Count: 2, Stddev: "1.00"}
+	if len(second.Stations) != 1 || fmt.Sprintf("%+v", second.Stations[0]) != fmt.Sprintf("%+v", want) {
+		t.Fatalf("snapshot 2s in: got %+v, want %+v", second.Stations, want)
+	}
+}
@@ -0,0 +1,52 @@
+package brc
+
+import (
+	"expvar"
+	"runtime/metrics"
+	"sync/atomic"
+)
+
+// liveOptions are the Options of the run in progress, the latest to start processFiles, for liveCounters to
+// read its bytes from. Nil before the first.
+var liveOptions atomic.Pointer[Options]
+
+// chunksInFlight counts the chunks pushed to a Scheduler and not yet parsed, queued or on a worker.
+// linesParsed counts the lines taken into any tally, or parsed alone with -phase=parse.
+var chunksInFlight, linesParsed atomic.Int64
+
+// LiveCounters is the brc variable of /debug/vars, polled to follow a long run without a metrics stack:
+//
+//	curl -s localhost:6060/debug/vars | jq .brc
+type LiveCounters struct {
+	BytesRead      int64  `json:"bytes_read"`
+	ChunksInFlight int64  `json:"chunks_in_flight"`
+	LinesParsed    int64  `json:"lines_parsed"`
+	StationsSeen   int    `json:"stations_seen"`
+	GCCycles       uint64 `json:"gc_cycles"`
+}
+
+func liveCounters() any {
+	var c LiveCounters
+	if o := liveOptions.Load(); o != nil {
+		c.BytesRead = o.bytes.Load()
+	}
+	c.ChunksInFlight = chunksInFlight.Load()
+	c.LinesParsed = linesParsed.Load()
+
+	//Every tally interns its stations, so the interner has seen each distinct one whichever worker read it
+	stationNames.m.Lock()
+	c.StationsSeen = len(stationNames.names)
+	stationNames.m.Unlock()
+
+	sample := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		c.GCCycles = sample[0].Value.Uint64()
+	}
+
+	return c
+}
+
+func init() {
+	expvar.Publish("brc", expvar.Func(liveCounters))
+}
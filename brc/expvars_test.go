@@ -0,0 +1,67 @@
+package brc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExpvar processes a file of random stations and checks /debug/vars counted its bytes, lines and stations,
+// with no chunk left in flight.
+func TestExpvar(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	n := 1 + rng.Intn(200)
+	var input strings.Builder
+	for i := range n {
+		fmt.Fprintf(&input, "expvar-%d-%d;%.1f\n", rng.Int63(), i, float64(rng.Intn(1999)-999)/10)
+	}
+
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte(input.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() (LiveCounters, error) {
+		rec := httptest.NewRecorder()
+		http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+		var vars struct{ Brc LiveCounters }
+		err := json.Unmarshal(rec.Body.Bytes(), &vars)
+		return vars.Brc, err
+	}
+
+	before, err := read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := newOptions(WithChunkSize(MIN_CHUNK_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processFiles(o, []string{file}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after.BytesRead != int64(input.Len()) {
+		t.Fatalf("bytes_read %d, want %d", after.BytesRead, input.Len())
+	}
+	if lines := after.LinesParsed - before.LinesParsed; lines != int64(n) {
+		t.Fatalf("lines_parsed went up %d, want %d", lines, n)
+	}
+	if stations := after.StationsSeen - before.StationsSeen; stations != n {
+		t.Fatalf("stations_seen went up %d, want %d", stations, n)
+	}
+	if after.ChunksInFlight != 0 {
+		t.Fatalf("%d chunks in flight after the run", after.ChunksInFlight)
+	}
+}
@@ -0,0 +1,72 @@
+package brc
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var matchStations = commandLine.String("match", "", "only aggregate and report stations whose name matches `regexp`")
+var onlyStations = commandLine.String("stations", "", "only aggregate and report the comma separated station `names`")
+
+// stationFilter is built from -match, -stations and -invalid-utf8, nil keeps every station.
+// It sees station names after -key, so it filters what is grouped and printed.
+var stationFilter func(station []byte) bool
+
+// buildStationFilter combines -match, -stations and -invalid-utf8=reject, a station has to pass every one given.
+func buildStationFilter() (func(station []byte) bool, error) {
+	var filters []func(station []byte) bool
+
+	if *matchStations != "" {
+		re, err := regexp.Compile(*matchStations)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, re.Match)
+	}
+
+	if *onlyStations != "" {
+		names := map[string]bool{}
+		for _, name := range strings.Split(*onlyStations, ",") {
+			names[name] = true
+		}
+		filters = append(filters, func(station []byte) bool {
+			return names[string(station)]
+		})
+	}
+
+	if *invalidUTF8 == "reject" {
+		filters = append(filters, utf8.Valid)
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	return func(station []byte) bool {
+		for _, keep := range filters {
+			if !keep(station) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// cachedFilter wraps stationFilter in a cache private to one parser, so the regexp runs about once
+// per station per chunk instead of once per line, and the parser never contends on a shared cache.
+func cachedFilter() func(station []byte) bool {
+	if stationFilter == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	return func(station []byte) bool {
+		keep, ok := seen[string(station)]
+		if !ok {
+			keep = stationFilter(station)
+			seen[string(station)] = keep
+		}
+		return keep
+	}
+}
@@ -0,0 +1,217 @@
+package brc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// A FlatBuffers encoder and reader for the few tables Arrow IPC metadata needs. Buffers are laid out front to back,
+// every object after the one pointing at it, which keeps each uoffset positive as the format requires.
+
+// fbScalar is a fixed size field of size bytes.
+type fbScalar struct {
+	size  int
+	value uint64
+}
+
+func fbBool(v bool) fbScalar {
+	if v {
+		return fbScalar{1, 1}
+	}
+	return fbScalar{1, 0}
+}
+
+func fbUint8(v uint8) fbScalar { return fbScalar{1, uint64(v)} }
+func fbInt16(v int16) fbScalar { return fbScalar{2, uint64(uint16(v))} }
+func fbInt32(v int32) fbScalar { return fbScalar{4, uint64(uint32(v))} }
+func fbInt64(v int64) fbScalar { return fbScalar{8, uint64(v)} }
+
+// fbTable is a table by field slot, nil leaving a field out. Fields are fbScalar or refer to an fbTable,
+// fbString, fbVector or fbStructs.
+type fbTable []any
+
+type fbString string
+
+// fbVector is a vector of tables or strings.
+type fbVector []any
+
+// fbStructs is a vector of count structs already encoded into data, aligned to align bytes.
+type fbStructs struct {
+	count int
+	align int
+	data  []byte
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbFinish encodes root as a whole buffer.
+func fbFinish(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	at := b.object(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(at))
+	return b.buf
+}
+
+func (b *fbBuilder) pad(align, phase int) {
+	for len(b.buf)%align != phase {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// ref points the uoffset at slot to the object v, written at the end of the buffer.
+func (b *fbBuilder) ref(slot int, v any) {
+	at := b.object(v)
+	binary.LittleEndian.PutUint32(b.buf[slot:], uint32(at-slot))
+}
+
+func (b *fbBuilder) object(v any) int {
+	switch v := v.(type) {
+	case fbTable:
+		return b.table(v)
+	case fbString:
+		b.pad(4, 0)
+		at := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(append(b.buf, v...), 0)
+		return at
+	case fbVector:
+		b.pad(4, 0)
+		at := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		slots := len(b.buf)
+		b.buf = append(b.buf, make([]byte, 4*len(v))...)
+		for i, elem := range v {
+			b.ref(slots+4*i, elem)
+		}
+		return at
+	case fbStructs:
+		//The length comes just before the first struct, which must be aligned
+		b.pad(v.align, (v.align-4%v.align)%v.align)
+		at := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.count))
+		b.buf = append(b.buf, v.data...)
+		return at
+	}
+
+	panic("flatbuffers: unknown object")
+}
+
+// table writes the vtable, then the table: its soffset to the vtable and the fields, biggest first so each
+// is aligned, uoffsets last. Referred objects follow.
+func (b *fbBuilder) table(fields fbTable) int {
+	offsets := make([]int, len(fields))
+	size := 4
+	for _, width := range []int{8, 4, 2, 1} {
+		for i, f := range fields {
+			s, ok := f.(fbScalar)
+			if f == nil || ok && s.size != width || !ok && width != 4 {
+				continue
+			}
+			for size%width != 0 {
+				size++
+			}
+			offsets[i] = size
+			size += width
+		}
+	}
+
+	//The vtable sits right before the table, which starts 8 aligned so its 8 byte fields are too
+	vtable := binary.LittleEndian.AppendUint16(nil, uint16(4+2*len(fields)))
+	vtable = binary.LittleEndian.AppendUint16(vtable, uint16(size))
+	for _, offset := range offsets {
+		vtable = binary.LittleEndian.AppendUint16(vtable, uint16(offset))
+	}
+
+	b.pad(8, (8-len(vtable)%8)%8)
+	vt := len(b.buf)
+	b.buf = append(b.buf, vtable...)
+	b.pad(8, 0)
+
+	at := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(int32(at-vt)))
+
+	for i, f := range fields {
+		if s, ok := f.(fbScalar); ok {
+			for j := 0; j < s.size; j++ {
+				b.buf[at+offsets[i]+j] = byte(s.value >> (8 * j))
+			}
+		}
+	}
+	for i, f := range fields {
+		if _, ok := f.(fbScalar); f != nil && !ok {
+			b.ref(at+offsets[i], f)
+		}
+	}
+
+	return at
+}
+
+// fbReader reads tables out of a flatbuffer by position. The first out of range read sticks as err and
+// returns zeroes, so a corrupt file fails once rather than panicking.
+type fbReader struct {
+	buf []byte
+	err error
+}
+
+var errFlatbufferShort = errors.New("flatbuffers: offset out of range")
+
+func (r *fbReader) bytes(at, n int) []byte {
+	if r.err != nil || at < 0 || n < 0 || at+n > len(r.buf) {
+		r.err = errFlatbufferShort
+		return make([]byte, 8)
+	}
+	return r.buf[at : at+n]
+}
+
+func (r *fbReader) u8(at int) uint8   { return r.bytes(at, 1)[0] }
+func (r *fbReader) u16(at int) uint16 { return binary.LittleEndian.Uint16(r.bytes(at, 2)) }
+func (r *fbReader) u32(at int) uint32 { return binary.LittleEndian.Uint32(r.bytes(at, 4)) }
+func (r *fbReader) u64(at int) uint64 { return binary.LittleEndian.Uint64(r.bytes(at, 8)) }
+
+// root is the position of the buffer's root table.
+func (r *fbReader) root() int {
+	return int(r.u32(0))
+}
+
+// field is the position of slot in the table at table, 0 when the field was left out.
+func (r *fbReader) field(table, slot int) int {
+	vt := table - int(int32(r.u32(table)))
+	if 4+2*slot >= int(r.u16(vt)) {
+		return 0
+	}
+	if offset := int(r.u16(vt + 4 + 2*slot)); offset != 0 {
+		return table + offset
+	}
+	return 0
+}
+
+// deref follows the uoffset at at, 0 staying 0 for a field left out.
+func (r *fbReader) deref(at int) int {
+	if at == 0 {
+		return 0
+	}
+	return at + int(r.u32(at))
+}
+
+// vector is the position of the first element of the vector the uoffset at at refers to, and its length.
+func (r *fbReader) vector(at int) (int, int) {
+	v := r.deref(at)
+	if v == 0 {
+		return 0, 0
+	}
+
+	n := int(r.u32(v))
+	if n > len(r.buf) {
+		r.err = errFlatbufferShort
+		return 0, 0
+	}
+	return v + 4, n
+}
+
+func (r *fbReader) string(at int) string {
+	start, n := r.vector(at)
+	return string(r.bytes(start, n))
+}
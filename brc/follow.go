@@ -0,0 +1,121 @@
+package brc
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+var follow = commandLine.Bool("follow", false, "after reaching EOF keep polling the inputs for appended lines, reporting again whenever they grow")
+var followInterval = commandLine.Duration("follow-interval", time.Second, "how often -follow polls the inputs, `duration`")
+
+// follower tails one input. Only whole lines are parsed, a line still being written is picked up by a later poll.
+type follower struct {
+	name  string
+	tally *Tally
+
+	//start of the first line not parsed yet
+	offset int64
+}
+
+func newFollower(name string) *follower {
+	f := &follower{name: name, tally: NewTally()}
+	f.tally.file = name
+	return f
+}
+
+// poll parses every whole line appended since the last poll, reporting whether there were any.
+// A file that got shorter was truncated or replaced and is read again from the start into a fresh tally.
+func (f *follower) poll(o *Options) (bool, error) {
+	filePtr, err := os.Open(f.name)
+	if err != nil {
+		return false, err
+	}
+	defer filePtr.Close()
+
+	info, err := filePtr.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if info.Size() < f.offset {
+		slog.Warn("input shrank, starting over", "file", f.name, "from", f.offset, "to", info.Size())
+		*f = *newFollower(f.name)
+	}
+
+	end, err := lastLineStart(filePtr, f.offset, info.Size())
+	if err != nil || end == f.offset {
+		return false, err
+	}
+
+	//Optimisation: a big append, the initial read above all, is still split across the parser pool
+	if err := processRange(filePtr, f.offset, end, o.workers, f.tally, o); err != nil {
+		return false, err
+	}
+	f.offset = end
+
+	return true, nil
+}
+
+// lastLineStart returns the offset just past the last line ending in [from, size), or from when there is none.
+func lastLineStart(filePtr *os.File, from, size int64) (int64, error) {
+	buf := make([]byte, 4096)
+
+	for end := size; end > from; {
+		start := max(end-int64(len(buf)), from)
+		n, err := filePtr.ReadAt(buf[:end-start], start)
+		if err != nil {
+			return 0, err
+		}
+
+		//A \r at size might still get its \n
+		if i := lastLineEnd(buf[:n]); i != -1 {
+			return start + int64(i) + 1, nil
+		}
+
+		//Blocks overlap by a byte so a \r\n split between two is seen whole
+		end = start + 1
+		if start == from {
+			break
+		}
+	}
+
+	return from, nil
+}
+
+// runFollow reports the inputs once read to their last whole line, then again after every poll that found
+// more. It only returns on an error.
+func runFollow(files []string, o *Options) error {
+	start := clock.Now()
+	followers := make([]*follower, len(files))
+
+	for i, name := range files {
+		followers[i] = newFollower(name)
+	}
+
+	for {
+		grown := false
+		for _, f := range followers {
+			more, err := f.poll(o)
+			if err != nil {
+				return err
+			}
+			grown = grown || more
+		}
+
+		if grown {
+			total := NewTally()
+			for _, f := range followers {
+				total.Merge(f.tally)
+			}
+
+			report := Report{RunID: *appendRunID, Tally: total, Phases: collectPhases(clock.Since(start))}
+			if err := reporters[*outputFormat](*outputFile).Report(report); err != nil {
+				return err
+			}
+		}
+
+		fired, _ := clock.NewTimer(*followInterval)
+		<-fired
+	}
+}
@@ -0,0 +1,61 @@
+package brc
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var gcTuning = commandLine.String("gc-tuning", "off", "GC `profile` for the run: off (the runtime's defaults or GOGC), low-latency (a small ballast, fewer early collections) or throughput (collect rarely, or only near -max-memory)")
+
+// gcProfile is what a -gc-tuning profile sets. A ballast is a heap allocation that is never touched, so it
+// costs address space but no RAM, and lifts the heap size GOGC paces collections from.
+type gcProfile struct {
+	percent int
+	ballast int
+}
+
+var gcProfiles = map[string]gcProfile{
+	"off":         {},
+	"low-latency": {100, 64 << 20},
+	"throughput":  {400, 256 << 20},
+}
+
+// gcBallast keeps the ballast reachable for the whole run.
+var gcBallast []byte
+
+// applyGCTuning sets -gc-tuning's profile. Under -max-memory throughput turns the GC off altogether and
+// without a ballast, which would count against the limit, the limit alone then triggers collections.
+func applyGCTuning() {
+	p := gcProfiles[*gcTuning]
+	if p.percent == 0 {
+		return
+	}
+
+	if *gcTuning == "throughput" && memoryBudget > 0 {
+		p = gcProfile{-1, 0}
+	}
+
+	//Optimisation: the live heap is a few MiB of tallies and pooled chunks, which GOGC=100 alone would
+	//collect every few MiB allocated
+	debug.SetGCPercent(p.percent)
+	if p.ballast > 0 {
+		gcBallast = make([]byte, p.ballast)
+	}
+
+	slog.Debug("gc tuning", "profile", *gcTuning, "gogc", p.percent, "ballast", p.ballast, "memory_limit", memoryBudget)
+}
+
+// logGCStats logs how much the collector ran, to compare -gc-tuning profiles with -v.
+func logGCStats() {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	slog.Debug("gc", "collections", stats.NumGC, "pause_total", time.Duration(stats.PauseTotalNs),
+		"cpu_fraction", stats.GCCPUFraction, "heap_sys", stats.HeapSys)
+}
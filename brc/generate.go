@@ -0,0 +1,274 @@
+package brc
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GenStation is a station the generator draws readings for, normally distributed around Mean with Stddev in °C.
+type GenStation struct {
+	Name         string
+	Mean, Stddev float64
+}
+
+// Generator writes a measurements file that is the same byte for byte for the same fields. Stations are picked
+// with Zipfian frequencies, the i-th (from 0) of Stations in proportion to 1/(i+1)^Skew, so Skew 0 is uniform
+// and the higher it is the more the first stations crowd out the rest.
+type Generator struct {
+	Stations []GenStation
+	Skew     float64
+	Seed     int64
+
+	//Rows is how many lines to write, or with Size set how many bytes at most, stopping at the first line that
+	//would go past it
+	Rows int64
+	Size int64
+}
+
+// Write writes the measurements to w, returning how many rows and bytes it wrote.
+func (g Generator) Write(w io.Writer) (int64, int64, error) {
+	rng := rand.New(rand.NewSource(g.Seed))
+	bw := bufio.NewWriterSize(w, BUFFER_SIZE)
+
+	//cdf[i] is the weight of the stations up to i, a station is found by where a draw below the total lands
+	cdf := make([]float64, len(g.Stations))
+	total := 0.0
+	for i := range cdf {
+		total += math.Pow(float64(i+1), -g.Skew)
+		cdf[i] = total
+	}
+
+	var rows, bytes int64
+	line := make([]byte, 0, 128)
+	for g.Size > 0 || rows < g.Rows {
+		s := g.Stations[sort.SearchFloat64s(cdf, rng.Float64()*total)]
+
+		//Rounded to tenths first so a reading just below zero isn't written -0.0
+		tenths := math.Round((s.Mean + rng.NormFloat64()*s.Stddev) * 10)
+		tenths = math.Max(-999, math.Min(999, tenths))
+
+		line = append(line[:0], s.Name...)
+		line = append(line, ';')
+		line = strconv.AppendFloat(line, tenths/10, 'f', 1, 64)
+		line = append(line, '\n')
+
+		if g.Size > 0 && bytes+int64(len(line)) > g.Size {
+			break
+		}
+		if _, err := bw.Write(line); err != nil {
+			return rows, bytes, err
+		}
+		rows++
+		bytes += int64(len(line))
+	}
+
+	return rows, bytes, bw.Flush()
+}
+
+// officialMeans lists the official stations as -stations does, each with the mean the challenge's own
+// generator draws its readings around.
+//
+//go:embed stations/official.csv
+var officialMeans string
+
+// officialGenStations returns the official stations, each around its mean in the challenge's generator.
+func officialGenStations() []GenStation {
+	stations, err := readGenStations(strings.NewReader(officialMeans))
+	if err != nil {
+		panic("stations/official.csv: " + err.Error())
+	}
+	return stations
+}
+
+// readGenStations reads the CSV at r, a station a row with an optional mean and stddev after its name and #
+// starting a comment. Missing means and stddevs are NaN. Names must be new, and fit on a line of their own.
+func readGenStations(r io.Reader) ([]GenStation, error) {
+	records := csv.NewReader(r)
+	records.FieldsPerRecord = -1
+	records.Comment = '#'
+
+	var stations []GenStation
+	seen := map[string]bool{}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := records.FieldPos(0)
+		if len(record) > 3 {
+			return nil, fmt.Errorf("line %d: want name, mean and stddev at most, got %d fields", line, len(record))
+		}
+
+		s := GenStation{record[0], math.NaN(), math.NaN()}
+		if s.Name == "" || strings.ContainsAny(s.Name, ";\n") {
+			return nil, fmt.Errorf("line %d: station name %q is empty or has a ; or newline", line, s.Name)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("line %d: %q is listed twice", line, s.Name)
+		}
+		seen[s.Name] = true
+
+		for i, p := range []*float64{&s.Mean, &s.Stddev} {
+			if len(record) <= i+1 || record[i+1] == "" {
+				continue
+			}
+			if *p, err = strconv.ParseFloat(strings.TrimSpace(record[i+1]), 64); err != nil || math.IsNaN(*p) || math.IsInf(*p, 0) {
+				return nil, fmt.Errorf("line %d: %q is not a number", line, record[i+1])
+			}
+		}
+		if s.Stddev < 0 {
+			return nil, fmt.Errorf("line %d: stddev of %q must not be negative", line, s.Name)
+		}
+
+		stations = append(stations, s)
+	}
+	return stations, nil
+}
+
+func readGenStationsFile(name string) ([]GenStation, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stations, err := readGenStations(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stations, nil
+}
+
+// overrideGenStations sets the mean and stddev of every station in overrides that gives them, and fills in
+// mean and stddev where neither stations nor overrides do. Overriding a station that isn't there is an error,
+// a typo would otherwise go unnoticed.
+func overrideGenStations(stations, overrides []GenStation, mean, stddev float64) error {
+	index := map[string]int{}
+	for i, s := range stations {
+		index[s.Name] = i
+	}
+
+	for _, o := range overrides {
+		i, ok := index[o.Name]
+		if !ok {
+			return fmt.Errorf("can't override %q, it isn't one of the stations", o.Name)
+		}
+		if !math.IsNaN(o.Mean) {
+			stations[i].Mean = o.Mean
+		}
+		if !math.IsNaN(o.Stddev) {
+			stations[i].Stddev = o.Stddev
+		}
+	}
+
+	for i := range stations {
+		if math.IsNaN(stations[i].Mean) {
+			stations[i].Mean = mean
+		}
+		if math.IsNaN(stations[i].Stddev) {
+			stations[i].Stddev = stddev
+		}
+	}
+	return nil
+}
+
+// runGenerate writes a measurements file. By default the stations are the official challenge's, each around
+// its own mean as in the challenge, read uniformly: -stations, -overrides and -skew make the mix as lopsided
+// as a real dataset's.
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	out := fs.String("o", "-", "`file` to write, - for stdout")
+	rows := fs.Int64("rows", 1_000_000, "measurement lines to write, `n`")
+	size := fs.String("size", "", "write lines up to a `size` such as 512MiB or 13GB instead of -rows")
+	stationsFile := fs.String("stations", "", "CSV `file` of the stations to draw from, a name with an optional mean and stddev a row (the official 413 by default, each with its mean in the challenge)")
+	overridesFile := fs.String("overrides", "", "CSV `file` of station,mean,stddev rows replacing those of the named stations, either may be left empty")
+	mean := fs.Float64("mean", 10, "mean reading in °C, `t`, of stations given none")
+	stddev := fs.Float64("stddev", 10, "standard deviation of the readings in °C, `t`, of stations given none")
+	skew := fs.Float64("skew", 0, "Zipf `exponent` of the station frequencies in the order listed, 0 for uniform, 1 or more for a few dominating stations")
+	seed := fs.Int64("seed", 1, "random `seed`, the same seed and flags write the same file")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	var errs []string
+	if *rows < 0 {
+		errs = append(errs, fmt.Sprintf("-rows must not be negative, got %d", *rows))
+	}
+	if *skew < 0 {
+		errs = append(errs, fmt.Sprintf("-skew must not be negative, got %v", *skew))
+	}
+	if *stddev < 0 {
+		errs = append(errs, fmt.Sprintf("-stddev must not be negative, got %v", *stddev))
+	}
+	g := Generator{Skew: *skew, Seed: *seed, Rows: *rows}
+	if *size != "" {
+		var err error
+		if g.Size, err = parseBytes(*size); err != nil {
+			errs = append(errs, "-size "+err.Error())
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "rows" {
+				errs = append(errs, "-size and -rows both say when to stop, pick one")
+			}
+		})
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, strings.Join(errs, "\n"))
+		os.Exit(2)
+	}
+
+	var err error
+	if *stationsFile != "" {
+		if g.Stations, err = readGenStationsFile(*stationsFile); err != nil {
+			log.Fatal("could not read -stations: ", err)
+		}
+		if len(g.Stations) == 0 {
+			log.Fatalf("-stations %s lists no stations", *stationsFile)
+		}
+	} else {
+		g.Stations = officialGenStations()
+	}
+
+	var overrides []GenStation
+	if *overridesFile != "" {
+		if overrides, err = readGenStationsFile(*overridesFile); err != nil {
+			log.Fatal("could not read -overrides: ", err)
+		}
+	}
+	if err := overrideGenStations(g.Stations, overrides, *mean, *stddev); err != nil {
+		log.Fatal("-overrides: ", err)
+	}
+
+	var w io.WriteCloser = os.Stdout
+	if *out != "-" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatal("could not create measurements: ", err)
+		}
+	}
+
+	written, bytes, err := g.Write(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal("could not write measurements: ", err)
+	}
+
+	slog.Info("generated", "file", *out, "rows", written, "bytes", bytes, "stations", len(g.Stations))
+}
@@ -0,0 +1,113 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestGenerate checks the generator writes the same file for the same seed, parses back as many rows as it
+// says, stops short of -size, keeps an overridden station at its mean and favours the first stations by -skew.
+func TestGenerate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	stations, err := readGenStations(strings.NewReader("# name,mean,stddev\nHot,40,0\nCold,-20\nWarm,,5\n" +
+		"A\nB\nC\nD\nE\nF\nG\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := readGenStations(strings.NewReader("Cold,,0\nG,12.3,0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := overrideGenStations(stations, overrides, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := overrideGenStations(stations, []GenStation{{"Nowhere", 1, 1}}, 10, 10); err == nil {
+		t.Fatalf("overriding a station not listed was let through")
+	}
+
+	generate := func(g Generator) ([]byte, error) {
+		var out bytes.Buffer
+		rows, n, err := g.Write(&out)
+		if err == nil && (n != int64(out.Len()) || rows != int64(bytes.Count(out.Bytes(), []byte{'\n'}))) {
+			err = fmt.Errorf("Write said %d rows, %d bytes, wrote %d lines, %d bytes", rows, n, bytes.Count(out.Bytes(), []byte{'\n'}), out.Len())
+		}
+		return out.Bytes(), err
+	}
+
+	seed := rng.Int63()
+	g := Generator{Stations: stations, Skew: 1.5, Seed: seed, Rows: 20_000}
+	first, err := generate(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := generate(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, again) {
+		t.Fatalf("seed %d wrote two different files", seed)
+	}
+
+	o, err := newOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tally := NewTally()
+	if err := streamInto(bytes.NewReader(first), "generated", tally, o); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, row := range tally.Rows("") {
+		counts[row.Station] = row.Count
+		if mean, ok := map[string]json.Number{"Hot": "40.0", "Cold": "-20.0", "G": "12.3"}[row.Station]; ok && (row.Min != mean || row.Max != mean) {
+			t.Fatalf("%s ranged %v to %v, want only its mean", row.Station, row.Min, row.Max)
+		}
+	}
+	if rows := tally.Summary(0, 0).Rows; rows != 20_000 {
+		t.Fatalf("parsed %d rows back, want 20000", rows)
+	}
+
+	//Hot is the first of 10 stations, 1/H(10, 1.5) of the rows, about 0.502
+	if share := float64(counts["Hot"]) / 20_000; math.Abs(share-0.502) > 0.02 || counts["Hot"] < counts["Cold"] || counts["Cold"] < counts["G"] {
+		t.Fatalf("-skew 1.5 gave the stations %v, want Hot about half and each less than the last", counts)
+	}
+
+	size := int64(1000 + rng.Intn(100_000))
+	sized, err := generate(Generator{Stations: stations, Seed: seed, Size: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := int64(len(sized)); n > size || n < size-20 {
+		t.Fatalf("-size %d wrote %d bytes", size, n)
+	}
+}
+
+// TestOfficialGenStations checks the generator's default stations are the official ones in order, each around
+// a mean of its own rather than one shared by all.
+func TestOfficialGenStations(t *testing.T) {
+	stations := officialGenStations()
+	names := strings.Split(strings.TrimSuffix(officialList, "\n"), "\n")
+	if len(stations) != len(names) {
+		t.Fatalf("%d stations with means, %d official stations", len(stations), len(names))
+	}
+
+	means := map[float64]bool{}
+	for i, s := range stations {
+		if s.Name != names[i] {
+			t.Fatalf("station %d is %q, want %q", i, s.Name, names[i])
+		}
+		if math.IsNaN(s.Mean) || s.Mean < -99.9 || s.Mean > 99.9 || !math.IsNaN(s.Stddev) {
+			t.Fatalf("%s has mean %v and stddev %v, want a mean in range and -stddev's", s.Name, s.Mean, s.Stddev)
+		}
+		means[s.Mean] = true
+	}
+	if len(means) < len(stations)/10 {
+		t.Fatalf("%d stations share %d means", len(stations), len(means))
+	}
+}
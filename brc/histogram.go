@@ -0,0 +1,91 @@
+package brc
+
+import (
+	"strconv"
+	"strings"
+)
+
+var histogramBuckets = commandLine.Int("histogram", 0, "keep a histogram of `n` buckets over -99.9..99.9 per station for the median and pNN -agg-fns, exact to the tenth from 1999 buckets up to 2000 (0 keeps none)")
+
+// MAX_HISTOGRAM_BUCKETS is one bucket per tenth of the challenge's range, and one to spare.
+const MAX_HISTOGRAM_BUCKETS = 2000
+
+// histogram counts the readings of one station by bucket, the range being split evenly between -histogram
+// buckets and readings outside it counted in the end buckets. A bucket of a single reading value gives exact
+// percentiles, wider ones the middle of the bucket.
+// Counts are uint32, a station would need 4 billion readings to wrap one.
+type histogram struct {
+	counts []uint32
+}
+
+// newHistogram returns an empty histogram, or nil when -histogram keeps none and AddAt pays one nil check.
+func newHistogram() *histogram {
+	if *histogramBuckets == 0 {
+		return nil
+	}
+	return &histogram{make([]uint32, *histogramBuckets)}
+}
+
+// histogramRange is the lowest reading and how many reading values -99.9..99.9 spans at readingScale.
+func histogramRange() (lo, span int) {
+	lo = -999 * readingScale / 10
+	return lo, 2*-lo + 1
+}
+
+func (h *histogram) add(temp int) {
+	lo, span := histogramRange()
+	v := min(max(temp-lo, 0), span-1)
+	h.counts[v*len(h.counts)/span]++
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+}
+
+// value returns the reading of rank rank, 1 being the lowest, or the middle of its bucket, clamped to r's
+// min and max so the end buckets never report a value no reading had.
+func (h *histogram) value(r *StationResult, rank int) int {
+	lo, span := histogramRange()
+	n := len(h.counts)
+
+	bucket, seen := 0, 0
+	for ; bucket < n-1; bucket++ {
+		if seen += int(h.counts[bucket]); seen >= rank {
+			break
+		}
+	}
+
+	//The values v-lo landing in bucket b are those with (v-lo)*n/span == b
+	first := lo + (bucket*span+n-1)/n
+	last := lo + ((bucket+1)*span+n-1)/n - 1
+	return min(max((first+last)/2, r.min), r.max)
+}
+
+// percentile formats the nearest rank pth percentile of r's readings, the median the mean of the two middle
+// readings of an even count.
+func percentile(r *StationResult, p int) string {
+	if r.hist == nil || r.count == 0 {
+		return "NaN"
+	}
+
+	unit := outputUnit()
+	if p == 50 && r.count%2 == 0 {
+		return formatValue(unit.mean(r.hist.value(r, r.count/2)+r.hist.value(r, r.count/2+1), 2))
+	}
+	return formatValue(unit.value(r.hist.value(r, max(1, (p*r.count+99)/100))))
+}
+
+// parsePercentile reads a pNN aggregate, p1 to p99, or median as p50.
+func parsePercentile(name string) (int, bool) {
+	if name == "median" {
+		return 50, true
+	}
+
+	p, err := strconv.Atoi(strings.TrimPrefix(name, "p"))
+	if !strings.HasPrefix(name, "p") || err != nil || p < 1 || p > 99 {
+		return 0, false
+	}
+	return p, true
+}
@@ -0,0 +1,58 @@
+package brc
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// TestHistogram checks median and pNN from histograms merged across tallies against the sorted readings:
+// exactly with MAX_HISTOGRAM_BUCKETS, and to within a bucket of the reading with fewer.
+func TestHistogram(t *testing.T) {
+	defer func(n int) { *histogramBuckets = n }(*histogramBuckets)
+
+	rng := rand.New(rand.NewSource(1))
+
+	for _, buckets := range []int{MAX_HISTOGRAM_BUCKETS, 1999, 200, 7} {
+		*histogramBuckets = buckets
+
+		for range 50 {
+			readings := make([]int, 1+rng.Intn(500))
+			tallies := []*Tally{NewTally(), NewTally(), NewTally()}
+			for i := range readings {
+				readings[i] = rng.Intn(1999) - 999
+				if rng.Intn(10) == 0 {
+					readings[i] = rng.Intn(21) - 10
+				}
+				tallies[rng.Intn(len(tallies))].Get([]byte("A")).Add(readings[i])
+			}
+			total := NewTally()
+			for _, tally := range tallies {
+				total.Merge(tally)
+			}
+			slices.Sort(readings)
+			r, _ := total.Lookup("A")
+
+			for _, p := range []int{1, 25, 50, 90, 99} {
+				want := readings[max(1, (p*len(readings)+99)/100)-1]
+				if p == 50 && len(readings)%2 == 0 {
+					want = meanTenths(readings[len(readings)/2-1]+readings[len(readings)/2], 2)
+				}
+
+				got, err := strconv.ParseFloat(percentile(r, p), 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tolerance := 0.0
+				if buckets < 1999 {
+					tolerance = math.Ceil(1999.0/float64(buckets)) / 10
+				}
+				if math.Abs(got-float64(want)/10) > tolerance+1e-9 {
+					t.Fatalf("-histogram=%d p%d of %d readings: got %v, want %s", buckets, p, len(readings), got, formatTenths(want))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package brc
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+var hugePages = commandLine.String("huge-pages", "off", "huge page `mode` behind -strategy=mmap, fewer TLB misses on a big file (Linux only): off, transparent (MADV_HUGEPAGE on the file's mapping, taken where the filesystem's page cache has huge pages) or explicit (copy the file into hugetlbfs pages reserved in /proc/sys/vm/nr_hugepages, falling back to transparent)")
+
+var hugePageModes = []string{"off", "transparent", "explicit"}
+
+// HUGE_PAGE_SIZE is the default huge page size on amd64 and arm64, an explicit mapping is a whole number of them.
+const HUGE_PAGE_SIZE = 2 << 20
+
+// Each fallback is logged once rather than for every file.
+var explicitHugePagesUnavailable, transparentHugePagesUnavailable sync.Once
+
+// mapHuge maps filePtr for MmapStrategy under -huge-pages. copied reports the data being a copy of the file in
+// anonymous huge pages rather than its pages in the page cache.
+func mapHuge(filePtr *os.File, size int) (data []byte, copied bool, err error) {
+	if *hugePages == "explicit" {
+		data, err := mapHugetlb(size)
+		if err == nil {
+			//Optimisation: one sequential read fills the pages, after that parsing never faults
+			if _, err := io.ReadFull(io.NewSectionReader(filePtr, 0, int64(size)), data[:size]); err != nil {
+				munmapFile(data)
+				return nil, false, err
+			}
+			return data[:size], true, nil
+		}
+		explicitHugePagesUnavailable.Do(func() {
+			slog.Info("no hugetlbfs pages to copy into, using transparent huge pages instead", "err", err, "see", "/proc/sys/vm/nr_hugepages")
+		})
+	}
+
+	data, err = mmapFile(filePtr, size)
+	if err != nil || *hugePages == "off" {
+		return data, false, err
+	}
+
+	if err := adviseHugePages(data); err != nil {
+		transparentHugePagesUnavailable.Do(func() { slog.Info("transparent huge pages unavailable, using normal pages", "err", err) })
+	}
+	return data, false, nil
+}
@@ -0,0 +1,18 @@
+//go:build linux && !arm
+
+package brc
+
+import "syscall"
+
+const hugePagesSupported = true
+
+// mapHugetlb reserves anonymous huge pages for size bytes up front, so running out is ENOMEM here rather than
+// SIGBUS on first touch.
+func mapHugetlb(size int) ([]byte, error) {
+	size = (size + HUGE_PAGE_SIZE - 1) &^ (HUGE_PAGE_SIZE - 1)
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS|syscall.MAP_HUGETLB)
+}
+
+func adviseHugePages(data []byte) error {
+	return syscall.Madvise(data, syscall.MADV_HUGEPAGE)
+}
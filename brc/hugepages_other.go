@@ -0,0 +1,17 @@
+//go:build !linux || arm
+
+package brc
+
+import "errors"
+
+const hugePagesSupported = false
+
+var errNoHugePages = errors.New("huge pages are only supported on Linux")
+
+func mapHugetlb(size int) ([]byte, error) {
+	return nil, errNoHugePages
+}
+
+func adviseHugePages(data []byte) error {
+	return errNoHugePages
+}
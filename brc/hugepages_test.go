@@ -0,0 +1,42 @@
+package brc
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// TestHugePages maps random files over a couple of huge pages in every -huge-pages mode, which must only change
+// the pages the mapping is backed by.
+func TestHugePages(t *testing.T) {
+	if !hugePagesSupported || !mmapSupported {
+		t.Skip("no huge pages on " + runtime.GOOS)
+	}
+	defer func(mode string) { *hugePages = mode }(*hugePages)
+
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 2, rng.Intn(2*HUGE_PAGE_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*hugePages = "off"
+	want, err := runPipeline(MmapStrategy{}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Without huge pages reserved or transparent ones enabled these check the fallbacks
+	for _, mode := range hugePageModes[1:] {
+		*hugePages = mode
+		got, err := runPipeline(MmapStrategy{}, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("-huge-pages=%s gave %q, normal pages %q", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package brc
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UPSTREAM_SAMPLES is where the official repository keeps its sample inputs and expected outputs.
+const UPSTREAM_SAMPLES = "src/test/resources/samples"
+
+// samplePairs collects the *.txt / *.out pairs of a samples directory from files, keyed by file name.
+// A .txt without its .out is useless as a golden and is skipped.
+func samplePairs(files map[string][]byte) map[string][]byte {
+	pairs := map[string][]byte{}
+
+	for name, content := range files {
+		if path.Ext(name) != ".txt" {
+			continue
+		}
+
+		golden, ok := files[strings.TrimSuffix(name, ".txt")+".out"]
+		if !ok {
+			continue
+		}
+
+		//Goldens are compared byte for byte against Print, which ends the line with exactly one newline
+		pairs[name] = content
+		pairs[strings.TrimSuffix(name, ".txt")+".out"] = append(bytes.TrimRight(golden, " \r\n"), '\n')
+	}
+
+	return pairs
+}
+
+// samplesFromDir reads the samples of a checkout, or of a samples directory given directly.
+func samplesFromDir(dir string) (map[string][]byte, error) {
+	if info, err := os.Stat(filepath.Join(dir, UPSTREAM_SAMPLES)); err == nil && info.IsDir() {
+		dir = filepath.Join(dir, UPSTREAM_SAMPLES)
+	}
+
+	files := map[string][]byte{}
+	err := fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.Contains(name, "/") {
+			return err
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		files[name] = content
+		return err
+	})
+
+	return files, err
+}
+
+// samplesFromURL downloads a gzipped tarball of the repository and reads the samples out of it.
+// A plain GitHub repository URL is turned into its HEAD archive.
+func samplesFromURL(url string) (map[string][]byte, error) {
+	if strings.HasPrefix(url, "https://github.com/") && !strings.HasSuffix(url, ".tar.gz") {
+		url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git") + "/archive/HEAD.tar.gz"
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		//Archives have a top level <repo>-<ref>/ directory in front of the repository paths
+		dir, name := path.Split(header.Name)
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(strings.TrimSuffix(dir, "/"), UPSTREAM_SAMPLES) {
+			continue
+		}
+
+		if files[name], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runImportTests copies the official samples into testdata, where TestGolden picks up every .txt with
+// a .out golden. They are prefixed so they never overwrite this repository's own fixtures of the same name.
+func runImportTests(args []string) {
+	fs := flag.NewFlagSet("import-tests", flag.ExitOnError)
+	from := fs.String("from", "https://github.com/gunnarmorling/1brc", "checkout `path`, samples directory or repository URL to import from")
+	to := fs.String("to", "brc/testdata", "`directory` to write the fixtures to")
+	prefix := fs.String("prefix", "upstream-", "`prefix` for imported file names")
+	fs.Parse(args)
+
+	var files map[string][]byte
+	var err error
+
+	if strings.HasPrefix(*from, "http://") || strings.HasPrefix(*from, "https://") {
+		files, err = samplesFromURL(*from)
+	} else {
+		files, err = samplesFromDir(*from)
+	}
+	if err != nil {
+		log.Fatal("could not read samples: ", err)
+	}
+
+	pairs := samplePairs(files)
+	if len(pairs) == 0 {
+		log.Fatalf("no .txt/.out sample pairs found in %s", *from)
+	}
+
+	names := sortedKeys(pairs)
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(*to, *prefix+name), pairs[name], 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	slog.Info("imported samples, go test runs them from now on", "samples", len(pairs)/2, "dir", *to)
+}
@@ -0,0 +1,184 @@
+package brc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const DEFAULT_INPUT = "./test_measurements.txt"
+
+// stringsFlag collects every occurrence of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+var inputs stringsFlag
+
+func init() {
+	commandLine.Var(&inputs, "input", "measurements `file`, glob pattern or http(s), s3 or gs URL, may be repeated (default "+DEFAULT_INPUT+")")
+}
+
+// inputPatterns returns every -input plus any positional arguments.
+func inputPatterns() []string {
+	patterns := append(append([]string{}, inputs...), commandLine.Args()...)
+
+	if len(patterns) == 0 {
+		patterns = []string{DEFAULT_INPUT}
+	}
+
+	return patterns
+}
+
+// inputFiles expands the input patterns into file names, in the order given.
+// A plain file name is passed through untouched so a missing file is reported when it is opened.
+func inputFiles() ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+
+	for _, pattern := range inputPatterns() {
+		//A URL is a single input, a ? in its query string is not a glob
+		if isURL(pattern) {
+			if !seen[pattern] {
+				seen[pattern] = true
+				files = append(files, pattern)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad -input pattern %q: %w", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			if strings.ContainsAny(pattern, "*?[") {
+				return nil, fmt.Errorf("-input pattern %q matched no files", pattern)
+			}
+			matches = []string{pattern}
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// processFiles runs strategy over every file concurrently (in turn with -serial), each into its own Tally.
+// The returned tallies are in the same order as files.
+func processFiles(o *Options, files []string) ([]*Tally, error) {
+	span := startSpan("process", runSpan)
+	defer span.end(intAttribute("brc.files", int64(len(files))))
+	liveOptions.Store(o)
+
+	tallies := make([]*Tally, len(files))
+	errs := make([]error, len(files))
+	wg := &sync.WaitGroup{}
+
+	for i, name := range files {
+		tallies[i] = NewTally()
+		tallies[i].file = name
+
+		if o.serial {
+			errs[i] = tracedProcessFile(o, name, tallies[i], span)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			errs[i] = tracedProcessFile(o, name, tallies[i], span)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return tallies, errors.Join(errs...)
+}
+
+// tracedProcessFile is processFile in a span of its own under parent.
+func tracedProcessFile(o *Options, name string, tally *Tally, parent *otelSpan) error {
+	span := startSpan("file", parent)
+	err := processFile(o, name, tally)
+	if err != nil {
+		span.end(stringAttribute("brc.file", name), stringAttribute("error", err.Error()))
+	} else {
+		span.end(stringAttribute("brc.file", name))
+	}
+	return err
+}
+
+func processFile(o *Options, name string, tally *Tally) error {
+	slog.Debug("opening input", "name", name)
+
+	if isURL(name) {
+		return processURL(name, tally, o)
+	}
+
+	filePtr, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("Error reading file: %w", err)
+	}
+	defer filePtr.Close()
+
+	//Converted and Parquet files are told apart by their magic, whatever -strategy says
+	info, err := filePtr.Stat()
+	if err != nil {
+		return err
+	}
+
+	adviseFile(filePtr)
+	if o.verify != "" {
+		tally.digest = sha256.New()
+	}
+	sampled := false
+	switch {
+	case o.phase != PHASE_ALL && (isBinary(filePtr) || isParquet(filePtr, info.Size())):
+		err = fmt.Errorf("-phase only takes text inputs apart")
+	case o.verify != "" && (isBinary(filePtr) || isParquet(filePtr, info.Size())):
+		err = fmt.Errorf("-verify-sha256 hashes text inputs as they stream, not converted or Parquet files")
+	case isBinary(filePtr):
+		err = processBinary(filePtr, tally, o)
+	case isParquet(filePtr, info.Size()):
+		err = processParquet(filePtr, info.Size(), tally, o)
+	case o.sample != 0 && info.Mode().IsRegular():
+		sampled = true
+		err = processSampled(filePtr, info.Size(), tally, o)
+	case sliced():
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s: -offset and -limit need a regular file", name)
+		}
+		err = processSlice(filePtr, info.Size(), tally, o)
+	case o.directIO:
+		err = processDirect(name, tally, o)
+	default:
+		err = o.strategy.Process(filePtr, tally, o)
+	}
+	if !sampled {
+		countWhole(info.Size(), o)
+	}
+	if err == nil {
+		err = verifyInput(name, tally.digest, o)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package brc
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// stationInterner gives each distinct station name one string and a small integer handle, shared by every tally
+// in the process, so a name is converted from the bytes it was read as once rather than once per tally. Tallies
+// carry the handle of each of their stations and Merge matches stations by it, never by name.
+// Names are kept for the life of the process, which only a long running serve sent ever new stations notices.
+type stationInterner struct {
+	m       sync.Mutex
+	handles map[string]int32
+	names   []string
+
+	//block names are copied into with -preset=max
+	arena []byte
+}
+
+var stationNames = &stationInterner{handles: map[string]int32{}}
+
+// internStation returns the handle and the string of station, making them on first sight.
+func internStation[S string | []byte](s *stationInterner, station S) (int32, string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if handle, ok := s.handles[string(station)]; ok {
+		return handle, s.names[handle]
+	}
+
+	var name string
+	if internKeys {
+		name = s.copyToArena([]byte(station))
+	} else {
+		name = string(station)
+	}
+
+	handle := int32(len(s.names))
+	s.handles[name] = handle
+	s.names = append(s.names, name)
+	return handle, name
+}
+
+// copyToArena copies station into the arena and returns a string viewing it. The arena is append only and
+// a full block is never written again, so the string is as immutable as any other.
+// Optimisation: one allocation per ARENA_BLOCK of names instead of one per station. Call with s.m held.
+func (s *stationInterner) copyToArena(station []byte) string {
+	if len(station) == 0 {
+		return ""
+	}
+
+	if len(s.arena)+len(station) > cap(s.arena) {
+		s.arena = make([]byte, 0, max(ARENA_BLOCK, len(station)))
+	}
+
+	start := len(s.arena)
+	s.arena = append(s.arena, station...)
+	return unsafe.String(&s.arena[start], len(station))
+}
@@ -0,0 +1,6 @@
+//go:build !brcdebug
+
+package brc
+
+// checkNotRetained is a no-op outside debug builds, see invariants_debug.go.
+func checkNotRetained(mapped []byte, tally *Tally) {}
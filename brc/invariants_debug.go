@@ -0,0 +1,29 @@
+//go:build brcdebug
+
+package brc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// checkNotRetained panics if any key of tally points into mapped, which is about to be unmapped.
+// Parsers see stations as sub slices of the mapping and must copy or intern them before they outlive
+// the chunk, this catches one that forgot. Build with -tags brcdebug to enable it.
+func checkNotRetained(mapped []byte, tally *Tally) {
+	if len(mapped) == 0 {
+		return
+	}
+
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(mapped)))
+	end := start + uintptr(len(mapped))
+
+	tally.m.Lock()
+	defer tally.m.Unlock()
+
+	for _, name := range tally.names {
+		if p := uintptr(unsafe.Pointer(unsafe.StringData(name))); len(name) > 0 && p >= start && p < end {
+			panic(fmt.Sprintf("station %q retains offset %d of the mapping past munmap", name, p-start))
+		}
+	}
+}
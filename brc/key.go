@@ -0,0 +1,151 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyFunc maps the raw station bytes of a line to the key it is aggregated under.
+// It runs on the parser's bytes before the station is looked up in the Tally, so custom grouping
+// doesn't need a fork of the parser. It must not modify or retain station (it may point into a read only mapping):
+// either return a sub slice of station or append the rewritten key to dst, which the parser reuses between lines.
+type KeyFunc func(dst, station []byte) []byte
+
+var keyName = commandLine.String("key", "name", "how stations are grouped: name, first-token or lower")
+var foldCase = commandLine.Bool("fold-case", false, "aggregate stations differing only in case as one, under the lower case name, Unicode aware unlike -key=lower")
+
+var keyFuncs = map[string]KeyFunc{
+	"name":        nil,
+	"first-token": FirstTokenKey,
+	"lower":       LowerASCIIKey,
+}
+
+// FirstTokenKey groups by the station name up to its first space, e.g. "St. John's" under "St.".
+func FirstTokenKey(dst, station []byte) []byte {
+	if i := bytes.IndexByte(station, ' '); i != -1 {
+		return station[:i]
+	}
+	return station
+}
+
+// LowerASCIIKey groups names differing only in ASCII case.
+func LowerASCIIKey(dst, station []byte) []byte {
+	for _, c := range station {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// FoldCaseKey wraps key so it sees every station case folded to lower case, Zürich and ZÜRICH as zürich.
+// Optimisation: ASCII is lowered eight bytes at a time and a name already in lower case ASCII is returned
+// as it is, only other runes go through foldRune.
+func FoldCaseKey(key KeyFunc) KeyFunc {
+	foldTable()
+
+	return func(dst, station []byte) []byte {
+		i := 0
+		for i+8 <= len(station) {
+			if word := binary.LittleEndian.Uint64(station[i:]); word&ASCII_HIGH_BITS != 0 || upperASCII(word) != 0 {
+				break
+			}
+			i += 8
+		}
+		for i < len(station) && station[i] < utf8.RuneSelf && (station[i] < 'A' || station[i] > 'Z') {
+			i++
+		}
+
+		if i < len(station) {
+			folded := appendFolded(append(dst, station[:i]...), station[i:])
+			station, dst = folded, folded[len(folded):]
+		}
+
+		if key == nil {
+			return station
+		}
+		return key(dst, station)
+	}
+}
+
+// ASCII_HIGH_BITS is the top bit of every byte of a word, none are set in eight ASCII bytes.
+const ASCII_HIGH_BITS = 0x8080808080808080
+
+// upperASCII has the top bit set of each byte of word, all ASCII, that is A to Z. Adding 0x3F sets the top
+// bit of the bytes from A up and adding 0x25 of those past Z, neither carrying into the next byte.
+func upperASCII(word uint64) uint64 {
+	return (word + 0x3F3F3F3F3F3F3F3F) &^ (word + 0x2525252525252525) & ASCII_HIGH_BITS
+}
+
+// appendFolded appends station folded to lower case to dst.
+func appendFolded(dst, station []byte) []byte {
+	for i := 0; i < len(station); {
+		if i+8 <= len(station) {
+			if word := binary.LittleEndian.Uint64(station[i:]); word&ASCII_HIGH_BITS == 0 {
+				//The top bit shifted down to 0x20 is the difference between A and a
+				dst = binary.LittleEndian.AppendUint64(dst, word|upperASCII(word)>>2)
+				i += 8
+				continue
+			}
+		}
+
+		c := station[i]
+		if c < utf8.RuneSelf {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			dst = append(dst, c)
+			i++
+			continue
+		}
+
+		//Bytes that aren't UTF-8 are kept as they are
+		r, size := utf8.DecodeRune(station[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, c)
+		} else {
+			dst = utf8.AppendRune(dst, foldRune(r))
+		}
+		i += size
+	}
+	return dst
+}
+
+// foldRune lowers the lowest rune of r's case orbit, so every rune of an orbit folds to the same one: final
+// and medial sigma to σ, the Kelvin sign to k. A rune without an orbit, such as the Turkish dotless ı, stays itself.
+func foldRune(r rune) rune {
+	if r < FOLD_TABLE_SIZE {
+		return rune(foldTable()[r])
+	}
+	return foldOrbit(r)
+}
+
+func foldOrbit(r rune) rune {
+	//İ lowers to i but isn't in i's orbit, so it has to stay itself for the key to mean the same name
+	if unicode.SimpleFold(r) == r {
+		return r
+	}
+
+	lowest := r
+	for o := unicode.SimpleFold(r); o != r; o = unicode.SimpleFold(o) {
+		lowest = min(lowest, o)
+	}
+	return unicode.ToLower(lowest)
+}
+
+// FOLD_TABLE_SIZE covers the Basic Multilingual Plane, whose runes all fold to runes within it.
+const FOLD_TABLE_SIZE = 0x10000
+
+// foldTable is foldOrbit of every rune below FOLD_TABLE_SIZE, built on first use.
+// Optimisation: the Unicode tables are binary searched for every rune of an orbit, a lookup here is one load.
+var foldTable = sync.OnceValue(func() *[FOLD_TABLE_SIZE]uint16 {
+	table := &[FOLD_TABLE_SIZE]uint16{}
+	for r := range rune(FOLD_TABLE_SIZE) {
+		table[r] = uint16(foldOrbit(r))
+	}
+	return table
+})
@@ -0,0 +1,86 @@
+package brc
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+var foldRunes = []rune("abzABZ09 .'-äöüÄÖÜßẞσςΣkKKıIİi日本😀Ǆǅǆ")
+
+// TestFoldCase checks that FoldCaseKey maps random names, long enough to take the eight byte paths, to a
+// key equal to the name under Unicode case folding, the same key for every casing of the name and to itself,
+// then that -fold-case aggregates every casing as one.
+func TestFoldCase(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	recase := func(name string) string {
+		runes := []rune(name)
+		for i, r := range runes {
+			for range rng.Intn(3) {
+				r = unicode.SimpleFold(r)
+			}
+			runes[i] = r
+		}
+		return string(runes)
+	}
+
+	key := FoldCaseKey(nil)
+	names := make([]string, 50)
+	for i := range names {
+		runes := make([]rune, 1+rng.Intn(30))
+		for j := range runes {
+			runes[j] = foldRunes[rng.Intn(len(foldRunes))]
+		}
+		names[i] = string(runes)
+
+		want := string(key(nil, []byte(names[i])))
+		if !strings.EqualFold(want, names[i]) {
+			t.Fatalf("%+q folds to %+q, not the same name under case folding", names[i], want)
+		}
+		if again := string(key(nil, []byte(want))); again != want {
+			t.Fatalf("%+q folds to %+q, which folds to %+q", names[i], want, again)
+		}
+		for range 10 {
+			other := recase(names[i])
+			if got := string(key([]byte("dst"), []byte(other))); got != want && got != "dst"+want {
+				t.Fatalf("%+q folds to %+q, want %+q as %+q does", other, got, want, names[i])
+			}
+		}
+	}
+	if got := string(key(nil, []byte("Bad\xffNAME"))); got != "bad\xffname" {
+		t.Fatalf("%+q folds to %+q, want the byte that isn't UTF-8 kept", "Bad\xffNAME", got)
+	}
+
+	dir := t.TempDir()
+
+	var sb strings.Builder
+	want := map[string]int{}
+	for range 5000 {
+		name := names[rng.Intn(len(names))]
+		fmt.Fprintf(&sb, "%s;1.0\n", recase(name))
+		want[string(key(nil, []byte(name)))]++
+	}
+	file := dir + "/names.txt"
+	if err := os.WriteFile(file, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tally, err := Process([]string{file}, WithKey(FoldCaseKey(keyFuncs["name"])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tally.names) != len(want) {
+		t.Fatalf("-fold-case aggregated %d stations, want %d", len(tally.names), len(want))
+	}
+	for name, count := range want {
+		if r, ok := tally.Lookup(name); !ok {
+			t.Fatalf("-fold-case: %+q wasn't aggregated", name)
+		} else if r.count != count {
+			t.Fatalf("-fold-case: %+q was counted %d times, want %d", name, r.count, count)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Lines may end in \n, \r\n (files written on Windows) or a bare \r (classic Mac), even mixed within one file.
+// The \r is never part of the line handed to the parser, where it would otherwise end up in the temperature.
+
+// scanLines is a bufio.SplitFunc like bufio.ScanLines that also ends a line at a bare \r.
+// Optimisation: the \n is found first and only the line before it is searched for a \r, so \n files pay
+// for one extra IndexByte over a handful of bytes.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '\n'); i != -1 {
+		switch j := bytes.IndexByte(data[:i], '\r'); {
+		case j == -1:
+			return i + 1, data[:i], nil
+		case j == i-1:
+			return i + 1, data[:j], nil
+		default:
+			return j + 1, data[:j], nil
+		}
+	}
+
+	//A \r as the last byte may yet be followed by a \n
+	if j := bytes.IndexByte(data, '\r'); j != -1 && (j < len(data)-1 || atEOF) {
+		return j + 1, data[:j], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// MAX_LINE_SIZE is the longest line, without its ending, that gets parsed. A spec line is a 100 byte name and a
+// temperature, anything near this long is a broken file and fails the run, whichever strategy cut it into chunks.
+const MAX_LINE_SIZE = MIN_CHUNK_SIZE / 2
+
+var errLineTooLong = fmt.Errorf("line longer than %d bytes", MAX_LINE_SIZE)
+
+// lineTooLong is errLineTooLong for the line starting at offset, -1 if unknown.
+func lineTooLong(offset int64) error {
+	if offset < 0 {
+		return errLineTooLong
+	}
+	return fmt.Errorf("at byte %d: %w", offset, errLineTooLong)
+}
+
+// lineSplitter remembers how far the last token advanced, so callers tracking offsets count the \r\n of a line as two bytes.
+type lineSplitter struct {
+	advance int
+}
+
+func (s *lineSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := scanLines(data, atEOF)
+	s.advance = advance
+	return advance, token, err
+}
+
+// firstLineEnd returns the index of the last byte of the first line ending in b (the \n of a \r\n), or -1 when
+// there is none. A \r as the very last byte might be the first half of a \r\n, so it only counts atEOF.
+func firstLineEnd(b []byte, atEOF bool) int {
+	i := bytes.IndexAny(b, "\r\n")
+	if i == -1 || b[i] == '\n' {
+		return i
+	}
+
+	if i+1 < len(b) {
+		if b[i+1] == '\n' {
+			return i + 1
+		}
+		return i
+	}
+
+	if atEOF {
+		return i
+	}
+	return -1
+}
+
+// lastLineEnd returns the index of the last byte of the last complete line in b, or -1 when there is none.
+// A trailing \r is passed over since its \n may be in the next read.
+func lastLineEnd(b []byte) int {
+	i := bytes.LastIndexAny(b, "\r\n")
+	if i == len(b)-1 && b[i] == '\r' {
+		i = bytes.LastIndexAny(b[:i], "\r\n")
+	}
+	return i
+}
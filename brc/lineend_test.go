@@ -0,0 +1,71 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lineEndings are what each line of a fixture gets rewritten to end with, mixed being a random pick per line.
+var lineEndings = []string{"\n", "\r\n", "\r"}
+
+// TestLineEndings reruns every golden fixture with its lines ended in \r\n, in a bare \r and in a random mix
+// of all three, repeated past a few BUFFER_SIZEs so a \r\n also gets split by a chunk boundary.
+func TestLineEndings(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, g := range readGoldens(t) {
+		lines := strings.Split(strings.TrimSuffix(g.input, "\n"), "\n")
+
+		for _, ending := range append(lineEndings, "mixed") {
+			var sb strings.Builder
+			for sb.Len() < 3*BUFFER_SIZE {
+				for _, line := range lines {
+					sb.WriteString(line)
+					if ending == "mixed" {
+						sb.WriteString(lineEndings[rng.Intn(len(lineEndings))])
+					} else {
+						sb.WriteString(ending)
+					}
+				}
+			}
+
+			t.Run(fmt.Sprintf("%s/%q", g.name, ending), func(t *testing.T) {
+				expectEveryStrategy(t, sb.String(), g.want)
+			})
+		}
+	}
+}
+
+// TestLineTooLong puts a line just over MAX_LINE_SIZE, over bufio.MaxScanTokenSize and over a whole chunk after
+// a few chunks of good lines, every strategy and -serial must fail on it rather than drop or split it.
+func TestLineTooLong(t *testing.T) {
+	good := repeatPastChunks("A;1.0\nB;2.0\n")
+	longest := strings.Repeat("x", MAX_LINE_SIZE-len(";3.0")) + ";3.0\n"
+	expectEveryStrategy(t, good+longest, "{A=1.0/1.0/1.0, B=2.0/2.0/2.0, "+longest[:len(longest)-len(";3.0\n")]+"=3.0/3.0/3.0}\n")
+
+	for _, size := range []int{MAX_LINE_SIZE + 1, 70 << 10, BUFFER_SIZE + 1} {
+		file := filepath.Join(t.TempDir(), "measurements.txt")
+		input := good + strings.Repeat("x", size-len(";3.0")) + ";3.0\nC;4.0\n"
+		if err := os.WriteFile(file, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, strategyName := range strings.Split(strategyNames(), ", ") {
+			if strategyName == "mmap" && !mmapSupported {
+				continue
+			}
+
+			for _, serial := range []bool{false, true} {
+				_, err := runPipeline(strategies[strategyName], []string{file}, WithSerial(serial))
+				if !errors.Is(err, errLineTooLong) {
+					t.Errorf("%d byte line, -strategy=%s -serial=%t: got %v, want %v", size, strategyName, serial, err, errLineTooLong)
+				}
+			}
+		}
+	}
+}
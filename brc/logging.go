@@ -0,0 +1,62 @@
+package brc
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Diagnostics go to stderr through slog, stdout only ever carries the results and the reports asked for.
+var verbose = commandLine.Bool("v", false, "also log debug diagnostics: the options in effect, every input as it is opened")
+var quiet = commandLine.Bool("quiet", false, "only log warnings and errors")
+var logFormat = commandLine.String("log-format", "text", "how diagnostics are logged to stderr: text or json")
+
+var logFormats = []string{"text", "json"}
+
+// addLogFlags registers the logging flags on a subcommand's flag set, sharing their values with the main ones.
+func addLogFlags(fs *flag.FlagSet) {
+	fs.BoolVar(verbose, "v", false, "also log debug diagnostics")
+	fs.BoolVar(quiet, "quiet", false, "only log warnings and errors")
+	fs.StringVar(logFormat, "log-format", "text", "how diagnostics are logged to stderr: text or json")
+}
+
+// logLevel is the lowest level logged, -quiet beating -v.
+func logLevel() slog.Level {
+	switch {
+	case *quiet:
+		return slog.LevelWarn
+	case *verbose:
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// setupLogging makes slog's default logger what -v, -quiet and -log-format ask for. What still goes through
+// the log package, the log.Fatal of a failed run above all, is logged as an error so -quiet never hides it.
+func setupLogging() error {
+	opts := &slog.HandlerOptions{Level: logLevel()}
+
+	var handler slog.Handler
+	switch *logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("-log-format=%s is unknown, want text or json", *logFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	return nil
+}
+
+// exitOnBadLogging sets up logging for a subcommand, which has no validateFlags of its own to catch -log-format.
+func exitOnBadLogging() {
+	if err := setupLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,991 @@
+// Package brc aggregates weather station measurements, the one billion row challenge, from code with Process
+// or from the command line with Main.
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/bits"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// Optimisation: Reusing buffers after having been parsed reduces memory allocation from
+// approx 30,000 buffers of 1024 x 512kb to approx 10,000
+// Reduced memory allocation from ~15gb to ~5gb
+var BufferPool = &sync.Pool{
+	New: func() interface{} {
+		return make([]byte, 0, BUFFER_SIZE)
+	},
+}
+
+const (
+	BUFFER_SIZE = 1024 * 512
+)
+
+// commandLine holds the flags of a run, a FlagSet of its own so importing the package registers none.
+var commandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+var cpuprofile = commandLine.String("cpuprofile", "", "write cpu profile to `file`")
+var memprofile = commandLine.String("memprofile", "", "write memory profile to `file`")
+var blockprofile = commandLine.String("blockprofile", "", "write a profile of where goroutines blocked, on channels, locks and selects, to `file`")
+var blockprofileRate = commandLine.Int("blockprofile-rate", 1, "sample one blocking event per `ns` nanoseconds blocked for -blockprofile (1 records every one)")
+var mutexprofile = commandLine.String("mutexprofile", "", "write a profile of contended mutexes to `file`")
+var mutexprofileFraction = commandLine.Int("mutexprofile-fraction", 1, "sample 1 in `n` mutex contention events for -mutexprofile")
+var pprofAddr = commandLine.String("pprof-addr", "localhost:6060", "`address` to serve net/http/pprof and the live counters of expvar's /debug/vars on, :0 picks a free port and empty disables it")
+var traceFile = commandLine.String("trace", "", "write an execution trace of the run to `file`, for go tool trace")
+var workers = commandLine.Int("workers", runtime.NumCPU(), "number of parser `goroutines`")
+var queueDepth = commandLine.Int("queue-depth", 0, "chunks read ahead of the parsers at most, `n`, the reader blocks until they catch up (0 is 2 per worker, or what fits -max-memory)")
+var strategyName = commandLine.String("strategy", "streaming", "`strategy` used to read and parse the file: naive, streaming, mmap, pread or uring (Linux only)")
+var perFile = commandLine.Bool("per-file", false, "print a result block per input file before the combined total")
+var maxStations = commandLine.Int("max-stations", 0, "abort once more than `n` unique stations are seen (0 disables)")
+
+type Tally struct {
+	//ids gives every station a small integer ID in order of first sight, indexing names and stats
+	ids   map[string]int
+	names []string
+
+	//Optimisation: results are kept in a flat array indexed by ID instead of one allocation each behind a map,
+	//so neighbouring stations share cache lines and a merge is an array walk. The array grows a page at a
+	//time and pages never move, keeping the *StationResult handed out by Get valid.
+	stats []*statPage
+	m     *sync.Mutex
+
+	//file the tally is being filled from, recorded with each station for the station dictionary
+	file string
+
+	//fast path for stations named by integers, see Station
+	numeric numericTable
+
+	//fast path for the official stations by perfect hash slot, see officialStation
+	official []*StationResult
+
+	//handles are the stationNames handle of each ID, byHandle the ID plus one of each handle, 0 for a station
+	//this tally hasn't seen
+	handles  []int32
+	byHandle []int32
+
+	//tallies of the -schema metrics after the first, see metric
+	metrics []*Tally
+
+	//sha256 of the file read so far under -verify-sha256, nil otherwise. Only the reader writes it, in order.
+	digest hash.Hash
+}
+
+// Get returns the result for station, creating it on first sight.
+func (t *Tally) Get(station []byte) *StationResult {
+	return t.GetAt(station, -1)
+}
+
+// GetAt is Get for a station read at offset of the tally's file, which is remembered if the station is new.
+// Guards against unbounded growth from garbage station names when -max-stations is set.
+// Only the goroutine filling t may call it, parsers fill a tally of their own and Merge it into the shared one.
+func (t *Tally) GetAt(station []byte, offset int64) *StationResult {
+	if id, ok := t.ids[string(station)]; ok {
+		return t.stat(id)
+	}
+
+	handle, name := internStation(stationNames, station)
+	return t.newStation(name, handle, offset)
+}
+
+// newStation adds the station name with stationNames handle, first read at offset.
+func (t *Tally) newStation(name string, handle int32, offset int64) *StationResult {
+	if *maxStations > 0 && len(t.names) >= *maxStations {
+		log.Fatalf("more than %d unique stations seen, aborting at %q", *maxStations, name)
+	}
+
+	return t.insert(name, handle, StationResult{
+		math.MaxInt, math.MinInt, 0, 0, 0, 0, newReservoir(), newHistogram(), t.file, offset,
+	})
+}
+
+// STAT_PAGE is how many stations each page of a tally's flat array holds.
+const STAT_PAGE = 256
+
+type statPage [STAT_PAGE]StationResult
+
+// stat returns the result of the station with ID id.
+func (t *Tally) stat(id int) *StationResult {
+	return &t.stats[id/STAT_PAGE][id%STAT_PAGE]
+}
+
+// add gives name the next ID with r as its result.
+func (t *Tally) add(name string, r StationResult) *StationResult {
+	handle, name := internStation(stationNames, name)
+	return t.insert(name, handle, r)
+}
+
+// insert gives name, interned with handle, the next ID with r as its result.
+func (t *Tally) insert(name string, handle int32, r StationResult) *StationResult {
+	id := len(t.names)
+	if id%STAT_PAGE == 0 {
+		t.stats = append(t.stats, &statPage{})
+	}
+
+	t.ids[name] = id
+	t.names = append(t.names, name)
+	t.handles = append(t.handles, handle)
+	if int(handle) >= len(t.byHandle) {
+		t.byHandle = append(t.byHandle, make([]int32, int(handle)+1-len(t.byHandle))...)
+	}
+	t.byHandle[handle] = int32(id + 1)
+
+	result := t.stat(id)
+	*result = r
+	return result
+}
+
+// getName is Get for a station already held as a string, which is interned as it is rather than converted.
+func (t *Tally) getName(station string) *StationResult {
+	if r, ok := t.Lookup(station); ok {
+		return r
+	}
+
+	handle, name := internStation(stationNames, station)
+	return t.newStation(name, handle, -1)
+}
+
+// Lookup returns the result for station without creating it.
+func (t *Tally) Lookup(station string) (*StationResult, bool) {
+	id, ok := t.ids[station]
+	if !ok {
+		return nil, false
+	}
+	return t.stat(id), true
+}
+
+// Local returns an empty tally for one goroutine to fill from t's file, to be merged back with Merge.
+func (t *Tally) Local() *Tally {
+	local := NewTally()
+	local.file = t.file
+	return local
+}
+
+// Merge folds every station of other into t. It is safe to call from several goroutines at once, other must
+// no longer be written to.
+func (t *Tally) Merge(other *Tally) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	//Optimisation: stations are matched by handle, a slice index, rather than hashing their names
+	for id, station := range other.names {
+		handle := other.handles[id]
+
+		var r *StationResult
+		if int(handle) < len(t.byHandle) && t.byHandle[handle] != 0 {
+			r = t.stat(int(t.byHandle[handle]) - 1)
+		} else {
+			r = t.newStation(station, handle, -1)
+		}
+		r.Merge(other.stat(id))
+	}
+
+	for j, metric := range other.metrics {
+		t.metric(j + 1).Merge(metric)
+	}
+}
+
+// Print writes the results sorted alphabetically by station name (or by -sort, or the -top report) in the challenge format:
+// {Abha=-23.0/18.0/59.2, Abidjan=-16.2/26.0/67.3, ...}
+// With several -schema metrics each is labelled: {Abha=temp:-23.0/18.0/59.2 humidity:12.0/50.1/96.0, ...}
+// With -sparse=flag a station below -min-count is followed by a *: {Abha=-23.0/18.0/59.2*, ...}
+func (t *Tally) Print(w io.Writer) {
+	sortStart := timingStart()
+	names := t.sortedNames()
+	if !sortStart.IsZero() {
+		addPhase(&phaseTimes.sort, time.Since(sortStart))
+	}
+
+	outputStart := timingStart()
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 32)
+	bw.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			bw.WriteString(", ")
+		}
+		bw.WriteString(k)
+		bw.WriteByte('=')
+
+		if activeSchema == nil || len(activeSchema.metrics) == 1 {
+			v, _ := t.Lookup(k)
+			buf = appendAggregates(buf[:0], v)
+			bw.Write(buf)
+			printSparse(bw, t, k)
+			continue
+		}
+
+		for j, metric := range activeSchema.metrics {
+			if j > 0 {
+				bw.WriteByte(' ')
+			}
+			bw.WriteString(metric)
+			bw.WriteByte(':')
+
+			//Lines missing any metric are skipped, so every metric has every station
+			v, _ := t.metric(j).Lookup(k)
+			buf = appendAggregates(buf[:0], v)
+			bw.Write(buf)
+		}
+		printSparse(bw, t, k)
+	}
+	bw.WriteString("}\n")
+	bw.Flush()
+	if !outputStart.IsZero() {
+		addPhase(&phaseTimes.output, time.Since(outputStart))
+	}
+}
+
+// appendAggregates appends min/mean/max of r, or the -agg-fns.
+func appendAggregates(buf []byte, r *StationResult) []byte {
+	if activeAggFns == nil {
+		unit := outputUnit()
+		buf = append(appendValue(buf, unit.value(r.min)), '/')
+		buf = append(appendValue(buf, unit.mean(r.sum, r.count)), '/')
+		return appendValue(buf, unit.value(r.max))
+	}
+
+	for j, fn := range activeAggFns {
+		if j > 0 {
+			buf = append(buf, '/')
+		}
+		buf = append(buf, fn(r)...)
+	}
+	return buf
+}
+
+func NewTally() *Tally {
+	return &Tally{
+		ids: make(map[string]int),
+		m:   &sync.Mutex{},
+	}
+}
+
+var FinalTally = NewTally()
+
+// min, max and sum are all multiplied by readingScale, ten unless -precision asks for more decimals, to avoid
+// floating point arithmetic
+type StationResult struct {
+	min, max, sum, count int
+
+	//sum of squared readings for the standard deviation, -1 when merged from a partial that didn't record it
+	sumSq int
+
+	//sum of the natural log of every reading, as kept, for -agg-fns geomean, NaN when unknown
+	sumLog float64
+
+	//-reservoir's sample of the readings, nil when it keeps none
+	sample *reservoir
+
+	//-histogram's counts of the readings, nil when it keeps none
+	hist *histogram
+
+	//where the station was first seen, offset is -1 when unknown
+	file   string
+	offset int64
+}
+
+func (r *StationResult) Add(temp int) {
+	r.AddAt(temp, -1)
+}
+
+// AddAt is Add for a reading at offset of the station's file.
+// Workers race through chunks out of order, so the earliest sighting is kept to make dictionary IDs stable.
+// Optimisation: no lock, every parser adds to results of its own tally.
+func (r *StationResult) AddAt(temp int, offset int64) {
+	if temp > r.max {
+		r.max = temp
+	}
+
+	if temp < r.min {
+		r.min = temp
+	}
+
+	r.count++
+	if r.sample != nil {
+		r.sample.add(temp, r.count)
+	}
+	if r.hist != nil {
+		r.hist.add(temp)
+	}
+
+	r.sum += temp
+	r.sumSq += temp * temp
+
+	//Readings at or below zero leave no geometric mean, the log makes that NaN
+	if trackLogs {
+		r.sumLog += math.Log(float64(temp))
+	}
+
+	if offset >= 0 && offset < r.offset {
+		r.offset = offset
+	}
+}
+
+func (r *StationResult) Merge(other *StationResult) {
+	if other.max > r.max {
+		r.max = other.max
+	}
+
+	if other.min < r.min {
+		r.min = other.min
+	}
+
+	if r.sample != nil && other.sample != nil {
+		r.sample.merge(other.sample, r.count, other.count)
+	}
+	if r.hist != nil && other.hist != nil {
+		r.hist.merge(other.hist)
+	}
+
+	r.count += other.count
+	r.sum += other.sum
+
+	if r.sumSq < 0 || other.sumSq < 0 {
+		r.sumSq = -1
+	} else {
+		r.sumSq += other.sumSq
+	}
+	r.sumLog += other.sumLog
+
+	if other.offset >= 0 && (r.offset < 0 || r.file == other.file && other.offset < r.offset) {
+		r.file, r.offset = other.file, other.offset
+	}
+}
+
+// Stddev returns the population standard deviation in degrees, ok is false when it is unknown.
+func (r *StationResult) Stddev() (float64, bool) {
+	if r.sumSq < 0 || r.count == 0 {
+		return 0, false
+	}
+
+	sum, count := float64(r.sum), float64(r.count)
+	variance := (float64(r.sumSq) - sum*sum/count) / count
+
+	//Cancellation can leave a tiny negative variance for constant readings
+	return math.Sqrt(math.Max(variance, 0)) / float64(readingScale), true
+}
+
+// subcommands are dispatched on the first argument, anything else is a normal run.
+var subcommands = map[string]func(args []string){
+	"bench":        runBench,
+	"check":        runCheck,
+	"consume":      runConsume,
+	"convert":      runConvert,
+	"demo":         runDemo,
+	"diff":         runDiff,
+	"emit":         runEmit,
+	"generate":     runGenerate,
+	"import-tests": runImportTests,
+	"merge":        runMerge,
+	"serve":        runServe,
+	"worker":       runWorker,
+	"coordinate":   runCoordinate,
+}
+
+// Main is the command line: a subcommand when os.Args names one, otherwise a run over the inputs the flags name.
+func Main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	commandLine.Parse(os.Args[1:])
+	applyPreset()
+	inferOutputFormat()
+	exitOnInvalidFlags()
+	setPrecision()
+	setupLogging()
+	numericMode = numericModes[*numericStations]
+	if *officialStations {
+		var err error
+		if officialHash, err = buildOfficialHash(); err != nil {
+			log.Fatal("could not build the official stations' perfect hash: ", err)
+		}
+	}
+	if *seedDictionary != "" {
+		var err error
+		if officialHash, err = buildDictionaryHash(*seedDictionary); err != nil {
+			log.Fatal("could not build the perfect hash of -seed-dictionary: ", err)
+		}
+	}
+	delimiterMode = delimiterModes[*duplicateDelimiter]
+	resolveAggFns()
+	if *schemaFlag != "" {
+		activeSchema, _ = parseSchema(*schemaFlag)
+	}
+	commentChar, _ = parseCommentChar(*commentCharFlag)
+	inputOffset, _ = parseSpan("offset", *offsetFlag)
+	inputLimit, _ = parseSpan("limit", *limitFlag)
+	stationFilter, _ = buildStationFilter()
+	//json and http reports and OpenTelemetry carry the phases too, -tui draws the lanes
+	collectTiming = *timingBreakdown || *timingJSON != "" || effectiveTimingFormat() == "human" ||
+		*outputFormat == "json" || *outputFormat == "http" || *tui || *otelEndpoint != ""
+	if collectTiming {
+		calibrateClock()
+	}
+
+	if *installCompletion != "" {
+		if err := runInstallCompletion(*installCompletion); err != nil {
+			log.Fatal("could not install completion: ", err)
+		}
+		return
+	}
+
+	if err := applyResourceLimits(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			log.Fatal("could not create CPU profile: ", err)
+		}
+		defer f.Close() // error handling omitted for example
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatal("could not start CPU profile: ", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	if *blockprofile != "" {
+		runtime.SetBlockProfileRate(*blockprofileRate)
+	}
+	if *mutexprofile != "" {
+		runtime.SetMutexProfileFraction(*mutexprofileFraction)
+	}
+
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			log.Fatal("could not create trace: ", err)
+		}
+		defer f.Close()
+		if err := trace.Start(f); err != nil {
+			log.Fatal("could not start trace: ", err)
+		}
+		defer trace.Stop()
+	}
+
+	opts, err := cliOptions(WithStrategy(strategies[*strategyName]))
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Debug("options", "strategy", *strategyName, "workers", opts.workers, "chunk_size", opts.chunkSize, "queue_depth", opts.depth, "max_memory", opts.memory)
+
+	if *pprofAddr != "" {
+		startPprof(*pprofAddr)
+	}
+	if *watch {
+		log.Fatal(runWatch(opts))
+	}
+
+	files, err := inputFiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *follow {
+		log.Fatal(runFollow(files, opts))
+	}
+	if *verifySHA256 != "" && len(files) != 1 {
+		log.Fatalf("-verify-sha256 checks a single input, got %d", len(files))
+	}
+
+	start := clock.Now()
+	runSpan = startSpan("run", nil)
+
+	//Deadlocks in the reader/parser handoff otherwise just hang silently
+	if *watchdogTimeout > 0 {
+		defer startWatchdog(*watchdogTimeout, *watchdogAbort, os.Stderr)()
+	}
+
+	stopDashboard := func() {}
+	if *tui {
+		stopDashboard = startDashboard(os.Stderr)
+	}
+	var tallies []*Tally
+	var runs []Phases
+	if *repeat > 1 || *dropCaches {
+		//The last run is reported as if it were the only one
+		tallies, start, runs, err = repeatRuns(opts, files)
+	} else {
+		tallies, err = processFiles(opts, files)
+	}
+	stopDashboard()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.phase != PHASE_ALL {
+		reportPhase(os.Stdout, opts.phase, opts.bytes.Load(), clock.Since(start))
+		return
+	}
+
+	var groups map[string]string
+	if *groupBy != "station" {
+		if groups, err = readGroupsFile(*metadataFile, *groupBy); err != nil {
+			log.Fatal("could not read station metadata: ", err)
+		}
+	}
+
+	mergeSpan := startSpan("merge", runSpan)
+	for i, tally := range tallies {
+		if groups != nil {
+			tally = tally.Rollup(groups)
+		}
+
+		if *perFile {
+			fmt.Printf("==> %s <==\n", files[i])
+			tally.Print(os.Stdout)
+		}
+
+		mergeStart := timingStart()
+		FinalTally.Merge(tally)
+		if !mergeStart.IsZero() {
+			addPhase(&phaseTimes.merge, time.Since(mergeStart))
+		}
+	}
+
+	mergeSpan.end(intAttribute("brc.stations", int64(len(FinalTally.names))))
+
+	if *perFile {
+		fmt.Println("==> total <==")
+	}
+	reportSpan := startSpan("report", runSpan)
+	report := Report{RunID: *appendRunID, Tally: FinalTally, Phases: collectPhases(clock.Since(start))}
+	if err := reporters[*outputFormat](*outputFile).Report(report); err != nil {
+		log.Fatal("could not report results: ", err)
+	}
+	reportSpan.end(stringAttribute("brc.output_format", *outputFormat))
+	writeSampleNote(os.Stderr)
+
+	if *chunkLog != "" {
+		if err := writeChunkLog(*chunkLog); err != nil {
+			log.Fatal("could not write chunk log: ", err)
+		}
+	}
+
+	if *dumpDictionary != "" {
+		if err := writeDictionaryFile(*dumpDictionary, FinalTally); err != nil {
+			log.Fatal("could not write station dictionary: ", err)
+		}
+	}
+
+	if *dumpPartial != "" {
+		if err := writePartialFile(*dumpPartial, FinalTally); err != nil {
+			log.Fatal("could not write partial tally: ", err)
+		}
+	}
+
+	//Timing
+	elapsed := clock.Since(start)
+	if len(runs) > 1 {
+		reportRepeatTiming(runs)
+	} else {
+		reportTiming(elapsed)
+	}
+	logGCStats()
+	if *summary {
+		fmt.Fprintln(os.Stderr, FinalTally.Summary(opts.bytes.Load(), elapsed))
+	}
+	if *timingBreakdown {
+		reportBreakdown(os.Stderr, elapsed)
+	}
+	if *otelEndpoint != "" {
+		phases := collectPhases(elapsed)
+		runSpan.end(phaseAttributes(phases)...)
+		counts := otelCounts{opts.bytes.Load(), int64(FinalTally.Summary(0, 0).Rows), opts.chunks.Load()}
+		if err := exportOTel(*otelEndpoint, counts, phases, start, clock.Now()); err != nil {
+			slog.Warn("could not export telemetry", "err", err)
+		}
+	}
+
+	if *memprofile != "" {
+		f, err := os.Create(*memprofile)
+		if err != nil {
+			log.Fatal("could not create memory profile: ", err)
+		}
+		defer f.Close() // error handling omitted for example
+		runtime.GC()    // get up-to-date statistics
+		pprof.Lookup("allocs").WriteTo(f, 0)
+	}
+
+	if *blockprofile != "" {
+		writeProfile("block", *blockprofile)
+	}
+	if *mutexprofile != "" {
+		writeProfile("mutex", *mutexprofile)
+	}
+}
+
+// startPprof serves the pprof endpoints and /debug/vars on addr in the background, logging where. A taken port, another
+// run on the same machine most likely, is logged and the run carries on without them.
+// cliOptions are the Options the command line flags ask for, with opts applied on top.
+func cliOptions(opts ...Option) (*Options, error) {
+	key, err := flagKey()
+	if err != nil {
+		return nil, err
+	}
+
+	sep, _ := parseDelimiter(*fieldDelimiter)
+	flagged := []Option{
+		WithWorkers(*workers), WithMaxMemory(memoryBudget), WithQueueDepth(*queueDepth),
+		WithSerial(*serial), WithSample(*sampleFlag), WithDirectIO(*directIO),
+		WithVerifySHA256(*verifySHA256, *verifyWarn), WithPhase(phaseModes[*phaseFlag]), WithDelimiter(sep),
+		WithKey(key),
+	}
+	return newOptions(append(flagged, opts...)...)
+}
+
+// flagKey is the KeyFunc of -key, wrapped in -fold-case, -nfc and -aliases in that order.
+func flagKey() (KeyFunc, error) {
+	key := keyFuncs[*keyName]
+	if *foldCase {
+		key = FoldCaseKey(key)
+	}
+	if *nfcStations {
+		key = NFCKey(key)
+	}
+	if *aliasesFile != "" {
+		aliases, err := readAliasesFile(*aliasesFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read station aliases: %w", err)
+		}
+		key = AliasKey(aliases, key)
+	}
+	return key, nil
+}
+
+func startPprof(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Warn("pprof disabled", "err", err)
+		return
+	}
+
+	slog.Info("pprof listening", "url", "http://"+listener.Addr().String()+"/debug/pprof/",
+		"vars", "http://"+listener.Addr().String()+"/debug/vars")
+	go func() {
+		slog.Error("pprof stopped", "err", http.Serve(listener, nil))
+	}()
+}
+
+// writeProfile writes the named runtime profile to file.
+func writeProfile(name, file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		log.Fatalf("could not create %s profile: %v", name, err)
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		log.Fatalf("could not write %s profile: %v", name, err)
+	}
+}
+
+// parseCh parses the chunks from in on the parser pool into tally, sending what they failed with, if anything, once done.
+func parseCh(in <-chan Chunk, name string, tally *Tally, o *Options) <-chan error {
+	out := make(chan error)
+
+	go func() {
+		scheduler := NewScheduler(o.workers, o.depth)
+		defer watchQueue(name+" scheduler", scheduler.Depth)()
+
+		locals := workerTallies(o.workers, tally)
+		errs := make([]error, o.workers)
+		scheduler.Run(in, func(worker int, chunk Chunk) {
+			recordChunk(name, chunk, o)
+			o.counted(chunk)
+			if err := parseLines(chunk.data, chunk.offset, locals[worker], o); err != nil && errs[worker] == nil {
+				errs[worker] = err
+			}
+
+			//Return buffer to pool
+			o.pool.Put(chunk.data)
+		})
+		mergeTallies(tally, locals)
+		out <- errors.Join(errs...)
+		close(out)
+	}()
+
+	return out
+}
+
+// workerTallies returns a Local tally of tally for each of n workers.
+func workerTallies(n int, tally *Tally) []*Tally {
+	locals := make([]*Tally, n)
+	for i := range locals {
+		locals[i] = tally.Local()
+	}
+	return locals
+}
+
+// mergeTallies folds the worker tallies back into tally once every worker is done.
+func mergeTallies(tally *Tally, locals []*Tally) {
+	mergeStart := timingStart()
+	for _, local := range locals {
+		tally.Merge(local)
+	}
+	if !mergeStart.IsZero() {
+		addPhase(&phaseTimes.merge, time.Since(mergeStart))
+	}
+}
+
+// parseLines aggregates every line of chunk, which starts at offset in the tally's file (-1 if unknown).
+// tally must be the caller's own, see GetAt. A line longer than MAX_LINE_SIZE stops it with lineTooLong.
+func parseLines(chunk []byte, offset int64, tally *Tally, o *Options) error {
+	if o.phase == PHASE_IO {
+		progress.Add(1)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(chunk))
+	split := &lineSplitter{}
+	scanner.Split(split.split)
+	key := o.key
+	keep := cachedFilter()
+	sep, comment := o.delimiter, commentChar
+	scratch := make([]byte, 0, 128)
+
+	schema := activeSchema
+	var values []int
+	if schema != nil {
+		values = make([]int, len(schema.metrics))
+	}
+
+	var aggregate time.Duration
+	lines := 0
+	parseOnly, checksum := o.phase == PHASE_PARSE, 0
+
+	//Line offsets only mean something when the chunk's own offset is known
+	step := int64(1)
+	if offset < 0 {
+		step = 0
+	}
+	offset += skipFileHeader(scanner, split, offset)
+
+	var err error
+	for ; scanner.Scan(); offset += step * int64(split.advance) {
+		b := scanner.Bytes()
+
+		if len(b) > MAX_LINE_SIZE {
+			err = lineTooLong(offset)
+			break
+		}
+
+		if comment != 0 && len(b) > 0 && b[0] == comment {
+			continue
+		}
+
+		if schema != nil {
+			station, ok := schema.cut(b, sep, values)
+			if !ok {
+				continue
+			}
+
+			if key != nil {
+				//A rewritten key is about as long as the name, grow scratch for the longest rather than per line
+				if cap(scratch) < len(station) {
+					scratch = make([]byte, 0, 2*len(station))
+				}
+				station = key(scratch[:0], station)
+			}
+
+			if keep == nil || keep(station) {
+				if parseOnly {
+					checksum += values[0] + len(station)
+				} else {
+					schema.add(tally, station, values, offset)
+				}
+				lines++
+			}
+			continue
+		}
+
+		semiColonIdx := lastDelimiter(b, sep)
+		if semiColonIdx == -1 {
+			continue
+		}
+
+		temp := b[semiColonIdx+1:]
+
+		//The scan above leaves the last delimiter which is the default, only other modes pay for a second look
+		if delimiterMode != DELIMITER_LAST {
+			if first := bytes.IndexByte(b, sep); first != semiColonIdx {
+				if delimiterMode == DELIMITER_REJECT {
+					continue
+				}
+
+				temp = b[first+1 : first+1+bytes.IndexByte(b[first+1:], sep)]
+				semiColonIdx = first
+			}
+		}
+
+		station := b[0:semiColonIdx]
+
+		stationTemp, ok := parseTempAny(temp)
+		if !ok {
+			continue
+		}
+
+		if key != nil {
+			if cap(scratch) < len(station) {
+				scratch = make([]byte, 0, 2*len(station))
+			}
+			station = key(scratch[:0], station)
+		}
+
+		if keep != nil && !keep(station) {
+			continue
+		}
+
+		if parseOnly {
+			checksum += stationTemp + len(station)
+			lines++
+			continue
+		}
+
+		if collectTiming && lines%AGGREGATE_SAMPLE == 0 {
+			start := time.Now()
+			tally.Station(station, offset).AddAt(stationTemp, offset)
+			aggregate += time.Since(start)
+		} else {
+			tally.Station(station, offset).AddAt(stationTemp, offset)
+		}
+		lines++
+	}
+
+	//Past MAX_LINE_SIZE is past bufio.MaxScanTokenSize too, which the scanner stops at without returning the line
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		err = lineTooLong(offset)
+	}
+
+	progress.Add(1)
+	linesParsed.Add(int64(lines))
+	if parseOnly {
+		phaseLines.Add(int64(lines))
+		phaseChecksum.Add(int64(checksum))
+	}
+	if *tui {
+		publishLeaders(tally)
+	}
+
+	if collectTiming {
+		addPhase(&phaseTimes.aggregate, sampledAggregate(aggregate, (lines+AGGREGATE_SAMPLE-1)/AGGREGATE_SAMPLE))
+	}
+	return err
+}
+
+// parseTemp parses a -?\d?\d\.\d temperature into tenths using arithmetic on a single 64 bit load.
+// Optimisation: no loop and no branches on the digits. The '.' is the first of bytes 1..3 with bit 4 clear (digits have it set),
+// the sign comes from bit 4 of byte 0, the digits get shifted into fixed lanes and a single multiply sums them as 100a + 10b + c.
+func parseTemp(b []byte) int {
+	var word uint64
+
+	//Bytes past the number only ever land in masked out lanes, so load 8 from the underlying buffer when there is room
+	if cap(b) >= 8 {
+		word = binary.LittleEndian.Uint64(b[:8])
+	} else {
+		for i := len(b) - 1; i >= 0; i-- {
+			word = word<<8 | uint64(b[i])
+		}
+	}
+
+	decimalSepPos := bits.TrailingZeros64(^word & 0x10101000)
+
+	//Without a '.' in bytes 1..3 this wraps to a huge unsigned shift, which yields a garbage value instead of panicking
+	shift := uint(28 - decimalSepPos)
+
+	//-1 when the first byte is '-', 0 otherwise
+	signed := int64(^word<<59) >> 63
+	designMask := ^(uint64(signed) & 0xFF)
+
+	digits := ((word & designMask) << shift) & 0x0F000F0F00
+	absValue := int64(((digits * 0x640a0001) >> 32) & 0x3FF)
+
+	return int((absValue ^ signed) - signed)
+}
+
+func readInFile(filePtr io.Reader, o *Options) <-chan Chunk {
+	out := make(chan Chunk)
+
+	go func() {
+		readChunks(filePtr, o, func(chunk Chunk) {
+			out <- chunk
+		})
+		close(out)
+	}()
+	return out
+}
+
+// readChunks reads filePtr to the end, handing emit one chunk of whole lines at a time.
+func readChunks(filePtr io.Reader, o *Options, emit func(chunk Chunk)) {
+	//Optimisation: Read straight into pooled buffers and hand each one to the parser, which returns it to the pool.
+	//Only the partial line at the end of a read is copied, into the front of the next buffer.
+	//Works best with approx 512kb buffer size
+
+	//offset of the first byte of buffer in the file
+	offset := int64(0)
+
+	//Partial line at the end of the previous buffer
+	fragment := make([]byte, 0, 256)
+
+	for {
+		//Buffer only gets returned to the pool when a scanner has read all it's bytes
+		buffer := o.pool.Get().([]byte)[:o.chunkSize]
+
+		//If any bytes are in the fragment, prepend to the buffer and resume reading after.
+		fragLength := copy(buffer, fragment)
+
+		//Read file into the buffer starting after the length of the fragment which was copied in.
+		readStart := timingStart()
+		n, err := filePtr.Read(buffer[fragLength:])
+		recordRead(READER_LANE, readStart, n)
+		progress.Add(1)
+
+		//Here the number of bytes in the buffer is fragLength + bytes read.
+		n += fragLength
+
+		if err == io.EOF || n == 0 {
+			//Last line had no trailing newline
+			if n > 0 {
+				emit(Chunk{buffer[:n], offset})
+			} else {
+				o.pool.Put(buffer[:0])
+			}
+			return
+		}
+
+		//Optimisation: Read backwards over the partial line and copy it aside for use next time through.
+		//It has to be copied before the send, once the parser owns the buffer it may recycle it at any moment.
+		fragment = fragment[:0]
+		if buffer[n-1] != byte('\n') {
+			if i := lastLineEnd(buffer[:n]); i != -1 {
+				fragment = append(fragment, buffer[i+1:n]...)
+				n = i + 1
+			}
+		}
+
+		emit(Chunk{buffer[:n], offset})
+		offset += int64(n)
+	}
+}
@@ -0,0 +1,245 @@
+package brc
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// challengeTemp is a temperature in the challenge's format, the only kind parseLines is held to. It doesn't
+// validate the others, they just must not crash it or leak into other stations.
+var challengeTemp = regexp.MustCompile(`^-?[0-9]{1,2}\.[0-9]$`)
+
+// referenceParse is the trivial strings.Split + strconv.ParseFloat parser.
+// The last semicolon separates the temperature, same as parseLines.
+func referenceParse(line string) (string, int, bool) {
+	fields := strings.Split(line, ";")
+	if len(fields) < 2 {
+		return "", 0, false
+	}
+
+	f, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return strings.Join(fields[:len(fields)-1], ";"), int(math.Round(f * 10)), true
+}
+
+// FuzzParseLine feeds chunks through parseLines and compares every station only ever seen on well formed
+// lines with referenceParse.
+func FuzzParseLine(f *testing.F) {
+	f.Add("Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4")
+	f.Add("St. John's;15.2\r\nCracow;-0.0\r\nCracow;99.9\n")
+	f.Add("a;b;1.0\nKyōto;-99.9\n日本語;0.5\n;1.0\nno semicolon\n")
+	f.Add("x;1.\ny;.5\nz;123.4\nw;+1.0\nv;1.23\nu;--1.0\nt; 1.0\ns;1e1\n")
+
+	o, err := newOptions()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, chunk string) {
+		want := map[string]*StationResult{}
+		tainted := map[string]bool{}
+		tooLong := false
+
+		//\r, \n and \r\n all end a line, the empty line between \r and \n has no station
+		for _, line := range strings.FieldsFunc(chunk, func(r rune) bool { return r == '\r' || r == '\n' }) {
+			if len(line) > MAX_LINE_SIZE {
+				tooLong = true
+			}
+
+			i := strings.LastIndexByte(line, ';')
+			if i == -1 {
+				continue
+			}
+
+			station, temp, ok := referenceParse(line)
+			if !ok || !challengeTemp.MatchString(line[i+1:]) {
+				tainted[line[:i]] = true
+				continue
+			}
+
+			r, ok := want[station]
+			if !ok {
+				r = &StationResult{min: math.MaxInt, max: math.MinInt}
+				want[station] = r
+			}
+			r.min, r.max = min(r.min, temp), max(r.max, temp)
+			r.sum += temp
+			r.count++
+		}
+
+		tally := NewTally()
+		err := parseLines([]byte(chunk), 0, tally, o)
+		if tooLong {
+			if !errors.Is(err, errLineTooLong) {
+				t.Fatalf("got %v for a line longer than %d bytes in chunk %q", err, MAX_LINE_SIZE, chunk)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for station, w := range want {
+			if tainted[station] {
+				continue
+			}
+
+			got, ok := tally.Lookup(station)
+			if !ok {
+				t.Fatalf("station %q missing from chunk %q", station, chunk)
+			}
+			if got.min != w.min || got.max != w.max || got.sum != w.sum || got.count != w.count {
+				t.Fatalf("station %q = %d/%d/%d/%d, reference %d/%d/%d/%d in chunk %q",
+					station, got.min, got.max, got.sum, got.count, w.min, w.max, w.sum, w.count, chunk)
+			}
+		}
+
+		for _, station := range tally.names {
+			if _, ok := want[station]; !ok && !tainted[station] {
+				t.Fatalf("station %q isn't on any line of chunk %q", station, chunk)
+			}
+		}
+	})
+}
+
+// TestNoAllocsPerLine makes sure the per line work allocates nothing once every station has been seen:
+// parseLines over a chunk must allocate as much as over the same lines eight times, under each -key. parseTemp
+// and the tally lookup on their own must not allocate at all.
+func TestNoAllocsPerLine(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	names := make([]string, 500)
+	for i := range names {
+		names[i] = randomName(rng)
+	}
+
+	var sb strings.Builder
+	for range 2000 {
+		fmt.Fprintf(&sb, "%s;%s\n", names[rng.Intn(len(names))], formatTenths(rng.Intn(1999)-999))
+	}
+	chunk := []byte(sb.String())
+	repeated := bytes.Repeat(chunk, 8)
+
+	for _, keyName := range sortedKeys(keyFuncs) {
+		o, err := newOptions(WithKey(keyFuncs[keyName]))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//As a parser does, into a worker's own tally that has already seen every station
+		tally := workerTallies(1, NewTally())[0]
+		parseLines(chunk, -1, tally, o)
+
+		once := testing.AllocsPerRun(20, func() { parseLines(chunk, -1, tally, o) })
+		eight := testing.AllocsPerRun(20, func() { parseLines(repeated, -1, tally, o) })
+		if eight != once {
+			t.Errorf("-key=%s: parseLines allocates %.0f times over a chunk, %.0f over it 8 times, so %.3f per line",
+				keyName, once, eight, (eight-once)/float64(7*bytes.Count(chunk, []byte{'\n'})))
+		}
+	}
+
+	temp := []byte("-12.3")
+	if allocs := testing.AllocsPerRun(100, func() { parseTemp(temp) }); allocs != 0 {
+		t.Errorf("parseTemp allocates %.0f times", allocs)
+	}
+
+	tally := workerTallies(1, NewTally())[0]
+	station := []byte(names[0])
+	tally.Station(station, -1).AddAt(1, -1)
+	if allocs := testing.AllocsPerRun(100, func() { tally.Station(station, -1).AddAt(1, -1) }); allocs != 0 {
+		t.Errorf("looking up a known station allocates %.0f times", allocs)
+	}
+
+	//Merging matches stations by handle, a worker's stations already in the total cost nothing
+	o, err := newOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseLines(chunk, -1, tally, o)
+	total := NewTally()
+	total.Merge(tally)
+	if allocs := testing.AllocsPerRun(20, func() { total.Merge(tally) }); allocs != 0 {
+		t.Errorf("merging %d known stations allocates %.0f times", len(tally.names), allocs)
+	}
+
+	//and new ones only make room for themselves, their names are the interned strings
+	for id, name := range tally.names {
+		if got, _ := total.Lookup(name); got == nil || unsafe.StringData(total.names[total.ids[name]]) != unsafe.StringData(tally.names[id]) {
+			t.Errorf("station %q was merged as a copy of its name", name)
+		}
+	}
+}
+
+// testdata holds measurements-*.txt fixtures, each next to a .out golden of the exact expected output.
+//
+//go:embed testdata
+var testdata embed.FS
+
+// golden is a testdata fixture and the exact output it must give.
+type golden struct {
+	name, input, want string
+}
+
+// readGoldens reads every measurements fixture of testdata with its .out golden.
+func readGoldens(t *testing.T) []golden {
+	t.Helper()
+
+	fixtures, err := testdata.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var goldens []golden
+	for _, fixture := range fixtures {
+		name := fixture.Name()
+		if path.Ext(name) != ".txt" {
+			continue
+		}
+
+		input, err := testdata.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := testdata.ReadFile("testdata/" + strings.TrimSuffix(name, ".txt") + ".out")
+		if err != nil {
+			t.Fatalf("%s has no golden: %v", name, err)
+		}
+		goldens = append(goldens, golden{name, string(input), string(want)})
+	}
+	return goldens
+}
+
+// TestGolden runs every golden fixture through every strategy, as it is and repeated past a few chunks, with
+// station names kept as they are and copied into the interner's arena, as -preset=max does.
+func TestGolden(t *testing.T) {
+	defer func(intern bool) { internKeys = intern }(internKeys)
+
+	for _, intern := range []bool{false, true} {
+		internKeys = intern
+
+		for _, g := range readGoldens(t) {
+			t.Run(fmt.Sprintf("%s/intern=%t", g.name, intern), func(t *testing.T) {
+				expectEveryStrategy(t, g.input, g.want)
+
+				lines := g.input
+				if !strings.HasSuffix(lines, "\n") {
+					lines += "\n"
+				}
+				expectEveryStrategy(t, repeatPastChunks(lines), g.want)
+			})
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package brc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+var metadataFile = commandLine.String("metadata", "", "CSV `file` describing the stations for -group-by, a header row naming a station column and any others, e.g. station,country,region")
+var groupBy = commandLine.String("group-by", "station", "`column` of -metadata to roll the stations up by as the tallies are merged, e.g. country")
+
+// UNKNOWN_GROUP collects the stations -metadata doesn't list.
+const UNKNOWN_GROUP = "(unknown)"
+
+// readGroups reads the CSV at r and maps every station to its value in column.
+func readGroups(r io.Reader, column string) (map[string]string, error) {
+	records := csv.NewReader(r)
+	records.FieldsPerRecord = -1
+
+	header, err := records.Read()
+	if err != nil {
+		return nil, fmt.Errorf("no header row: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	station, group := slices.Index(header, "station"), slices.Index(header, column)
+	if station == -1 || group == -1 {
+		return nil, fmt.Errorf("want station and %s columns, the header has %s", column, strings.Join(header, ", "))
+	}
+
+	groups := map[string]string{}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			return groups, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if station < len(record) && group < len(record) {
+			groups[record[station]] = record[group]
+		}
+	}
+}
+
+func readGroupsFile(name, column string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups, err := readGroups(f, column)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return groups, nil
+}
+
+// Rollup returns t with every station merged into its group, stations groups doesn't list into UNKNOWN_GROUP.
+// Groups get no first seen offset, they have no single line to point at.
+func (t *Tally) Rollup(groups map[string]string) *Tally {
+	rolled := NewTally()
+
+	for id, name := range t.names {
+		group, ok := groups[name]
+		if !ok {
+			group = UNKNOWN_GROUP
+		}
+
+		r := *t.stat(id)
+		r.offset = -1
+		rolled.getName(group).Merge(&r)
+	}
+
+	for _, metric := range t.metrics {
+		rolled.metrics = append(rolled.metrics, metric.Rollup(groups))
+	}
+
+	return rolled
+}
@@ -0,0 +1,54 @@
+package brc
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const groupMetadata = "station,country,region\nHamburg,Germany,Europe\nBerlin,Germany,Europe\nLyon,France,Europe\n\"Lagos, NG\",Nigeria,Africa\n"
+
+// TestGroupBy rolls a tally up by every metadata column through every strategy.
+func TestGroupBy(t *testing.T) {
+	dir := t.TempDir()
+
+	file := dir + "/measurements.txt"
+	input := "Hamburg;1.0\nBerlin;3.0\nLyon;5.0\nLagos, NG;30.0\nOslo;-4.0\nHamburg;-1.0\n"
+	if err := os.WriteFile(file, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wants := map[string]string{
+		"country": "{(unknown)=-4.0/-4.0/-4.0, France=5.0/5.0/5.0, Germany=-1.0/1.0/3.0, Nigeria=30.0/30.0/30.0}\n",
+		"region":  "{(unknown)=-4.0/-4.0/-4.0, Africa=30.0/30.0/30.0, Europe=-1.0/2.0/5.0}\n",
+	}
+
+	for column, want := range wants {
+		groups, err := readGroups(strings.NewReader(groupMetadata), column)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, strategyName := range strings.Split(strategyNames(), ", ") {
+			if strategyName == "mmap" && !mmapSupported {
+				continue
+			}
+
+			tally, err := Process([]string{file}, WithStrategy(strategies[strategyName]))
+			if err != nil {
+				t.Fatalf("-strategy=%s: %v", strategyName, err)
+			}
+
+			buf := &bytes.Buffer{}
+			tally.Rollup(groups).Print(buf)
+			if buf.String() != want {
+				t.Fatalf("-group-by=%s -strategy=%s: got %q, want %q", column, strategyName, buf.String(), want)
+			}
+		}
+	}
+
+	if _, err := readGroups(strings.NewReader(groupMetadata), "continent"); err == nil {
+		t.Fatalf("-group-by=continent accepted without such a column")
+	}
+}
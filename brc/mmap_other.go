@@ -0,0 +1,18 @@
+//go:build !unix
+
+package brc
+
+import (
+	"errors"
+	"os"
+)
+
+const mmapSupported = false
+
+func mmapFile(filePtr *os.File, size int) ([]byte, error) {
+	return nil, errors.New("mmap strategy is not supported on this platform, use -strategy=pread")
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package brc
+
+import (
+	"os"
+	"syscall"
+)
+
+const mmapSupported = true
+
+func mmapFile(filePtr *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(filePtr.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,234 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var nfcStations = commandLine.Bool("nfc", false, "NFC normalize station names before -key, so an é written as e and a combining accent is aggregated with the precomposed é")
+var invalidUTF8 = commandLine.String("invalid-utf8", "pass", "station names that aren't valid UTF-8: pass them through as bytes or reject their lines")
+
+var invalidUTF8Modes = []string{"pass", "reject"}
+
+//go:embed nfc
+var nfcData embed.FS
+
+// Hangul syllables decompose into leading, vowel and trailing jamo arithmetically, as in the Unicode standard's 3.12.
+const (
+	HANGUL_S_BASE  = 0xAC00
+	HANGUL_L_BASE  = 0x1100
+	HANGUL_V_BASE  = 0x1161
+	HANGUL_T_BASE  = 0x11A7
+	HANGUL_L_COUNT = 19
+	HANGUL_V_COUNT = 21
+	HANGUL_T_COUNT = 28
+	HANGUL_N_COUNT = HANGUL_V_COUNT * HANGUL_T_COUNT
+	HANGUL_S_COUNT = HANGUL_L_COUNT * HANGUL_N_COUNT
+)
+
+// FIRST_NFC_CHECK is the first rune the quick check has to look up, every rune below it is a starter that
+// is NFC on its own and never composes with the rune before it.
+const FIRST_NFC_CHECK = 0x300
+
+// nfcTables are the embedded decompositions and combining classes, loaded on first use.
+type nfcTables struct {
+	decompose map[rune][]rune
+	compose   map[[2]rune]rune
+	combining map[rune]uint8
+
+	//Runes never in NFC, and runes that may compose with the one before them
+	no, maybe map[rune]bool
+}
+
+var nfc = sync.OnceValue(loadNFC)
+
+func loadNFC() *nfcTables {
+	t := &nfcTables{decompose: map[rune][]rune{}, compose: map[[2]rune]rune{}, combining: map[rune]uint8{}, no: map[rune]bool{}, maybe: map[rune]bool{}}
+
+	eachLine := func(name string, fn func(fields []string)) {
+		data, err := nfcData.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" && line[0] != '#' {
+				fn(strings.Fields(line))
+			}
+		}
+	}
+	hex := func(s string) rune {
+		r, err := strconv.ParseUint(s, 16, 32)
+		if err != nil {
+			panic("nfc: bad code point " + s)
+		}
+		return rune(r)
+	}
+
+	eachLine("nfc/combining.txt", func(fields []string) {
+		class, _ := strconv.Atoi(fields[1])
+		t.combining[hex(fields[0])] = uint8(class)
+	})
+	eachLine("nfc/decompositions.txt", func(fields []string) {
+		r := hex(fields[0])
+		excluded := fields[len(fields)-1] == "x"
+		if excluded {
+			fields = fields[:len(fields)-1]
+		}
+
+		for _, s := range fields[1:] {
+			t.decompose[r] = append(t.decompose[r], hex(s))
+		}
+		if excluded {
+			t.no[r] = true
+			return
+		}
+		pair := [2]rune(t.decompose[r])
+		t.compose[pair] = r
+		t.maybe[pair[1]] = true
+	})
+
+	for v := range rune(HANGUL_V_COUNT) {
+		t.maybe[HANGUL_V_BASE+v] = true
+	}
+	for tr := rune(1); tr < HANGUL_T_COUNT; tr++ {
+		t.maybe[HANGUL_T_BASE+tr] = true
+	}
+
+	return t
+}
+
+// isNFC is the quick check: every rune may be in NFC, none may compose with the one before it and the
+// combining marks are in canonical order.
+// Optimisation: a name of runes below FIRST_NFC_CHECK, which is all of ASCII and most of Latin, is
+// decided without a single lookup, and runs of ASCII are skipped a word at a time.
+func (t *nfcTables) isNFC(station []byte) bool {
+	//Eight ASCII bytes at a time first
+	i := 0
+	for ; i+8 <= len(station) && binary.LittleEndian.Uint64(station[i:])&0x8080808080808080 == 0; i += 8 {
+	}
+
+	last := uint8(0)
+	for i < len(station) {
+		if station[i] < utf8.RuneSelf {
+			i, last = i+1, 0
+			continue
+		}
+
+		r, size := utf8.DecodeRune(station[i:])
+		i += size
+		if r < FIRST_NFC_CHECK {
+			last = 0
+			continue
+		}
+
+		class := t.combining[r]
+		if t.no[r] || t.maybe[r] || class != 0 && last > class {
+			return false
+		}
+		last = class
+	}
+	return true
+}
+
+// appendDecomposed appends the full canonical decomposition of r.
+func (t *nfcTables) appendDecomposed(runes []rune, r rune) []rune {
+	if s := r - HANGUL_S_BASE; 0 <= s && s < HANGUL_S_COUNT {
+		runes = append(runes, HANGUL_L_BASE+s/HANGUL_N_COUNT, HANGUL_V_BASE+s%HANGUL_N_COUNT/HANGUL_T_COUNT)
+		if trailing := s % HANGUL_T_COUNT; trailing != 0 {
+			runes = append(runes, HANGUL_T_BASE+trailing)
+		}
+		return runes
+	}
+
+	parts, ok := t.decompose[r]
+	if !ok {
+		return append(runes, r)
+	}
+	for _, part := range parts {
+		runes = t.appendDecomposed(runes, part)
+	}
+	return runes
+}
+
+// composePair is the primary composite of a and b, if they have one.
+func (t *nfcTables) composePair(a, b rune) (rune, bool) {
+	if l, v := a-HANGUL_L_BASE, b-HANGUL_V_BASE; 0 <= l && l < HANGUL_L_COUNT && 0 <= v && v < HANGUL_V_COUNT {
+		return HANGUL_S_BASE + (l*HANGUL_V_COUNT+v)*HANGUL_T_COUNT, true
+	}
+	if s, tr := a-HANGUL_S_BASE, b-HANGUL_T_BASE; 0 <= s && s < HANGUL_S_COUNT && s%HANGUL_T_COUNT == 0 && 0 < tr && tr < HANGUL_T_COUNT {
+		return a + tr, true
+	}
+
+	r, ok := t.compose[[2]rune{a, b}]
+	return r, ok
+}
+
+// appendNFC appends the NFC form of station to dst: decomposed, the combining marks put in canonical order,
+// then composed back.
+func (t *nfcTables) appendNFC(dst, station []byte) []byte {
+	var runes []rune
+	for _, r := range string(station) {
+		runes = t.appendDecomposed(runes, r)
+	}
+
+	//Canonical ordering is a stable sort of each run of combining marks by class
+	for i := 1; i < len(runes); i++ {
+		for j := i; j > 0; j-- {
+			class, before := t.combining[runes[j]], t.combining[runes[j-1]]
+			if class == 0 || before <= class {
+				break
+			}
+			runes[j], runes[j-1] = runes[j-1], runes[j]
+		}
+	}
+
+	//A mark composes with the last starter unless a mark of the same or a higher class comes between them
+	composed := runes[:0]
+	starter := -1
+	last := uint8(0)
+	for _, r := range runes {
+		class := t.combining[r]
+		if starter != -1 && (last < class || last == 0 && starter == len(composed)-1) {
+			if c, ok := t.composePair(composed[starter], r); ok {
+				composed[starter] = c
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(composed)
+		}
+		last = class
+		composed = append(composed, r)
+	}
+
+	for _, r := range composed {
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst
+}
+
+// NFCKey wraps key so it sees every station NFC normalized, those already in NFC as they are and those that
+// aren't valid UTF-8 untouched. The normalized name is appended to dst and key appends after it.
+func NFCKey(key KeyFunc) KeyFunc {
+	t := nfc()
+
+	return func(dst, station []byte) []byte {
+		if !t.isNFC(station) && utf8.Valid(station) {
+			normalized := t.appendNFC(dst, station)
+			station, dst = normalized, normalized[len(normalized):]
+		}
+
+		if key == nil {
+			return station
+		}
+		return key(dst, station)
+	}
+}
@@ -0,0 +1,91 @@
+package brc
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// nfcCases are names and their NFC form, as Python's unicodedata.normalize gives them.
+var nfcCases = []struct{ name, want string }{
+	{"São Paulo", "São Paulo"},
+	{"Sa\u0303o Paulo", "S\u00e3o Paulo"},
+	{"U\u0308ru\u0308mqi", "\u00dcr\u00fcmqi"},
+	{"\u1e0b\u0323", "\u1e0d\u0307"},
+	{"q\u0307\u0323", "q\u0323\u0307"},
+	{"a\u0301\u0301", "\u00e1\u0301"},
+	{"\u212b", "\u00c5"},
+	{"\u0958", "\u0915\u093c"},
+	{"\u1100\u1161\u11a8", "\uac01"},
+	{"Bad\xff\u0301", "Bad\xff\u0301"},
+}
+
+// TestNFC checks NFCKey against nfcCases and that every composition in the tables comes back from its
+// decomposition, then that -nfc aggregates both spellings of a name as one and -invalid-utf8=reject drops
+// names that aren't UTF-8.
+func TestNFC(t *testing.T) {
+	defer func(filter func([]byte) bool, mode string) {
+		stationFilter, *invalidUTF8 = filter, mode
+	}(stationFilter, *invalidUTF8)
+
+	rng := rand.New(rand.NewSource(1))
+
+	key := NFCKey(nil)
+	for _, c := range nfcCases {
+		if got := string(key(nil, []byte(c.name))); got != c.want {
+			t.Fatalf("NFC of %+q is %+q, want %+q", c.name, got, c.want)
+		}
+	}
+
+	tables := nfc()
+	for pair, composite := range tables.compose {
+		decomposed := tables.appendDecomposed(nil, composite)
+		if got := []rune(string(key(nil, []byte(string(decomposed))))); len(got) != 1 || got[0] != composite || !tables.isNFC([]byte(string(composite))) {
+			t.Fatalf("%+q, the composition of %+q, normalizes to %+q", composite, pair, string(got))
+		}
+	}
+
+	dir := t.TempDir()
+
+	var sb strings.Builder
+	want := map[string]int{}
+	for range 1000 {
+		c := nfcCases[rng.Intn(len(nfcCases))]
+		name := c.name
+		if rng.Intn(2) == 0 {
+			name = c.want
+		}
+		fmt.Fprintf(&sb, "%s;1.0\n", name)
+		want[c.want]++
+	}
+	file := dir + "/names.txt"
+	if err := os.WriteFile(file, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range invalidUTF8Modes {
+		*invalidUTF8 = mode
+		filter, err := buildStationFilter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stationFilter = filter
+
+		tally, err := Process([]string{file}, WithKey(NFCKey(keyFuncs["name"])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, count := range want {
+			r, ok := tally.Lookup(name)
+			if rejected := mode == "reject" && !utf8.ValidString(name); ok == rejected {
+				t.Fatalf("-nfc -invalid-utf8=%s: %+q was aggregated %v", mode, name, ok)
+			}
+			if ok && r.count != count {
+				t.Fatalf("-nfc -invalid-utf8=%s: %+q was counted %d times, want %d", mode, name, r.count, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package brc
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+var numericStations = commandLine.String("numeric-stations", "auto", "array indexed fast path for stations named by integers: auto, on (assert every station is one) or off")
+
+const (
+	NUMERIC_AUTO = iota
+	NUMERIC_ON
+	NUMERIC_OFF
+)
+
+var numericModes = map[string]int{"auto": NUMERIC_AUTO, "on": NUMERIC_ON, "off": NUMERIC_OFF}
+
+// numericMode is -numeric-stations resolved once, the zero value is auto for subcommands that never set it.
+var numericMode = NUMERIC_AUTO
+
+// NUMERIC_PAGE_BITS sizes the two level station table, ids below 1<<(2*NUMERIC_PAGE_BITS) take the fast path.
+// Pages are only allocated for id ranges actually seen, so an empty table costs one 8KB directory per tally.
+const NUMERIC_PAGE_BITS = 10
+
+type numericPage [1 << NUMERIC_PAGE_BITS]atomic.Pointer[StationResult]
+
+type numericTable [1 << NUMERIC_PAGE_BITS]atomic.Pointer[numericPage]
+
+// numericID returns the value of station when it is a canonical decimal integer small enough for the table.
+// Canonical (no sign, no leading zeros) so that "7" and "007" can't share a slot, each id is exactly one name.
+func numericID(station []byte) (int, bool) {
+	if len(station) == 0 || len(station) > 7 || station[0] == '0' && len(station) > 1 {
+		return 0, false
+	}
+
+	id := 0
+	for _, c := range station {
+		if c-'0' > 9 {
+			return 0, false
+		}
+		id = id*10 + int(c-'0')
+	}
+
+	return id, id < 1<<(2*NUMERIC_PAGE_BITS)
+}
+
+// Station returns the result for station like GetAt does.
+// Optimisation: machine generated datasets often number their stations, those are found by indexing
+// a flat table with the parsed id instead of hashing the name.
+// New stations still go through GetAt so the map remains the one place every station is listed.
+func (t *Tally) Station(station []byte, offset int64) *StationResult {
+	if officialHash != nil {
+		if result := t.officialStation(station, offset); result != nil {
+			return result
+		}
+	}
+
+	if numericMode == NUMERIC_OFF {
+		return t.GetAt(station, offset)
+	}
+
+	id, ok := numericID(station)
+	if !ok {
+		if numericMode == NUMERIC_ON {
+			log.Fatalf("-numeric-stations=on but station %q is not an integer below %d", station, 1<<(2*NUMERIC_PAGE_BITS))
+		}
+		return t.GetAt(station, offset)
+	}
+
+	slot := &t.numeric[id>>NUMERIC_PAGE_BITS]
+	page := slot.Load()
+	if page == nil {
+		slot.CompareAndSwap(nil, &numericPage{})
+		page = slot.Load()
+	}
+
+	entry := &page[id&(1<<NUMERIC_PAGE_BITS-1)]
+	if result := entry.Load(); result != nil {
+		return result
+	}
+
+	result := t.GetAt(station, offset)
+	entry.Store(result)
+	return result
+}
@@ -0,0 +1,184 @@
+package brc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3:// and gs:// inputs are read over their HTTPS APIs with the same ranged GETs as any other URL, so the
+// dataset never has to be downloaded first. Credentials come from the environment, without any the request goes
+// out anonymously which is enough for a public bucket:
+//
+//	s3://  AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION or AWS_DEFAULT_REGION,
+//	       AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL for S3 compatible stores (path style addressing)
+//	gs://  GOOGLE_OAUTH_ACCESS_TOKEN, e.g. from gcloud auth print-access-token
+
+// S3_DEFAULT_REGION is used when neither AWS_REGION nor AWS_DEFAULT_REGION is set.
+const S3_DEFAULT_REGION = "us-east-1"
+
+const GCS_ENDPOINT = "https://storage.googleapis.com"
+
+func isObjectURL(name string) bool {
+	return strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "gs://")
+}
+
+// resolveRemote maps an input URL to the HTTPS request that fetches it.
+func resolveRemote(name string) (remote, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return remote{}, err
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if isObjectURL(name) && (u.Host == "" || key == "") {
+		return remote{}, fmt.Errorf("%s: want %s://bucket/key", name, u.Scheme)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return s3Remote(u.Host, key), nil
+	case "gs":
+		r := remote{url: GCS_ENDPOINT + "/" + u.Host + "/" + escapePath(key)}
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			r.sign = func(req *http.Request) error {
+				req.Header.Set("Authorization", "Bearer "+token)
+				return nil
+			}
+		}
+		return r, nil
+	}
+
+	return remote{url: name}, nil
+}
+
+// awsCredentials are what a request is signed with, token only for temporary credentials.
+type awsCredentials struct {
+	id, secret, token string
+}
+
+func s3Remote(bucket, key string) remote {
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = S3_DEFAULT_REGION
+	}
+
+	r := remote{url: "https://" + bucket + ".s3." + region + ".amazonaws.com/" + escapePath(key)}
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		r.url = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + escapePath(key)
+	}
+
+	creds := awsCredentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+	if creds.id != "" && creds.secret != "" {
+		r.sign = func(req *http.Request) error {
+			//The body of a GET is empty, there is nothing to hash
+			req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+			signV4(req, creds, region, "s3", clock.Now())
+			return nil
+		}
+	}
+
+	return r
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// escapePath percent encodes every byte of an object key outside RFC 3986's unreserved set, keeping the /.
+// This is the encoding SigV4 signs, so the URL sent and the one signed can't disagree.
+func escapePath(key string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) != -1 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+
+	return sb.String()
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req, signing its host and every X-Amz-* header.
+// The payload hash is X-Amz-Content-Sha256 when set, otherwise that of an empty body.
+func signV4(req *http.Request, creds awsCredentials, region, service string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+
+	req.Header.Set("X-Amz-Date", stamp)
+	if creds.token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.token)
+	}
+
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		empty := sha256.Sum256(nil)
+		payload = hex.EncodeToString(empty[:])
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := sortedKeys(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, payload}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + creds.secret)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.id, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.ReplaceAll(strings.Join(pairs, "&"), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,78 @@
+package brc
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestObjectStorage checks signV4 against the get-vanilla case of AWS's SigV4 test suite, then reads an s3://
+// input from a fake S3 compatible endpoint that only serves requests signed with the environment's keys.
+func TestObjectStorage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{"AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", ""}
+	signV4(req, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("get-vanilla signed as\n%s\nwant\n%s", got, want)
+	}
+
+	input, err := testdata.ReadFile("testdata/measurements-30-stations.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := testdata.ReadFile("testdata/measurements-30-stations.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/data/measurements 30.txt" {
+			http.NotFound(w, r)
+			return
+		}
+
+		//Signing it again must come out the same, or the keys or the canonical request differ
+		auth := r.Header.Get("Authorization")
+		stamp, _ := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		r.Header.Del("Authorization")
+		r.URL.Host = r.Host
+		signV4(r, awsCredentials{"AKIDSELFTEST", "selftest-secret", ""}, "eu-west-1", "s3", stamp)
+
+		if auth == "" || auth != r.Header.Get("Authorization") {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "measurements.txt", time.Time{}, bytes.NewReader(input))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDSELFTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "selftest-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+
+	defer func(ranges int, size int64) { *httpRanges, *partSize = ranges, size }(*httpRanges, *partSize)
+
+	for _, ranges := range []int{0, 4} {
+		*httpRanges, *partSize = ranges, int64(1+rng.Intn(len(input)))
+
+		got, err := runPipeline(StreamingStrategy{}, []string{"s3://bucket/data/measurements 30.txt"})
+		if err != nil {
+			t.Fatalf("-http-ranges=%d -part-size=%d: %v", ranges, *partSize, err)
+		}
+
+		if got != string(golden) {
+			t.Fatalf("-http-ranges=%d -part-size=%d:\ngot  %q\nwant %q", ranges, *partSize, got, golden)
+		}
+	}
+}
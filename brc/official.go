@@ -0,0 +1,142 @@
+package brc
+
+import (
+	_ "embed"
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+var officialStations = commandLine.Bool("official-stations", false, "direct indexed fast path for the 413 stations of the official challenge, found by a perfect hash with no probing; other stations still go through the map")
+
+// officialList is the station list of the challenge's measurement generator, one name a line.
+//
+//go:embed stations/official.txt
+var officialList string
+
+// PERFECT_BUCKETS is the fewest first level buckets a perfectHash spreads its names over, each with its own seed.
+// Bigger sets get a bucket per PERFECT_BUCKET_LOAD names, so every bucket stays small enough to place.
+const PERFECT_BUCKETS = 128
+const PERFECT_BUCKET_LOAD = 4
+
+// perfectHash gives each of a fixed set of names a slot of its own, by hash and displace: a name's hash picks
+// its bucket, and the bucket's seed, searched for when it is built, sends every name in it to a slot none
+// of the others took.
+type perfectHash struct {
+	seed  maphash.Seed
+	seeds []uint32
+	shift uint
+
+	//names by slot, "" for a free one, checked on each lookup as any other byte string may hash to a slot
+	names []string
+}
+
+// newPerfectHash builds a perfectHash of names in a table of the next power of two above them.
+func newPerfectHash(names []string) (*perfectHash, error) {
+	size := 1
+	for size < len(names) {
+		size *= 2
+	}
+
+	n := uint64(max(PERFECT_BUCKETS, len(names)/PERFECT_BUCKET_LOAD))
+	p := &perfectHash{seed: maphash.MakeSeed(), seeds: make([]uint32, n), shift: uint(64 - bits.TrailingZeros(uint(size))), names: make([]string, size)}
+
+	buckets := make([][]uint64, n)
+	for _, name := range names {
+		h := maphash.String(p.seed, name)
+		buckets[h%n] = append(buckets[h%n], h)
+	}
+
+	//Biggest buckets first, while most slots are free
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return len(buckets[b]) - len(buckets[a]) })
+
+	taken := make([]bool, size)
+	for _, b := range order {
+		seed, ok := p.displace(buckets[b], taken)
+		if !ok {
+			return nil, fmt.Errorf("no seed places the %d names of bucket %d", len(buckets[b]), b)
+		}
+		p.seeds[b] = seed
+	}
+
+	for _, name := range names {
+		slot := p.slotOf(maphash.String(p.seed, name))
+		if p.names[slot] != "" {
+			return nil, fmt.Errorf("%q and %q share slot %d, is a name listed twice?", p.names[slot], name, slot)
+		}
+		p.names[slot] = name
+	}
+	return p, nil
+}
+
+// displace finds a seed sending each of hashes to a slot free in taken and of its own, and takes them.
+func (p *perfectHash) displace(hashes []uint64, taken []bool) (uint32, bool) {
+	slots := make([]int, len(hashes))
+
+seeds:
+	for seed := uint32(0); seed < 1<<20; seed++ {
+		for i, h := range hashes {
+			slots[i] = int(perfectMix(h, seed) >> p.shift)
+			if taken[slots[i]] || slices.Contains(slots[:i], slots[i]) {
+				continue seeds
+			}
+		}
+
+		for _, slot := range slots {
+			taken[slot] = true
+		}
+		return seed, true
+	}
+	return 0, false
+}
+
+func perfectMix(h uint64, seed uint32) uint64 {
+	h ^= uint64(seed) * 0x9E3779B97F4A7C15
+	h ^= h >> 31
+	return h * 0xBF58476D1CE4E5B9
+}
+
+func (p *perfectHash) slotOf(h uint64) int {
+	return int(perfectMix(h, p.seeds[h%uint64(len(p.seeds))]) >> p.shift)
+}
+
+// slot returns the slot of station, false when it isn't one of the names.
+func (p *perfectHash) slot(station []byte) (int, bool) {
+	slot := p.slotOf(maphash.Bytes(p.seed, station))
+	return slot, p.names[slot] == string(station) && len(station) > 0
+}
+
+// officialHash is the perfect hash of officialList under -official-stations, or of the stations of
+// -seed-dictionary, nil otherwise.
+var officialHash *perfectHash
+
+func buildOfficialHash() (*perfectHash, error) {
+	return newPerfectHash(strings.Split(strings.TrimSuffix(officialList, "\n"), "\n"))
+}
+
+// officialStation returns the result for station when it is one of the official stations, nil otherwise.
+// Optimisation: one hash and one compare to a slot of a flat array, never a probe, for every line of an
+// input generated from the official list. New stations still go through GetAt like numeric ones.
+func (t *Tally) officialStation(station []byte, offset int64) *StationResult {
+	slot, ok := officialHash.slot(station)
+	if !ok {
+		return nil
+	}
+
+	if t.official == nil {
+		t.official = make([]*StationResult, len(officialHash.names))
+	}
+	if result := t.official[slot]; result != nil {
+		return result
+	}
+
+	result := t.GetAt(station, offset)
+	t.official[slot] = result
+	return result
+}
@@ -0,0 +1,71 @@
+package brc
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestOfficialStations checks the perfect hash finds each official station in a slot of its own and none of
+// a few near misses, and that lines of official and other stations mixed tally the same through the fast path.
+func TestOfficialStations(t *testing.T) {
+	defer func(p *perfectHash) { officialHash = p }(officialHash)
+	rng := rand.New(rand.NewSource(1))
+
+	p, err := buildOfficialHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := strings.Split(strings.TrimSuffix(officialList, "\n"), "\n")
+	official := map[string]bool{}
+	slots := map[int]string{}
+	for _, name := range names {
+		official[name] = true
+
+		slot, ok := p.slot([]byte(name))
+		if !ok {
+			t.Errorf("official station %q isn't found", name)
+			continue
+		}
+		if other, taken := slots[slot]; taken {
+			t.Errorf("%q and %q share slot %d", other, name, slot)
+		}
+		slots[slot] = name
+	}
+
+	for _, name := range names {
+		for _, miss := range []string{name[:len(name)-1], name + "x", strings.ToLower(name), "x" + name[1:], ""} {
+			if _, ok := p.slot([]byte(miss)); ok && !official[miss] {
+				t.Errorf("%q is found as an official station", miss)
+			}
+		}
+	}
+
+	var lines strings.Builder
+	for range 5000 {
+		name := names[rng.Intn(len(names))]
+		if rng.Intn(5) == 0 {
+			name = strings.ReplaceAll(randomName(rng), ";", "")
+		}
+		fmt.Fprintf(&lines, "%s;%.1f\n", name, float64(rng.Intn(1999)-999)/10)
+	}
+
+	officialHash = nil
+	want, err := runEveryStrategy(lines.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	officialHash = p
+	got, err := runEveryStrategy(lines.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for strategyName := range want {
+		if got[strategyName] != want[strategyName] {
+			t.Errorf("%s with -official-stations gave %q, the map %q", strategyName, got[strategyName], want[strategyName])
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MIN_CHUNK_SIZE keeps chunks well clear of MAX_LINE_SIZE, so a line longer than a chunk still reaches the
+// parser longer than MAX_LINE_SIZE rather than cut into pieces that each look fine.
+const MIN_CHUNK_SIZE = 4 * 1024
+
+// QUEUE_DEPTH_PER_WORKER is the default -queue-depth for each worker, enough to never leave one waiting on the reader.
+const QUEUE_DEPTH_PER_WORKER = 2
+
+// MEMORY_CHUNK_SHARE is the fraction, 1/n, of a memory budget given to chunks in flight. The rest is left for
+// the tallies, the runtime and the GC's headroom.
+const MEMORY_CHUNK_SHARE = 4
+
+// Options configure one run of the pipeline. Main builds them from the command line flags, code calling
+// Process sets them with the With* options instead, anything left unset keeps the challenge's defaults.
+type Options struct {
+	workers   int
+	chunkSize int
+	strategy  Strategy
+	stats     *Stats
+
+	//memory is the budget in bytes, 0 for none. depth caps the chunks queued for the parsers.
+	memory int64
+	depth  int
+
+	//how the lines are read and taken apart, see the With* option of each
+	serial     bool
+	sample     float64
+	directIO   bool
+	verify     string
+	verifyWarn bool
+	phase      int
+	delimiter  byte
+	key        KeyFunc
+
+	//buffers of chunkSize, the shared BufferPool when that is BUFFER_SIZE
+	pool *sync.Pool
+
+	bytes, chunks atomic.Int64
+}
+
+type Option func(o *Options)
+
+// WithWorkers sets the number of parser goroutines, and the number of segments pread splits a file into.
+func WithWorkers(n int) Option {
+	return func(o *Options) { o.workers = n }
+}
+
+// WithChunkSize sets how many bytes are read, or mapped, per chunk handed to a parser.
+func WithChunkSize(bytes int) Option {
+	return func(o *Options) { o.chunkSize = bytes }
+}
+
+// WithStrategy sets how files are read and parsed, one of strategies.
+func WithStrategy(strategy Strategy) Option {
+	return func(o *Options) { o.strategy = strategy }
+}
+
+// WithStats has Process fill in stats once it is done.
+func WithStats(stats *Stats) Option {
+	return func(o *Options) { o.stats = stats }
+}
+
+// WithMaxMemory fits the run into about bytes: chunks get smaller and fewer are queued at once, and files bigger
+// than bytes are streamed instead of mapped. It doesn't set the GC's limit, -max-memory does that for the process.
+func WithMaxMemory(bytes int64) Option {
+	return func(o *Options) { o.memory = bytes }
+}
+
+// WithQueueDepth sets how many chunks may wait for a parser before the reader blocks, 0 for the default.
+// Memory use is bounded by this plus the workers, times the chunk size.
+func WithQueueDepth(n int) Option {
+	return func(o *Options) { o.depth = n }
+}
+
+// WithSerial reads and parses on the calling goroutine, one chunk after the other.
+func WithSerial(serial bool) Option {
+	return func(o *Options) { o.serial = serial }
+}
+
+// WithSample parses about fraction of each regular file in whole chunks spread over it, 0 for all of it.
+// Counts and sums are scaled up to match.
+func WithSample(fraction float64) Option {
+	return func(o *Options) { o.sample = fraction }
+}
+
+// WithDirectIO reads the inputs bypassing the page cache, where the OS supports it.
+func WithDirectIO(direct bool) Option {
+	return func(o *Options) { o.directIO = direct }
+}
+
+// WithVerifySHA256 fails the run unless the input hashes to digest, 64 hex digits, or only warns with warn.
+// "" verifies nothing.
+func WithVerifySHA256(digest string, warn bool) Option {
+	return func(o *Options) { o.verify, o.verifyWarn = strings.ToLower(digest), warn }
+}
+
+// WithPhase sets how far each line is taken, one of phaseModes.
+func WithPhase(phase int) Option {
+	return func(o *Options) { o.phase = phase }
+}
+
+// WithDelimiter sets the byte separating station and temperature.
+func WithDelimiter(sep byte) Option {
+	return func(o *Options) { o.delimiter = sep }
+}
+
+// WithKey aggregates every station under what key maps it to, nil (the default) by the name as is.
+func WithKey(key KeyFunc) Option {
+	return func(o *Options) { o.key = key }
+}
+
+// Stats describe a finished Process run.
+type Stats struct {
+	Files, Stations int
+	Bytes, Chunks   int64
+	Elapsed         time.Duration
+}
+
+func newOptions(opts ...Option) (*Options, error) {
+	o := &Options{workers: runtime.NumCPU(), chunkSize: BUFFER_SIZE, strategy: StreamingStrategy{}, pool: BufferPool, phase: PHASE_ALL, delimiter: ';'}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var errs []error
+	if o.workers < 1 {
+		errs = append(errs, fmt.Errorf("workers must be at least 1, got %d", o.workers))
+	}
+	if o.chunkSize < MIN_CHUNK_SIZE {
+		errs = append(errs, fmt.Errorf("chunk size must be at least %d bytes, got %d", MIN_CHUNK_SIZE, o.chunkSize))
+	}
+	if o.strategy == nil {
+		errs = append(errs, errors.New("no strategy"))
+	}
+	if o.depth < 0 {
+		errs = append(errs, fmt.Errorf("queue depth must not be negative, got %d", o.depth))
+	}
+	if o.memory < 0 {
+		errs = append(errs, fmt.Errorf("memory budget must not be negative, got %d", o.memory))
+	}
+	if o.sample < 0 || o.sample > 1 {
+		errs = append(errs, fmt.Errorf("sample must be a fraction between 0 and 1, got %v", o.sample))
+	}
+	if o.verify != "" && !sha256Hex.MatchString(o.verify) {
+		errs = append(errs, fmt.Errorf("sha256 must be 64 hex digits, got %q", o.verify))
+	}
+	if o.phase != PHASE_ALL && o.phase != PHASE_IO && o.phase != PHASE_PARSE {
+		errs = append(errs, fmt.Errorf("unknown phase %d", o.phase))
+	}
+	if _, err := parseDelimiter(string(o.delimiter)); err != nil {
+		errs = append(errs, err)
+	}
+
+	//Every worker has a chunk being parsed and at least one more queued, shrink chunks until that many fit
+	if share := o.memory / MEMORY_CHUNK_SHARE; o.memory > 0 && o.workers > 0 {
+		o.chunkSize = int(min(int64(o.chunkSize), max(MIN_CHUNK_SIZE, share/int64(2*o.workers))))
+		if o.depth == 0 {
+			o.depth = int(max(int64(o.workers), share/int64(o.chunkSize)-int64(o.workers)))
+		}
+	}
+	if o.depth == 0 {
+		o.depth = QUEUE_DEPTH_PER_WORKER * o.workers
+	}
+
+	if o.chunkSize != BUFFER_SIZE {
+		size := o.chunkSize
+		o.pool = &sync.Pool{New: func() interface{} { return make([]byte, 0, size) }}
+	}
+
+	return o, errors.Join(errs...)
+}
+
+// counted tallies chunk into the Stats of the run.
+func (o *Options) counted(chunk Chunk) {
+	o.bytes.Add(int64(len(chunk.data)))
+	o.chunks.Add(1)
+}
+
+// Process aggregates files, paths or URLs, into one tally. It is the entry point for running the pipeline
+// from code rather than the command line.
+func Process(files []string, opts ...Option) (*Tally, error) {
+	o, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	start := clock.Now()
+	tallies, err := processFiles(o, files)
+	if err != nil {
+		return nil, err
+	}
+
+	total := NewTally()
+	for _, tally := range tallies {
+		total.Merge(tally)
+	}
+
+	if o.stats != nil {
+		*o.stats = Stats{len(files), len(total.names), o.bytes.Load(), o.chunks.Load(), clock.Since(start)}
+	}
+
+	return total, nil
+}
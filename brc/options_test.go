@@ -0,0 +1,81 @@
+package brc
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestOptions runs random files through Process with every strategy and small chunks on an odd number of
+// workers, which must agree with the defaults and count every byte in its Stats, then checks Process
+// rejects options that make no sense.
+func TestOptions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	files, err := writeRandomFiles(rng, t.TempDir(), 2, BUFFER_SIZE+rng.Intn(BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := int64(0)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+
+	chunkSize := MIN_CHUNK_SIZE + rng.Intn(MIN_CHUNK_SIZE)
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		want, err := runPipeline(strategies[strategyName], files)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := Stats{}
+		tally, err := Process(files, WithStrategy(strategies[strategyName]), WithWorkers(3), WithChunkSize(chunkSize), WithStats(&stats))
+		if err != nil {
+			t.Fatalf("-strategy=%s: %v", strategyName, err)
+		}
+
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		if buf.String() != want {
+			t.Fatalf("-strategy=%s with %d byte chunks: got %q, want %q", strategyName, chunkSize, buf.String(), want)
+		}
+
+		//naive scans the file itself, without chunks. The others run a chunk up to the end of a line, by less than a chunk
+		if stats.Bytes != size || strategyName != "naive" && stats.Chunks < size/int64(2*chunkSize) {
+			t.Fatalf("-strategy=%s with %d byte chunks: stats counted %d bytes in %d chunks of %d bytes",
+				strategyName, chunkSize, stats.Bytes, stats.Chunks, size)
+		}
+		if stats.Files != len(files) || stats.Stations != len(tally.names) {
+			t.Fatalf("-strategy=%s: stats %+v for %d files and %d stations", strategyName, stats, len(files), len(tally.names))
+		}
+	}
+
+	for name, opts := range map[string][]Option{
+		"no workers":        {WithWorkers(0)},
+		"tiny chunks":       {WithChunkSize(MIN_CHUNK_SIZE - 1)},
+		"no strategy":       {WithStrategy(nil)},
+		"negative depth":    {WithQueueDepth(-1)},
+		"negative memory":   {WithMaxMemory(-1)},
+		"sample over 1":     {WithSample(1.5)},
+		"short sha256":      {WithVerifySHA256("abc", false)},
+		"unknown phase":     {WithPhase(-1)},
+		"numeric delimiter": {WithDelimiter('1')},
+		"newline delimiter": {WithDelimiter('\n')},
+	} {
+		if _, err := Process(files, opts...); err == nil {
+			t.Errorf("Process accepted %s", name)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package brc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var otelEndpoint = commandLine.String("otel-endpoint", "", "OTLP/HTTP `url` of a collector, e.g. http://localhost:4318, to export the run's trace and its byte, line, chunk and phase metrics to as JSON")
+
+// OTEL_SERVICE is the service.name every span and metric is exported under.
+const OTEL_SERVICE = "1brc"
+
+// otelSpan is a span of the run's trace, kept until the run is exported. A nil span is tracing turned off,
+// every method does nothing.
+type otelSpan struct {
+	id, parent   string
+	name         string
+	start, ended time.Time
+	attributes   []otelAttribute
+}
+
+type otelAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func stringAttribute(key, value string) otelAttribute {
+	return otelAttribute{key, map[string]any{"stringValue": value}}
+}
+
+// intAttribute is an integer attribute, which OTLP JSON writes as a string to survive 64 bits.
+func intAttribute(key string, value int64) otelAttribute {
+	return otelAttribute{key, map[string]any{"intValue": strconv.FormatInt(value, 10)}}
+}
+
+// otelTrace is every span ended so far under one trace id.
+var otelTrace = struct {
+	id    string
+	spans []*otelSpan
+	m     sync.Mutex
+}{}
+
+// runSpan is the root span of the run, the parent of every other, nil without -otel-endpoint.
+var runSpan *otelSpan
+
+func otelID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// startSpan starts a span called name under parent, nil for a root span, or returns nil without -otel-endpoint.
+func startSpan(name string, parent *otelSpan) *otelSpan {
+	if *otelEndpoint == "" {
+		return nil
+	}
+
+	otelTrace.m.Lock()
+	if otelTrace.id == "" {
+		otelTrace.id = otelID(16)
+	}
+	otelTrace.m.Unlock()
+
+	span := &otelSpan{id: otelID(8), name: name, start: clock.Now()}
+	if parent != nil {
+		span.parent = parent.id
+	}
+	return span
+}
+
+// end ends s with attributes added to any it has.
+func (s *otelSpan) end(attributes ...otelAttribute) {
+	if s == nil {
+		return
+	}
+
+	s.ended = clock.Now()
+	s.attributes = append(s.attributes, attributes...)
+	otelTrace.m.Lock()
+	otelTrace.spans = append(otelTrace.spans, s)
+	otelTrace.m.Unlock()
+}
+
+// phaseAttributes are where the time of a run went, read, parse and aggregate summed over goroutines.
+func phaseAttributes(p Phases) []otelAttribute {
+	return []otelAttribute{
+		intAttribute("brc.phase.read_ns", int64(p.Read)), intAttribute("brc.phase.parse_ns", int64(p.Parse)),
+		intAttribute("brc.phase.aggregate_ns", int64(p.Aggregate)), intAttribute("brc.phase.merge_ns", int64(p.Merge)),
+		intAttribute("brc.phase.sort_ns", int64(p.Sort)), intAttribute("brc.phase.output_ns", int64(p.Output)),
+	}
+}
+
+// otelCounts are the totals of a run exported as cumulative sums.
+type otelCounts struct {
+	bytes, lines, chunks int64
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otelResource() map[string]any {
+	return map[string]any{"attributes": []otelAttribute{
+		stringAttribute("service.name", OTEL_SERVICE), stringAttribute("brc.strategy", *strategyName),
+		intAttribute("brc.workers", int64(*workers)),
+	}}
+}
+
+// otelTraces is the OTLP ExportTraceServiceRequest of every span ended so far.
+func otelTraces() map[string]any {
+	otelTrace.m.Lock()
+	defer otelTrace.m.Unlock()
+
+	spans := make([]map[string]any, len(otelTrace.spans))
+	for i, s := range otelTrace.spans {
+		spans[i] = map[string]any{
+			"traceId": otelTrace.id, "spanId": s.id, "name": s.name,
+			//SPAN_KIND_INTERNAL
+			"kind":              1,
+			"startTimeUnixNano": unixNano(s.start), "endTimeUnixNano": unixNano(s.ended),
+			"attributes": s.attributes,
+		}
+		if s.parent != "" {
+			spans[i]["parentSpanId"] = s.parent
+		}
+	}
+
+	return map[string]any{"resourceSpans": []map[string]any{{
+		"resource":   otelResource(),
+		"scopeSpans": []map[string]any{{"scope": map[string]any{"name": OTEL_SERVICE}, "spans": spans}},
+	}}}
+}
+
+// otelMetrics is the OTLP ExportMetricsServiceRequest of a run from start to end: the counts as monotonic
+// sums and each phase as a gauge of nanoseconds.
+func otelMetrics(counts otelCounts, phases Phases, start, end time.Time) map[string]any {
+	sum := func(name, unit string, value int64) map[string]any {
+		return map[string]any{"name": name, "unit": unit, "sum": map[string]any{
+			//AGGREGATION_TEMPORALITY_CUMULATIVE
+			"aggregationTemporality": 2,
+			"isMonotonic":            true,
+			"dataPoints": []map[string]any{{
+				"startTimeUnixNano": unixNano(start), "timeUnixNano": unixNano(end), "asInt": strconv.FormatInt(value, 10),
+			}},
+		}}
+	}
+
+	var points []map[string]any
+	for _, a := range phaseAttributes(phases) {
+		phase := strings.TrimSuffix(strings.TrimPrefix(a.Key, "brc.phase."), "_ns")
+		points = append(points, map[string]any{
+			"timeUnixNano": unixNano(end), "asInt": a.Value["intValue"],
+			"attributes": []otelAttribute{stringAttribute("phase", phase)},
+		})
+	}
+
+	metrics := []map[string]any{
+		sum("brc.bytes", "By", counts.bytes),
+		sum("brc.lines", "{line}", counts.lines),
+		sum("brc.chunks", "{chunk}", counts.chunks),
+		{"name": "brc.phase.duration", "unit": "ns", "gauge": map[string]any{"dataPoints": points}},
+	}
+
+	return map[string]any{"resourceMetrics": []map[string]any{{
+		"resource":     otelResource(),
+		"scopeMetrics": []map[string]any{{"scope": map[string]any{"name": OTEL_SERVICE}, "metrics": metrics}},
+	}}}
+}
+
+// exportOTel POSTs the trace and the metrics of a run to the collector at endpoint's /v1/traces and /v1/metrics.
+func exportOTel(endpoint string, counts otelCounts, phases Phases, start, end time.Time) error {
+	base := strings.TrimSuffix(endpoint, "/")
+	return errors.Join(
+		postOTLP(base+"/v1/traces", otelTraces()),
+		postOTLP(base+"/v1/metrics", otelMetrics(counts, phases, start, end)),
+	)
+}
+
+func postOTLP(url string, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestOTel traces processing a file to a fake collector, checking the spans nest run, process, file and
+// the bytes counter adds up.
+func TestOTel(t *testing.T) {
+	posts := map[string][]byte{}
+	var m sync.Mutex
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		m.Lock()
+		posts[r.URL.Path] = body
+		m.Unlock()
+	}))
+	defer collector.Close()
+
+	defer func(endpoint string, span *otelSpan) {
+		*otelEndpoint, runSpan = endpoint, span
+		otelTrace.m.Lock()
+		otelTrace.id, otelTrace.spans = "", nil
+		otelTrace.m.Unlock()
+	}(*otelEndpoint, runSpan)
+	*otelEndpoint = collector.URL
+
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte("A;1.0\nB;2.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := newOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := clock.Now()
+	runSpan = startSpan("run", nil)
+	if _, err := processFiles(o, []string{file}); err != nil {
+		t.Fatal(err)
+	}
+	runSpan.end()
+	if err := exportOTel(collector.URL+"/", otelCounts{o.bytes.Load(), 2, o.chunks.Load()}, Phases{}, start, clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var traces struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID, SpanID, ParentSpanID, Name string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(posts["/v1/traces"], &traces); err != nil {
+		t.Fatalf("/v1/traces: %v", err)
+	}
+	ids, parents := map[string]string{}, map[string]string{}
+	for _, span := range traces.ResourceSpans[0].ScopeSpans[0].Spans {
+		ids[span.Name], parents[span.Name] = span.SpanID, span.ParentSpanID
+	}
+	if len(ids) != 3 || parents["run"] != "" || parents["process"] != ids["run"] || parents["file"] != ids["process"] {
+		t.Fatalf("spans %v with parents %v, want file under process under run", ids, parents)
+	}
+
+	if want := fmt.Sprintf(`"name":"brc.bytes","sum":{"aggregationTemporality":2,"dataPoints":[{"asInt":"%d"`, 12); !bytes.Contains(posts["/v1/metrics"], []byte(want)) {
+		t.Fatalf("/v1/metrics %s doesn't have %s", posts["/v1/metrics"], want)
+	}
+}
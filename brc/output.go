@@ -0,0 +1,374 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+var outputFormat = commandLine.String("output-format", "text", "where and how results are reported: text (the challenge format), chart (a min to max whisker per station for a terminal), csv, jsonl, json, arrow (an Arrow IPC file for pandas and polars), sqlite or http")
+var outputFile = commandLine.String("output", "", "write results to `file` instead of stdout, the database for sqlite, the URL to POST to for http")
+var appendRunID = commandLine.String("append-run-id", "", "tag results with a run_id column set to `id` and append them to -output instead of overwriting it")
+
+// StationRow is one station of the long format csv and jsonl outputs. Values are formatted exactly as in
+// the text output so every format agrees, and kept as json.Number so jsonl doesn't print 12.300000000000001.
+type StationRow struct {
+	RunID   string      `json:"run_id,omitempty"`
+	Station string      `json:"station"`
+	Min     json.Number `json:"min"`
+	Mean    json.Number `json:"mean"`
+	Max     json.Number `json:"max"`
+	Count   int         `json:"count"`
+
+	//Population standard deviation in degrees, empty when a merged partial didn't record it
+	Stddev json.Number `json:"stddev,omitempty"`
+
+	//Fewer measurements than -min-count, only ever set with -sparse=flag
+	Sparse bool `json:"sparse,omitempty"`
+
+	//-reservoir's random sample of the readings, in the order kept
+	Sample []json.Number `json:"sample,omitempty"`
+}
+
+// Rows returns the stations of t in output order, each tagged with runID.
+func (t *Tally) Rows(runID string) []StationRow {
+	names := t.sortedNames()
+	unit := outputUnit()
+
+	rows := make([]StationRow, len(names))
+	for i, k := range names {
+		v, _ := t.Lookup(k)
+		rows[i] = StationRow{
+			RunID:   runID,
+			Station: k,
+			Min:     json.Number(formatValue(unit.value(v.min))),
+			Mean:    json.Number(formatValue(unit.mean(v.sum, v.count))),
+			Max:     json.Number(formatValue(unit.value(v.max))),
+			Count:   v.count,
+		}
+
+		if stddev, ok := v.Stddev(); ok {
+			rows[i].Stddev = json.Number(fmt.Sprintf("%.2f", unit.degrees(stddev)))
+		}
+		rows[i].Sparse = flagSparse() && sparse(v)
+		if v.sample != nil {
+			rows[i].Sample = make([]json.Number, len(v.sample.values))
+			for j, temp := range v.sample.values {
+				rows[i].Sample[j] = json.Number(formatValue(unit.value(temp)))
+			}
+		}
+	}
+
+	return rows
+}
+
+func csvHeader(runID string) []string {
+	header := []string{"station", "min", "mean", "max", "count"}
+	if flagSparse() {
+		header = append(header, "sparse")
+	}
+	if runID != "" {
+		header = append([]string{"run_id"}, header...)
+	}
+	return header
+}
+
+func writeCSV(w io.Writer, rows []StationRow, runID string, header bool) error {
+	cw := csv.NewWriter(w)
+
+	if header {
+		cw.Write(csvHeader(runID))
+	}
+
+	for _, r := range rows {
+		record := []string{r.Station, r.Min.String(), r.Mean.String(), r.Max.String(), strconv.Itoa(r.Count)}
+		if flagSparse() {
+			record = append(record, strconv.FormatBool(r.Sparse))
+		}
+		if runID != "" {
+			record = append([]string{runID}, record...)
+		}
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSONLines(w io.Writer, rows []StationRow) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// checkAppendHeader makes sure a csv being appended to has the columns about to be written,
+// so a nightly job can't silently mix runs with and without run_id into one file.
+func checkAppendHeader(f *os.File, runID string) error {
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if want := strings.Join(csvHeader(runID), ","); strings.TrimRight(line, "\r\n") != want {
+		return fmt.Errorf("%s has header %q, want %q", f.Name(), strings.TrimRight(line, "\r\n"), want)
+	}
+
+	_, err = f.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Report is a finished run as handed to a Reporter. Phases are as of the moment reporting started.
+type Report struct {
+	RunID  string
+	Tally  *Tally
+	Phases Phases
+}
+
+// Reporter delivers a finished run to one destination. Destinations register a constructor in
+// reporters and are picked with -output-format, the pipeline itself only ever calls Report.
+type Reporter interface {
+	Report(r Report) error
+}
+
+// reporters build the Reporter for each -output-format from -output.
+var reporters = map[string]func(output string) Reporter{
+	"text":   func(output string) Reporter { return fileReporter{output, writeText, nil} },
+	"chart":  func(output string) Reporter { return fileReporter{output, writeChart, nil} },
+	"csv":    func(output string) Reporter { return fileReporter{output, writeCSVReport, checkAppendHeader} },
+	"jsonl":  func(output string) Reporter { return fileReporter{output, writeJSONLinesReport, nil} },
+	"json":   func(output string) Reporter { return fileReporter{output, writeJSONReport, nil} },
+	"arrow":  func(output string) Reporter { return fileReporter{output, writeArrowReport, nil} },
+	"sqlite": func(output string) Reporter { return sqliteReporter{output} },
+	"http":   func(output string) Reporter { return httpReporter{output} },
+}
+
+// outputExtensions pick -output-format from the -output file name when the format isn't given,
+// so -output results.db is all it takes to get a SQLite database.
+var outputExtensions = map[string]string{
+	".db":      "sqlite",
+	".sqlite":  "sqlite",
+	".sqlite3": "sqlite",
+	".csv":     "csv",
+	".jsonl":   "jsonl",
+	".json":    "json",
+	".arrow":   "arrow",
+	".feather": "arrow",
+}
+
+// inferOutputFormat applies outputExtensions, an explicit -output-format always wins.
+func inferOutputFormat() {
+	if flagSet("output-format") {
+		return
+	}
+
+	if format, ok := outputExtensions[strings.ToLower(filepath.Ext(*outputFile))]; ok {
+		*outputFormat = format
+	}
+}
+
+// timedRows is Rows counted as the sort phase, with the counts of a -sample run estimated.
+func timedRows(r Report) []StationRow {
+	defer timePhase(&phaseTimes.sort)()
+	rows := r.Tally.Rows(r.RunID)
+	for i := range rows {
+		rows[i].Count = estimate(rows[i].Count)
+	}
+	return rows
+}
+
+func writeText(w io.Writer, r Report, header bool) error {
+	r.Tally.Print(w)
+	return nil
+}
+
+func writeCSVReport(w io.Writer, r Report, header bool) error {
+	rows := timedRows(r)
+	defer timePhase(&phaseTimes.output)()
+	return writeCSV(w, rows, r.RunID, header)
+}
+
+func writeJSONLinesReport(w io.Writer, r Report, header bool) error {
+	rows := timedRows(r)
+	defer timePhase(&phaseTimes.output)()
+	return writeJSONLines(w, rows)
+}
+
+// jsonReport is the single document the json and http reporters send.
+type jsonReport struct {
+	RunID    string       `json:"run_id,omitempty"`
+	Strategy string       `json:"strategy"`
+	Timing   Phases       `json:"timing"`
+	Stations []StationRow `json:"stations"`
+
+	//Fraction of the input a -sample run parsed, counts are estimates scaled up from it
+	Sampled float64 `json:"sampled,omitempty"`
+}
+
+func newJSONReport(r Report) jsonReport {
+	rows := timedRows(Report{Tally: r.Tally})
+	return jsonReport{r.RunID, *strategyName, r.Phases, rows, sampledFraction()}
+}
+
+// writeJSONReport writes one compact document per line, so appended runs stay readable as JSON lines.
+func writeJSONReport(w io.Writer, r Report, header bool) error {
+	doc := newJSONReport(r)
+	defer timePhase(&phaseTimes.output)()
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// fileReporter writes to file, or stdout when file is "". A run id appends to what earlier runs left,
+// header then tells write whether the file was empty and check can refuse a file of a different shape.
+type fileReporter struct {
+	file  string
+	write func(w io.Writer, r Report, header bool) error
+	check func(f *os.File, runID string) error
+}
+
+func (fr fileReporter) Report(r Report) error {
+	if fr.file == "" {
+		return fr.write(os.Stdout, r, true)
+	}
+
+	if r.RunID == "" {
+		f, err := os.Create(fr.file)
+		if err != nil {
+			return err
+		}
+		return errors.Join(fr.write(f, r, true), f.Close())
+	}
+
+	f, err := os.OpenFile(fr.file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := info.Size() == 0
+	if !header && fr.check != nil {
+		if err := fr.check(f, r.RunID); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(fr.write(f, r, header), f.Close())
+}
+
+// sqliteReporter appends each run to the runs and results tables of the database at file, through the pure
+// Go modernc.org/sqlite driver so the binary needs neither cgo nor a sqlite3 shell.
+type sqliteReporter struct {
+	file string
+}
+
+// sqlNull is s, or NULL when it is empty.
+func sqlNull(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (sr sqliteReporter) Report(r Report) error {
+	rows := timedRows(r)
+	defer timePhase(&phaseTimes.output)()
+
+	db, err := sql.Open("sqlite", sr.file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sr.file, err)
+	}
+	if err := sr.insert(db, r, rows); err != nil {
+		db.Close()
+		return fmt.Errorf("%s: %w", sr.file, err)
+	}
+	return db.Close()
+}
+
+// insert adds the run and its rows in one transaction, creating the tables on first use.
+func (sr sqliteReporter) insert(db *sql.DB, r Report, rows []StationRow) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, create := range []string{
+		"CREATE TABLE IF NOT EXISTS runs (run_id TEXT, strategy TEXT, elapsed_ns INTEGER, recorded_at TEXT)",
+		"CREATE TABLE IF NOT EXISTS results (run_id TEXT, station TEXT, min REAL, mean REAL, max REAL, count INTEGER, stddev REAL)",
+	} {
+		if _, err := tx.Exec(create); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO runs VALUES (?, ?, ?, ?)",
+		sqlNull(r.RunID), sqlNull(*strategyName), r.Phases.Total.Nanoseconds(), clock.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare("INSERT INTO results (run_id, station, min, mean, max, count, stddev) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, row := range rows {
+		var stddev sql.NullString
+		if row.Stddev != "" {
+			stddev = sqlNull(row.Stddev.String())
+		}
+
+		if _, err := insert.Exec(sqlNull(r.RunID), row.Station, row.Min.String(), row.Mean.String(), row.Max.String(), row.Count, stddev); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// httpReporter POSTs the json report to url, for dashboards and collectors that take pushes.
+type httpReporter struct {
+	url string
+}
+
+func (hr httpReporter) Report(r Report) error {
+	body, err := json.Marshal(newJSONReport(r))
+	if err != nil {
+		return err
+	}
+	defer timePhase(&phaseTimes.output)()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(hr.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", hr.url, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package brc
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// TestSQLite reports two runs into the same database and reads both back, rows of the second appended to the
+// first's.
+func TestSQLite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "results.db")
+	tally := NewTally()
+	tally.Get([]byte("Abha")).Add(-23)
+	tally.Get([]byte("Abha")).Add(592)
+	tally.Get([]byte("O'Hare")).Add(10)
+
+	for _, runID := range []string{"first", "second"} {
+		if err := (sqliteReporter{file}).Report(Report{RunID: runID, Tally: tally}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var runs int
+	if err := db.QueryRow("SELECT count(*) FROM runs").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Fatalf("%d runs recorded, want 2", runs)
+	}
+
+	var min, mean, max float64
+	var count int
+	var stddev sql.NullFloat64
+	if err := db.QueryRow("SELECT min, mean, max, count, stddev FROM results WHERE run_id = 'second' AND station = 'Abha'").Scan(&min, &mean, &max, &count, &stddev); err != nil {
+		t.Fatal(err)
+	}
+	if min != -2.3 || mean != 28.5 || max != 59.2 || count != 2 {
+		t.Fatalf("Abha read back as %v/%v/%v of %d readings, want -2.3/28.5/59.2 of 2", min, mean, max, count)
+	}
+
+	var stations int
+	if err := db.QueryRow("SELECT count(*) FROM results WHERE station = 'O''Hare'").Scan(&stations); err != nil {
+		t.Fatal(err)
+	}
+	if stations != 2 {
+		t.Fatalf("O'Hare recorded %d times, want 2", stations)
+	}
+}
@@ -0,0 +1,588 @@
+package brc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var parquetColumns = commandLine.String("parquet-columns", "station,temperature", "`station,temperature` column names read from Parquet inputs")
+
+// Parquet inputs are read without a third party module: the footer is Thrift compact protocol, pages may be
+// PLAIN or dictionary encoded, data pages v1 or v2, uncompressed, SNAPPY or GZIP. That covers what pyarrow and
+// DuckDB write by default, ZSTD (polars' default) is refused with an error naming the codec.
+const PARQUET_MAGIC = "PAR1"
+
+// Parquet's physical types, page types, encodings and codecs, numbered as in parquet.thrift.
+const (
+	PARQUET_INT32      = 1
+	PARQUET_INT64      = 2
+	PARQUET_FLOAT      = 4
+	PARQUET_DOUBLE     = 5
+	PARQUET_BYTE_ARRAY = 6
+
+	PARQUET_DATA_PAGE       = 0
+	PARQUET_DICTIONARY_PAGE = 2
+	PARQUET_DATA_PAGE_V2    = 3
+
+	PARQUET_PLAIN            = 0
+	PARQUET_PLAIN_DICTIONARY = 2
+	PARQUET_RLE_DICTIONARY   = 8
+
+	PARQUET_UNCOMPRESSED = 0
+	PARQUET_SNAPPY       = 1
+	PARQUET_GZIP         = 2
+
+	PARQUET_OPTIONAL = 1
+)
+
+var parquetCodecs = []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+
+// isParquet reports whether a file of size bytes starts and ends with Parquet's magic.
+func isParquet(filePtr io.ReaderAt, size int64) bool {
+	head, tail := make([]byte, 4), make([]byte, 4)
+	if size < 12 {
+		return false
+	}
+
+	filePtr.ReadAt(head, 0)
+	filePtr.ReadAt(tail, size-4)
+	return string(head) == PARQUET_MAGIC && string(tail) == PARQUET_MAGIC
+}
+
+func readParquetMeta(filePtr io.ReaderAt, size int64) (thriftStruct, error) {
+	tail := make([]byte, 8)
+	if _, err := filePtr.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+
+	n := int64(binary.LittleEndian.Uint32(tail))
+	if n > size-12 {
+		return nil, fmt.Errorf("footer of %d bytes in a %d byte file", n, size)
+	}
+
+	footer := make([]byte, n)
+	if _, err := filePtr.ReadAt(footer, size-8-n); err != nil {
+		return nil, err
+	}
+
+	r := &thriftReader{data: footer}
+	meta := r.readStruct(0)
+	return meta, r.err
+}
+
+// parquetColumn is a top level column, index being its place among each row group's column chunks.
+type parquetColumn struct {
+	name     string
+	index    int
+	typ      int64
+	optional bool
+
+	//decimal digits of an integer column, a DECIMAL(p, 1) temperature holds tenths
+	scale int64
+}
+
+// findParquetColumn looks name up among the leaves of the schema, which are in the order of every row group's chunks.
+func findParquetColumn(meta thriftStruct, name string) (parquetColumn, error) {
+	leaf := 0
+
+	for i, elem := range meta.list(2) {
+		s, _ := elem.(thriftStruct)
+		if children, _ := s.int(5); i == 0 || children > 0 {
+			continue
+		}
+
+		if s.string(4) == name {
+			typ, _ := s.int(1)
+			repetition, _ := s.int(3)
+			scale, _ := s.int(7)
+			return parquetColumn{name, leaf, typ, repetition == PARQUET_OPTIONAL, scale}, nil
+		}
+		leaf++
+	}
+
+	return parquetColumn{}, fmt.Errorf("no %s column", name)
+}
+
+// parquetValues is one column of a row group, row by row: strs for byte arrays, nums for numbers, null
+// marking the rows without a value.
+type parquetValues struct {
+	strs [][]byte
+	nums []float64
+	null []bool
+}
+
+// plain decodes n PLAIN values of the column's type onto v.
+func (c parquetColumn) plain(v *parquetValues, data []byte, n int) error {
+	//Bytes per value, for a byte array those of its length prefix
+	size := map[int64]int{PARQUET_INT32: 4, PARQUET_INT64: 8, PARQUET_FLOAT: 4, PARQUET_DOUBLE: 8, PARQUET_BYTE_ARRAY: 4}[c.typ]
+
+	for i := 0; i < n; i++ {
+		if len(data) < size {
+			return errors.New("PLAIN values cut short")
+		}
+
+		switch c.typ {
+		case PARQUET_BYTE_ARRAY:
+			length := int(binary.LittleEndian.Uint32(data))
+			if length > len(data)-4 {
+				return errors.New("PLAIN byte array cut short")
+			}
+			v.strs = append(v.strs, data[4:4+length])
+			data = data[4+length:]
+			continue
+		case PARQUET_INT32:
+			v.nums = append(v.nums, float64(int32(binary.LittleEndian.Uint32(data)))/math.Pow10(int(c.scale)))
+		case PARQUET_INT64:
+			v.nums = append(v.nums, float64(int64(binary.LittleEndian.Uint64(data)))/math.Pow10(int(c.scale)))
+		case PARQUET_FLOAT:
+			v.nums = append(v.nums, float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+		case PARQUET_DOUBLE:
+			v.nums = append(v.nums, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		}
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// read decodes the column's chunk of a row group of rows rows.
+func (c parquetColumn) read(filePtr io.ReaderAt, chunk thriftStruct, rows int) (*parquetValues, error) {
+	meta := chunk.sub(3)
+	codec, _ := meta.int(4)
+	start, _ := meta.int(9)
+	if dict, ok := meta.int(11); ok && dict > 0 && dict < start {
+		start = dict
+	}
+	size, _ := meta.int(7)
+
+	if codec != PARQUET_UNCOMPRESSED && codec != PARQUET_SNAPPY && codec != PARQUET_GZIP {
+		name := fmt.Sprint(codec)
+		if codec >= 0 && codec < int64(len(parquetCodecs)) {
+			name = parquetCodecs[codec]
+		}
+		return nil, fmt.Errorf("column %s is %s compressed, only UNCOMPRESSED, SNAPPY and GZIP are supported", c.name, name)
+	}
+
+	data := make([]byte, size)
+	if _, err := filePtr.ReadAt(data, start); err != nil {
+		return nil, err
+	}
+
+	v := &parquetValues{}
+	dict := &parquetValues{}
+
+	for len(v.null) < rows && len(data) > 0 {
+		r := &thriftReader{data: data}
+		header := r.readStruct(0)
+		if r.err != nil {
+			return nil, fmt.Errorf("column %s page header: %w", c.name, r.err)
+		}
+
+		typ, _ := header.int(1)
+		uncompressed, _ := header.int(2)
+		compressed, _ := header.int(3)
+		if compressed < 0 || compressed > int64(len(data)-r.pos) {
+			return nil, fmt.Errorf("column %s: page of %d bytes past the chunk", c.name, compressed)
+		}
+		page := data[r.pos : r.pos+int(compressed)]
+		data = data[r.pos+int(compressed):]
+
+		var err error
+		switch typ {
+		case PARQUET_DICTIONARY_PAGE:
+			n, _ := header.sub(7).int(1)
+			if page, err = decompress(codec, page, uncompressed); err == nil {
+				err = c.plain(dict, page, int(n))
+			}
+		case PARQUET_DATA_PAGE:
+			dh := header.sub(5)
+			n, _ := dh.int(1)
+			encoding, _ := dh.int(2)
+			if page, err = decompress(codec, page, uncompressed); err != nil {
+				break
+			}
+
+			var defs []uint32
+			if c.optional {
+				if len(page) < 4 || int(binary.LittleEndian.Uint32(page)) > len(page)-4 {
+					err = errors.New("definition levels cut short")
+					break
+				}
+				length := int(binary.LittleEndian.Uint32(page))
+				if defs, err = decodeHybrid(page[4:4+length], 1, int(n)); err != nil {
+					break
+				}
+				page = page[4+length:]
+			}
+			err = c.values(v, dict, page, encoding, int(n), defs)
+		case PARQUET_DATA_PAGE_V2:
+			dh := header.sub(8)
+			n, _ := dh.int(1)
+			encoding, _ := dh.int(4)
+			defLength, _ := dh.int(5)
+			repLength, _ := dh.int(6)
+			if defLength < 0 || repLength < 0 || defLength+repLength > int64(len(page)) {
+				err = errors.New("levels past the page")
+				break
+			}
+
+			var defs []uint32
+			if c.optional {
+				if defs, err = decodeHybrid(page[repLength:repLength+defLength], 1, int(n)); err != nil {
+					break
+				}
+			}
+
+			body := page[repLength+defLength:]
+			if compressed, ok := dh.bool(7); !ok || compressed {
+				if body, err = decompress(codec, body, uncompressed-repLength-defLength); err != nil {
+					break
+				}
+			}
+			err = c.values(v, dict, body, encoding, int(n), defs)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.name, err)
+		}
+	}
+
+	if len(v.null) != rows {
+		return nil, fmt.Errorf("column %s has %d of the row group's %d rows", c.name, len(v.null), rows)
+	}
+	return v, nil
+}
+
+// values decodes a data page of n rows onto v, defs being each row's definition level when the column is optional.
+func (c parquetColumn) values(v, dict *parquetValues, data []byte, encoding int64, n int, defs []uint32) error {
+	if n < 0 {
+		return fmt.Errorf("page of %d values", n)
+	}
+
+	present := n
+	if defs != nil {
+		present = 0
+		for _, def := range defs {
+			present += int(def)
+		}
+	}
+
+	page := &parquetValues{}
+	switch encoding {
+	case PARQUET_PLAIN:
+		if err := c.plain(page, data, present); err != nil {
+			return err
+		}
+	case PARQUET_PLAIN_DICTIONARY, PARQUET_RLE_DICTIONARY:
+		if len(data) == 0 {
+			return errors.New("dictionary indices have no bit width")
+		}
+
+		indices, err := decodeHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return err
+		}
+
+		for _, i := range indices {
+			if int(i) >= max(len(dict.strs), len(dict.nums)) {
+				return fmt.Errorf("dictionary index %d past the dictionary", i)
+			}
+			if c.typ == PARQUET_BYTE_ARRAY {
+				page.strs = append(page.strs, dict.strs[i])
+			} else {
+				page.nums = append(page.nums, dict.nums[i])
+			}
+		}
+	default:
+		return fmt.Errorf("encoding %d is not supported, only PLAIN and dictionary", encoding)
+	}
+
+	next := 0
+	for i := 0; i < n; i++ {
+		null := defs != nil && defs[i] == 0
+		v.null = append(v.null, null)
+
+		switch {
+		case c.typ == PARQUET_BYTE_ARRAY && null:
+			v.strs = append(v.strs, nil)
+		case c.typ == PARQUET_BYTE_ARRAY:
+			v.strs = append(v.strs, page.strs[next])
+		case null:
+			v.nums = append(v.nums, 0)
+		default:
+			v.nums = append(v.nums, page.nums[next])
+		}
+
+		if !null {
+			next++
+		}
+	}
+
+	return nil
+}
+
+// decodeHybrid decodes n values of the RLE / bit packed hybrid encoding levels and dictionary indices use.
+func decodeHybrid(data []byte, bitWidth, n int) ([]uint32, error) {
+	if bitWidth > 32 || n < 0 {
+		return nil, fmt.Errorf("%d values of bit width %d", n, bitWidth)
+	}
+	out := make([]uint32, 0, n)
+
+	for len(out) < n {
+		header, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, errors.New("RLE runs cut short")
+		}
+		data = data[k:]
+
+		if header&1 == 0 {
+			//A run of one value, in just enough bytes for bitWidth
+			width := (bitWidth + 7) / 8
+			if len(data) < width {
+				return nil, errors.New("RLE run cut short")
+			}
+
+			value := uint32(0)
+			for i := 0; i < width; i++ {
+				value |= uint32(data[i]) << (8 * i)
+			}
+			data = data[width:]
+
+			for count := header >> 1; count > 0 && len(out) < n; count-- {
+				out = append(out, value)
+			}
+			continue
+		}
+
+		//Groups of 8 values packed bitWidth bits each, least significant bit first
+		groups := int(min(header>>1, uint64(len(data))))
+		if len(data) < groups*bitWidth {
+			return nil, errors.New("bit packed run cut short")
+		}
+
+		for i := 0; i < groups*8 && len(out) < n; i++ {
+			value := uint32(0)
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				value |= uint32(data[bit/8]>>(bit%8)&1) << b
+			}
+			out = append(out, value)
+		}
+		data = data[groups*bitWidth:]
+	}
+
+	for _, v := range out {
+		if bitWidth < 32 && v >= 1<<bitWidth {
+			return nil, fmt.Errorf("value %d wider than %d bits", v, bitWidth)
+		}
+	}
+	return out, nil
+}
+
+func decompress(codec int64, data []byte, size int64) ([]byte, error) {
+	switch codec {
+	case PARQUET_SNAPPY:
+		return snappyDecode(data)
+	case PARQUET_GZIP:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.LimitReader(r, size))
+	}
+	return data, nil
+}
+
+// snappyDecode decodes a raw Snappy block, the unframed format Parquet pages use.
+func snappyDecode(src []byte) ([]byte, error) {
+	size, k := binary.Uvarint(src)
+	if k <= 0 || size > math.MaxInt32 {
+		return nil, errors.New("snappy: bad length")
+	}
+	src = src[k:]
+	dst := make([]byte, 0, size)
+
+	for len(src) > 0 {
+		tag := src[0]
+		length, offset := 0, 0
+
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			src = src[1:]
+
+			//Lengths past 60 are in the 1 to 4 bytes that follow
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, errors.New("snappy: literal cut short")
+				}
+
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			length++
+
+			if length > len(src) || uint64(len(dst)+length) > size {
+				return nil, errors.New("snappy: literal past the end")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errors.New("snappy: copy cut short")
+			}
+			length, offset = 4+int(tag>>2&7), int(tag>>5)<<8|int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("snappy: copy cut short")
+			}
+			length, offset = 1+int(tag>>2), int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, errors.New("snappy: copy cut short")
+			}
+			length, offset = 1+int(tag>>2), int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > size {
+			return nil, errors.New("snappy: copy out of range")
+		}
+
+		//Copies may overlap what they write, a byte at a time keeps that right
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if uint64(len(dst)) != size {
+		return nil, errors.New("snappy: shorter than its length")
+	}
+	return dst, nil
+}
+
+// processParquet aggregates a Parquet file's station and temperature columns, the row groups shared out
+// between the workers, each into a Local tally.
+func processParquet(filePtr *os.File, size int64, tally *Tally, o *Options) error {
+	meta, err := readParquetMeta(filePtr, size)
+	if err != nil {
+		return fmt.Errorf("parquet footer: %w", err)
+	}
+
+	names := strings.Split(*parquetColumns, ",")
+	if len(names) != 2 {
+		return fmt.Errorf("-parquet-columns=%s wants station,temperature", *parquetColumns)
+	}
+
+	station, err := findParquetColumn(meta, names[0])
+	if err != nil {
+		return err
+	}
+	temp, err := findParquetColumn(meta, names[1])
+	if err != nil {
+		return err
+	}
+
+	if station.typ != PARQUET_BYTE_ARRAY {
+		return fmt.Errorf("column %s must be a string", station.name)
+	}
+	if !slices.Contains([]int64{PARQUET_INT32, PARQUET_INT64, PARQUET_FLOAT, PARQUET_DOUBLE}, temp.typ) {
+		return fmt.Errorf("column %s must be a number", temp.name)
+	}
+
+	groups := meta.list(4)
+	next := atomic.Int64{}
+	workers := min(o.workers, max(len(groups), 1))
+	if o.serial {
+		workers = 1
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, workers)
+	locals := workerTallies(workers, tally)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := next.Add(1) - 1; i < int64(len(groups)) && errs[w] == nil; i = next.Add(1) - 1 {
+				group, _ := groups[i].(thriftStruct)
+				errs[w] = parquetRowGroup(filePtr, group, station, temp, locals[w], o)
+			}
+		}(w)
+	}
+	wg.Wait()
+	mergeTallies(tally, locals)
+
+	return errors.Join(errs...)
+}
+
+func parquetRowGroup(filePtr *os.File, group thriftStruct, station, temp parquetColumn, tally *Tally, o *Options) error {
+	n, _ := group.int(3)
+	rows := int(n)
+	chunks := group.list(1)
+	if max(station.index, temp.index) >= len(chunks) {
+		return fmt.Errorf("row group has %d column chunks", len(chunks))
+	}
+
+	stationChunk, _ := chunks[station.index].(thriftStruct)
+	tempChunk, _ := chunks[temp.index].(thriftStruct)
+
+	stations, err := station.read(filePtr, stationChunk, rows)
+	if err != nil {
+		return err
+	}
+	temps, err := temp.read(filePtr, tempChunk, rows)
+	if err != nil {
+		return err
+	}
+
+	bytes, _ := group.int(2)
+	o.bytes.Add(bytes)
+	o.chunks.Add(1)
+
+	key, keep := o.key, cachedFilter()
+	scratch := make([]byte, 0, 128)
+	lines := 0
+
+	for i := 0; i < rows; i++ {
+		if stations.null[i] || temps.null[i] {
+			continue
+		}
+
+		name := stations.strs[i]
+		if key != nil {
+			name = key(scratch[:0], name)
+		}
+		if keep != nil && !keep(name) {
+			continue
+		}
+
+		tally.Station(name, -1).AddAt(int(math.Round(temps.nums[i]*float64(readingScale))), -1)
+		lines++
+	}
+	progress.Add(1)
+	linesParsed.Add(int64(lines))
+	if *tui {
+		publishLeaders(tally)
+	}
+
+	return nil
+}
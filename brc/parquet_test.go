@@ -0,0 +1,433 @@
+package brc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// parquetFixture picks how writeParquet lays a file out, so TestParquet covers every page shape the reader handles.
+type parquetFixture struct {
+	codec                    int64
+	v2, dictionary, optional bool
+
+	//PARQUET_DOUBLE, PARQUET_FLOAT or PARQUET_INT32 holding DECIMAL tenths
+	tempType int64
+	groups   int
+}
+
+// parquetRow is a reading, null ones only in optional columns.
+type parquetRow struct {
+	station     string
+	tenths      int
+	stationNull bool
+	tempNull    bool
+}
+
+func TestParquet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	names := make([]string, 1+rng.Intn(300))
+	for i := range names {
+		names[i] = strings.ReplaceAll(randomName(rng), ";", "")
+	}
+
+	rows := make([]parquetRow, rng.Intn(20_000))
+	text := &bytes.Buffer{}
+	for i := range rows {
+		rows[i] = parquetRow{station: names[rng.Intn(len(names))], tenths: rng.Intn(1999) - 999}
+		if rng.Intn(50) == 0 {
+			rows[i].stationNull = rng.Intn(2) == 0
+			rows[i].tempNull = !rows[i].stationNull
+		}
+	}
+
+	for _, codec := range []int64{PARQUET_UNCOMPRESSED, PARQUET_SNAPPY, PARQUET_GZIP} {
+		for _, v2 := range []bool{false, true} {
+			for _, dictionary := range []bool{false, true} {
+				for _, optional := range []bool{false, true} {
+					tempType := []int64{PARQUET_DOUBLE, PARQUET_FLOAT, PARQUET_INT32}[rng.Intn(3)]
+					fixture := parquetFixture{codec, v2, dictionary, optional, tempType, 1 + rng.Intn(4)}
+
+					text.Reset()
+					for _, row := range rows {
+						if !optional || !row.stationNull && !row.tempNull {
+							fmt.Fprintf(text, "%s;%s\n", row.station, formatTenths(row.tenths))
+						}
+					}
+
+					txt, pq := dir+"/rows.txt", dir+"/rows.parquet"
+					if err := os.WriteFile(txt, text.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(pq, writeParquet(rows, fixture), 0o644); err != nil {
+						t.Fatal(err)
+					}
+
+					want, err := runPipeline(StreamingStrategy{}, []string{txt})
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := runPipeline(StreamingStrategy{}, []string{pq})
+					if err != nil {
+						t.Fatalf("%+v: %v", fixture, err)
+					}
+					if got != want {
+						t.Fatalf("%+v: got %q, want %q", fixture, got, want)
+					}
+				}
+			}
+		}
+	}
+
+	//Unsupported codecs fail naming themselves
+	zstd := dir + "/zstd.parquet"
+	if err := os.WriteFile(zstd, writeParquet(rows, parquetFixture{codec: 6, tempType: PARQUET_DOUBLE, groups: 1}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process([]string{zstd}, WithStrategy(StreamingStrategy{})); len(rows) > 0 && (err == nil || !strings.Contains(err.Error(), "ZSTD")) {
+		t.Fatalf("a ZSTD file gave %v", err)
+	}
+
+	for _, c := range hybridCases {
+		if got, err := decodeHybrid(c.data, c.bitWidth, len(c.want)); err != nil || !slices.Equal(got, c.want) {
+			t.Fatalf("decodeHybrid(%v, %d) = %v, %v, want %v", c.data, c.bitWidth, got, err, c.want)
+		}
+	}
+}
+
+// hybridCases are RLE / bit packed hybrid runs worked out by hand from the Parquet spec.
+var hybridCases = []struct {
+	data     []byte
+	bitWidth int
+	want     []uint32
+}{
+	//A run of five 3s
+	{[]byte{5 << 1, 3}, 2, []uint32{3, 3, 3, 3, 3}},
+	//The spec's example, 0..7 bit packed 3 bits each
+	{[]byte{1<<1 | 1, 0b10001000, 0b11000110, 0b11111010}, 3, []uint32{0, 1, 2, 3, 4, 5, 6, 7}},
+	//A run of 300, then a group cut to its first two
+	{[]byte{0xd8, 0x04, 0x01, 0x01<<1 | 1, 0b10}, 1, append(slices.Repeat([]uint32{1}, 300), 0, 1)},
+	//A 9 bit value takes two bytes in a run
+	{[]byte{2 << 1, 0x2c, 0x01}, 9, []uint32{300, 300}},
+}
+
+// writeParquet writes rows as a Parquet file of fixture's shape. Each column chunk is two data pages.
+func writeParquet(rows []parquetRow, fixture parquetFixture) []byte {
+	file := []byte(PARQUET_MAGIC)
+	var groups []any
+
+	for g := 0; g < fixture.groups; g++ {
+		group := rows[g*len(rows)/fixture.groups : (g+1)*len(rows)/fixture.groups]
+
+		var columns []any
+		total := int64(0)
+		for _, column := range []int{0, 1} {
+			var chunk []thriftField
+			file, chunk = writeParquetChunk(file, group, column, fixture)
+			columns = append(columns, chunk)
+
+			meta := chunk[1].value.([]thriftField)
+			total += meta[5].value.(int64)
+		}
+
+		groups = append(groups, []thriftField{{1, columns}, {2, total}, {3, int64(len(group))}})
+	}
+
+	repetition := int32(0)
+	if fixture.optional {
+		repetition = PARQUET_OPTIONAL
+	}
+	temp := []thriftField{{1, int32(fixture.tempType)}, {3, repetition}, {4, "temperature"}}
+	if fixture.tempType == PARQUET_INT32 {
+		temp = append(temp, thriftField{6, int32(5)}, thriftField{7, int32(1)}, thriftField{8, int32(4)})
+	}
+
+	footer := thriftEncode(nil, []thriftField{
+		{1, int32(1)},
+		{2, []any{
+			[]thriftField{{4, "schema"}, {5, int32(2)}},
+			[]thriftField{{1, int32(PARQUET_BYTE_ARRAY)}, {3, repetition}, {4, "station"}},
+			temp,
+		}},
+		{3, int64(len(rows))},
+		{4, groups},
+	})
+
+	file = append(file, footer...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(footer)))
+	return append(file, PARQUET_MAGIC...)
+}
+
+// writeParquetChunk appends the pages of one column chunk to file, returning its ColumnChunk.
+func writeParquetChunk(file []byte, rows []parquetRow, column int, fixture parquetFixture) ([]byte, []thriftField) {
+	start := int64(len(file))
+	typ := int64(PARQUET_BYTE_ARRAY)
+	if column == 1 {
+		typ = fixture.tempType
+	}
+
+	//values PLAIN encodes the rows with a value, defs has every row's definition level
+	plain := func(rows []parquetRow) []byte {
+		var out []byte
+		for _, row := range rows {
+			switch typ {
+			case PARQUET_BYTE_ARRAY:
+				out = binary.LittleEndian.AppendUint32(out, uint32(len(row.station)))
+				out = append(out, row.station...)
+			case PARQUET_DOUBLE:
+				out = binary.LittleEndian.AppendUint64(out, math.Float64bits(float64(row.tenths)/10))
+			case PARQUET_FLOAT:
+				out = binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(row.tenths)/10))
+			case PARQUET_INT32:
+				out = binary.LittleEndian.AppendUint32(out, uint32(int32(row.tenths)))
+			}
+		}
+		return out
+	}
+	null := func(row parquetRow) bool {
+		return fixture.optional && (column == 0 && row.stationNull || column == 1 && row.tempNull)
+	}
+
+	//The dictionary holds every distinct row with a value, data pages index it
+	var dict []parquetRow
+	index := map[parquetRow]int{}
+	key := func(row parquetRow) parquetRow {
+		if column == 0 {
+			return parquetRow{station: row.station}
+		}
+		return parquetRow{tenths: row.tenths}
+	}
+
+	var header []thriftField
+	page := func(pageType int32, body []byte, levels []byte, sub int16, fields []thriftField) {
+		compressed := compressParquet(fixture.codec, body)
+		header = []thriftField{{1, pageType}, {2, int32(len(levels) + len(body))}, {3, int32(len(levels) + len(compressed))}, {sub, fields}}
+		file = thriftEncode(file, header)
+		file = append(file, levels...)
+		file = append(file, compressed...)
+	}
+
+	dictOffset := int64(-1)
+	if fixture.dictionary {
+		for _, row := range rows {
+			if _, ok := index[key(row)]; !null(row) && !ok {
+				index[key(row)] = len(dict)
+				dict = append(dict, row)
+			}
+		}
+
+		dictOffset = int64(len(file))
+		page(PARQUET_DICTIONARY_PAGE, plain(dict), nil, 7, []thriftField{{1, int32(len(dict))}, {2, int32(PARQUET_PLAIN)}})
+	}
+
+	dataOffset := int64(len(file))
+	for _, half := range [][]parquetRow{rows[:len(rows)/2], rows[len(rows)/2:]} {
+		var defs []uint32
+		var present []parquetRow
+		for _, row := range half {
+			if null(row) {
+				defs = append(defs, 0)
+				continue
+			}
+			defs = append(defs, 1)
+			present = append(present, row)
+		}
+
+		encoding := int32(PARQUET_PLAIN)
+		values := plain(present)
+		if fixture.dictionary {
+			indices := make([]uint32, len(present))
+			for i, row := range present {
+				indices[i] = uint32(index[key(row)])
+			}
+
+			width := bits.Len(uint(max(len(dict)-1, 0)))
+			encoding, values = PARQUET_RLE_DICTIONARY, append([]byte{byte(width)}, encodeHybrid(indices, width)...)
+		}
+
+		var levels []byte
+		if fixture.optional {
+			levels = encodeHybrid(defs, 1)
+		}
+
+		nulls := int32(len(half) - len(present))
+		if fixture.v2 {
+			page(PARQUET_DATA_PAGE_V2, values, levels, 8, []thriftField{{1, int32(len(half))}, {2, nulls}, {3, int32(len(half))},
+				{4, encoding}, {5, int32(len(levels))}, {6, int32(0)}})
+			continue
+		}
+
+		body := values
+		if fixture.optional {
+			body = append(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))), append(levels, values...)...)
+		}
+		page(PARQUET_DATA_PAGE, body, nil, 5, []thriftField{{1, int32(len(half))}, {2, encoding}, {3, int32(3)}, {4, int32(3)}})
+	}
+
+	size := int64(len(file)) - start
+	meta := []thriftField{{1, int32(typ)}, {2, []any{int32(PARQUET_PLAIN)}}, {3, []any{[]string{"station", "temperature"}[column]}},
+		{4, int32(fixture.codec)}, {5, int64(len(rows))}, {6, size}, {7, size}, {9, dataOffset}}
+	if dictOffset >= 0 {
+		meta = append(meta, thriftField{11, dictOffset})
+	}
+
+	return file, []thriftField{{2, start}, {3, meta}}
+}
+
+// encodeHybrid writes runs of 8 or more as RLE runs and the rest in bit packed groups of 8.
+func encodeHybrid(values []uint32, bitWidth int) []byte {
+	var out []byte
+
+	for i := 0; i < len(values); {
+		run := 1
+		for i+run < len(values) && values[i+run] == values[i] {
+			run++
+		}
+
+		if run >= 8 {
+			out = binary.AppendUvarint(out, uint64(run)<<1)
+			for b := 0; b < (bitWidth+7)/8; b++ {
+				out = append(out, byte(values[i]>>(8*b)))
+			}
+			i += run
+			continue
+		}
+
+		out = binary.AppendUvarint(out, 1<<1|1)
+		group := make([]byte, bitWidth)
+		for j := 0; j < 8 && i+j < len(values); j++ {
+			for b := 0; b < bitWidth; b++ {
+				bit := j*bitWidth + b
+				group[bit/8] |= byte(values[i+j]>>b&1) << (bit % 8)
+			}
+		}
+		out = append(out, group...)
+		i += 8
+	}
+
+	return out
+}
+
+func compressParquet(codec int64, data []byte) []byte {
+	switch codec {
+	case PARQUET_SNAPPY:
+		return snappyEncode(data)
+	case PARQUET_GZIP:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	return data
+}
+
+// snappyEncode only finds runs of a repeated byte, as overlapping copies of offset 1, which is enough to use
+// every tag snappyDecode knows but the 4 byte offset one.
+func snappyEncode(data []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(data)))
+	literal := func(b []byte) {
+		for len(b) > 0 {
+			n := min(len(b), 256)
+			if n <= 60 {
+				out = append(out, byte(n-1)<<2)
+			} else {
+				out = append(out, 60<<2, byte(n-1))
+			}
+			out = append(out, b[:n]...)
+			b = b[n:]
+		}
+	}
+
+	start := 0
+	for i := 1; i < len(data); {
+		run := 0
+		for i+run < len(data) && run < 64 && data[i+run] == data[i-1] {
+			run++
+		}
+
+		if run < 4 {
+			i++
+			continue
+		}
+
+		literal(data[start:i])
+		if run <= 11 {
+			out = append(out, byte(run-4)<<2|1, 1)
+		} else {
+			out = append(out, byte(run-1)<<2|2, 1, 0)
+		}
+		i += run
+		start = i
+	}
+	literal(data[start:])
+
+	return out
+}
+
+// thriftField is a field to encode with thriftEncode: int32, int64, bool, string, []any lists or []thriftField structs.
+type thriftField struct {
+	id    int16
+	value any
+}
+
+func thriftEncode(buf []byte, fields []thriftField) []byte {
+	last := int16(0)
+
+	for _, f := range fields {
+		typ, body := thriftValue(f.value)
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			buf = append(buf, byte(delta)<<4|typ)
+		} else {
+			buf = append(buf, typ)
+			buf = binary.AppendUvarint(buf, uint64(f.id)<<1^uint64(f.id>>15))
+		}
+
+		buf = append(buf, body...)
+		last = f.id
+	}
+
+	return append(buf, THRIFT_STOP)
+}
+
+func thriftValue(v any) (byte, []byte) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return THRIFT_TRUE, nil
+		}
+		return THRIFT_FALSE, nil
+	case int32:
+		return THRIFT_I32, binary.AppendUvarint(nil, uint64(v)<<1^uint64(v>>31))
+	case int64:
+		return THRIFT_I64, binary.AppendUvarint(nil, uint64(v)<<1^uint64(v>>63))
+	case string:
+		return THRIFT_BINARY, append(binary.AppendUvarint(nil, uint64(len(v))), v...)
+	case []thriftField:
+		return THRIFT_STRUCT, thriftEncode(nil, v)
+	case []any:
+		var elem byte
+		var body []byte
+		for _, e := range v {
+			typ, b := thriftValue(e)
+			elem, body = typ, append(body, b...)
+		}
+
+		if len(v) < 15 {
+			return THRIFT_LIST, append([]byte{byte(len(v))<<4 | elem}, body...)
+		}
+		return THRIFT_LIST, append(binary.AppendUvarint([]byte{0xF0 | elem}, uint64(len(v))), body...)
+	}
+
+	panic(fmt.Sprintf("thriftValue: %T", v))
+}
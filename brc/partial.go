@@ -0,0 +1,414 @@
+package brc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PARTIAL_MAGIC starts every partial tally file, the trailing digit is the format version.
+// Layout after the magic, all integers varint encoded:
+// readingScale, -reservoir, -histogram, metric count, then per metric: name length, name (empty without
+// -schema), station count, then per station: name length, name, min, max, sum, count, sum of squares, the
+// sum of logs as 8 little endian bytes of its float64 bits, the sample's length and readings with -reservoir
+// and every bucket count with -histogram.
+// Older versions are still read: BRC3 has a single metric and stops at the sum of squares, BRC2 also lacks
+// the scale and holds tenths, BRC1 lacks the sum of squares too and leaves the standard deviation unknown.
+const PARTIAL_MAGIC = "BRC4"
+
+const PARTIAL_MAGIC_V3 = "BRC3"
+
+const PARTIAL_MAGIC_V2 = "BRC2"
+
+const PARTIAL_MAGIC_V1 = "BRC1"
+
+var errPartialMagic = errors.New("not a partial tally file")
+
+var dumpPartial = commandLine.String("dump-partial", "", "write the unformatted tally to `file` for a later merge")
+
+// WritePartial serialises t so it can be merged with tallies produced elsewhere or later.
+func (t *Tally) WritePartial(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, binary.MaxVarintLen64)
+
+	bw.WriteString(PARTIAL_MAGIC)
+	bw.Write(binary.AppendUvarint(buf, uint64(readingScale)))
+	bw.Write(binary.AppendUvarint(buf, uint64(*reservoirSize)))
+	bw.Write(binary.AppendUvarint(buf, uint64(*histogramBuckets)))
+
+	//A metric no line had yet has no tally, it is written without stations
+	names := []string{""}
+	if activeSchema != nil {
+		names = activeSchema.metrics
+	}
+	metrics := max(len(names), 1+len(t.metrics))
+	bw.Write(binary.AppendUvarint(buf, uint64(metrics)))
+	for j := range metrics {
+		name := ""
+		if j < len(names) {
+			name = names[j]
+		}
+		bw.Write(binary.AppendUvarint(buf, uint64(len(name))))
+		bw.WriteString(name)
+
+		if j > len(t.metrics) {
+			bw.Write(binary.AppendUvarint(buf, 0))
+			continue
+		}
+		writePartialStations(bw, t.metric(j))
+	}
+
+	return bw.Flush()
+}
+
+// writePartialStations writes the station count and every station of t, sorted by name.
+func writePartialStations(bw *bufio.Writer, t *Tally) {
+	names := append([]string(nil), t.names...)
+	sort.Strings(names)
+
+	buf := make([]byte, 0, binary.MaxVarintLen64)
+	bw.Write(binary.AppendUvarint(buf, uint64(len(names))))
+
+	for _, name := range names {
+		r, _ := t.Lookup(name)
+		bw.Write(binary.AppendUvarint(buf, uint64(len(name))))
+		bw.WriteString(name)
+		bw.Write(binary.AppendVarint(buf, int64(r.min)))
+		bw.Write(binary.AppendVarint(buf, int64(r.max)))
+		bw.Write(binary.AppendVarint(buf, int64(r.sum)))
+		bw.Write(binary.AppendUvarint(buf, uint64(r.count)))
+		bw.Write(binary.AppendVarint(buf, int64(r.sumSq)))
+		bw.Write(binary.LittleEndian.AppendUint64(buf, math.Float64bits(r.sumLog)))
+
+		//A station added whole by add may carry no sample or histogram, it is written with empty ones
+		if *reservoirSize > 0 {
+			var sample []int
+			if r.sample != nil {
+				sample = r.sample.values
+			}
+			bw.Write(binary.AppendUvarint(buf, uint64(len(sample))))
+			for _, v := range sample {
+				bw.Write(binary.AppendVarint(buf, int64(v)))
+			}
+		}
+		if *histogramBuckets > 0 {
+			counts := make([]uint32, *histogramBuckets)
+			if r.hist != nil {
+				counts = r.hist.counts
+			}
+			for _, n := range counts {
+				bw.Write(binary.AppendUvarint(buf, uint64(n)))
+			}
+		}
+	}
+}
+
+// partialLayout is what the header of a partial says follows it.
+type partialLayout struct {
+	version              int
+	scale                int
+	reservoir, histogram int
+}
+
+// ReadPartial reads a tally written by WritePartial, rescaled to this run's readingScale. Its -schema metrics
+// must be this run's, and its -reservoir and -histogram too unless this run keeps none.
+func ReadPartial(r io.Reader) (*Tally, error) {
+	tally, metrics, err := readPartial(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if activeSchema != nil && !slices.Equal(metrics, activeSchema.metrics) {
+		return nil, fmt.Errorf("partial has the metrics %s, this run -schema %s", strings.Join(metrics, ","), strings.Join(activeSchema.metrics, ","))
+	}
+
+	return tally, nil
+}
+
+// readPartial reads a tally written by WritePartial and the names of its -schema metrics, none without.
+func readPartial(r io.Reader) (*Tally, []string, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(PARTIAL_MAGIC))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, nil, errPartialMagic
+	}
+	layout := partialLayout{scale: 10}
+	switch string(magic) {
+	case PARTIAL_MAGIC:
+		layout.version = 4
+	case PARTIAL_MAGIC_V3:
+		layout.version = 3
+	case PARTIAL_MAGIC_V2:
+		layout.version = 2
+	case PARTIAL_MAGIC_V1:
+		layout.version = 1
+	default:
+		return nil, nil, errPartialMagic
+	}
+
+	if layout.version >= 3 {
+		scale, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		if scale != 10 && scale != 100 && scale != 1000 {
+			return nil, nil, fmt.Errorf("readings kept in units of 1/%d, file is corrupt", scale)
+		}
+		layout.scale = int(scale)
+	}
+
+	metrics := uint64(1)
+	if layout.version >= 4 {
+		var header [3]uint64
+		for i := range header {
+			var err error
+			if header[i], err = binary.ReadUvarint(br); err != nil {
+				return nil, nil, err
+			}
+		}
+		if header[0] > math.MaxInt32 || header[1] > MAX_HISTOGRAM_BUCKETS || header[2] == 0 || header[2] > math.MaxUint16 {
+			return nil, nil, fmt.Errorf("-reservoir=%d -histogram=%d with %d metrics, file is corrupt", header[0], header[1], header[2])
+		}
+		layout.reservoir, layout.histogram, metrics = int(header[0]), int(header[1]), header[2]
+
+		if *reservoirSize > 0 && layout.reservoir != *reservoirSize {
+			return nil, nil, fmt.Errorf("written with -reservoir=%d, this run keeps %d", layout.reservoir, *reservoirSize)
+		}
+		if *histogramBuckets > 0 && layout.histogram != *histogramBuckets {
+			return nil, nil, fmt.Errorf("written with -histogram=%d, this run keeps %d", layout.histogram, *histogramBuckets)
+		}
+	} else if *reservoirSize > 0 || *histogramBuckets > 0 {
+		return nil, nil, fmt.Errorf("%s partials keep no -reservoir or -histogram", magic)
+	}
+
+	tally := NewTally()
+	var names []string
+	for j := range metrics {
+		if layout.version >= 4 {
+			name, err := readPartialName(br)
+			if err != nil {
+				return nil, nil, fmt.Errorf("metric %d: %w", j, err)
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+
+		if err := readPartialStations(br, tally.metric(int(j)), layout); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(names) > 0 && len(names) != int(metrics) {
+		return nil, nil, fmt.Errorf("%d of %d metrics are named, file is corrupt", len(names), metrics)
+	}
+
+	return tally, names, nil
+}
+
+// readPartialName reads a length prefixed station or metric name.
+func readPartialName(br *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if length > math.MaxUint16 {
+		return "", fmt.Errorf("%d byte name, file is corrupt", length)
+	}
+
+	name := make([]byte, length)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
+
+// readPartialStations reads the stations of one metric into tally.
+func readPartialStations(br *bufio.Reader, tally *Tally, layout partialLayout) error {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		name, err := readPartialName(br)
+		if err != nil {
+			return fmt.Errorf("station %d: %w", i, err)
+		}
+		if _, ok := tally.Lookup(name); ok {
+			return fmt.Errorf("station %q appears twice, file is corrupt", name)
+		}
+
+		result, err := readPartialResult(br, layout)
+		if err != nil {
+			return fmt.Errorf("station %q: %w", name, err)
+		}
+		tally.add(name, rescale(result, layout.scale))
+	}
+
+	return nil
+}
+
+// readPartialResult reads the accumulators of one station, those the layout lacks left unknown.
+func readPartialResult(br *bufio.Reader, layout partialLayout) (StationResult, error) {
+	values := [5]int64{4: -1}
+	for j := range values {
+		if j == 4 && layout.version == 1 {
+			break
+		}
+
+		var err error
+		if j == 3 {
+			var count uint64
+			count, err = binary.ReadUvarint(br)
+			values[j] = int64(count)
+		} else {
+			values[j], err = binary.ReadVarint(br)
+		}
+		if err != nil {
+			return StationResult{}, err
+		}
+	}
+	r := StationResult{int(values[0]), int(values[1]), int(values[2]), int(values[3]), int(values[4]), math.NaN(), nil, nil, "", -1}
+
+	if layout.version < 4 {
+		return r, nil
+	}
+
+	var bits [8]byte
+	if _, err := io.ReadFull(br, bits[:]); err != nil {
+		return r, err
+	}
+	r.sumLog = math.Float64frombits(binary.LittleEndian.Uint64(bits[:]))
+
+	if layout.reservoir > 0 {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return r, err
+		}
+		if n > uint64(min(layout.reservoir, r.count)) {
+			return r, fmt.Errorf("%d readings sampled of %d, file is corrupt", n, r.count)
+		}
+
+		sample := &reservoir{make([]int, n)}
+		for i := range sample.values {
+			v, err := binary.ReadVarint(br)
+			if err != nil {
+				return r, err
+			}
+			sample.values[i] = int(v)
+		}
+		if *reservoirSize > 0 {
+			r.sample = sample
+		}
+	}
+
+	if layout.histogram > 0 {
+		hist := &histogram{make([]uint32, layout.histogram)}
+		for i := range hist.counts {
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return r, err
+			}
+			if n > math.MaxUint32 {
+				return r, fmt.Errorf("%d readings in a bucket, file is corrupt", n)
+			}
+			hist.counts[i] = uint32(n)
+		}
+		if *histogramBuckets > 0 {
+			r.hist = hist
+		}
+	}
+
+	return r, nil
+}
+
+func writePartialFile(name string, tally *Tally) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := tally.WritePartial(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readPartialFile reads the partial tally file name and the names of its -schema metrics.
+func readPartialFile(name string) (*Tally, []string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	tally, metrics, err := readPartial(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return tally, metrics, nil
+}
+
+// runMerge combines partial tallies written with -dump-partial and prints the result,
+// optionally writing the combined tally back out as another partial.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("dump-partial", "", "also write the merged tally to `file`")
+	fs.IntVar(precisionFlag, "precision", 1, "`decimals` printed for min, mean and max, 0 to 3, partials kept to fewer decimals are scaled up")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: merge [-dump-partial file] [-precision decimals] partial.brc...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *precisionFlag < 0 || *precisionFlag > MAX_PRECISION {
+		log.Fatalf("-precision must be 0 to %d, got %d", MAX_PRECISION, *precisionFlag)
+	}
+	setPrecision()
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	tally := NewTally()
+	var metrics []string
+
+	for i, name := range fs.Args() {
+		partial, names, err := readPartialFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i == 0 {
+			metrics = names
+		} else if !slices.Equal(names, metrics) {
+			log.Fatalf("%s has the metrics %q, %s has %q", name, names, fs.Arg(0), metrics)
+		}
+		tally.Merge(partial)
+	}
+
+	//Label every metric as the run that wrote the partials did
+	if len(metrics) > 0 {
+		activeSchema, _ = parseSchema("station," + strings.Join(metrics, ","))
+	}
+
+	if *out != "" {
+		if err := writePartialFile(*out, tally); err != nil {
+			log.Fatal("could not write partial tally: ", err)
+		}
+	}
+
+	tally.Print(os.Stdout)
+}
@@ -0,0 +1,110 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPartialRoundTrip writes a tally of two -schema metrics kept with -reservoir and -histogram as a partial
+// and checks every accumulator of every station of both metrics reads back as it was.
+func TestPartialRoundTrip(t *testing.T) {
+	defer func(schema *lineSchema, size, buckets int) {
+		activeSchema, *reservoirSize, *histogramBuckets = schema, size, buckets
+	}(activeSchema, *reservoirSize, *histogramBuckets)
+
+	rng := rand.New(rand.NewSource(1))
+
+	var err error
+	if activeSchema, err = parseSchema("station,temp,humidity"); err != nil {
+		t.Fatal(err)
+	}
+	*reservoirSize, *histogramBuckets = 5, 1999
+
+	var input strings.Builder
+	for range 1000 {
+		fmt.Fprintf(&input, "S%d;%.1f;%.1f\n", rng.Intn(20), float64(rng.Intn(1999)-999)/10, float64(1+rng.Intn(999))/10)
+	}
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte(input.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tally, err := Process([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := &bytes.Buffer{}
+	if err := tally.WritePartial(partial); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPartial(bytes.NewReader(partial.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for j, metric := range activeSchema.metrics {
+		want, got := tally.metric(j), read.metric(j)
+		if len(got.names) != len(want.names) {
+			t.Fatalf("%s: read %d stations, wrote %d", metric, len(got.names), len(want.names))
+		}
+		for _, name := range want.names {
+			w, _ := want.Lookup(name)
+			g, ok := got.Lookup(name)
+			if !ok {
+				t.Fatalf("%s: %s was not read back", metric, name)
+			}
+			if g.min != w.min || g.max != w.max || g.sum != w.sum || g.count != w.count || g.sumSq != w.sumSq ||
+				g.sumLog != w.sumLog && !(math.IsNaN(g.sumLog) && math.IsNaN(w.sumLog)) ||
+				!reflect.DeepEqual(g.sample, w.sample) || !reflect.DeepEqual(g.hist, w.hist) {
+				t.Fatalf("%s: %s read back as %+v, want %+v", metric, name, *g, *w)
+			}
+		}
+	}
+
+	//The partial is kept with other settings than this run's
+	activeSchema, err = parseSchema("station,temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPartial(bytes.NewReader(partial.Bytes())); err == nil {
+		t.Error("a partial of other -schema metrics was read")
+	}
+	activeSchema, _ = parseSchema("station,temp,humidity")
+	*reservoirSize = 6
+	if _, err := ReadPartial(bytes.NewReader(partial.Bytes())); err == nil {
+		t.Error("a partial of another -reservoir was read")
+	}
+}
+
+// TestPartialDuplicate checks a partial naming a station twice is rejected rather than counted twice.
+func TestPartialDuplicate(t *testing.T) {
+	buf := []byte(PARTIAL_MAGIC)
+	for _, v := range []uint64{10, 0, 0, 1, 0, 2} {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	for range 2 {
+		buf = binary.AppendUvarint(buf, 1)
+		buf = append(buf, 'A')
+		for _, v := range []int64{10, 10, 10} {
+			buf = binary.AppendVarint(buf, v)
+		}
+		buf = binary.AppendUvarint(buf, 1)
+		buf = binary.AppendVarint(buf, 100)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(math.Log(10)))
+	}
+
+	if _, err := ReadPartial(bytes.NewReader(buf)); err == nil || !strings.Contains(err.Error(), "twice") {
+		t.Fatalf("got %v, want station \"A\" appears twice", err)
+	}
+	if _, err := ReadPartial(bytes.NewReader(buf[:len(buf)-3])); err == nil {
+		t.Fatal("a truncated partial was read")
+	}
+}
@@ -0,0 +1,38 @@
+package brc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPhase parses a chunk under each -phase: io counts nothing, parse counts every line and sums what it
+// found in them without aggregating any, and all aggregates them.
+func TestPhase(t *testing.T) {
+	chunk := []byte("Abha;1.5\nbad line\nB;-20.0\nAbha;0.0\n")
+	for _, c := range []struct {
+		mode            int
+		lines, checksum int64
+		want            string
+	}{
+		{PHASE_IO, 0, 0, "{}\n"},
+		{PHASE_PARSE, 3, 15 + 4 + -200 + 1 + 0 + 4, "{}\n"},
+		{PHASE_ALL, 0, 0, "{Abha=0.0/0.8/1.5, B=-20.0/-20.0/-20.0}\n"},
+	} {
+		o, err := newOptions(WithPhase(c.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, checksum := phaseLines.Load(), phaseChecksum.Load()
+
+		tally := NewTally()
+		parseLines(chunk, 0, tally, o)
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+
+		gotLines, gotChecksum := phaseLines.Load()-lines, phaseChecksum.Load()-checksum
+		if buf.String() != c.want || gotLines != c.lines || gotChecksum != c.checksum {
+			t.Fatalf("-phase mode %d: got %q, %d lines summing to %d, want %q, %d lines summing to %d",
+				c.mode, buf.String(), gotLines, gotChecksum, c.want, c.lines, c.checksum)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package brc
+
+import (
+	"math"
+)
+
+var precisionFlag = commandLine.Int("precision", 1, "`decimals` printed for min, mean and max, 0 to 3, readings being kept to as many so 2 or 3 aren't zero padding")
+
+// MAX_PRECISION is the most decimals -precision prints. Kept in thousandths, the sum of squares of a billion
+// readings still fits an int64 unless they average beyond ±96 degrees.
+const MAX_PRECISION = 3
+
+// readingScale is what a reading in degrees is multiplied by to be kept as an integer: 10, tenths as the
+// challenge writes them, or 100 or 1000 when -precision asks for more decimals. setPrecision sets it before
+// any input is read, the fast paths still parse tenths and scale them up.
+var readingScale = 10
+
+// setPrecision keeps readings to as many decimals as -precision prints, and never fewer than tenths.
+func setPrecision() {
+	readingScale = pow10(max(1, *precisionFlag))
+}
+
+func pow10(n int) int {
+	p := 1
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// outputStep is how many units of readingScale the last printed decimal is worth, 10 when readings are kept
+// in tenths and -precision=0 prints whole degrees.
+func outputStep() int {
+	return readingScale / pow10(*precisionFlag)
+}
+
+// appendValue appends a value already in units of the last printed decimal with -precision decimals.
+func appendValue(b []byte, v int) []byte {
+	return appendDecimals(b, v, *precisionFlag)
+}
+
+func formatValue(v int) string {
+	return string(appendValue(nil, v))
+}
+
+// rescale converts r from readings kept at scale to readingScale, exactly when scale is coarser and rounding
+// half toward positive infinity when it is finer. The histogram's buckets span degrees and need no change.
+func rescale(r StationResult, scale int) StationResult {
+	switch {
+	case scale < readingScale:
+		k := readingScale / scale
+		r.min, r.max, r.sum = r.min*k, r.max*k, r.sum*k
+		if r.sumSq >= 0 {
+			r.sumSq *= k * k
+		}
+		r.sumLog += float64(r.count) * math.Log(float64(k))
+		if r.sample != nil {
+			for i := range r.sample.values {
+				r.sample.values[i] *= k
+			}
+		}
+	case scale > readingScale:
+		k := scale / readingScale
+		r.min, r.max, r.sum = roundDiv(r.min, k), roundDiv(r.max, k), roundDiv(r.sum, k)
+		if r.sumSq >= 0 {
+			r.sumSq = roundDiv(r.sumSq, k*k)
+		}
+		r.sumLog -= float64(r.count) * math.Log(float64(k))
+		if r.sample != nil {
+			for i := range r.sample.values {
+				r.sample.values[i] = roundDiv(r.sample.values[i], k)
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,77 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// outputPrecisionInput has readings to three decimals, the last two of which -precision=1 rounds away.
+const outputPrecisionInput = "A;-40.0\nA;10.125\nA;12.345\nB;0.05\nB;-0.05\nC;-0.5\nC;1.0\n"
+
+var outputPrecisionWants = []string{
+	"{A=-40/-6/12, B=0/0/0, C=0/0/1}\n",
+	"{A=-40.0/-5.9/12.3, B=-0.1/0.0/0.1, C=-0.5/0.3/1.0}\n",
+	"{A=-40.00/-5.84/12.35, B=-0.05/0.00/0.05, C=-0.50/0.25/1.00}\n",
+	"{A=-40.000/-5.843/12.345, B=-0.050/0.000/0.050, C=-0.500/0.250/1.000}\n",
+}
+
+// TestOutputPrecision runs outputPrecisionInput through every strategy at each -precision, then checks a
+// partial written at one precision merges at every other.
+func TestOutputPrecision(t *testing.T) {
+	defer func(precision int) { *precisionFlag = precision; setPrecision() }(*precisionFlag)
+
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte(outputPrecisionInput), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	partials := make([][]byte, len(outputPrecisionWants))
+	for precision, want := range outputPrecisionWants {
+		*precisionFlag = precision
+		setPrecision()
+
+		t.Run(fmt.Sprintf("-precision=%d", precision), func(t *testing.T) {
+			expectEveryStrategy(t, outputPrecisionInput, want)
+		})
+
+		tally, err := Process([]string{file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		partial := &bytes.Buffer{}
+		if err := tally.WritePartial(partial); err != nil {
+			t.Fatal(err)
+		}
+		partials[precision] = partial.Bytes()
+	}
+
+	//A partial comes back as it was written, and scaled up exactly when the merge keeps more decimals
+	for written, want := range outputPrecisionWants {
+		*precisionFlag = written
+		setPrecision()
+		expectPartialPrint(t, fmt.Sprintf("partial of -precision=%d", written), partials[written], want)
+	}
+	*precisionFlag = 3
+	setPrecision()
+	expectPartialPrint(t, "partial of -precision=1 merged at -precision=3", partials[1],
+		"{A=-40.000/-5.867/12.300, B=-0.100/0.000/0.100, C=-0.500/0.250/1.000}\n")
+}
+
+// expectPartialPrint fails t unless partial, named what, reads back and prints as want.
+func expectPartialPrint(t *testing.T, what string, partial []byte, want string) {
+	t.Helper()
+
+	tally, err := ReadPartial(bytes.NewReader(partial))
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+
+	buf := &bytes.Buffer{}
+	tally.Print(buf)
+	if buf.String() != want {
+		t.Errorf("%s: got %q, want %q", what, buf.String(), want)
+	}
+}
@@ -0,0 +1,54 @@
+package brc
+
+import (
+	"flag"
+	"runtime/debug"
+)
+
+var preset = commandLine.String("preset", "", "`name` of a bundle of settings, flags given explicitly still win: max (mmap, arena interned keys, GC off)")
+
+// presets are the flag values each -preset stands for, plus the settings that have no flag of their own.
+var presets = map[string]struct {
+	flags  map[string]string
+	intern bool
+	noGC   bool
+}{
+	"max": {flags: map[string]string{"strategy": "mmap", "numeric-stations": "auto"}, intern: true, noGC: true},
+}
+
+// ARENA_BLOCK is how many bytes of interned station names are allocated at a time.
+const ARENA_BLOCK = 64 * 1024
+
+// internKeys makes new station names views of the interner's arena rather than a string allocation each.
+var internKeys bool
+
+// applyPreset sets every flag of -preset that wasn't given on the command line. Runs before validation so the
+// preset's values are checked like any other, an unknown preset is left to validateFlags.
+func applyPreset() {
+	p, ok := presets[*preset]
+	if !ok {
+		return
+	}
+
+	explicit := map[string]bool{}
+	commandLine.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for name, value := range p.flags {
+		//mmap is the fastest safe way in, where it exists
+		if name == "strategy" && value == "mmap" && !mmapSupported {
+			value = "pread"
+		}
+
+		if !explicit[name] {
+			commandLine.Set(name, value)
+		}
+	}
+
+	internKeys = p.intern
+
+	//Optimisation: the mmap path allocates next to nothing per chunk, so the heap stays small enough that a
+	//collection only costs time. GOMEMLIMIT still forces one if the process gets near it.
+	if p.noGC {
+		debug.SetGCPercent(-1)
+	}
+}
@@ -0,0 +1,68 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// RACE_FIXTURE_SIZE is how big each file of TestConcurrentMatchesSerial is, a few chunks per worker.
+const RACE_FIXTURE_SIZE = 8 * BUFFER_SIZE
+
+// TestConcurrentMatchesSerial runs a medium fixture, every golden fixture repeated over several files, through
+// every strategy with more workers than chunks per file, plain and with interned keys, comparing each against
+// -serial. It's how CI catches concurrency regressions, any unsynchronised access it provokes fails it under
+//
+//	go test -race -run TestConcurrentMatchesSerial ./brc
+func TestConcurrentMatchesSerial(t *testing.T) {
+	defer func(intern bool) { internKeys = intern }(internKeys)
+	dir := t.TempDir()
+
+	fixtures, err := testdata.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines bytes.Buffer
+	for _, fixture := range fixtures {
+		if path.Ext(fixture.Name()) != ".txt" {
+			continue
+		}
+
+		input, err := testdata.ReadFile("testdata/" + fixture.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines.Write(input)
+		if len(input) > 0 && input[len(input)-1] != '\n' {
+			lines.WriteByte('\n')
+		}
+	}
+
+	files := make([]string, 3)
+	for i := range files {
+		files[i] = filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		content := strings.Repeat(lines.String(), RACE_FIXTURE_SIZE/lines.Len()+1)
+		if err := os.WriteFile(files[i], []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	random, err := writeRandomFiles(rand.New(rand.NewSource(1)), dir, 2, RACE_FIXTURE_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, random...)
+
+	for _, intern := range []bool{false, true} {
+		internKeys = intern
+		if err := compareSerial(files, WithWorkers(8)); err != nil {
+			t.Errorf("interned keys %t: %v", intern, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var httpRetries = commandLine.Int("http-retries", 4, "retry a failed or cut off request for a -input URL `n` times, backing off exponentially")
+var httpRanges = commandLine.Int("http-ranges", 0, "fetch a -input URL as `n` parallel ranged GETs when the server supports them (0 streams it)")
+var partSize = commandLine.Int64("part-size", 8<<20, "`bytes` asked for by each ranged GET of -http-ranges, read as they arrive")
+
+// HTTP_BACKOFF is the wait before the first retry, doubled for every one after.
+const HTTP_BACKOFF = 250 * time.Millisecond
+
+var httpClient = &http.Client{}
+
+// remote is an object fetched over HTTP. sign, if set, adds credentials to every request just before it is sent.
+type remote struct {
+	url  string
+	sign func(req *http.Request) error
+}
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") || isObjectURL(name)
+}
+
+// withRetries runs try until it succeeds, fails for good or -http-retries is used up.
+func withRetries(try func() (retry bool, err error)) error {
+	for attempt := 0; ; attempt++ {
+		retry, err := try()
+		if err == nil || !retry || attempt >= *httpRetries {
+			return err
+		}
+
+		slog.Warn("retrying", "err", err, "retry", attempt+1, "of", *httpRetries)
+		clock.Sleep(HTTP_BACKOFF << attempt)
+	}
+}
+
+// httpTry GETs [start, end) of r, end -1 meaning to the end. retry is true for failures that may well
+// go away: the connection, timeouts, 429 and 5xx.
+func httpTry(r remote, start, end int64) (*http.Response, bool, error) {
+	url := r.url
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ranged := start > 0 || end >= 0
+	if ranged {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+		}
+	}
+
+	if r.sign != nil {
+		if err := r.sign(req); err != nil {
+			return nil, false, fmt.Errorf("GET %s: %w", url, err)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if ranged && resp.StatusCode == http.StatusPartialContent || !ranged && resp.StatusCode == http.StatusOK {
+		return resp, false, nil
+	}
+	resp.Body.Close()
+
+	if ranged && resp.StatusCode == http.StatusOK {
+		return nil, false, fmt.Errorf("GET %s: server ignored Range %s", url, req.Header.Get("Range"))
+	}
+
+	code := resp.StatusCode
+	return nil, code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500, fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+// probeRanges asks for the first byte of url. A 206 with the total size in its Content-Range means ranged GETs work.
+func probeRanges(r remote) (int64, bool) {
+	size := int64(-1)
+
+	withRetries(func() (bool, error) {
+		resp, retry, err := httpTry(r, 0, 1)
+		if err != nil {
+			return retry, err
+		}
+		resp.Body.Close()
+
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+			size = n
+		}
+		return false, nil
+	})
+
+	return size, size > 0
+}
+
+// httpFile reads a URL with ranged GETs of up to -part-size for the positional read path, so segments are
+// fetched in parallel. A GET is left open for the ReadAt that carries on from where the last one stopped.
+type httpFile struct {
+	remote
+	size int64
+
+	//open GETs by the offset they will read next
+	parts map[int64]*httpStream
+	m     sync.Mutex
+}
+
+func newHTTPFile(r remote, size int64) *httpFile {
+	return &httpFile{remote: r, size: size, parts: map[int64]*httpStream{}}
+}
+
+func (f *httpFile) Name() string {
+	return f.url
+}
+
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+
+	for n < len(p) && off < f.size {
+		f.m.Lock()
+		part, ok := f.parts[off]
+		delete(f.parts, off)
+		f.m.Unlock()
+
+		if !ok {
+			part = &httpStream{remote: f.remote, pos: off, end: min(off+max(*partSize, int64(len(p))), f.size)}
+		}
+
+		read, err := part.Read(p[n:])
+		n += read
+		off += int64(read)
+
+		if err == io.EOF {
+			continue
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		f.m.Lock()
+		f.parts[off] = part
+		f.m.Unlock()
+	}
+
+	if off >= f.size {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// httpStream reads [pos, end) of a URL front to back, end -1 meaning to the end. When the server supports ranges a body cut off part way is
+// resumed from the byte it got to, otherwise the error ends the stream.
+type httpStream struct {
+	remote
+	ranges   bool
+	pos, end int64
+	body     io.ReadCloser
+
+	//cuts counts reconnects in a row that got nothing past opened, err is why the stream ended early
+	opened int64
+	cuts   int
+	err    error
+}
+
+func (s *httpStream) open() error {
+	if s.cuts > 0 {
+		clock.Sleep(HTTP_BACKOFF << (s.cuts - 1))
+	}
+
+	return withRetries(func() (bool, error) {
+		resp, retry, err := httpTry(s.remote, s.pos, s.end)
+		if err != nil {
+			return retry, err
+		}
+
+		//A 206 is as good as an Accept-Ranges
+		if resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Accept-Ranges") == "bytes" {
+			s.ranges = true
+		}
+		s.body, s.opened = resp.Body, s.pos
+		return false, nil
+	})
+}
+
+// Read fills p as far as the body allows, readInFile hands every Read to the parsers as a chunk.
+func (s *httpStream) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) && s.err == nil {
+		if s.body == nil {
+			if s.err = s.open(); s.err != nil {
+				break
+			}
+		}
+
+		read, err := s.body.Read(p[n:])
+		n += read
+		s.pos += int64(read)
+
+		if err == io.EOF || s.end >= 0 && s.pos >= s.end {
+			s.body.Close()
+			s.body = nil
+			return n, io.EOF
+		}
+
+		if err != nil {
+			s.body.Close()
+			s.body = nil
+
+			if s.pos > s.opened {
+				s.cuts = 0
+			}
+			s.cuts++
+
+			if !s.ranges || s.cuts > *httpRetries {
+				s.err = fmt.Errorf("GET %s: %w at byte %d", s.url, err, s.pos)
+				break
+			}
+			slog.Warn("GET cut off, resuming", "url", s.url, "err", err, "byte", s.pos)
+		}
+	}
+
+	if n == 0 && s.err != nil {
+		return 0, s.err
+	}
+	return n, nil
+}
+
+// processURL streams url through the parser pool, or with -http-ranges reads it with the pread strategy's
+// segments over ranged GETs. -strategy only applies to local files.
+func processURL(name string, tally *Tally, o *Options) error {
+	r, err := resolveRemote(name)
+	if err != nil {
+		return err
+	}
+
+	if *httpRanges > 1 {
+		if size, ok := probeRanges(r); ok {
+			return processRange(newHTTPFile(r, size), 0, size, *httpRanges, tally, o)
+		}
+		slog.Info("server doesn't do ranged GETs, streaming it instead", "url", name)
+	}
+
+	stream := &httpStream{remote: r, end: -1}
+	err = streamInto(stream, name, tally, o)
+
+	return errors.Join(err, stream.err)
+}
@@ -0,0 +1,95 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHTTPInput serves a fixture repeated past a few BUFFER_SIZEs over HTTP and reads it back streamed and
+// with ranged GETs, from a server that answers well and from one that fails then cuts off every request before
+// answering it in full the third time.
+func TestHTTPInput(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	input, err := testdata.ReadFile("testdata/measurements-30-stations.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := testdata.ReadFile("testdata/measurements-30-stations.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat(input, 3*BUFFER_SIZE/len(input)+1)
+	attempts := map[string]int{}
+	m := sync.Mutex{}
+
+	serve := func(flaky bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			m.Lock()
+			attempts[r.Header.Get("Range")]++
+			n, limit := attempts[r.Header.Get("Range")], 1+rng.Intn(BUFFER_SIZE/2)
+			m.Unlock()
+
+			switch {
+			case flaky && n == 1:
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			case flaky && n == 2:
+				w = &flakyWriter{w, limit}
+			}
+			http.ServeContent(w, r, "measurements.txt", time.Time{}, bytes.NewReader(content))
+		}
+	}
+
+	//Backoffs run on a FakeClock so the retries cost nothing, and their logging is noise here
+	defer func(c Clock, out io.Writer, ranges int) { clock, *httpRanges = c, ranges; log.SetOutput(out) }(clock, log.Writer(), *httpRanges)
+	clock = NewFakeClock(time.Unix(0, 0))
+	log.SetOutput(io.Discard)
+
+	for _, flaky := range []bool{false, true} {
+		server := httptest.NewServer(serve(flaky))
+		t.Cleanup(server.Close)
+
+		for _, ranges := range []int{0, 4} {
+			*httpRanges = ranges
+			clear(attempts)
+
+			got, err := runPipeline(StreamingStrategy{}, []string{server.URL + "/measurements.txt?flaky=" + strconv.FormatBool(flaky)})
+			if err != nil {
+				t.Fatalf("-http-ranges=%d from a flaky=%t server: %v", ranges, flaky, err)
+			}
+
+			if got != string(want) {
+				t.Fatalf("-http-ranges=%d from a flaky=%t server:\ngot  %q\nwant %q", ranges, flaky, got, want)
+			}
+		}
+	}
+}
+
+// flakyWriter cuts a response body off after limit bytes, the way a dropped connection looks to the client.
+type flakyWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		w.ResponseWriter.Write(p[:w.limit])
+		w.limit = 0
+		return 0, errors.New("cut off")
+	}
+
+	w.limit -= len(p)
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,183 @@
+package brc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var repeat = commandLine.Int("repeat", 1, "run the aggregation `n` times, reporting the results of the last and the mean, median, stddev and min of the wall time and each phase over all of them")
+var dropCaches = commandLine.Bool("drop-caches", false, "evict the input files from the page cache before every run, so each reads cold (64 bit Linux only)")
+
+// Spread summarises one time over the runs of -repeat. Stddev is the sample standard deviation, 0 for one run.
+type Spread struct {
+	Mean   time.Duration `json:"mean_ns"`
+	Median time.Duration `json:"median_ns"`
+	Stddev time.Duration `json:"stddev_ns"`
+	Min    time.Duration `json:"min_ns"`
+	Max    time.Duration `json:"max_ns"`
+}
+
+func spreadOf(times []time.Duration) Spread {
+	sorted := slices.Sorted(slices.Values(times))
+	n := len(sorted)
+
+	var sum float64
+	for _, d := range sorted {
+		sum += float64(d)
+	}
+	mean := sum / float64(n)
+
+	var squares float64
+	for _, d := range sorted {
+		squares += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stddev := 0.0
+	if n > 1 {
+		stddev = math.Sqrt(squares / float64(n-1))
+	}
+
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	return Spread{time.Duration(math.Round(mean)), median, time.Duration(math.Round(stddev)), sorted[0], sorted[n-1]}
+}
+
+// RepeatTiming is the -timing-json document of a -repeat run. Runs time reading, parsing and aggregating the
+// inputs, the results are only sorted and output once so those phases aren't repeated.
+type RepeatTiming struct {
+	Runs      int             `json:"runs"`
+	Total     Spread          `json:"total"`
+	Read      Spread          `json:"read"`
+	Parse     Spread          `json:"parse"`
+	Aggregate Spread          `json:"aggregate"`
+	Merge     Spread          `json:"merge"`
+	Times     []time.Duration `json:"times_ns"`
+}
+
+func newRepeatTiming(runs []Phases) RepeatTiming {
+	phase := func(of func(p Phases) time.Duration) []time.Duration {
+		times := make([]time.Duration, len(runs))
+		for i, p := range runs {
+			times[i] = of(p)
+		}
+		return times
+	}
+
+	times := phase(func(p Phases) time.Duration { return p.Total })
+	return RepeatTiming{
+		Runs:      len(runs),
+		Total:     spreadOf(times),
+		Read:      spreadOf(phase(func(p Phases) time.Duration { return p.Read })),
+		Parse:     spreadOf(phase(func(p Phases) time.Duration { return p.Parse })),
+		Aggregate: spreadOf(phase(func(p Phases) time.Duration { return p.Aggregate })),
+		Merge:     spreadOf(phase(func(p Phases) time.Duration { return p.Merge })),
+		Times:     times,
+	}
+}
+
+// print writes t as a table:
+//
+//	5 runs       mean         median       stddev       min
+//	  total      1.234567s    1.23s        12.3ms       1.2s
+//	  read       ...
+func (t RepeatTiming) print(w io.Writer) {
+	fmt.Fprintf(w, "%-12s %-12s %-12s %-12s %s\n", fmt.Sprintf("%d runs", t.Runs), "mean", "median", "stddev", "min")
+	for _, phase := range []struct {
+		name string
+		s    Spread
+	}{{"total", t.Total}, {"read", t.Read}, {"parse", t.Parse}, {"aggregate", t.Aggregate}, {"merge", t.Merge}} {
+		fmt.Fprintf(w, "  %-10s %-12v %-12v %-12v %v\n", phase.name, phase.s.Mean.Round(time.Microsecond),
+			phase.s.Median.Round(time.Microsecond), phase.s.Stddev.Round(time.Microsecond), phase.s.Min.Round(time.Microsecond))
+	}
+	fmt.Fprintln(w, "  (read, parse and aggregate are summed over goroutines)")
+}
+
+// repeatRuns runs the aggregation over files -repeat times, returning the tallies of the last run, when it
+// started and the phases of every run. Nothing is carried over from one run to the next but the page cache,
+// which -drop-caches empties of the inputs too.
+func repeatRuns(o *Options, files []string) ([]*Tally, time.Time, []Phases, error) {
+	var tallies []*Tally
+	var start time.Time
+	runs := make([]Phases, 0, *repeat)
+
+	for run := range *repeat {
+		if *dropCaches {
+			evictInputs(files)
+		}
+		resetPhases()
+		resetSampling()
+		o.bytes.Store(0)
+		o.chunks.Store(0)
+		chunkReports.m.Lock()
+		chunkReports.reports = nil
+		chunkReports.m.Unlock()
+
+		var err error
+		start = clock.Now()
+		if tallies, err = processFiles(o, files); err != nil {
+			return nil, start, nil, err
+		}
+		runs = append(runs, collectPhases(clock.Since(start)))
+		slog.Debug("run done", "run", run+1, "of", *repeat, "elapsed", runs[run].Total)
+	}
+
+	return tallies, start, runs, nil
+}
+
+// evictInputs asks the kernel to drop the local inputs from the page cache. Pages another process has
+// mapped or dirtied stay, as they would for echo 1 > /proc/sys/vm/drop_caches.
+func evictInputs(files []string) {
+	for _, name := range files {
+		if isURL(name) {
+			continue
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		fadvise(f.Fd(), 0, 0, ADVISE_DONTNEED)
+		f.Close()
+	}
+}
+
+// reportRepeatTiming is reportTiming for the runs of -repeat.
+func reportRepeatTiming(runs []Phases) {
+	t := newRepeatTiming(runs)
+
+	switch effectiveTimingFormat() {
+	case "human":
+		t.print(os.Stderr)
+	case "benchstat":
+		//A line per run is a benchmark run with -count, benchstat works the spread out itself
+		for _, p := range runs {
+			fmt.Printf("Benchmark1BRC/strategy=%s 1 %d ns/op\n", *strategyName, p.Total.Nanoseconds())
+		}
+	case "hyperfine":
+		seconds := make([]float64, len(runs))
+		for i, p := range runs {
+			seconds[i] = p.Total.Seconds()
+		}
+		export := map[string][]hyperfineResult{
+			"results": {{strings.Join(os.Args, " "), t.Total.Mean.Seconds(), t.Total.Stddev.Seconds(),
+				t.Total.Median.Seconds(), t.Total.Min.Seconds(), t.Total.Max.Seconds(), seconds}},
+		}
+		json.NewEncoder(os.Stdout).Encode(export)
+	}
+
+	if *timingJSON != "" {
+		if err := writeTimingJSON(*timingJSON, t); err != nil {
+			log.Fatal("could not write timing JSON: ", err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package brc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRepeat checks the spread of a few known times, then that -repeat runs a file that many times and
+// leaves the tally of the last run alone, not the sum of them all.
+func TestRepeat(t *testing.T) {
+	ms := func(ns ...int) []time.Duration {
+		times := make([]time.Duration, len(ns))
+		for i, n := range ns {
+			times[i] = time.Duration(n) * time.Millisecond
+		}
+		return times
+	}
+	for _, c := range []struct {
+		times []time.Duration
+		want  Spread
+	}{
+		{ms(10), Spread{10 * time.Millisecond, 10 * time.Millisecond, 0, 10 * time.Millisecond, 10 * time.Millisecond}},
+		{ms(40, 10, 30, 20), Spread{25 * time.Millisecond, 25 * time.Millisecond, 12909944, 10 * time.Millisecond, 40 * time.Millisecond}},
+		{ms(3, 1, 8), Spread{4 * time.Millisecond, 3 * time.Millisecond, 3605551, time.Millisecond, 8 * time.Millisecond}},
+	} {
+		if got := spreadOf(c.times); got != c.want {
+			t.Fatalf("spread of %v: got %+v, want %+v", c.times, got, c.want)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte("A;1.0\nB;2.0\nA;3.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(n int) { *repeat = n }(*repeat)
+	*repeat = 3
+	o, err := newOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tallies, _, runs, err := repeatRuns(o, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	tallies[0].Print(buf)
+	if want := "{A=1.0/2.0/3.0, B=2.0/2.0/2.0}\n"; len(runs) != 3 || buf.String() != want {
+		t.Fatalf("-repeat=3: got %d runs of %q, want 3 of %q", len(runs), buf.String(), want)
+	}
+}
@@ -0,0 +1,54 @@
+package brc
+
+import (
+	"math/rand/v2"
+)
+
+var reservoirSize = commandLine.Int("reservoir", 0, "keep a uniform random sample of up to `n` raw readings per station, listed in the json and jsonl outputs (0 keeps none)")
+
+// reservoir is a uniform random sample of the readings of one station, kept by Algorithm R: the first
+// -reservoir readings fill it, then the nth replaces a random one of them with probability size/n.
+type reservoir struct {
+	values []int
+}
+
+// newReservoir returns an empty reservoir, or nil when -reservoir keeps none and AddAt pays one nil check.
+func newReservoir() *reservoir {
+	if *reservoirSize == 0 {
+		return nil
+	}
+	return &reservoir{make([]int, 0, min(*reservoirSize, 16))}
+}
+
+// add offers the count-th reading of the station.
+func (s *reservoir) add(temp, count int) {
+	if len(s.values) < *reservoirSize {
+		s.values = append(s.values, temp)
+		return
+	}
+	if j := rand.IntN(count); j < len(s.values) {
+		s.values[j] = temp
+	}
+}
+
+// merge makes s a sample of the union of the n readings s samples and the m other samples. How many of the
+// union's sample come from s is drawn as if sampling without replacement from the n + m readings, then that
+// many are kept from s at random and the rest taken from other at random.
+func (s *reservoir) merge(other *reservoir, n, m int) {
+	size := min(*reservoirSize, n+m)
+
+	fromS := 0
+	for remainingS, remaining := n, n+m; remaining > n+m-size; remaining-- {
+		if rand.IntN(remaining) < remainingS {
+			fromS++
+			remainingS--
+		}
+	}
+
+	rand.Shuffle(len(s.values), func(i, j int) { s.values[i], s.values[j] = s.values[j], s.values[i] })
+	picked := append(make([]int, 0, size), s.values[:fromS]...)
+	for _, i := range rand.Perm(len(other.values))[:size-fromS] {
+		picked = append(picked, other.values[i])
+	}
+	s.values = picked
+}
@@ -0,0 +1,54 @@
+package brc
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestReservoir adds readings 0..19 to two tallies, twelve to one and eight to the other, merges them and
+// checks every reading ends up in about a quarter of 5 reading samples, then that the jsonl output lists them.
+func TestReservoir(t *testing.T) {
+	defer func(n int) { *reservoirSize = n }(*reservoirSize)
+	*reservoirSize = 5
+
+	const TRIALS = 20000
+	seen := make([]int, 20)
+	for range TRIALS {
+		a, b, total := NewTally(), NewTally(), NewTally()
+		for temp := range 20 {
+			if temp < 12 {
+				a.Get([]byte("A")).Add(temp)
+			} else {
+				b.Get([]byte("A")).Add(temp)
+			}
+		}
+		total.Merge(a)
+		total.Merge(b)
+
+		r, _ := total.Lookup("A")
+		if len(r.sample.values) != *reservoirSize {
+			t.Fatalf("sample of %d readings, want %d", len(r.sample.values), *reservoirSize)
+		}
+		for _, temp := range r.sample.values {
+			seen[temp]++
+		}
+	}
+	for temp, n := range seen {
+		if f := float64(n) / TRIALS; math.Abs(f-0.25) > 0.02 {
+			t.Fatalf("reading %d was sampled in %.3f of the runs, want 0.25", temp, f)
+		}
+	}
+
+	tally := NewTally()
+	tally.Get([]byte("A")).Add(-5)
+	tally.Get([]byte("A")).Add(123)
+	buf := &bytes.Buffer{}
+	if err := writeJSONLines(buf, tally.Rows("")); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"sample":[-0.5,12.3]`; !strings.Contains(buf.String(), want) {
+		t.Fatalf("jsonl output %q doesn't list %s", buf.String(), want)
+	}
+}
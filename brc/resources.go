@@ -0,0 +1,153 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Guardrails for shared benchmark servers, applied before any work starts.
+var nice = commandLine.Int("nice", 0, "scheduling niceness `n` (-20..19) to run at, 0 leaves it alone")
+var ionice = commandLine.String("ionice", "", "I/O scheduling `class[:level]` to run at: realtime, best-effort or idle (Linux only)")
+var maxCPUs = commandLine.Int("max-cpus", 0, "cap GOMAXPROCS and the default -workers to `n` CPUs (0 uses all, or the container's CPU quota)")
+var pinCPUs = commandLine.Bool("pin-cpus", false, "also restrict CPU affinity to the first -max-cpus CPUs (Linux only)")
+var maxMemory = commandLine.String("max-memory", "", "soft memory `limit` such as 2GiB: sets the GC's limit, shrinks chunks and the queue to fit and streams files bigger than it instead of mapping them (empty is no limit)")
+
+// memoryBudget is -max-memory in bytes once applied, 0 for no limit.
+var memoryBudget int64
+
+func init() {
+	commandLine.IntVar(maxCPUs, "cpus", 0, "same as -max-cpus")
+}
+
+// IOPRIO_CLASS_SHIFT and the class numbers are from linux/ioprio.h.
+const IOPRIO_CLASS_SHIFT = 13
+
+var ioprioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// parseIonice turns class[:level] into a Linux ioprio value.
+func parseIonice(value string) (int, error) {
+	name, levelStr, hasLevel := strings.Cut(value, ":")
+
+	class, ok := ioprioClasses[name]
+	if !ok {
+		return 0, fmt.Errorf("-ionice class %q is unknown, want realtime, best-effort or idle", name)
+	}
+
+	level := 4
+	if hasLevel {
+		var err error
+		level, err = strconv.Atoi(levelStr)
+		if err != nil || level < 0 || level > 7 {
+			return 0, fmt.Errorf("-ionice level %q must be 0..7", levelStr)
+		}
+
+		if name == "idle" {
+			return 0, fmt.Errorf("-ionice=idle takes no level")
+		}
+	}
+
+	if name == "idle" {
+		level = 0
+	}
+
+	return class<<IOPRIO_CLASS_SHIFT | level, nil
+}
+
+var byteUnits = map[string]int64{
+	"": 1, "B": 1,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+}
+
+// parseBytes parses a size such as 512MiB, 2GB or 1.5GiB, a bare number being bytes.
+func parseBytes(value string) (int64, error) {
+	number := strings.TrimRight(value, "KMGTiB")
+	unit, ok := byteUnits[value[len(number):]]
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a size such as 512MiB or 2GiB", value)
+	}
+
+	return int64(n * float64(unit)), nil
+}
+
+// effectiveCPUs is how many CPUs the process may actually use. runtime.NumCPU only knows about the
+// affinity mask, so in a container with a CPU quota (Kubernetes limits) it is the host's core count.
+func effectiveCPUs() int {
+	cpus := runtime.NumCPU()
+
+	if limit, ok := cgroupCPULimit(); ok {
+		//A quota of 1.5 CPUs still lets 2 threads make progress at once
+		if quota := int(math.Ceil(limit)); quota < cpus {
+			cpus = quota
+		}
+	}
+
+	return cpus
+}
+
+// applyResourceLimits lowers the process priority and caps CPU and memory use as requested.
+func applyResourceLimits() error {
+	//Without an explicit cap, the container's CPU quota is the cap
+	capped := *maxCPUs
+	if capped == 0 {
+		if cpus := effectiveCPUs(); cpus < runtime.NumCPU() {
+			capped = cpus
+		}
+	}
+
+	if capped > 0 && !flagSet("workers") && *workers > capped {
+		*workers = capped
+	}
+
+	//A GOMAXPROCS set in the environment beats a detected quota, but not an explicit -max-cpus
+	if *maxCPUs > 0 || capped > 0 && os.Getenv("GOMAXPROCS") == "" {
+		runtime.GOMAXPROCS(capped)
+	}
+
+	if *pinCPUs {
+		if err := pinToCPUs(*maxCPUs); err != nil {
+			return fmt.Errorf("could not pin CPUs: %w", err)
+		}
+	}
+
+	if *maxMemory != "" {
+		budget, err := parseBytes(*maxMemory)
+		if err != nil {
+			return fmt.Errorf("-max-memory %w", err)
+		}
+
+		memoryBudget = budget
+		debug.SetMemoryLimit(budget)
+	}
+	applyGCTuning()
+
+	if *nice != 0 {
+		if err := setNice(*nice); err != nil {
+			return fmt.Errorf("could not set nice %d: %w", *nice, err)
+		}
+	}
+
+	if *ionice != "" {
+		prio, err := parseIonice(*ionice)
+		if err != nil {
+			return err
+		}
+
+		if err := setIoprio(prio); err != nil {
+			return fmt.Errorf("could not set ionice %s: %w", *ionice, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package brc
+
+import (
+	"errors"
+	"syscall"
+)
+
+const resourceNiceSupported, resourceIoniceSupported, resourcePinSupported = true, false, false
+
+// setNice is process wide on the BSDs.
+func setNice(n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, n)
+}
+
+func setIoprio(prio int) error {
+	return errors.New("not supported on this platform")
+}
+
+func pinToCPUs(n int) error {
+	return errors.New("not supported on this platform")
+}
+
+func cgroupCPULimit() (float64, bool) {
+	return 0, false
+}
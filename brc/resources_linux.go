@@ -0,0 +1,183 @@
+package brc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const resourceNiceSupported, resourceIoniceSupported, resourcePinSupported = true, true, true
+
+// IOPRIO_WHO_PROCESS is from linux/ioprio.h.
+const IOPRIO_WHO_PROCESS = 1
+
+// eachThread calls fn with every thread id of the process.
+// Linux applies nice, ioprio and affinity per thread, and a new thread inherits them from the
+// thread that creates it, so updating every existing thread covers the threads the runtime starts later too.
+func eachThread(fn func(tid int) error) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+
+		//A thread may exit between reading the directory and updating it
+		if err := fn(tid); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setNice(n int) error {
+	return eachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, n)
+	})
+}
+
+func setIoprio(prio int) error {
+	return eachThread(func(tid int) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, IOPRIO_WHO_PROCESS, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// cpuMask is a cpu_set_t big enough for 1024 CPUs.
+type cpuMask [16]uint64
+
+// pinToCPUs restricts every thread to the first n CPUs of the current affinity mask.
+func pinToCPUs(n int) error {
+	var current, pinned cpuMask
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(current), uintptr(unsafe.Pointer(&current)))
+	if errno != 0 {
+		return errno
+	}
+
+	for cpu := 0; cpu < len(current)*64 && n > 0; cpu++ {
+		if current[cpu/64]&(1<<(cpu%64)) != 0 {
+			pinned[cpu/64] |= 1 << (cpu % 64)
+			n--
+		}
+	}
+
+	return eachThread(func(tid int) error {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), unsafe.Sizeof(pinned), uintptr(unsafe.Pointer(&pinned)))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// CGROUP_ROOT is where the cgroup hierarchies are mounted. cgroup v2 is either mounted here
+// or, on hybrid hosts, at unified/ under it, and every v1 controller gets its own directory.
+const CGROUP_ROOT = "/sys/fs/cgroup"
+
+// cgroupCPULimit returns the CPU quota of this process's cgroup in CPUs. ok is false when there is none,
+// which is the norm outside containers. The tightest quota on the way up to the root wins, as it does in the kernel.
+func cgroupCPULimit() (float64, bool) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+
+	limit, found := 0.0, false
+	lower := func(cpus float64, ok bool) {
+		if ok && (!found || cpus < limit) {
+			limit, found = cpus, true
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		//hierarchy-id:controller,controller:path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] == "0" && fields[1] == "" {
+			for _, mount := range []string{CGROUP_ROOT, filepath.Join(CGROUP_ROOT, "unified")} {
+				lower(walkCgroup(mount, fields[2], cgroupV2Quota))
+			}
+		} else if contains(strings.Split(fields[1], ","), "cpu") {
+			for _, mount := range []string{filepath.Join(CGROUP_ROOT, fields[1]), filepath.Join(CGROUP_ROOT, "cpu")} {
+				lower(walkCgroup(mount, fields[2], cgroupV1Quota))
+			}
+		}
+	}
+
+	return limit, found
+}
+
+// walkCgroup applies quota to path and every parent under mount, returning the smallest limit.
+// Inside a cgroup namespace path is just "/" and the container's own cgroup is the mount root.
+func walkCgroup(mount, path string, quota func(dir string) (float64, bool)) (float64, bool) {
+	limit, found := 0.0, false
+
+	for dir := filepath.Clean("/" + path); ; dir = filepath.Dir(dir) {
+		if cpus, ok := quota(filepath.Join(mount, dir)); ok && (!found || cpus < limit) {
+			limit, found = cpus, true
+		}
+
+		if dir == "/" {
+			return limit, found
+		}
+	}
+}
+
+// cgroupV2Quota reads cpu.max, "max 100000" or "<quota> <period>" in microseconds.
+func cgroupV2Quota(dir string) (float64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+
+	return quotaCPUs(fields[0], fields[1])
+}
+
+// cgroupV1Quota reads cpu.cfs_quota_us, -1 meaning unlimited, and cpu.cfs_period_us.
+func cgroupV1Quota(dir string) (float64, bool) {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, false
+	}
+
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, false
+	}
+
+	return quotaCPUs(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func quotaCPUs(quota, period string) (float64, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+
+	return float64(q) / float64(p), true
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package brc
+
+import (
+	"errors"
+)
+
+const resourceNiceSupported, resourceIoniceSupported, resourcePinSupported = false, false, false
+
+func setNice(n int) error {
+	return errors.New("not supported on this platform")
+}
+
+func setIoprio(prio int) error {
+	return errors.New("not supported on this platform")
+}
+
+func pinToCPUs(n int) error {
+	return errors.New("not supported on this platform")
+}
+
+func cgroupCPULimit() (float64, bool) {
+	return 0, false
+}
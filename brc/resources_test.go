@@ -0,0 +1,78 @@
+package brc
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var byteSizeCases = []struct {
+	value string
+	want  int64
+}{
+	{"512", 512},
+	{"64KB", 64_000},
+	{"512MiB", 512 << 20},
+	{"2GiB", 2 << 30},
+	{"1.5GiB", 3 << 29},
+	{"2 GB", 2_000_000_000},
+	{"", -1},
+	{"GiB", -1},
+	{"-1MiB", -1},
+	{"2gb", -1},
+	{"2XiB", -1},
+}
+
+func TestParseBytes(t *testing.T) {
+	for _, c := range byteSizeCases {
+		got, err := parseBytes(c.value)
+		if c.want < 0 && err == nil || c.want >= 0 && (err != nil || got != c.want) {
+			t.Errorf("parseBytes(%q) = %d, %v, want %d", c.value, got, err, c.want)
+		}
+	}
+}
+
+// TestMaxMemory runs every strategy under a budget smaller than either file, which must shrink the chunks and
+// stream rather than map, and only slow it down.
+func TestMaxMemory(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	files, err := writeRandomFiles(rng, t.TempDir(), 2, BUFFER_SIZE+rng.Intn(BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Smaller than either file, so mmap streams them
+	budget := int64(64*MIN_CHUNK_SIZE + rng.Intn(BUFFER_SIZE/2))
+
+	o, err := newOptions(WithWorkers(3), WithMaxMemory(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(o.chunkSize*(2*3)) > max(budget/MEMORY_CHUNK_SHARE, 2*3*MIN_CHUNK_SIZE) || o.depth < 3 || o.pool == BufferPool {
+		t.Fatalf("a %d byte budget got %d byte chunks, %d deep", budget, o.chunkSize, o.depth)
+	}
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		want, err := runPipeline(strategies[strategyName], files)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tally, err := Process(files, WithStrategy(strategies[strategyName]), WithWorkers(3), WithMaxMemory(budget))
+		if err != nil {
+			t.Fatalf("-strategy=%s: %v", strategyName, err)
+		}
+
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		if buf.String() != want {
+			t.Errorf("-strategy=%s with -max-memory=%d: got %q, want %q", strategyName, budget, buf.String(), want)
+		}
+	}
+}
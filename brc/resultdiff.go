@@ -0,0 +1,275 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// result is one station of a results file, in degrees.
+type result struct {
+	min, mean, max float64
+}
+
+// readResults reads a results file of any of the file output formats, telling them apart by their first byte
+// and line: text is one {...} line (the last one, after any -per-file blocks), json a document with stations,
+// jsonl a StationRow per line, arrow starts with its magic and csv has a header. Only the first three values of a text station are read,
+// so -stats and -agg-fns columns past max are fine, as are results from other 1BRC implementations.
+func readResults(r io.Reader) (map[string]result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte(ARROW_MAGIC)) {
+		return readArrowResults(data)
+	}
+
+	data = bytes.TrimSpace(data)
+	first, _, _ := bytes.Cut(data, []byte{'\n'})
+
+	switch {
+	case bytes.HasPrefix(first, []byte(`{"`)) && bytes.Contains(first, []byte(`"stations":`)):
+		return readJSONResults(data)
+	case bytes.HasPrefix(first, []byte(`{"`)):
+		return readJSONLinesResults(data)
+	case bytes.HasPrefix(first, []byte{'{'}) || bytes.HasPrefix(first, []byte("==> ")):
+		return readTextResults(data)
+	}
+	return readCSVResults(data)
+}
+
+func readTextResults(data []byte) (map[string]result, error) {
+	var line string
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(l, "{") {
+			line = strings.TrimRight(l, "\r")
+		}
+	}
+
+	if !strings.HasSuffix(line, "}") {
+		return nil, fmt.Errorf("results line %q isn't {station=min/mean/max, ...}", line)
+	}
+
+	results := map[string]result{}
+	body := line[1 : len(line)-1]
+	if body == "" {
+		return results, nil
+	}
+
+	for _, entry := range strings.Split(body, ", ") {
+		i := strings.LastIndexByte(entry, '=')
+		if i == -1 {
+			return nil, fmt.Errorf("station %q has no =", entry)
+		}
+
+		values := strings.Split(entry[i+1:], "/")
+		if len(values) < 3 {
+			return nil, fmt.Errorf("station %q wants min/mean/max", entry)
+		}
+
+		r, err := parseResult(values[0], values[1], values[2])
+		if err != nil {
+			return nil, fmt.Errorf("station %q: %w", entry[:i], err)
+		}
+		results[entry[:i]] = r
+	}
+
+	return results, nil
+}
+
+func readJSONResults(data []byte) (map[string]result, error) {
+	results := map[string]result{}
+
+	//-append-run-id leaves a document per run, the last is the latest
+	lines := bytes.Split(data, []byte{'\n'})
+	doc := jsonReport{}
+	if err := json.Unmarshal(lines[len(lines)-1], &doc); err != nil {
+		return nil, err
+	}
+
+	return results, addRows(results, doc.Stations)
+}
+
+func readJSONLinesResults(data []byte) (map[string]result, error) {
+	results := map[string]result{}
+	var rows []StationRow
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		row := StationRow{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return results, errors.Join(scanner.Err(), addRows(results, rows))
+}
+
+func readCSVResults(data []byte) (map[string]result, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty csv")
+	}
+
+	columns := map[string]int{}
+	for _, name := range []string{"station", "min", "mean", "max"} {
+		columns[name] = slices.Index(records[0], name)
+		if columns[name] == -1 {
+			return nil, fmt.Errorf("csv header %v has no %s column", records[0], name)
+		}
+	}
+
+	rows := make([]StationRow, len(records)-1)
+	for i, record := range records[1:] {
+		rows[i] = StationRow{Station: record[columns["station"]], Min: json.Number(record[columns["min"]]),
+			Mean: json.Number(record[columns["mean"]]), Max: json.Number(record[columns["max"]])}
+	}
+
+	results := map[string]result{}
+	return results, addRows(results, rows)
+}
+
+func addRows(results map[string]result, rows []StationRow) error {
+	for _, row := range rows {
+		r, err := parseResult(row.Min.String(), row.Mean.String(), row.Max.String())
+		if err != nil {
+			return fmt.Errorf("station %q: %w", row.Station, err)
+		}
+		results[row.Station] = r
+	}
+	return nil
+}
+
+func parseResult(min, mean, max string) (result, error) {
+	var values [3]float64
+
+	for i, s := range []string{min, mean, max} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return result{}, err
+		}
+		values[i] = v
+	}
+
+	return result{values[0], values[1], values[2]}, nil
+}
+
+// diffResults lists every station missing from a or b, or whose min, mean or max differ by more than tolerance degrees.
+func diffResults(a, b map[string]result, tolerance float64) []string {
+	names := sortedKeys(a)
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	//A hair of slack so 0.1 apart still counts as within -tolerance 0.1 after float rounding
+	const epsilon = 1e-9
+
+	var diffs []string
+	for _, name := range names {
+		ra, inA := a[name]
+		rb, inB := b[name]
+
+		switch {
+		case !inB:
+			diffs = append(diffs, fmt.Sprintf("%s: only in the first", name))
+		case !inA:
+			diffs = append(diffs, fmt.Sprintf("%s: only in the second", name))
+		default:
+			var parts []string
+			for _, v := range []struct {
+				name string
+				a, b float64
+			}{{"min", ra.min, rb.min}, {"mean", ra.mean, rb.mean}, {"max", ra.max, rb.max}} {
+				if math.Abs(v.a-v.b) > tolerance+epsilon {
+					parts = append(parts, fmt.Sprintf("%s %.1f vs %.1f", v.name, v.a, v.b))
+				}
+			}
+			if len(parts) > 0 {
+				diffs = append(diffs, name+": "+strings.Join(parts, ", "))
+			}
+		}
+	}
+
+	return diffs
+}
+
+func readResultsFile(name string) (map[string]result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results, err := readResults(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return results, nil
+}
+
+// runDiff compares two results files station by station, exiting 1 when they differ like diff(1) does.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	tolerance := fs.Float64("tolerance", 0, "`degrees` min, mean and max may differ by and still match")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: diff a.out b.out [-tolerance degrees]")
+		fs.PrintDefaults()
+	}
+
+	//Flags may come after the files too
+	var files []string
+	for fs.Parse(args); fs.NArg() > 0; fs.Parse(args) {
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(files) != 2 || *tolerance < 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a, err := readResultsFile(files[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := readResultsFile(files[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diffs := diffResults(a, b, *tolerance)
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+
+	if len(diffs) > 0 {
+		stations := len(a)
+		for name := range b {
+			if _, ok := a[name]; !ok {
+				stations++
+			}
+		}
+
+		fmt.Printf("%d of %d stations differ\n", len(diffs), stations)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package brc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiff reads every golden back in each file output format, which must all match it, then moves and drops stations.
+func TestDiff(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	for _, g := range readGoldens(t) {
+		t.Run(g.name, func(t *testing.T) {
+			want, err := readResults(strings.NewReader(g.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file := filepath.Join(dir, g.name)
+			if err := os.WriteFile(file, []byte(g.input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			tally, err := Process([]string{file}, WithStrategy(StreamingStrategy{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			formats := map[string]func(w io.Writer) error{
+				"csv":   func(w io.Writer) error { return writeCSV(w, tally.Rows(""), "", true) },
+				"jsonl": func(w io.Writer) error { return writeJSONLines(w, tally.Rows("")) },
+				"json":  func(w io.Writer) error { return json.NewEncoder(w).Encode(newJSONReport(Report{Tally: tally})) },
+				"arrow": func(w io.Writer) error { return writeArrowReport(w, Report{Tally: tally}, true) },
+			}
+
+			for format, write := range formats {
+				buf := &bytes.Buffer{}
+				if err := write(buf); err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := readResults(buf)
+				if err != nil {
+					t.Fatalf("as %s: %v", format, err)
+				}
+				if diffs := diffResults(got, want, 0); len(diffs) > 0 {
+					t.Errorf("as %s differs from its golden: %v", format, diffs)
+				}
+			}
+
+			if len(want) == 0 {
+				return
+			}
+
+			stations := sortedKeys(want)
+			moved, dropped := stations[rng.Intn(len(stations))], stations[rng.Intn(len(stations))]
+
+			changed := maps.Clone(want)
+			r := changed[moved]
+			r.mean += 0.1
+			changed[moved] = r
+
+			if diffs := diffResults(want, changed, 0.1); len(diffs) > 0 {
+				t.Errorf("a mean 0.1 off is within -tolerance 0.1, got %v", diffs)
+			}
+			if diffs := diffResults(want, changed, 0.05); len(diffs) != 1 || !strings.HasPrefix(diffs[0], moved+": mean ") {
+				t.Errorf("moved the mean of %q, got %v", moved, diffs)
+			}
+
+			delete(changed, dropped)
+			if diffs := diffResults(want, changed, 0.1); len(diffs) != 1 || diffs[0] != dropped+": only in the first" {
+				t.Errorf("dropped %q, got %v", dropped, diffs)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package brc
+
+import "strconv"
+
+// Values are printed the way the Java baseline prints them: min and max as read, the mean as Math.round of
+// sum/count, which rounds halves toward positive infinity, and never as -0.0. %.1f would round halves of the
+// binary value to even and print -0.0 for a mean just below zero.
+
+// meanTenths returns sum/count in tenths rounded half toward positive infinity, using integers only so a
+// mean like 0.25 can't come out of a float as 0.24999.
+func meanTenths(sum, count int) int {
+	return roundDiv(sum, count)
+}
+
+// roundDiv returns n/d, d positive, rounded half toward positive infinity.
+func roundDiv(n, d int) int {
+	n, d = 2*n+d, 2*d
+
+	//floor(n/d), Go's division truncates toward zero
+	q := n / d
+	if n%d != 0 && n < 0 {
+		q--
+	}
+	return q
+}
+
+// appendTenths appends a value held in tenths with exactly one fractional digit, zero always as 0.0.
+func appendTenths(b []byte, tenths int) []byte {
+	return appendDecimals(b, tenths, 1)
+}
+
+// appendDecimals appends v, held in units of its last decimal, with exactly decimals fractional digits and
+// never as -0.
+func appendDecimals(b []byte, v, decimals int) []byte {
+	if v < 0 {
+		b = append(b, '-')
+		v = -v
+	}
+
+	unit := pow10(decimals)
+	b = strconv.AppendInt(b, int64(v/unit), 10)
+	if decimals == 0 {
+		return b
+	}
+
+	b = append(b, '.')
+	for unit /= 10; unit > 0; unit /= 10 {
+		b = append(b, byte('0'+v/unit%10))
+	}
+	return b
+}
+
+func formatTenths(tenths int) string {
+	return string(appendTenths(nil, tenths))
+}
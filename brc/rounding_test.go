@@ -0,0 +1,64 @@
+package brc
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// roundingCases are aggregates in tenths and the Java baseline's output for them.
+var roundingCases = []struct {
+	min, max, sum, count int
+	want                 string
+}{
+	{0, 0, 0, 1, "0.0/0.0/0.0"},
+	{-1, 0, -1, 2, "-0.1/0.0/0.0"},
+	{-1, 0, -1, 3, "-0.1/0.0/0.0"},
+	{-2, -1, -3, 2, "-0.2/-0.1/-0.1"},
+	{1, 2, 3, 2, "0.1/0.2/0.2"},
+	{-2, -1, -5, 3, "-0.2/-0.2/-0.1"},
+	{124, 126, 250, 2, "12.4/12.5/12.6"},
+	{-126, -124, -251, 2, "-12.6/-12.5/-12.4"},
+	{-999, 999, 0, 2, "-99.9/0.0/99.9"},
+	{-999, -999, -2997, 3, "-99.9/-99.9/-99.9"},
+	{5, 10, 45, 6, "0.5/0.8/1.0"},
+}
+
+// TestRounding formats roundingCases with Print and -agg-fns, then runs inputs whose means land on halves
+// and negative zero through every strategy.
+func TestRounding(t *testing.T) {
+	defer func(fns []func(r *StationResult) string) { activeAggFns = fns }(activeAggFns)
+
+	for _, c := range roundingCases {
+		tally := NewTally()
+		tally.add("A", StationResult{c.min, c.max, c.sum, c.count, -1, math.NaN(), nil, nil, "", -1})
+
+		want := "{A=" + c.want + "}\n"
+		for _, fns := range []string{AGG_FNS_DEFAULT, "min,mean,max,sum"} {
+			activeAggFns, _, _ = parseAggFns(fns)
+			if fns == AGG_FNS_DEFAULT {
+				activeAggFns = nil
+			} else {
+				want = "{A=" + c.want + "/" + formatTenths(c.sum) + "}\n"
+			}
+
+			buf := &bytes.Buffer{}
+			tally.Print(buf)
+			if buf.String() != want {
+				t.Errorf("%d/%d/%d/%d with -agg-fns=%s: got %q, want %q", c.min, c.max, c.sum, c.count, fns, buf.String(), want)
+			}
+		}
+	}
+	activeAggFns = nil
+
+	inputs := map[string]string{
+		"Zero;-0.0\n":                               "{Zero=0.0/0.0/0.0}\n",
+		"Tiny;-0.1\nTiny;0.0\n":                     "{Tiny=-0.1/0.0/0.0}\n",
+		"Neg;-0.1\nNeg;-0.2\n":                      "{Neg=-0.2/-0.1/-0.1}\n",
+		"Pos;0.1\nPos;0.2\n":                        "{Pos=0.1/0.2/0.2}\n",
+		"Half;0.1\nHalf;0.2\nHalf;-0.1\nHalf;0.0\n": "{Half=-0.1/0.1/0.2}\n",
+	}
+	for input, want := range inputs {
+		t.Run(input, func(t *testing.T) { expectEveryStrategy(t, input, want) })
+	}
+}
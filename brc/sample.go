@@ -0,0 +1,116 @@
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+var sampleFlag = commandLine.Float64("sample", 0, "estimate from about this `fraction` of each input, 0.01 parsing 1% of it in whole chunks spread evenly over the file, counts scaled up to match and the results marked as sampled on stderr (0 reads everything)")
+
+// sampling counts the bytes parsed and the bytes of the inputs they were sampled from.
+var sampling struct {
+	read, of atomic.Int64
+}
+
+// sampledFraction is the fraction of the inputs a -sample run actually parsed, 0 for a full run.
+func sampledFraction() float64 {
+	if sampling.of.Load() == 0 {
+		return 0
+	}
+	return float64(sampling.read.Load()) / float64(sampling.of.Load())
+}
+
+// estimate scales a count or sum of the sampled lines up to the whole input.
+func estimate(n int) int {
+	if f := sampledFraction(); f > 0 {
+		return int(math.Round(float64(n) / f))
+	}
+	return n
+}
+
+// sampledBlock reports whether block i of blocks is sampled, one every 1/fraction blocks starting half a stride
+// in, so the sample spans the whole file. A file too small for a single stride still gets its middle block.
+func sampledBlock(i, blocks int64, fraction float64) bool {
+	if float64(blocks)*fraction < 0.5 {
+		return i == blocks/2
+	}
+	return math.Floor(float64(i+1)*fraction+0.5) > math.Floor(float64(i)*fraction+0.5)
+}
+
+// processSampled parses the chunk sized blocks of filePtr that sampledBlock picks, each narrowed to the lines
+// starting in it, on the worker pool.
+func processSampled(filePtr *os.File, size int64, tally *Tally, o *Options) error {
+	block := int64(o.chunkSize)
+	blocks := (size + block - 1) / block
+
+	var ranges [][2]int64
+	read := int64(0)
+	for i := int64(0); i < blocks; i++ {
+		if !sampledBlock(i, blocks, o.sample) {
+			continue
+		}
+
+		start, end, err := alignRange(filePtr, i*block, (i+1)*block, size)
+		if err != nil {
+			return err
+		}
+		if end > start {
+			ranges = append(ranges, [2]int64{start, end})
+			read += end - start
+		}
+	}
+	sampling.read.Add(read)
+	sampling.of.Add(size)
+
+	next := make(chan [2]int64)
+	go func() {
+		for _, r := range ranges {
+			next <- r
+		}
+		close(next)
+	}()
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, o.workers)
+	for w := range o.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := tally.Local()
+			for r := range next {
+				errs[w] = errors.Join(errs[w], readSegment(filePtr, r[0], r[1], w, local, o))
+			}
+			tally.Merge(local)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// countWhole counts a file read whole during a -sample run, Parquet, converted or not a regular file, so the
+// fraction sampled is of every input.
+func countWhole(size int64, o *Options) {
+	if o.sample != 0 {
+		sampling.read.Add(size)
+		sampling.of.Add(size)
+	}
+}
+
+// writeSampleNote marks the results of a -sample run as estimates on w.
+func writeSampleNote(w io.Writer) {
+	if f := sampledFraction(); f > 0 && f < 1 {
+		fmt.Fprintf(w, "sampled %.2f%% of the input: counts and sums are estimates, means are of the sampled lines and min and max may miss rarer extremes\n", 100*f)
+	}
+}
+
+// resetSampling starts counting a new run.
+func resetSampling() {
+	sampling.read.Store(0)
+	sampling.of.Store(0)
+}
@@ -0,0 +1,69 @@
+package brc
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestSample checks sampledBlock picks about the fraction asked for and never nothing, -sample=1 gives
+// exactly the full results, and a smaller sample only finds stations the full run has, within its extremes.
+func TestSample(t *testing.T) {
+	defer resetSampling()
+
+	rng := rand.New(rand.NewSource(1))
+
+	for range 200 {
+		blocks := 1 + rng.Int63n(5000)
+		fraction := rng.Float64()
+		picked := int64(0)
+		for i := range blocks {
+			if sampledBlock(i, blocks, fraction) {
+				picked++
+			}
+		}
+		if want := math.Round(float64(blocks) * fraction); picked == 0 || math.Abs(float64(picked)-want) > 1 {
+			t.Fatalf("%.3f of %d blocks picked %d", fraction, blocks, picked)
+		}
+	}
+
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 2, 4*BUFFER_SIZE+rng.Intn(4*BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	whole, err := Process(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bytes.Buffer{}
+	whole.Print(want)
+
+	resetSampling()
+	got, err := runPipeline(StreamingStrategy{}, files, WithSample(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want.String() || sampledFraction() != 1 {
+		t.Fatalf("-sample=1 read %.3f and gave %q, the whole input %q", sampledFraction(), got, want)
+	}
+
+	resetSampling()
+	sampled, err := Process(files, WithChunkSize(BUFFER_SIZE/4), WithSample(0.3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := sampledFraction(); f <= 0 || f >= 1 {
+		t.Fatalf("-sample=0.3 parsed %.3f of the input", f)
+	}
+	for _, name := range sampled.names {
+		s, _ := sampled.Lookup(name)
+		w, ok := whole.Lookup(name)
+		if !ok || s.min < w.min || s.max > w.max || s.count > w.count {
+			t.Fatalf("-sample=0.3 found %q, which the whole input doesn't have or has within %+v", name, s)
+		}
+	}
+}
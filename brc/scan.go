@@ -0,0 +1,78 @@
+package brc
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// The hot scan of every line is for its last delimiter, the one before the temperature. Newlines are found by
+// bytes.IndexByte, which the runtime already dispatches to AVX2 or NEON, so only the delimiter has kernels here.
+
+// vectorKernel is the CPU's SIMD delimiter scan, picked at startup, and vectorMin the shortest line it takes.
+// Shorter lines, and every line on CPUs without one, get lastDelimiterSWAR.
+var (
+	vectorKernel func(b []byte, sep byte) int
+	vectorMin    = math.MaxInt
+)
+
+// delimiterKernels are the scans this CPU can run by name, each taking lines of any length, for the tests
+// and bench to check and time against each other.
+var delimiterKernels = map[string]func(b []byte, sep byte) int{"swar": lastDelimiterSWAR}
+
+// useVectorKernel registers kernel as name and has lastDelimiter scan with it what is left of lines of at least
+// min+8 bytes. The entry in delimiterKernels is kernel alone, on every line of min bytes or more.
+func useVectorKernel(name string, kernel func(b []byte, sep byte) int, min int) {
+	vectorKernel, vectorMin = kernel, min
+	delimiterKernels[name] = func(b []byte, sep byte) int {
+		if len(b) >= min {
+			return kernel(b, sep)
+		}
+		return lastDelimiterSWAR(b, sep)
+	}
+}
+
+// lastDelimiter is the index of the last sep in b, or -1.
+// Optimisation: the temperature after the delimiter is at most 5 bytes, so on good lines the last word has it
+// and the vector kernel, slower to start than one word of SWAR, only gets the rest of lines where it doesn't.
+func lastDelimiter(b []byte, sep byte) int {
+	if len(b) < 8 {
+		return lastDelimiterSWAR(b, sep)
+	}
+
+	pattern := uint64(sep) * 0x0101010101010101
+	if found := matchBytes(binary.LittleEndian.Uint64(b[len(b)-8:]), pattern); found != 0 {
+		return len(b) - 8 + (63-bits.LeadingZeros64(found))/8
+	}
+
+	if rest := b[:len(b)-8]; len(rest) >= vectorMin {
+		return vectorKernel(rest, sep)
+	}
+	return lastDelimiterSWAR(b[:len(b)-8], sep)
+}
+
+// lastDelimiterSWAR scans b back to front 8 bytes at a time.
+func lastDelimiterSWAR(b []byte, sep byte) int {
+	pattern := uint64(sep) * 0x0101010101010101
+	i := len(b)
+
+	for ; i >= 8; i -= 8 {
+		if found := matchBytes(binary.LittleEndian.Uint64(b[i-8:]), pattern); found != 0 {
+			return i - 8 + (63-bits.LeadingZeros64(found))/8
+		}
+	}
+
+	for i--; i >= 0; i-- {
+		if b[i] == sep {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchBytes sets the high bit of each byte of word equal to that byte of pattern and no other. The usual
+// (x - 0x01..) & ^x has false positives above a match, which for the last one is the side that counts.
+func matchBytes(word, pattern uint64) uint64 {
+	x := word ^ pattern
+	return ^((x&0x7F7F7F7F7F7F7F7F + 0x7F7F7F7F7F7F7F7F) | x | 0x7F7F7F7F7F7F7F7F)
+}
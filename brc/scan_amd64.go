@@ -0,0 +1,38 @@
+package brc
+
+// AVX2_MIN is the shortest line lastDelimiterAVX2 takes, one vector, so a short tail can be loaded overlapping it.
+const AVX2_MIN = 32
+
+func init() {
+	if hasAVX2() {
+		useVectorKernel("avx2", lastDelimiterAVX2, AVX2_MIN)
+	}
+}
+
+// hasAVX2 reports the CPU having AVX2 and the OS saving the upper halves of the YMM registers.
+func hasAVX2() bool {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+
+	_, _, ecx, _ := cpuid(1, 0)
+	if ecx&(1<<27) == 0 || ecx&(1<<28) == 0 {
+		return false //No OSXSAVE or no AVX
+	}
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false //XMM and YMM state not enabled
+	}
+
+	_, ebx, _, _ := cpuid(7, 0)
+	return ebx&(1<<5) != 0
+}
+
+func cpuid(leaf, sub uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// lastDelimiterAVX2 is lastDelimiter 32 bytes at a time, back to front. len(b) must be at least AVX2_MIN.
+//
+//go:noescape
+func lastDelimiterAVX2(b []byte, sep byte) int
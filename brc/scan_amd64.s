@@ -0,0 +1,69 @@
+#include "textflag.h"
+
+// func cpuid(leaf, sub uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL leaf+0(FP), AX
+	MOVL sub+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// func lastDelimiterAVX2(b []byte, sep byte) int
+TEXT ·lastDelimiterAVX2(SB), NOSPLIT, $0-40
+	MOVQ b_base+0(FP), SI
+	MOVQ b_len+8(FP), CX
+	MOVBLZX sep+24(FP), AX
+	MOVD AX, X0
+	VPBROADCASTB X0, Y0
+
+	// CX is the length still to scan, the vector ending at it is compared each round
+loop:
+	CMPQ CX, $32
+	JB tail
+	VMOVDQU -32(SI)(CX*1), Y1
+	VPCMPEQB Y0, Y1, Y1
+	VPMOVMSKB Y1, DX
+	TESTL DX, DX
+	JNZ found
+	SUBQ $32, CX
+	JMP loop
+
+found:
+	BSRL DX, DX
+	LEAQ -32(CX)(DX*1), AX
+	VZEROUPPER
+	MOVQ AX, ret+32(FP)
+	RET
+
+	// Fewer than 32 bytes are left at the front, the first vector covers them and bits from CX up are masked off
+tail:
+	TESTQ CX, CX
+	JZ none
+	VMOVDQU (SI), Y1
+	VPCMPEQB Y0, Y1, Y1
+	VPMOVMSKB Y1, DX
+	MOVL $1, BX
+	SHLL CX, BX
+	DECL BX
+	ANDL BX, DX
+	JZ none
+	BSRL DX, AX
+	VZEROUPPER
+	MOVQ AX, ret+32(FP)
+	RET
+
+none:
+	VZEROUPPER
+	MOVQ $-1, ret+32(FP)
+	RET
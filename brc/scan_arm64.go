@@ -0,0 +1,14 @@
+package brc
+
+// NEON_MIN is the shortest line lastDelimiterNEON takes, one vector, so a short tail can be loaded overlapping it.
+const NEON_MIN = 16
+
+// Advanced SIMD is part of ARMv8-A, which every arm64 CPU Go runs on implements, so there is nothing to detect.
+func init() {
+	useVectorKernel("neon", lastDelimiterNEON, NEON_MIN)
+}
+
+// lastDelimiterNEON is lastDelimiter 16 bytes at a time, back to front. len(b) must be at least NEON_MIN.
+//
+//go:noescape
+func lastDelimiterNEON(b []byte, sep byte) int
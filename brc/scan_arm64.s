@@ -0,0 +1,74 @@
+#include "textflag.h"
+
+// func lastDelimiterNEON(b []byte, sep byte) int
+TEXT ·lastDelimiterNEON(SB), NOSPLIT, $0-40
+	MOVD b_base+0(FP), R0
+	MOVD b_len+8(FP), R1
+	MOVBU sep+24(FP), R2
+	VDUP R2, V0.B16
+
+	// R1 is the length still to scan, the vector ending at it is compared each round. A match is a 0xFF byte
+	// in one of the two halves, the last one found from the leading zeros of the half.
+loop:
+	CMP $16, R1
+	BLT tail
+	SUB $16, R1, R3
+	ADD R0, R3, R4
+	VLD1 (R4), [V1.B16]
+	VCMEQ V0.B16, V1.B16, V1.B16
+	VMOV V1.D[1], R5
+	VMOV V1.D[0], R6
+	CBNZ R5, high
+	CBNZ R6, low
+	MOVD R3, R1
+	B loop
+
+high:
+	ADD $8, R3, R3
+	MOVD R5, R6
+
+	// R3 plus the byte of the highest set bit in R6
+low:
+	CLZ R6, R7
+	MOVD $63, R8
+	SUB R7, R8, R8
+	LSR $3, R8, R8
+	ADD R8, R3, R0
+	MOVD R0, ret+32(FP)
+	RET
+
+	// Fewer than 16 bytes are left at the front, the first vector covers them and bytes from R1 up are masked off
+tail:
+	CBZ R1, none
+	VLD1 (R0), [V1.B16]
+	VCMEQ V0.B16, V1.B16, V1.B16
+	VMOV V1.D[1], R5
+	VMOV V1.D[0], R6
+	MOVD $0, R3
+	CMP $8, R1
+	BGE tailhigh
+
+	// Only bytes below R1 of the low half count, the shift of 8*R1 is under 64
+	LSL $3, R1, R9
+	MOVD $1, R10
+	LSL R9, R10, R10
+	SUB $1, R10, R10
+	AND R10, R6, R6
+	CBNZ R6, low
+	B none
+
+	// The low half counts whole, bytes of the high half below R1-8
+tailhigh:
+	SUB $8, R1, R9
+	LSL $3, R9, R9
+	MOVD $1, R10
+	LSL R9, R10, R10
+	SUB $1, R10, R10
+	AND R10, R5, R5
+	CBNZ R5, high
+	CBNZ R6, low
+
+none:
+	MOVD $-1, R0
+	MOVD R0, ret+32(FP)
+	RET
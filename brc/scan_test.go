@@ -0,0 +1,64 @@
+package brc
+
+import (
+	"bytes"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// allDelimiterKernels is every delimiter scan the CPU has, and lastDelimiter picking between them.
+func allDelimiterKernels() map[string]func(b []byte, sep byte) int {
+	kernels := maps.Clone(delimiterKernels)
+	kernels["lastDelimiter"] = lastDelimiter
+	return kernels
+}
+
+// checkKernels fails t unless every kernel finds sep in b where bytes.LastIndexByte does. b is copied to start
+// bytes into a buffer padded with sep, so a kernel reading outside its slice would see bytes other than its own.
+func checkKernels(t *testing.T, b []byte, sep byte, start int) {
+	t.Helper()
+
+	buf := bytes.Repeat([]byte{sep}, start+len(b)+32)
+	line := buf[start : start+copy(buf[start:], b)]
+	want := bytes.LastIndexByte(b, sep)
+
+	kernels := allDelimiterKernels()
+	for _, name := range slices.Sorted(maps.Keys(kernels)) {
+		if got := kernels[name](line, sep); got != want {
+			t.Fatalf("%s found %q in %q at %d, want %d", name, sep, b, got, want)
+		}
+	}
+}
+
+// TestDelimiterKernels checks every kernel against bytes.LastIndexByte on lengths around each kernel's vector and
+// tail boundaries with the delimiter anywhere, several times or not at all.
+func TestDelimiterKernels(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for range 2000 {
+		b := make([]byte, rng.Intn(200))
+		for i := range b {
+			b[i] = "ab;\x00\xff\x80"[rng.Intn(6)]
+		}
+		sep := byte(';')
+		if rng.Intn(4) == 0 {
+			sep = byte(rng.Intn(256))
+		}
+
+		checkKernels(t, b, sep, rng.Intn(33))
+	}
+}
+
+// FuzzDelimiterKernels compares every kernel with bytes.LastIndexByte on any line, delimiter and alignment.
+func FuzzDelimiterKernels(f *testing.F) {
+	f.Add([]byte("Hamburg;12.0"), byte(';'), uint8(0))
+	f.Add([]byte("a;b;c;d;e;f;g;h;i;j;k;l;m;n;o;p;q;r;s;t;u;v;w;x;y;z;1.0"), byte(';'), uint8(7))
+	f.Add([]byte("St. John's,15.2"), byte(','), uint8(31))
+	f.Add([]byte("\x80\xff\x00no delimiter at all in this line, past one vector"), byte(';'), uint8(1))
+
+	f.Fuzz(func(t *testing.T, b []byte, sep byte, start uint8) {
+		checkKernels(t, b, sep, int(start%64))
+	})
+}
@@ -0,0 +1,193 @@
+package brc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Chunk is a run of whole lines and the offset in its file where it starts.
+type Chunk struct {
+	data   []byte
+	offset int64
+}
+
+// chunkDeque holds the pending chunks of one worker.
+// The owner pops from the bottom, idle workers steal from the top.
+type chunkDeque struct {
+	chunks []Chunk
+	m      sync.Mutex
+}
+
+func (d *chunkDeque) push(chunk Chunk) {
+	d.m.Lock()
+	d.chunks = append(d.chunks, chunk)
+	d.m.Unlock()
+}
+
+func (d *chunkDeque) pop() (Chunk, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	n := len(d.chunks)
+	if n == 0 {
+		return Chunk{}, false
+	}
+
+	chunk := d.chunks[n-1]
+	d.chunks[n-1] = Chunk{}
+	d.chunks = d.chunks[:n-1]
+	return chunk, true
+}
+
+func (d *chunkDeque) steal() (Chunk, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if len(d.chunks) == 0 {
+		return Chunk{}, false
+	}
+
+	chunk := d.chunks[0]
+	d.chunks[0] = Chunk{}
+	d.chunks = d.chunks[1:]
+	return chunk, true
+}
+
+// Scheduler hands chunks to a fixed pool of workers.
+// Optimisation: chunks are dealt round robin into per-worker deques and a worker that runs dry
+// steals from the others, so a slow chunk (dense station mix) can't leave the rest of the pool idle at the tail.
+type Scheduler struct {
+	deques  []*chunkDeque
+	next    int
+	pending int
+	closed  bool
+	m       sync.Mutex
+	cond    *sync.Cond
+
+	//depth caps the chunks queued at once, 0 leaves it unbounded. room wakes a Push waiting on it.
+	depth int
+	room  *sync.Cond
+}
+
+// NewScheduler returns a scheduler for workers, where Push blocks while depth chunks are queued (0 never blocks).
+func NewScheduler(workers, depth int) *Scheduler {
+	s := &Scheduler{
+		deques: make([]*chunkDeque, workers),
+		depth:  depth,
+	}
+	s.cond = sync.NewCond(&s.m)
+	s.room = sync.NewCond(&s.m)
+
+	for i := range s.deques {
+		s.deques[i] = &chunkDeque{}
+	}
+
+	return s
+}
+
+// Push queues a chunk on the next worker's deque. Only the reader goroutine calls Push.
+func (s *Scheduler) Push(chunk Chunk) {
+	if s.depth > 0 {
+		s.m.Lock()
+		for s.pending >= s.depth {
+			s.room.Wait()
+		}
+		s.m.Unlock()
+	}
+
+	s.deques[s.next].push(chunk)
+	s.next = (s.next + 1) % len(s.deques)
+
+	s.m.Lock()
+	s.pending++
+	s.m.Unlock()
+	chunksInFlight.Add(1)
+	s.cond.Signal()
+}
+
+// Close wakes all workers once the reader is done. Workers drain what is left before returning.
+func (s *Scheduler) Close() {
+	s.m.Lock()
+	s.closed = true
+	s.m.Unlock()
+	s.cond.Broadcast()
+}
+
+// Next returns the next chunk for worker id, blocking until one is available.
+// Returns false once the scheduler is closed and every deque is empty.
+func (s *Scheduler) Next(id int) (Chunk, bool) {
+	for {
+		chunk, ok := s.deques[id].pop()
+
+		for i := 1; !ok && i < len(s.deques); i++ {
+			chunk, ok = s.deques[(id+i)%len(s.deques)].steal()
+		}
+
+		s.m.Lock()
+		if ok {
+			s.pending--
+			s.m.Unlock()
+			if s.depth > 0 {
+				s.room.Signal()
+			}
+			return chunk, true
+		}
+
+		//pending can briefly dip below zero when a chunk is taken before Push has counted it
+		for s.pending <= 0 && !s.closed {
+			s.cond.Wait()
+		}
+
+		if s.pending <= 0 && s.closed {
+			s.m.Unlock()
+			return Chunk{}, false
+		}
+		s.m.Unlock()
+	}
+}
+
+// Depth describes how many chunks are queued on each deque, for the watchdog.
+func (s *Scheduler) Depth() string {
+	depths := make([]int, len(s.deques))
+	for i, d := range s.deques {
+		d.m.Lock()
+		depths[i] = len(d.chunks)
+		d.m.Unlock()
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	return fmt.Sprintf("%d of %d pending, deques %v, closed %t", s.pending, s.depth, depths, s.closed)
+}
+
+// Run starts workers goroutines calling fn with the worker's index for every chunk received on in, returns once all are parsed.
+func (s *Scheduler) Run(in <-chan Chunk, fn func(worker int, chunk Chunk)) {
+	wg := &sync.WaitGroup{}
+
+	for id := range s.deques {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for {
+				chunk, ok := s.Next(id)
+				if !ok {
+					return
+				}
+				start := timingStart()
+				fn(id, chunk)
+				chunksInFlight.Add(-1)
+				if !start.IsZero() {
+					recordParse(id, time.Since(start), 1, int64(len(chunk.data)))
+				}
+			}
+		}(id)
+	}
+
+	for chunk := range in {
+		s.Push(chunk)
+	}
+	s.Close()
+
+	wg.Wait()
+}
@@ -0,0 +1,74 @@
+package brc
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSchedulerDepth makes sure the queue never holds more than depth chunks, however slow the workers.
+func TestSchedulerDepth(t *testing.T) {
+	const depth = 2
+	in := make(chan Chunk)
+	go func() {
+		for i := 0; i < 100; i++ {
+			in <- Chunk{nil, int64(i)}
+		}
+		close(in)
+	}()
+
+	scheduler := NewScheduler(3, depth)
+	deepest := 0
+	scheduler.Run(in, func(worker int, chunk Chunk) {
+		scheduler.m.Lock()
+		deepest = max(deepest, scheduler.pending)
+		scheduler.m.Unlock()
+		time.Sleep(time.Microsecond)
+	})
+	if deepest > depth {
+		t.Fatalf("a scheduler %d deep queued %d chunks", depth, deepest)
+	}
+}
+
+// TestQueueDepth runs every strategy with a reader held to a single chunk ahead, which must only slow it down.
+func TestQueueDepth(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 2, BUFFER_SIZE+rng.Intn(BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := newOptions(WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.depth != QUEUE_DEPTH_PER_WORKER*3 {
+		t.Fatalf("3 workers got a queue %d deep, want %d", o.depth, QUEUE_DEPTH_PER_WORKER*3)
+	}
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		want, err := runPipeline(strategies[strategyName], files)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tally, err := Process(files, WithStrategy(strategies[strategyName]), WithWorkers(1+rng.Intn(4)), WithChunkSize(MIN_CHUNK_SIZE), WithQueueDepth(1))
+		if err != nil {
+			t.Fatalf("-strategy=%s: %v", strategyName, err)
+		}
+
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		if buf.String() != want {
+			t.Fatalf("-strategy=%s with -queue-depth=1: got %q, want %q", strategyName, buf.String(), want)
+		}
+	}
+}
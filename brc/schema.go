@@ -0,0 +1,103 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var schemaFlag = commandLine.String("schema", "", "comma separated `columns` of every line, e.g. station,temp,humidity: station names the station, _ skips a column and every other name is a metric aggregated on its own (empty means station;temperature)")
+
+// SCHEMA_SKIP is the column name of a field the schema ignores.
+const SCHEMA_SKIP = "_"
+
+// lineSchema is -schema resolved. Lines are split on every -delimiter, so station names can't contain one,
+// and fields past the last column are ignored.
+type lineSchema struct {
+	//metric index of every column, -1 for the station and skipped columns
+	columns []int
+	station int
+	metrics []string
+}
+
+// activeSchema is -schema resolved in main, nil for station;temperature lines.
+var activeSchema *lineSchema
+
+func parseSchema(s string) (*lineSchema, error) {
+	schema := &lineSchema{station: -1}
+	seen := map[string]bool{}
+
+	for i, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("-schema: column %d has no name", i+1)
+		case name == SCHEMA_SKIP:
+			schema.columns = append(schema.columns, -1)
+			continue
+		case seen[name]:
+			return nil, fmt.Errorf("-schema: %s appears twice", name)
+		case name == "station":
+			schema.station = i
+			schema.columns = append(schema.columns, -1)
+		default:
+			schema.columns = append(schema.columns, len(schema.metrics))
+			schema.metrics = append(schema.metrics, name)
+		}
+		seen[name] = true
+	}
+
+	if schema.station == -1 || len(schema.metrics) == 0 {
+		return nil, errors.New("-schema needs a station column and at least one metric")
+	}
+
+	return schema, nil
+}
+
+// cut splits line into its station and metrics, filling values in tenths. ok is false when a column is
+// missing or a metric is malformed, the whole line is skipped then so every metric sees the same stations.
+func (s *lineSchema) cut(line []byte, sep byte, values []int) (station []byte, ok bool) {
+	for i, metric := range s.columns {
+		if line == nil {
+			return nil, false
+		}
+
+		field, rest, found := bytes.Cut(line, []byte{sep})
+		line = rest
+		if !found {
+			line = nil
+		}
+
+		if i == s.station {
+			station = field
+		} else if metric >= 0 {
+			if values[metric], ok = parseTempAny(field); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return station, true
+}
+
+// add adds the metrics of one line to t, the first metric to t itself and the others to its metric tallies.
+func (s *lineSchema) add(t *Tally, station []byte, values []int, offset int64) {
+	for j, v := range values {
+		t.metric(j).Station(station, offset).AddAt(v, offset)
+	}
+}
+
+// metric returns the tally of the j-th -schema metric, creating it on first use. The first is t itself,
+// so everything reading just one metric (other output formats, -top, partials) reads that one.
+func (t *Tally) metric(j int) *Tally {
+	if j == 0 {
+		return t
+	}
+
+	for len(t.metrics) < j {
+		t.metrics = append(t.metrics, t.Local())
+	}
+	return t.metrics[j-1]
+}
@@ -0,0 +1,39 @@
+package brc
+
+import (
+	"fmt"
+	"testing"
+)
+
+var schemaCases = []struct {
+	schema, input, want string
+}{
+	{"station,temp,humidity", "A;1.0;50\nB;2.0;60.5\nA;3.0;70\n", "{A=temp:1.0/2.0/3.0 humidity:50.0/60.0/70.0, B=temp:2.0/2.0/2.0 humidity:60.5/60.5/60.5}\n"},
+	{"station,_,humidity", "A;1.0;50\nA;x;70\n", "{A=50.0/60.0/70.0}\n"},
+	{"temp,station", "1.5;A\n-2.5;A\n", "{A=-2.5/-0.5/1.5}\n"},
+	//A short line or a malformed metric drops the whole line
+	{"station,temp,humidity", "A;1.0;50\nA;2.0\nA;x;1\nB;1.0;\nA;3.0;70;extra\n", "{A=temp:1.0/2.0/3.0 humidity:50.0/60.0/70.0}\n"},
+}
+
+// TestSchema runs schemaCases through every strategy, each file also repeated past a few BUFFER_SIZEs.
+func TestSchema(t *testing.T) {
+	defer func(schema *lineSchema) { activeSchema = schema }(activeSchema)
+
+	for i, c := range schemaCases {
+		t.Run(fmt.Sprintf("%d -schema=%s", i, c.schema), func(t *testing.T) {
+			var err error
+			if activeSchema, err = parseSchema(c.schema); err != nil {
+				t.Fatal(err)
+			}
+
+			expectEveryStrategy(t, c.input, c.want)
+			expectEveryStrategy(t, repeatPastChunks(c.input), c.want)
+		})
+	}
+
+	for _, bad := range []string{"temp,humidity", "station", "station,temp,temp", "station,,temp", "station,station,temp"} {
+		if _, err := parseSchema(bad); err == nil {
+			t.Errorf("-schema=%s accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package brc
+
+import (
+	"io"
+	"time"
+)
+
+var serial = commandLine.Bool("serial", false, "run the whole pipeline on one goroutine without channels, one file and one chunk after another: for debugging, baselining and -race runs without noise")
+
+// parseSerial returns what a scheduler worker does with a chunk, for a reader to call on its own goroutine.
+// The first error of any chunk is kept, later chunks are still parsed as a worker would, see failed.
+func parseSerial(name string, tally *Tally, o *Options) (parse func(chunk Chunk), failed func() error) {
+	var first error
+	return func(chunk Chunk) {
+		start := timingStart()
+		recordChunk(name, chunk, o)
+		o.counted(chunk)
+		if err := parseLines(chunk.data, chunk.offset, tally, o); err != nil && first == nil {
+			first = err
+		}
+		if !start.IsZero() {
+			recordParse(0, time.Since(start), 1, int64(len(chunk.data)))
+		}
+	}, func() error { return first }
+}
+
+// streamInto parses everything read from r into tally, through the parser pool or with -serial as it is read.
+func streamInto(r io.Reader, name string, tally *Tally, o *Options) error {
+	if tally.digest != nil {
+		r = io.TeeReader(r, tally.digest)
+	}
+
+	if !o.serial {
+		return <-parseCh(readInFile(r, o), name, tally, o)
+	}
+
+	parse, failed := parseSerial(name, tally, o)
+	readChunks(r, o, func(chunk Chunk) {
+		parse(chunk)
+		o.pool.Put(chunk.data)
+	})
+	return failed()
+}
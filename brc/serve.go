@@ -0,0 +1,446 @@
+package brc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SERVE_BATCH_SIZE is roughly how many bytes of whole lines a connection hands to the parser pool at a time.
+const SERVE_BATCH_SIZE = 64 * 1024
+
+// Duration is a time.Duration that reads and writes as "10s" in JSON config.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	*d = Duration(parsed)
+	return err
+}
+
+// ServeConfig is everything that can be changed while the server is running.
+type ServeConfig struct {
+	Workers  int      `json:"workers"`
+	Window   Duration `json:"window"`
+	Interval Duration `json:"interval"`
+}
+
+func (c ServeConfig) validate() error {
+	switch {
+	case c.Workers < 1:
+		return fmt.Errorf("workers must be at least 1, got %d", c.Workers)
+	case c.Window < 0:
+		return fmt.Errorf("window must not be negative, got %v", time.Duration(c.Window))
+	case c.Interval <= 0:
+		return fmt.Errorf("interval must be positive, got %v", time.Duration(c.Interval))
+	}
+	return nil
+}
+
+// ErrServerClosed is returned by Ingest once the Server has been closed.
+var ErrServerClosed = errors.New("server closed")
+
+// Server aggregates measurement lines streamed to it over TCP and periodically prints the running tally.
+// With a window the tally starts over every window, otherwise it accumulates for the life of the process.
+// Reconfiguring never drops the tally, only the worker pool and timers change.
+type Server struct {
+	config      ServeConfig
+	tally       *Tally
+	windowStart time.Time
+	workers     []serveWorker
+	m           sync.Mutex
+
+	//merging is held shared by a worker for as long as it merges into the tally, and exclusively to start
+	//a new window, so no merge lands in a tally that has already been printed
+	merging sync.RWMutex
+
+	//reconfiguring serialises Reconfigure and Close, which wait on workers outside m
+	reconfiguring sync.Mutex
+	closed        bool
+
+	batches chan []byte
+	changed chan struct{}
+	done    chan struct{}
+	running sync.WaitGroup
+	out     io.Writer
+
+	//parse takes the batches apart, always the defaults since serve has no flags for it
+	parse *Options
+}
+
+// serveWorker is one parser goroutine of a Server, stopped by closing stop and gone once exited is closed.
+type serveWorker struct {
+	stop, exited chan struct{}
+}
+
+func NewServer(config ServeConfig, out io.Writer) *Server {
+	//The defaults are always valid
+	parse, _ := newOptions()
+	s := &Server{
+		tally:       NewTally(),
+		windowStart: clock.Now(),
+		batches:     make(chan []byte, 64),
+		changed:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		out:         out,
+		parse:       parse,
+	}
+	s.Reconfigure(config)
+	return s
+}
+
+// Config returns the configuration currently in effect.
+func (s *Server) Config() ServeConfig {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.config
+}
+
+// Reconfigure applies config while running, growing or shrinking the worker pool to match. Workers taken
+// out of the pool finish merging the batch they have before the new config, a new window with it, applies.
+func (s *Server) Reconfigure(config ServeConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	s.reconfiguring.Lock()
+	defer s.reconfiguring.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+
+	s.m.Lock()
+	for len(s.workers) < config.Workers {
+		w := serveWorker{make(chan struct{}), make(chan struct{})}
+		s.workers = append(s.workers, w)
+		s.running.Add(1)
+		go s.work(w)
+	}
+	surplus := slices.Clone(s.workers[min(config.Workers, len(s.workers)):])
+	s.workers = s.workers[:min(config.Workers, len(s.workers))]
+	s.m.Unlock()
+
+	for _, w := range surplus {
+		close(w.stop)
+		<-w.exited
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.config = config
+
+	//Wake the emit loop so a new interval or window takes effect now rather than after the old interval
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (s *Server) current() *Tally {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.tally
+}
+
+// Close stops the workers and the emit loop and waits for them to return. Batches queued and not yet parsed
+// are dropped, Ingest returns ErrServerClosed from then on.
+func (s *Server) Close() error {
+	s.reconfiguring.Lock()
+	defer s.reconfiguring.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	s.m.Lock()
+	for _, w := range s.workers {
+		close(w.stop)
+	}
+	s.workers = nil
+	s.m.Unlock()
+
+	close(s.done)
+	s.running.Wait()
+	return nil
+}
+
+func (s *Server) work(w serveWorker) {
+	defer s.running.Done()
+	defer close(w.exited)
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case batch := <-s.batches:
+			local := NewTally()
+			if err := parseLines(batch, -1, local, s.parse); err != nil {
+				slog.Warn("dropped the rest of a batch", "err", err)
+			}
+
+			s.merging.RLock()
+			s.current().Merge(local)
+			s.merging.RUnlock()
+		}
+	}
+}
+
+// Emit starts printing the tally every interval in the background, until Close.
+func (s *Server) Emit() {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.emit()
+	}()
+}
+
+// emit prints the tally every interval and starts a new one when the window has passed.
+func (s *Server) emit() {
+	for {
+		config := s.Config()
+		fired, stop := clock.NewTimer(time.Duration(config.Interval))
+
+		select {
+		case <-s.done:
+			stop()
+			return
+		case <-s.changed:
+			stop()
+			continue
+		case <-fired:
+		}
+
+		//A new window waits for merges into the old one, which is printed as complete
+		s.merging.Lock()
+		s.m.Lock()
+		tally := s.tally
+		if s.config.Window > 0 && clock.Since(s.windowStart) >= time.Duration(s.config.Window) {
+			s.tally = NewTally()
+			s.windowStart = clock.Now()
+		}
+		s.m.Unlock()
+		s.merging.Unlock()
+
+		//Workers may still be merging batches into it
+		tally.m.Lock()
+		tally.Print(s.out)
+		tally.m.Unlock()
+	}
+}
+
+// Ingest reads newline separated measurements from r and queues them in batches of whole lines.
+func (s *Server) Ingest(r io.Reader) error {
+	br := bufio.NewReaderSize(r, SERVE_BATCH_SIZE)
+	batch := make([]byte, 0, SERVE_BATCH_SIZE)
+
+	for {
+		line, err := br.ReadSlice('\n')
+		batch = append(batch, line...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		if len(batch) >= SERVE_BATCH_SIZE-128 || (err != nil && len(batch) > 0) {
+			if !s.queue(batch) {
+				return ErrServerClosed
+			}
+			batch = make([]byte, 0, SERVE_BATCH_SIZE)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		//Don't sit on a partly filled batch while the sender is idle
+		if br.Buffered() == 0 && len(batch) > 0 {
+			if !s.queue(batch) {
+				return ErrServerClosed
+			}
+			batch = make([]byte, 0, SERVE_BATCH_SIZE)
+		}
+	}
+}
+
+// queue hands batch to the workers, or reports false when the server is closed.
+func (s *Server) queue(batch []byte) bool {
+	select {
+	case s.batches <- batch:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// ServeTCP accepts connections on listener, each streaming measurement lines.
+func (s *Server) ServeTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			if err := s.Ingest(conn); err != nil {
+				slog.Warn("connection failed", "from", conn.RemoteAddr().String(), "err", err)
+			}
+		}()
+	}
+}
+
+// AdminHandler serves the live configuration: GET returns it, PUT or POST a JSON object with any
+// subset of workers, window and interval to change just those. /dictionary exports the current window's stations,
+// /events pushes its running tally to a browser as Server-Sent Events, /debug/vars the live counters of expvar.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			config := s.Config()
+			if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := s.Reconfigure(config); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			slog.Info("reconfigured", "from", r.RemoteAddr, "config", fmt.Sprintf("%+v", config))
+		default:
+			http.Error(w, "use GET, PUT or POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Config())
+	})
+
+	mux.HandleFunc("/dictionary", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		s.current().WriteDictionary(w)
+	})
+
+	mux.HandleFunc("/events", s.serveEvents)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux
+}
+
+// loadServeConfig overlays the JSON config file at name onto config.
+func loadServeConfig(name string, config ServeConfig) (ServeConfig, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return config, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&config); err != nil {
+		return config, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return config, nil
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":7000", "`address` accepting newline separated measurements over TCP")
+	admin := fs.String("admin", "", "`address` for the admin HTTP endpoints (/config, /dictionary, /events, /debug/vars), empty disables them")
+	configFile := fs.String("config", "", "JSON config `file` with workers, window and interval, reloaded on SIGHUP")
+	fs.IntVar(maxStations, "max-stations", 0, "abort once more than `n` unique stations are seen (0 disables)")
+
+	config := ServeConfig{}
+	fs.IntVar(&config.Workers, "workers", *workers, "number of parser `goroutines`")
+	window := fs.Duration("window", 0, "start a fresh tally every `duration`, 0 accumulates forever")
+	interval := fs.Duration("interval", 10*time.Second, "print the tally every `duration`")
+	addLogFlags(fs)
+	fs.Parse(args)
+	exitOnBadLogging()
+
+	config.Window, config.Interval = Duration(*window), Duration(*interval)
+
+	if *configFile != "" {
+		var err error
+		if config, err = loadServeConfig(*configFile, config); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	server := NewServer(config, os.Stdout)
+	server.Emit()
+
+	if *configFile != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		go func() {
+			for range hup {
+				reloaded, err := loadServeConfig(*configFile, server.Config())
+				if err == nil {
+					err = server.Reconfigure(reloaded)
+				}
+
+				if err != nil {
+					slog.Warn("SIGHUP: keeping current config", "err", err)
+					continue
+				}
+				slog.Info("SIGHUP: reloaded", "file", *configFile, "config", fmt.Sprintf("%+v", reloaded))
+			}
+		}()
+	}
+
+	if *admin != "" {
+		go func() {
+			slog.Error("admin endpoints stopped", "err", http.ListenAndServe(*admin, server.AdminHandler()))
+		}()
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal("could not listen: ", err)
+	}
+
+	slog.Info("serving", "addr", listener.Addr().String())
+	log.Fatal(server.ServeTCP(listener))
+}
@@ -0,0 +1,53 @@
+package brc
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the bytes written to it from any goroutine.
+type countingWriter struct{ n atomic.Int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// TestServeEmitsWhileMerging prints serve's tally every interval while a stream of lines is being merged into
+// it by several workers, which must never race: run with -race, or the runtime's own check on maps catches a
+// print walking the tally as a merge adds to it.
+func TestServeEmitsWhileMerging(t *testing.T) {
+	fake := NewFakeClock(time.Unix(0, 0))
+	defer func(c Clock) { clock = c }(clock)
+	clock = fake
+
+	out := &countingWriter{}
+	server := NewServer(ServeConfig{Workers: 4, Interval: Duration(time.Second)}, out)
+	t.Cleanup(func() { server.Close() })
+	<-server.changed
+	server.Emit()
+
+	//Ingest only queues batches for the workers, so printing between slices of the input prints as they merge
+	prints := 0
+	for slice := range 50 {
+		var lines strings.Builder
+		for i := range 4000 {
+			fmt.Fprintf(&lines, "S%d;%d.5\n", (slice*4000+i)%5000, i%100)
+		}
+		if err := server.Ingest(strings.NewReader(lines.String())); err != nil {
+			t.Fatal(err)
+		}
+
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+		prints++
+	}
+	fake.BlockUntil(1)
+
+	if out.n.Load() == 0 {
+		t.Fatalf("%d intervals printed nothing", prints)
+	}
+}
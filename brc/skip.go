@@ -0,0 +1,33 @@
+package brc
+
+import (
+	"bufio"
+	"fmt"
+)
+
+var skipHeader = commandLine.Bool("skip-header", false, "ignore the first line of every input file, e.g. the column names of a CSV export")
+var commentCharFlag = commandLine.String("comment-char", "", "ignore lines starting with `byte`, e.g. #")
+
+// commentChar is -comment-char resolved once, 0 when comments are off.
+var commentChar byte
+
+func parseCommentChar(s string) (byte, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if len(s) != 1 || s[0] == '\n' || s[0] == '\r' {
+		return 0, fmt.Errorf("-comment-char must be a single byte, got %q", s)
+	}
+
+	return s[0], nil
+}
+
+// skipFileHeader consumes the header line when scanner is at the very start of a file with -skip-header set,
+// returning how many bytes it took up. Chunks starting anywhere else are left alone.
+func skipFileHeader(scanner *bufio.Scanner, split *lineSplitter, offset int64) int64 {
+	if !*skipHeader || offset != 0 || !scanner.Scan() {
+		return 0
+	}
+	return int64(split.advance)
+}
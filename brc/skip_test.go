@@ -0,0 +1,46 @@
+package brc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// skipCases are inputs with a header or comments and what -skip-header and -comment-char make of them.
+var skipCases = []struct {
+	header  bool
+	comment string
+	input   string
+	want    string
+}{
+	{true, "", "station;temperature\nA;1.0\nA;3.0\n", "{A=1.0/2.0/3.0}\n"},
+	{true, "", "station,temp\r\nA;1.0\r\n", "{A=1.0/1.0/1.0}\n"},
+	{false, "#", "# exported 2024-01-01\nA;1.0\n#A;9.0\nB;-1.0\n", "{A=1.0/1.0/1.0, B=-1.0/-1.0/-1.0}\n"},
+	{true, "#", "station;temperature\n#note;5.0\nA;2.0\n", "{A=2.0/2.0/2.0}\n"},
+	{false, "", "#A;1.0\n", "{#A=1.0/1.0/1.0}\n"},
+}
+
+// TestSkip runs skipCases through every strategy, each file also repeated past a few BUFFER_SIZEs with its
+// header once at the top so the header is only ever skipped in the chunk at offset 0.
+func TestSkip(t *testing.T) {
+	defer func(header bool, comment byte) { *skipHeader, commentChar = header, comment }(*skipHeader, commentChar)
+
+	for i, c := range skipCases {
+		t.Run(fmt.Sprintf("%d -skip-header=%t -comment-char=%q", i, c.header, c.comment), func(t *testing.T) {
+			*skipHeader = c.header
+			var err error
+			if commentChar, err = parseCommentChar(c.comment); err != nil {
+				t.Fatal(err)
+			}
+
+			header, body := "", c.input
+			if c.header {
+				header, body, _ = strings.Cut(c.input, "\n")
+				header += "\n"
+			}
+
+			expectEveryStrategy(t, c.input, c.want)
+			expectEveryStrategy(t, header+repeatPastChunks(body), c.want)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package brc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var offsetFlag = commandLine.String("offset", "", "start parsing every input this far in, a `size` such as 2GiB (from the first line starting there) or a line count such as 1000000lines")
+var limitFlag = commandLine.String("limit", "", "parse only this much of every input after -offset, a `size` such as 64MiB (the lines starting within it) or a line count such as 1000lines")
+
+// span is how far into a file -offset or -limit reaches, in bytes or in lines.
+type span struct {
+	n     int64
+	lines bool
+}
+
+// inputOffset and inputLimit are -offset and -limit resolved once, a zero limit meaning the rest of the file.
+var inputOffset, inputLimit span
+
+func parseSpan(name, value string) (span, error) {
+	if value == "" {
+		return span{}, nil
+	}
+
+	if number, ok := strings.CutSuffix(value, "lines"); ok {
+		n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+		if err != nil || n < 0 {
+			return span{}, fmt.Errorf("-%s %q is not a line count such as 1000lines", name, value)
+		}
+		return span{n, true}, nil
+	}
+
+	n, err := parseBytes(value)
+	if err != nil {
+		return span{}, fmt.Errorf("-%s %w", name, err)
+	}
+	return span{n: n}, nil
+}
+
+// sliced reports whether -offset or -limit restricts the inputs.
+func sliced() bool {
+	return *offsetFlag != "" || *limitFlag != ""
+}
+
+// sliceRange is the range of filePtr -offset and -limit select, from the start of a line to the end of one.
+func sliceRange(filePtr segmentFile, size int64, chunkSize int) (int64, int64, error) {
+	start, end := int64(0), size
+	var err error
+
+	if inputOffset.lines {
+		start, err = skipLines(filePtr, 0, inputOffset.n, size, chunkSize)
+	} else {
+		start, _, err = alignRange(filePtr, min(inputOffset.n, size), size, size)
+	}
+	if err != nil || inputLimit.n == 0 {
+		return start, end, err
+	}
+
+	if inputLimit.lines {
+		end, err = skipLines(filePtr, start, inputLimit.n, size, chunkSize)
+	} else {
+		_, end, err = alignRange(filePtr, start, start+min(inputLimit.n, size-start), size)
+	}
+	return start, end, err
+}
+
+// skipLines returns the offset just past n lines from start, which must be the start of a line, or size when
+// the file has fewer.
+func skipLines(filePtr segmentFile, start, n, size int64, chunkSize int) (int64, error) {
+	scanner := bufio.NewScanner(io.NewSectionReader(filePtr, start, size-start))
+	scanner.Buffer(make([]byte, chunkSize), chunkSize)
+	split := &lineSplitter{}
+	scanner.Split(split.split)
+
+	offset := start
+	for ; n > 0 && scanner.Scan(); n-- {
+		offset += int64(split.advance)
+	}
+	return offset, scanner.Err()
+}
+
+// processSlice parses the lines of filePtr -offset and -limit select with positional reads, whatever -strategy
+// says, split between the workers.
+func processSlice(filePtr segmentFile, size int64, tally *Tally, o *Options) error {
+	start, end, err := sliceRange(filePtr, size, o.chunkSize)
+	if err != nil {
+		return err
+	}
+
+	return processRange(filePtr, start, end, o.workers, tally, o)
+}
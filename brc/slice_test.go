@@ -0,0 +1,92 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+)
+
+// TestSlice checks -offset and -limit in bytes and in lines parse exactly the lines a file holding just
+// those would, \n or \r\n ended.
+func TestSlice(t *testing.T) {
+	defer func(offset, limit string, o, l span) {
+		*offsetFlag, *limitFlag, inputOffset, inputLimit = offset, limit, o, l
+	}(*offsetFlag, *limitFlag, inputOffset, inputLimit)
+
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	files, err := writeRandomFiles(rng, dir, 1, 2*BUFFER_SIZE+rng.Intn(2*BUFFER_SIZE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rng.Intn(2) == 0 {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+		if err := os.WriteFile(files[0], data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var starts []int
+	for at := 0; at < len(data); at += bytes.IndexByte(data[at:], '\n') + 1 {
+		starts = append(starts, at)
+	}
+	starts = append(starts, len(data))
+
+	for range 20 {
+		//The lines starting in the window, or the count of lines from the offset one
+		var offset, limit string
+		first, last := 0, len(starts)-1
+		if rng.Intn(2) == 0 {
+			at := 1 + rng.Intn(len(data))
+			offset = fmt.Sprint(at)
+			first, _ = slices.BinarySearch(starts, at)
+		} else {
+			first = rng.Intn(len(starts))
+			offset = fmt.Sprintf("%dlines", first)
+		}
+
+		switch rng.Intn(3) {
+		case 1:
+			n := 1 + rng.Intn(len(data))
+			limit = fmt.Sprint(n)
+			last, _ = slices.BinarySearch(starts, min(starts[first]+n, len(data)))
+		case 2:
+			n := 1 + rng.Intn(len(starts))
+			limit = fmt.Sprintf("%dlines", n)
+			last = min(first+n, len(starts)-1)
+		}
+
+		want := dir + "/want.txt"
+		if err := os.WriteFile(want, data[starts[first]:starts[last]], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		*offsetFlag, *limitFlag = "", ""
+		expected, err := runPipeline(NaiveStrategy{}, []string{want})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		*offsetFlag, *limitFlag = offset, limit
+		if inputOffset, err = parseSpan("offset", offset); err != nil {
+			t.Fatal(err)
+		}
+		if inputLimit, err = parseSpan("limit", limit); err != nil {
+			t.Fatal(err)
+		}
+		got, err := runPipeline(StreamingStrategy{}, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("-offset=%s -limit=%s: got %q, want %q", offset, limit, got, expected)
+		}
+	}
+}
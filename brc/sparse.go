@@ -0,0 +1,42 @@
+package brc
+
+import (
+	"bufio"
+)
+
+var minCount = commandLine.Int("min-count", 0, "stations with fewer than `n` measurements are left out of the output, or marked with -sparse=flag (0 keeps every station)")
+var sparseMode = commandLine.String("sparse", "omit", "what -min-count does with the stations below it: omit them, or flag them, with a * after the text output's aggregates and sparse in csv and json")
+
+var sparseModes = []string{"omit", "flag"}
+
+// sparse reports whether r has too few measurements for -min-count, the count a -sample run estimates.
+func sparse(r *StationResult) bool {
+	return estimate(r.count) < *minCount
+}
+
+// flagSparse reports whether stations below -min-count are printed marked rather than left out.
+func flagSparse() bool {
+	return *minCount > 0 && *sparseMode == "flag"
+}
+
+// printSparse marks station of t with a * when -min-count=flag finds it sparse.
+func printSparse(bw *bufio.Writer, t *Tally, station string) {
+	if r, _ := t.Lookup(station); flagSparse() && sparse(r) {
+		bw.WriteByte('*')
+	}
+}
+
+// withoutSparse returns names without the stations of t -min-count=omit leaves out, reusing its array.
+func (t *Tally) withoutSparse(names []string) []string {
+	if *minCount == 0 || flagSparse() {
+		return names
+	}
+
+	kept := names[:0]
+	for _, name := range names {
+		if r, _ := t.Lookup(name); !sparse(r) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,43 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var minCountCases = []struct {
+	minCount int
+	mode     string
+	want     string
+	csv      string
+}{
+	{0, "omit", "{A=1.0/1.5/2.0, B=3.0/3.0/3.0, C=1.0/2.0/4.0}\n", "station,min,mean,max,count\nA,1.0,1.5,2.0,2\nB,3.0,3.0,3.0,1\nC,1.0,2.0,4.0,3\n"},
+	{2, "omit", "{A=1.0/1.5/2.0, C=1.0/2.0/4.0}\n", "station,min,mean,max,count\nA,1.0,1.5,2.0,2\nC,1.0,2.0,4.0,3\n"},
+	{4, "omit", "{}\n", "station,min,mean,max,count\n"},
+	{3, "flag", "{A=1.0/1.5/2.0*, B=3.0/3.0/3.0*, C=1.0/2.0/4.0}\n", "station,min,mean,max,count,sparse\nA,1.0,1.5,2.0,2,true\nB,3.0,3.0,3.0,1,true\nC,1.0,2.0,4.0,3,false\n"},
+}
+
+// TestMinCount prints a tally as text and csv under every minCountCases -min-count and -sparse.
+func TestMinCount(t *testing.T) {
+	defer func(n int, mode string) { *minCount, *sparseMode = n, mode }(*minCount, *sparseMode)
+
+	tally := NewTally()
+	for _, line := range []string{"A;1.0", "A;2.0", "B;3.0", "C;1.0", "C;1.0", "C;4.0"} {
+		station, temp, _ := strings.Cut(line, ";")
+		tally.Get([]byte(station)).Add(parseTemp([]byte(temp)))
+	}
+
+	for _, c := range minCountCases {
+		*minCount, *sparseMode = c.minCount, c.mode
+
+		text, csv := &bytes.Buffer{}, &bytes.Buffer{}
+		tally.Print(text)
+		if err := writeCSV(csv, tally.Rows(""), "", true); err != nil {
+			t.Fatal(err)
+		}
+		if text.String() != c.want || csv.String() != c.csv {
+			t.Fatalf("-min-count=%d -sparse=%s: got %q and csv %q, want %q and %q", c.minCount, c.mode, text, csv, c.want, c.csv)
+		}
+	}
+}
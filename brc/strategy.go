@@ -0,0 +1,434 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Strategy is one way of getting the measurements file into a Tally.
+// Every strategy must produce identical results, which makes A/B profiling a matter of flipping -strategy.
+type Strategy interface {
+	Process(filePtr *os.File, tally *Tally, o *Options) error
+}
+
+var strategies = map[string]Strategy{
+	"naive":     NaiveStrategy{},
+	"streaming": StreamingStrategy{},
+	"mmap":      MmapStrategy{},
+	"pread":     PreadStrategy{},
+}
+
+// NaiveStrategy is the obvious single goroutine implementation, kept as the reference the optimised strategies are verified against.
+type NaiveStrategy struct{}
+
+func (NaiveStrategy) Process(filePtr *os.File, tally *Tally, o *Options) error {
+	keep := cachedFilter()
+	var r io.Reader = filePtr
+	if tally.digest != nil {
+		r = io.TeeReader(filePtr, tally.digest)
+	}
+	reader := &timedReader{r: r}
+	scanner := bufio.NewScanner(reader)
+	split := &lineSplitter{}
+	scanner.Split(split.split)
+	offset := skipFileHeader(scanner, split, 0)
+	start := timingStart()
+
+	var aggregate time.Duration
+	lines := 0
+
+	for ; scanner.Scan(); offset += int64(split.advance) {
+		if len(scanner.Bytes()) > MAX_LINE_SIZE {
+			return lineTooLong(offset)
+		}
+
+		if line := scanner.Bytes(); commentChar != 0 && len(line) > 0 && line[0] == commentChar {
+			continue
+		}
+
+		if activeSchema != nil {
+			station, values, ok := naiveColumns(scanner.Bytes(), o.delimiter)
+			if ok && o.key != nil {
+				station = o.key(nil, station)
+			}
+
+			if ok && (keep == nil || keep(station)) {
+				for j, v := range values {
+					tally.metric(j).Station(station, offset).AddAt(v, offset)
+				}
+			}
+			continue
+		}
+
+		station, temp, ok := cutStation(scanner.Bytes(), o.delimiter)
+		if !ok {
+			continue
+		}
+
+		if *strictFormat && !isSpecTemp(temp) {
+			continue
+		}
+
+		if !isDecimal(temp) {
+			return fmt.Errorf("parsing %q: malformed temperature", scanner.Text())
+		}
+
+		f, err := strconv.ParseFloat(string(temp), 64)
+		if err != nil {
+			return fmt.Errorf("parsing %q: %w", scanner.Text(), err)
+		}
+
+		key := station
+		if o.key != nil {
+			key = o.key(nil, key)
+		}
+
+		if keep != nil && !keep(key) {
+			continue
+		}
+
+		if collectTiming && lines%AGGREGATE_SAMPLE == 0 {
+			start := time.Now()
+			tally.Station(key, offset).AddAt(int(math.Round(f*float64(readingScale))), offset)
+			aggregate += time.Since(start)
+		} else {
+			tally.Station(key, offset).AddAt(int(math.Round(f*float64(readingScale))), offset)
+		}
+		lines++
+	}
+
+	o.bytes.Add(offset)
+	linesParsed.Add(int64(lines))
+	if *tui {
+		publishLeaders(tally)
+	}
+
+	if !start.IsZero() {
+		recordParse(0, time.Since(start)-reader.blocked, 1, offset)
+		addPhase(&phaseTimes.aggregate, sampledAggregate(aggregate, (lines+AGGREGATE_SAMPLE-1)/AGGREGATE_SAMPLE))
+	}
+
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return lineTooLong(offset)
+	}
+	return scanner.Err()
+}
+
+// naiveColumns splits line by -schema the obvious way.
+func naiveColumns(line []byte, sep byte) ([]byte, []int, bool) {
+	fields := bytes.Split(line, []byte{sep})
+	if len(fields) < len(activeSchema.columns) {
+		return nil, nil, false
+	}
+
+	values := make([]int, len(activeSchema.metrics))
+	for i, metric := range activeSchema.columns {
+		if metric < 0 {
+			continue
+		}
+
+		if *strictFormat && !isSpecTemp(fields[i]) || !isDecimal(fields[i]) {
+			return nil, nil, false
+		}
+
+		f, err := strconv.ParseFloat(string(fields[i]), 64)
+		if err != nil {
+			return nil, nil, false
+		}
+		values[metric] = int(math.Round(f * float64(readingScale)))
+	}
+
+	return fields[activeSchema.station], values, true
+}
+
+// StreamingStrategy reads the file sequentially into pooled buffers and fans them out to the parser pool.
+type StreamingStrategy struct{}
+
+func (StreamingStrategy) Process(filePtr *os.File, tally *Tally, o *Options) error {
+	//Optimisation: Multithreading application.
+	//Use channels to synchronise
+	return streamInto(adviseStream(filePtr), filePtr.Name(), tally, o)
+}
+
+// MmapStrategy maps the whole file and hands newline aligned sub slices of the mapping to the parser pool.
+// Optimisation: no copies out of the page cache at all. Chunks must never be returned to the BufferPool.
+type MmapStrategy struct{}
+
+func (MmapStrategy) Process(filePtr *os.File, tally *Tally, o *Options) error {
+	info, err := filePtr.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 {
+		return nil
+	}
+
+	//Mapped pages count against the process too, a mapping past the budget would blow it
+	if o.memory > 0 && info.Size() > o.memory {
+		slog.Info("bigger than the memory budget, streaming it instead", "file", filePtr.Name(), "bytes", info.Size(), "budget", o.memory)
+		return StreamingStrategy{}.Process(filePtr, tally, o)
+	}
+
+	data, copied, err := mapHuge(filePtr, int(info.Size()))
+	if err != nil {
+		return err
+	}
+	defer munmapFile(data)
+
+	//A copy in anonymous memory isn't the page cache, dropping its pages would lose them
+	mode := *advise
+	if copied {
+		mode = "off"
+	}
+	advice := newMappingAdvice(data, mode)
+
+	if o.serial {
+		parse, failed := parseSerial(filePtr.Name(), tally, o)
+		mmapChunks(data, o.chunkSize, func(chunk Chunk) {
+			advice.reading(chunk.offset)
+			if tally.digest != nil {
+				tally.digest.Write(chunk.data)
+			}
+			parse(chunk)
+			advice.consumed(chunk)
+		})
+		checkNotRetained(data, tally)
+		return failed()
+	}
+
+	chunks := make(chan Chunk)
+
+	go func() {
+		mmapChunks(data, o.chunkSize, func(chunk Chunk) {
+			advice.reading(chunk.offset)
+
+			//Chunks are cut in order, hashing here faults the pages in on this goroutine rather than the parsers
+			if tally.digest != nil {
+				tally.digest.Write(chunk.data)
+			}
+			chunks <- chunk
+		})
+		close(chunks)
+	}()
+
+	scheduler := NewScheduler(o.workers, o.depth)
+	defer watchQueue(filePtr.Name()+" scheduler", scheduler.Depth)()
+
+	locals := workerTallies(o.workers, tally)
+	errs := make([]error, o.workers)
+	scheduler.Run(chunks, func(worker int, chunk Chunk) {
+		recordChunk(filePtr.Name(), chunk, o)
+		o.counted(chunk)
+		if err := parseLines(chunk.data, chunk.offset, locals[worker], o); err != nil && errs[worker] == nil {
+			errs[worker] = err
+		}
+		advice.consumed(chunk)
+	})
+	mergeTallies(tally, locals)
+	checkNotRetained(data, tally)
+
+	return errors.Join(errs...)
+}
+
+// mmapChunks cuts the mapping into newline aligned chunks of about size bytes, handing each to emit.
+func mmapChunks(data []byte, size int, emit func(chunk Chunk)) {
+	for rest := data; len(rest) > 0; {
+		end := len(rest)
+
+		if end > size {
+			end = size
+			if i := firstLineEnd(rest[end:], true); i == -1 {
+				end = len(rest)
+			} else {
+				end += i + 1
+			}
+		}
+
+		emit(Chunk{rest[:end], int64(len(data) - len(rest))})
+		rest = rest[end:]
+	}
+}
+
+// PreadStrategy splits the file into one newline aligned segment per worker and lets each worker
+// read its own segment with positional reads, so there is no single reader goroutine to bottleneck on.
+type PreadStrategy struct{}
+
+func (PreadStrategy) Process(filePtr *os.File, tally *Tally, o *Options) error {
+	info, err := filePtr.Stat()
+	if err != nil {
+		return err
+	}
+
+	return processRange(filePtr, 0, info.Size(), o.workers, tally, o)
+}
+
+// segmentFile is all the positional read path needs, an *os.File or a remote file read with ranged GETs.
+type segmentFile interface {
+	io.ReaderAt
+	Name() string
+}
+
+// processRange splits [start, end) into newline aligned segments, one goroutine and Local tally each.
+// start must already be the start of a line and end the end of one.
+// With -serial the whole range is the one segment, read on the calling goroutine.
+func processRange(filePtr segmentFile, start, end int64, segments int, tally *Tally, o *Options) error {
+	if o.serial {
+		return readSegment(filePtr, start, end, 0, tally, o)
+	}
+
+	var err error
+	bounds := make([]int64, segments+1)
+	bounds[0] = start
+	bounds[segments] = end
+
+	for i := 1; i < segments; i++ {
+		bounds[i], err = nextLineStart(filePtr, start+(end-start)*int64(i)/int64(segments), end)
+		if err != nil {
+			return err
+		}
+
+		if bounds[i] < bounds[i-1] {
+			bounds[i] = bounds[i-1]
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, segments)
+
+	for i := 0; i < segments; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			local := tally.Local()
+			errs[i] = readSegment(filePtr, bounds[i], bounds[i+1], i, local, o)
+			tally.Merge(local)
+		}(i)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// alignRange narrows [start, end) to exactly the lines whose first byte lies inside it,
+// so arbitrary byte ranges of a file can be handed out and every line is parsed exactly once.
+func alignRange(filePtr segmentFile, start, end, size int64) (int64, int64, error) {
+	var err error
+
+	if end > size {
+		end = size
+	}
+
+	if start > 0 {
+		if start, err = nextLineStart(filePtr, start-1, size); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if end < size {
+		if end, err = nextLineStart(filePtr, end-1, size); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if end < start {
+		end = start
+	}
+
+	return start, end, nil
+}
+
+// nextLineStart returns the offset just past the first line ending at or after off, or size if there is none.
+func nextLineStart(filePtr segmentFile, off, size int64) (int64, error) {
+	buf := make([]byte, 128)
+
+	for off < size {
+		readStart := timingStart()
+		n, err := filePtr.ReadAt(buf, off)
+		recordRead(READER_LANE, readStart, n)
+
+		atEOF := off+int64(n) >= size
+		if i := firstLineEnd(buf[:n], atEOF); i != -1 {
+			return off + int64(i) + 1, nil
+		}
+
+		//Read again from a trailing \r to see whether a \n follows it
+		if n > 1 && buf[n-1] == '\r' && !atEOF {
+			n--
+		}
+		off += int64(n)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// readSegment reads and parses [start, end) with positional reads, timing both against lane id.
+func readSegment(filePtr segmentFile, start, end int64, id int, tally *Tally, o *Options) error {
+	buffer := make([]byte, o.chunkSize)
+	carry := 0
+
+	for off := start; off < end; {
+		want := len(buffer) - carry
+		if int64(want) > end-off {
+			want = int(end - off)
+		}
+
+		readStart := timingStart()
+		n, err := filePtr.ReadAt(buffer[carry:carry+want], off)
+		recordRead(id, readStart, n)
+		progress.Add(1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		off += int64(n)
+		data := buffer[:carry+n]
+
+		if off >= end || n == 0 {
+			parseStart := timingStart()
+			recordChunk(filePtr.Name(), Chunk{data, off - int64(len(data))}, o)
+			o.counted(Chunk{data, off - int64(len(data))})
+			err := parseLines(data, off-int64(len(data)), tally, o)
+			if !parseStart.IsZero() {
+				recordParse(id, time.Since(parseStart), 1, int64(len(data)))
+			}
+			return err
+		}
+
+		//Carry the partial line over to the front of the buffer for the next read
+		last := lastLineEnd(data)
+		if last == -1 {
+			last = len(data) - 1
+		}
+
+		parseStart := timingStart()
+		recordChunk(filePtr.Name(), Chunk{data[:last+1], off - int64(len(data))}, o)
+		o.counted(Chunk{data[:last+1], off - int64(len(data))})
+		if err := parseLines(data[:last+1], off-int64(len(data)), tally, o); err != nil {
+			return err
+		}
+		if !parseStart.IsZero() {
+			recordParse(id, time.Since(parseStart), 1, int64(last+1))
+		}
+		carry = copy(buffer, data[last+1:])
+	}
+
+	return nil
+}
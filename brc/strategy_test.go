@@ -0,0 +1,267 @@
+package brc
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// runEveryStrategy writes input to a file and processes it with each strategy this platform has, returning
+// what a normal run prints as its result by strategy name.
+func runEveryStrategy(input string, opts ...Option) (map[string]string, error) {
+	dir, err := os.MkdirTemp("", "brc-strategies")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(file, []byte(input), 0o644); err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]string{}
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		got, err := runPipeline(strategies[strategyName], []string{file}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("-strategy=%s: %w", strategyName, err)
+		}
+		outputs[strategyName] = got
+	}
+	return outputs, nil
+}
+
+// expectEveryStrategy fails t unless every strategy prints want for input.
+func expectEveryStrategy(t *testing.T, input, want string, opts ...Option) {
+	t.Helper()
+
+	outputs, err := runEveryStrategy(input, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, strategyName := range slices.Sorted(maps.Keys(outputs)) {
+		if got := outputs[strategyName]; got != want {
+			t.Errorf("-strategy=%s: got %q, want %q", strategyName, got, want)
+		}
+	}
+}
+
+// runPipeline processes files with strategy and returns exactly what a normal run prints as its result.
+func runPipeline(strategy Strategy, files []string, opts ...Option) (string, error) {
+	total, err := Process(files, append([]Option{WithStrategy(strategy)}, opts...)...)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	total.Print(buf)
+	return buf.String(), nil
+}
+
+// writeRandomFiles writes n files of at least size bytes of well formed lines into dir.
+func writeRandomFiles(rng *rand.Rand, dir string, n, size int) ([]string, error) {
+	names := make([]string, 1+rng.Intn(500))
+	for i := range names {
+		names[i] = strings.ReplaceAll(randomName(rng), ";", "")
+	}
+
+	files := make([]string, n)
+	for i := range files {
+		var sb strings.Builder
+		for sb.Len() < size {
+			fmt.Fprintf(&sb, "%s;%.1f\n", names[rng.Intn(len(names))], float64(rng.Intn(1999)-999)/10)
+		}
+
+		files[i] = fmt.Sprintf("%s/%d.txt", dir, i)
+		if err := os.WriteFile(files[i], []byte(sb.String()), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// repeatPastChunks repeats lines, which end in a newline, past a few BUFFER_SIZEs so every strategy splits
+// them into chunks. Repeating every line leaves min, mean and max as they were.
+func repeatPastChunks(lines string) string {
+	return strings.Repeat(lines, 3*BUFFER_SIZE/len(lines)+1)
+}
+
+// naiveAggregate is the reference the pipeline must agree with, every line split on its last semicolon and
+// the temperature parsed as a float.
+func naiveAggregate(lines []string) map[string]*StationResult {
+	want := map[string]*StationResult{}
+	for _, line := range lines {
+		station, temp, ok := referenceParse(line)
+		if !ok {
+			continue
+		}
+
+		r, ok := want[station]
+		if !ok {
+			r = &StationResult{min: math.MaxInt, max: math.MinInt}
+			want[station] = r
+		}
+		r.min, r.max = min(r.min, temp), max(r.max, temp)
+		r.sum += temp
+		r.count++
+	}
+	return want
+}
+
+// propertyTemps generate the temperature sequences propertyInput tries: anything in range, the extremes,
+// one constant, around zero where the sign is easy to lose, and a ramp.
+var propertyTemps = []func(rng *rand.Rand, i int) int{
+	func(rng *rand.Rand, i int) int { return rng.Intn(1999) - 999 },
+	func(rng *rand.Rand, i int) int { return []int{-999, 999}[rng.Intn(2)] },
+	func(rng *rand.Rand, i int) int { return 123 },
+	func(rng *rand.Rand, i int) int { return rng.Intn(21) - 10 },
+	func(rng *rand.Rand, i int) int { return i%1999 - 999 },
+}
+
+// propertyInput is a random measurements file and how to process it, as testing/quick generates them.
+type propertyInput struct {
+	lines           []string
+	trailingNewline bool
+	workers         int
+	chunkSize       int
+}
+
+func (propertyInput) Generate(rng *rand.Rand, size int) reflect.Value {
+	names := make([]string, 1+rng.Intn([]int{1, 10, 500, 5000}[rng.Intn(4)]))
+	for i := range names {
+		names[i] = randomName(rng)
+	}
+
+	temps := propertyTemps[rng.Intn(len(propertyTemps))]
+	lines := make([]string, rng.Intn(20_000))
+	for i := range lines {
+		lines[i] = names[rng.Intn(len(names))] + ";" + formatTenths(temps(rng, i))
+	}
+
+	return reflect.ValueOf(propertyInput{lines, rng.Intn(2) == 0, 1 + rng.Intn(8), MIN_CHUNK_SIZE + rng.Intn(4*MIN_CHUNK_SIZE)})
+}
+
+// run writes in to file and processes it with strategy, comparing min, max, sum and count, so the mean too,
+// with naiveAggregate.
+func (in propertyInput) run(file string, strategy Strategy) error {
+	data := strings.Join(in.lines, "\n")
+	if len(in.lines) > 0 && in.trailingNewline {
+		data += "\n"
+	}
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		return err
+	}
+
+	tally, err := Process([]string{file}, WithWorkers(in.workers), WithChunkSize(in.chunkSize), WithStrategy(strategy))
+	if err != nil {
+		return err
+	}
+
+	want := naiveAggregate(in.lines)
+	if len(tally.names) != len(want) {
+		return fmt.Errorf("%d stations, reference %d", len(tally.names), len(want))
+	}
+	for station, w := range want {
+		got, ok := tally.Lookup(station)
+		if !ok {
+			return fmt.Errorf("station %q missing", station)
+		}
+		if got.min != w.min || got.max != w.max || got.sum != w.sum || got.count != w.count {
+			return fmt.Errorf("station %q = %d/%d/%d/%d, reference %d/%d/%d/%d",
+				station, got.min, got.max, got.sum, got.count, w.min, w.max, w.sum, w.count)
+		}
+	}
+	return nil
+}
+
+// TestStrategiesMatchNaiveAggregate runs random station sets and temperature sequences through every
+// strategy, with random workers and chunk sizes. A failing input is shrunk by dropping lines for as long as
+// it keeps failing.
+func TestStrategiesMatchNaiveAggregate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		t.Run(strategyName, func(t *testing.T) {
+			if strategyName == "mmap" && !mmapSupported {
+				t.Skip("mmap isn't supported here")
+			}
+			strategy := strategies[strategyName]
+
+			matches := func(in propertyInput) bool { return in.run(file, strategy) == nil }
+			err := quick.Check(matches, &quick.Config{MaxCount: 30, Rand: rand.New(rand.NewSource(1))})
+			if err == nil {
+				return
+			}
+			failed, ok := err.(*quick.CheckError)
+			if !ok {
+				t.Fatal(err)
+			}
+
+			in := failed.In[0].(propertyInput)
+			for step := len(in.lines) / 2; step > 0; step /= 2 {
+				for i := 0; i+step <= len(in.lines); {
+					smaller := in
+					if smaller.lines = slices.Concat(in.lines[:i], in.lines[i+step:]); !matches(smaller) {
+						in = smaller
+					} else {
+						i += step
+					}
+				}
+			}
+
+			t.Fatalf("%d lines %q: %v", len(in.lines), in.lines, in.run(file, strategy))
+		})
+	}
+}
+
+// TestSerial runs random files spanning several chunks through every strategy with and without -serial.
+// One goroutine must get exactly what the pools and segments get.
+func TestSerial(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	files, err := writeRandomFiles(rng, t.TempDir(), 2, 3*BUFFER_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := compareSerial(files); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compareSerial runs files through every strategy with opts, with and without -serial, the two must agree.
+func compareSerial(files []string, opts ...Option) error {
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		var results [2]string
+		for i, s := range []bool{false, true} {
+			var err error
+			if results[i], err = runPipeline(strategies[strategyName], files, append(opts, WithSerial(s))...); err != nil {
+				return fmt.Errorf("-strategy=%s -serial=%t: %w", strategyName, s, err)
+			}
+		}
+
+		if results[0] != results[1] {
+			return fmt.Errorf("-strategy=%s: -serial got %q, the pool %q", strategyName, results[1], results[0])
+		}
+	}
+
+	return nil
+}
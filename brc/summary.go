@@ -0,0 +1,47 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var summary = commandLine.Bool("summary", false, "also print one summary line to stderr: rows, stations, overall min/mean/max, bytes read, wall time and throughput")
+
+// Summary is every station of a run folded into one, the quickest check that all rows were attributed.
+type Summary struct {
+	Rows, Stations int
+	Min, Max, Sum  int
+	Bytes          int64
+	Elapsed        time.Duration
+}
+
+func (t *Tally) Summary(bytes int64, elapsed time.Duration) Summary {
+	s := Summary{Stations: len(t.names), Min: math.MaxInt, Max: math.MinInt, Bytes: bytes, Elapsed: elapsed}
+
+	for id := range t.names {
+		r := t.stat(id)
+		s.Rows += r.count
+		s.Sum += r.sum
+		s.Min = min(s.Min, r.min)
+		s.Max = max(s.Max, r.max)
+	}
+
+	return s
+}
+
+// String renders s as e.g.
+// 1000000000 rows, 413 stations, min -99.9 mean 0.0 max 99.9, 13156.4 MiB in 2.1s (476.2M rows/s, 6265.0 MiB/s)
+func (s Summary) String() string {
+	extremes := "no readings"
+	if s.Rows > 0 {
+		unit := outputUnit()
+		extremes = fmt.Sprintf("min %s mean %s max %s", formatValue(unit.value(s.Min)), formatValue(unit.mean(s.Sum, s.Rows)), formatValue(unit.value(s.Max)))
+	}
+
+	mib := float64(s.Bytes) / (1 << 20)
+	seconds := s.Elapsed.Seconds()
+
+	return fmt.Sprintf("%d rows, %d stations, %s, %.1f MiB in %v (%.1fM rows/s, %.1f MiB/s)",
+		s.Rows, s.Stations, extremes, mib, s.Elapsed.Round(time.Millisecond), float64(s.Rows)/seconds/1e6, mib/seconds)
+}
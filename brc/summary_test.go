@@ -0,0 +1,41 @@
+package brc
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummary folds aggInput into a Summary through every strategy.
+func TestSummary(t *testing.T) {
+	dir := t.TempDir()
+
+	file := dir + "/measurements.txt"
+	if err := os.WriteFile(file, []byte(aggInput), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Summary{Rows: 7, Stations: 2, Min: -20, Max: 80, Sum: 150, Bytes: int64(len(aggInput)), Elapsed: time.Second}
+
+	for _, strategyName := range strings.Split(strategyNames(), ", ") {
+		if strategyName == "mmap" && !mmapSupported {
+			continue
+		}
+
+		stats := Stats{}
+		tally, err := Process([]string{file}, WithStrategy(strategies[strategyName]), WithStats(&stats))
+		if err != nil {
+			t.Fatalf("-strategy=%s: %v", strategyName, err)
+		}
+
+		if got := tally.Summary(stats.Bytes, time.Second); got != want {
+			t.Fatalf("-strategy=%s: got %+v, want %+v", strategyName, got, want)
+		}
+	}
+
+	const line = "7 rows, 2 stations, min -2.0 mean 2.1 max 8.0, 0.0 MiB in 1s (0.0M rows/s, 0.0 MiB/s)"
+	if got := want.String(); got != line {
+		t.Fatalf("summary line %q, want %q", got, line)
+	}
+}
@@ -0,0 +1,69 @@
+package brc
+
+import (
+	"math"
+	"strconv"
+)
+
+var strictFormat = commandLine.Bool("strict-format", false, "skip temperatures not written as the challenge's -?d?d.d instead of reading integers, more decimals and a leading +")
+
+// isSpecTemp reports whether b is a -?\d?\d\.\d temperature, the only format parseTemp reads.
+func isSpecTemp(b []byte) bool {
+	n := len(b)
+	if n < 3 || n > 5 || b[n-2] != '.' || !isDigit(b[n-1]) || !isDigit(b[n-3]) {
+		return false
+	}
+
+	switch n {
+	case 4:
+		return b[0] == '-' || isDigit(b[0])
+	case 5:
+		return b[0] == '-' && isDigit(b[1])
+	}
+	return true
+}
+
+// isDecimal reports whether b is [+-]?\d+(\.\d+)?, which is every temperature parseTempAny reads.
+func isDecimal(b []byte) bool {
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		b = b[1:]
+	}
+
+	digits, dot := 0, -1
+	for i, c := range b {
+		switch {
+		case isDigit(c):
+			digits++
+		case c == '.' && dot == -1:
+			dot = i
+		default:
+			return false
+		}
+	}
+
+	return digits > 0 && dot != 0 && dot != len(b)-1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parseTempAny parses a temperature of any precision into units of readingScale, ok is false for a malformed one or with
+// -strict-format one not in the challenge's format.
+// Optimisation: the challenge's format still goes through parseTemp, the rest takes the naive strategy's
+// ParseFloat and rounding so every strategy agrees on values like 12.35.
+func parseTempAny(b []byte) (int, bool) {
+	if isSpecTemp(b) {
+		return parseTemp(b) * (readingScale / 10), true
+	}
+
+	if *strictFormat || !isDecimal(b) {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(math.Round(f * float64(readingScale))), true
+}
@@ -0,0 +1,90 @@
+package brc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+var precisionCases = []struct {
+	strict bool
+	input  string
+	want   string
+}{
+	{false, "A;12\nA;+1.5\nA;-0.25\nA;2.0\nB;12.34\nB;1.25\n", "{A=-0.3/3.8/12.0, B=1.3/6.8/12.3}\n"},
+	{false, "A;-123.4\nA;100\n", "{A=-123.4/-11.7/100.0}\n"},
+	{true, "A;12\nA;+1.5\nA;-0.25\nA;2.0\nB;12.34\nB;1.25\n", "{A=2.0/2.0/2.0}\n"},
+	{true, "A;1.0\nA;abc\nA;1.\nA;-\n", "{A=1.0/1.0/1.0}\n"},
+}
+
+// TestStrictFormat runs precisionCases through every strategy, each file also repeated past a few BUFFER_SIZEs.
+func TestStrictFormat(t *testing.T) {
+	defer func(strict bool) { *strictFormat = strict }(*strictFormat)
+
+	for i, c := range precisionCases {
+		t.Run(fmt.Sprintf("%d -strict-format=%t", i, c.strict), func(t *testing.T) {
+			*strictFormat = c.strict
+			expectEveryStrategy(t, c.input, c.want)
+			expectEveryStrategy(t, repeatPastChunks(c.input), c.want)
+		})
+	}
+}
+
+// TestParseTemp compares parseTemp with strconv.ParseFloat for every valid temperature, each followed by random
+// bytes, both with room for a full word load and at the very end of a buffer.
+func TestParseTemp(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for tenths := -999; tenths <= 999; tenths++ {
+		text := strconv.FormatFloat(float64(tenths)/10, 'f', 1, 64)
+
+		for i := 0; i < 100; i++ {
+			buf := append([]byte(text), '\n')
+			for j := rng.Intn(8); j > 0; j-- {
+				buf = append(buf, byte(rng.Intn(256)))
+			}
+
+			b := buf[:len(text)]
+			if i%2 == 0 {
+				//No bytes after the number at all
+				b = append([]byte(nil), text...)
+				b = b[:len(b):len(b)]
+			}
+
+			if got := parseTemp(b); got != tenths {
+				t.Fatalf("parseTemp(%q) = %d, want %d", b, got, tenths)
+			}
+		}
+	}
+
+	//-0.0 is valid input that FormatFloat never produces
+	if got := parseTemp([]byte("-0.0")); got != 0 {
+		t.Fatalf("parseTemp(\"-0.0\") = %d, want 0", got)
+	}
+}
+
+// FuzzParseTemp compares parseTemp with strconv.ParseFloat on any temperature in the challenge's format,
+// followed by any bytes it must not read.
+func FuzzParseTemp(f *testing.F) {
+	f.Add("-99.9", []byte("\n"))
+	f.Add("0.0", []byte{})
+	f.Add("-0.0", []byte("\xff\xff\xff\xff\xff\xff\xff"))
+	f.Add("12.3", []byte(";4.5\n"))
+
+	f.Fuzz(func(t *testing.T, temp string, tail []byte) {
+		if !challengeTemp.MatchString(temp) {
+			t.Skip()
+		}
+
+		want, err := strconv.ParseFloat(temp, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := append([]byte(temp), tail...)[:len(temp)]
+		if got := parseTemp(b); got != int(math.Round(want*10)) {
+			t.Fatalf("parseTemp(%q) = %d, strconv.ParseFloat %v", temp, got, want)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package brc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Thrift compact protocol types, the encoding of Parquet's metadata.
+const (
+	THRIFT_STOP   = 0
+	THRIFT_TRUE   = 1
+	THRIFT_FALSE  = 2
+	THRIFT_BYTE   = 3
+	THRIFT_I16    = 4
+	THRIFT_I32    = 5
+	THRIFT_I64    = 6
+	THRIFT_DOUBLE = 7
+	THRIFT_BINARY = 8
+	THRIFT_LIST   = 9
+	THRIFT_SET    = 10
+	THRIFT_MAP    = 11
+	THRIFT_STRUCT = 12
+)
+
+// THRIFT_MAX_DEPTH bounds how deeply structs may nest, so a corrupt footer can't recurse without end.
+const THRIFT_MAX_DEPTH = 32
+
+// thriftStruct is a decoded struct by field ID. Values are int64 for every integer, bool, float64, []byte,
+// []any for lists and sets, thriftStruct, or nil for maps, which Parquet's metadata only has in fields not read.
+type thriftStruct map[int16]any
+
+func (s thriftStruct) int(id int16) (int64, bool) {
+	v, ok := s[id].(int64)
+	return v, ok
+}
+
+func (s thriftStruct) bool(id int16) (bool, bool) {
+	v, ok := s[id].(bool)
+	return v, ok
+}
+
+func (s thriftStruct) string(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStruct) list(id int16) []any {
+	v, _ := s[id].([]any)
+	return v
+}
+
+func (s thriftStruct) sub(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+// thriftReader decodes the compact protocol from data. The first error sticks, reads past it return zeroes.
+type thriftReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+var errThriftShort = errors.New("thrift: truncated")
+
+func (r *thriftReader) byte() byte {
+	if r.err != nil || r.pos >= len(r.data) {
+		r.err = errThriftShort
+		return 0
+	}
+
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = errThriftShort
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) bytes(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.data)-r.pos) {
+		r.err = errThriftShort
+		return nil
+	}
+
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// readStruct decodes fields up to the struct's stop byte.
+func (r *thriftReader) readStruct(depth int) thriftStruct {
+	if depth > THRIFT_MAX_DEPTH {
+		r.err = errors.New("thrift: structs nested too deep")
+		return nil
+	}
+
+	s := thriftStruct{}
+	id := int16(0)
+
+	for r.err == nil {
+		header := r.byte()
+		if header == THRIFT_STOP {
+			break
+		}
+
+		//The field ID is a delta on the last one in the high nibble, or a zigzag i16 of its own after the header
+		if delta := header >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			id = int16(r.zigzag())
+		}
+
+		s[id] = r.readValue(header&0x0F, depth)
+	}
+
+	return s
+}
+
+func (r *thriftReader) readValue(typ byte, depth int) any {
+	switch typ {
+	case THRIFT_TRUE:
+		return true
+	case THRIFT_FALSE:
+		return false
+	case THRIFT_BYTE:
+		return int64(int8(r.byte()))
+	case THRIFT_I16, THRIFT_I32, THRIFT_I64:
+		return r.zigzag()
+	case THRIFT_DOUBLE:
+		if b := r.bytes(8); b != nil {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return 0.0
+	case THRIFT_BINARY:
+		return r.bytes(r.uvarint())
+	case THRIFT_LIST, THRIFT_SET:
+		header := r.byte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.uvarint()
+		}
+
+		//Every element takes at least a byte
+		if size > uint64(len(r.data)-r.pos) {
+			r.err = errThriftShort
+			return nil
+		}
+
+		list := make([]any, 0, size)
+		for i := uint64(0); i < size && r.err == nil; i++ {
+			list = append(list, r.readElem(header&0x0F, depth+1))
+		}
+		return list
+	case THRIFT_MAP:
+		size := r.uvarint()
+		if size == 0 {
+			return nil
+		}
+
+		types := r.byte()
+		for i := uint64(0); i < size && r.err == nil; i++ {
+			r.readElem(types>>4, depth+1)
+			r.readElem(types&0x0F, depth+1)
+		}
+		return nil
+	case THRIFT_STRUCT:
+		return r.readStruct(depth + 1)
+	}
+
+	r.err = errors.New("thrift: unknown type")
+	return nil
+}
+
+// readElem reads an element of a list, set or map, where a bool is a byte of its own, 1 for true.
+func (r *thriftReader) readElem(typ byte, depth int) any {
+	if typ == THRIFT_TRUE || typ == THRIFT_FALSE {
+		return r.byte() == THRIFT_TRUE
+	}
+	return r.readValue(typ, depth)
+}
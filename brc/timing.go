@@ -0,0 +1,201 @@
+package brc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var timingFormat = commandLine.String("timing-format", "human", "how the elapsed time is reported: human on stderr, benchstat or hyperfine on stdout, or none")
+
+var timingFormats = []string{"human", "benchstat", "hyperfine", "none"}
+
+var timingJSON = commandLine.String("timing-json", "", "also write the time spent in each phase as JSON to `file`, - for stdout")
+
+// collectTiming turns on the clock reads behind the phase report and -timing-breakdown. It is decided once
+// after the flags are parsed so that runs timed externally, or with -timing-format=none, pay nothing for it.
+var collectTiming bool
+
+// AGGREGATE_SAMPLE is how often parseLines times a tally update. It times one in every AGGREGATE_SAMPLE
+// and scales up, reading the clock around every update would cost more than the update itself.
+const AGGREGATE_SAMPLE = 64
+
+// clockOverhead is the cost of the time.Now and time.Since pair around a sampled update, which is
+// comparable to the update itself and would otherwise be counted as aggregate time.
+var clockOverhead time.Duration
+
+// calibrateClock sets clockOverhead to the average of many back to back readings.
+// Sampled readings are rarely the cheapest case, so the minimum would undercount it.
+func calibrateClock() {
+	var total time.Duration
+	for i := 0; i < 1000; i++ {
+		start := time.Now()
+		total += time.Since(start)
+	}
+	clockOverhead = total / 1000
+}
+
+// sampledAggregate scales the time of sampled tally updates up to all lines.
+func sampledAggregate(sampled time.Duration, samples int) time.Duration {
+	sampled -= time.Duration(samples) * clockOverhead
+	if sampled < 0 {
+		sampled = 0
+	}
+	return sampled * AGGREGATE_SAMPLE
+}
+
+var phaseTimes = struct {
+	aggregate, merge, sort, output time.Duration
+	m                              sync.Mutex
+}{}
+
+// timePhase starts timing phase and returns the func that stops it, for use with defer.
+func timePhase(phase *time.Duration) func() {
+	start := timingStart()
+	return func() {
+		if !start.IsZero() {
+			addPhase(phase, time.Since(start))
+		}
+	}
+}
+
+func addPhase(phase *time.Duration, d time.Duration) {
+	phaseTimes.m.Lock()
+	*phase += d
+	phaseTimes.m.Unlock()
+}
+
+// Phases is where a run's time went. Read, parse and aggregate overlap in the pipeline and are summed
+// over every goroutine, so together they can exceed Total. Merge, sort and output run alone at the end.
+type Phases struct {
+	Total     time.Duration `json:"total_ns"`
+	Read      time.Duration `json:"read_ns"`
+	Parse     time.Duration `json:"parse_ns"`
+	Aggregate time.Duration `json:"aggregate_ns"`
+	Merge     time.Duration `json:"merge_ns"`
+	Sort      time.Duration `json:"sort_ns"`
+	Output    time.Duration `json:"output_ns"`
+}
+
+// resetPhases zeroes every phase and lane, for a process that runs the aggregation more than once.
+func resetPhases() {
+	breakdown.m.Lock()
+	breakdown.lanes = map[int]*laneTiming{}
+	breakdown.m.Unlock()
+
+	phaseTimes.m.Lock()
+	phaseTimes.aggregate, phaseTimes.merge, phaseTimes.sort, phaseTimes.output = 0, 0, 0, 0
+	phaseTimes.m.Unlock()
+}
+
+func collectPhases(total time.Duration) Phases {
+	p := Phases{Total: total}
+
+	breakdown.m.Lock()
+	for _, l := range breakdown.lanes {
+		p.Read += l.read
+		p.Parse += l.parse
+	}
+	breakdown.m.Unlock()
+
+	phaseTimes.m.Lock()
+	p.Aggregate, p.Merge, p.Sort, p.Output = phaseTimes.aggregate, phaseTimes.merge, phaseTimes.sort, phaseTimes.output
+	phaseTimes.m.Unlock()
+
+	//Lanes time the whole of parseLines, tally updates included
+	p.Parse -= p.Aggregate
+	if p.Parse < 0 {
+		p.Parse = 0
+	}
+
+	return p
+}
+
+func (p Phases) print(w io.Writer) {
+	for _, phase := range []struct {
+		name string
+		d    time.Duration
+	}{{"read", p.Read}, {"parse", p.Parse}, {"aggregate", p.Aggregate}, {"merge", p.Merge}, {"sort", p.Sort}, {"output", p.Output}} {
+		fmt.Fprintf(w, "  %-10s %v\n", phase.name, phase.d.Round(time.Microsecond))
+	}
+	fmt.Fprintln(w, "  (read, parse and aggregate are summed over goroutines)")
+}
+
+// writeTimingJSON writes timing, the Phases of a run or the RepeatTiming of -repeat, to the file name.
+func writeTimingJSON(name string, timing any) error {
+	if name == "-" {
+		return json.NewEncoder(os.Stdout).Encode(timing)
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(f).Encode(timing); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// externalTimingEnv are set when an external harness is timing the run.
+// hyperfine exports HYPERFINE_RANDOMIZED_ENVIRONMENT_OFFSET to every benchmarked command.
+var externalTimingEnv = []string{"HYPERFINE_RANDOMIZED_ENVIRONMENT_OFFSET", "BRC_EXTERNAL_TIMING"}
+
+// effectiveTimingFormat suppresses internal timing under an external harness, unless -timing-format was given
+// explicitly.
+func effectiveTimingFormat() string {
+	if !flagSet("timing-format") {
+		for _, env := range externalTimingEnv {
+			if _, ok := os.LookupEnv(env); ok {
+				return "none"
+			}
+		}
+	}
+
+	return *timingFormat
+}
+
+// hyperfineResult mirrors one entry of hyperfine's --export-json output, times are in seconds.
+type hyperfineResult struct {
+	Command string    `json:"command"`
+	Mean    float64   `json:"mean"`
+	Stddev  float64   `json:"stddev"`
+	Median  float64   `json:"median"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Times   []float64 `json:"times"`
+}
+
+func reportTiming(elapsed time.Duration) {
+	phases := collectPhases(elapsed)
+
+	switch effectiveTimingFormat() {
+	case "human":
+		//On stderr, where it can't end up in the results
+		fmt.Fprintln(os.Stderr, elapsed)
+		phases.print(os.Stderr)
+	case "benchstat":
+		//Same shape as a go test -bench line so benchstat can compare runs directly
+		fmt.Printf("Benchmark1BRC/strategy=%s 1 %d ns/op\n", *strategyName, elapsed.Nanoseconds())
+	case "hyperfine":
+		s := elapsed.Seconds()
+		export := map[string][]hyperfineResult{
+			"results": {{strings.Join(os.Args, " "), s, 0, s, s, s, []float64{s}}},
+		}
+		json.NewEncoder(os.Stdout).Encode(export)
+	}
+
+	if *timingJSON != "" {
+		if err := writeTimingJSON(*timingJSON, phases); err != nil {
+			log.Fatal("could not write timing JSON: ", err)
+		}
+	}
+}
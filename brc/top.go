@@ -0,0 +1,66 @@
+package brc
+
+import (
+	"slices"
+	"sort"
+)
+
+var top = commandLine.Int("top", 0, "print only the first `n` stations by -by instead of every station (0 prints all)")
+var topBy = commandLine.String("by", "mean", "what -top ranks by: mean, max (hottest first), min (coldest first) or count (most measured first)")
+var sortBy = commandLine.String("sort", "name", "what the stations are printed in order of: name, mean, min, max or count, ascending unless -desc")
+var desc = commandLine.Bool("desc", false, "print the stations in descending -sort order")
+
+var topOrders = []string{"mean", "max", "min", "count"}
+var sortOrders = []string{"name", "mean", "min", "max", "count"}
+
+// stationStats are the values -by and -sort order stations by.
+var stationStats = map[string]func(r *StationResult) float64{
+	"mean":  func(r *StationResult) float64 { return float64(r.sum) / float64(r.count) },
+	"max":   func(r *StationResult) float64 { return float64(r.max) },
+	"min":   func(r *StationResult) float64 { return float64(r.min) },
+	"count": func(r *StationResult) float64 { return float64(r.count) },
+}
+
+// sortedNames returns the station names of t in output order: by -sort, reversed with -desc, or with -top the
+// first n by -by, left in that order unless -sort or -desc is given too. Ties are broken by name so the cut is
+// the same on every run. Stations -min-count omits are left out before the cut.
+func (t *Tally) sortedNames() []string {
+	names := t.withoutSparse(append([]string(nil), t.names...))
+	sortCollated(names)
+
+	if *top > 0 {
+		stat := stationStats[*topBy]
+		//min ranks the coldest first, the others the highest
+		sign := 1.0
+		if *topBy == "min" {
+			sign = -1
+		}
+		t.sortByStat(names, func(r *StationResult) float64 { return -sign * stat(r) })
+
+		if len(names) > *top {
+			names = names[:*top]
+		}
+		if !flagSet("sort") && !*desc {
+			return names
+		}
+		sortCollated(names)
+	}
+
+	if *sortBy != "name" {
+		t.sortByStat(names, stationStats[*sortBy])
+	}
+	if *desc {
+		slices.Reverse(names)
+	}
+
+	return names
+}
+
+// sortByStat stably sorts names, already by name, by stat ascending.
+func (t *Tally) sortByStat(names []string, stat func(r *StationResult) float64) {
+	sort.SliceStable(names, func(i, j int) bool {
+		a, _ := t.Lookup(names[i])
+		b, _ := t.Lookup(names[j])
+		return stat(a) < stat(b)
+	})
+}
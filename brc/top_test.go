@@ -0,0 +1,46 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var sortCases = []struct {
+	sort string
+	desc bool
+	top  int
+	want string
+}{
+	{"name", false, 0, "{A=5.0/5.0/5.0, B=1.0/1.0/1.0, C=3.0/3.0/3.0, D=-1.0/1.0/3.0}\n"},
+	{"name", true, 0, "{D=-1.0/1.0/3.0, C=3.0/3.0/3.0, B=1.0/1.0/1.0, A=5.0/5.0/5.0}\n"},
+	{"mean", false, 0, "{B=1.0/1.0/1.0, D=-1.0/1.0/3.0, C=3.0/3.0/3.0, A=5.0/5.0/5.0}\n"},
+	{"min", false, 0, "{D=-1.0/1.0/3.0, B=1.0/1.0/1.0, C=3.0/3.0/3.0, A=5.0/5.0/5.0}\n"},
+	{"max", true, 0, "{A=5.0/5.0/5.0, D=-1.0/1.0/3.0, C=3.0/3.0/3.0, B=1.0/1.0/1.0}\n"},
+	{"count", true, 0, "{D=-1.0/1.0/3.0, C=3.0/3.0/3.0, B=1.0/1.0/1.0, A=5.0/5.0/5.0}\n"},
+	{"name", false, 2, "{A=5.0/5.0/5.0, C=3.0/3.0/3.0}\n"},
+	{"count", false, 2, "{A=5.0/5.0/5.0, C=3.0/3.0/3.0}\n"},
+	{"count", true, 2, "{C=3.0/3.0/3.0, A=5.0/5.0/5.0}\n"},
+}
+
+// TestSort prints a tally under every sortCases -sort, -desc and -top, the cut left in -by=mean order
+// unless -desc is given.
+func TestSort(t *testing.T) {
+	defer func(by string, descending bool, n int) { *sortBy, *desc, *top = by, descending, n }(*sortBy, *desc, *top)
+
+	tally := NewTally()
+	for _, line := range []string{"C;3.0", "A;5.0", "D;-1.0", "B;1.0", "D;3.0", "C;3.0", "D;1.0"} {
+		station, temp, _ := strings.Cut(line, ";")
+		tally.Get([]byte(station)).Add(parseTemp([]byte(temp)))
+	}
+
+	for _, c := range sortCases {
+		*sortBy, *desc, *top = c.sort, c.desc, c.top
+
+		buf := &bytes.Buffer{}
+		tally.Print(buf)
+		if buf.String() != c.want {
+			t.Fatalf("-sort=%s -desc=%v -top=%d: got %q, want %q", c.sort, c.desc, c.top, buf.String(), c.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package brc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+var tui = commandLine.Bool("tui", false, "redraw a dashboard on stderr while the inputs are parsed: throughput of every worker, queue depths, GC activity and the hottest and coldest stations so far")
+
+// TUI_INTERVAL is how often -tui redraws, and how often a parser publishes its hottest and coldest stations.
+const TUI_INTERVAL = 250 * time.Millisecond
+
+// TUI_LEADERS is how many of the hottest and of the coldest stations -tui lists.
+const TUI_LEADERS = 5
+
+// leader is a station and its most extreme reading so far, in units of readingScale.
+type leader struct {
+	station string
+	value   int
+}
+
+// leaderboard holds the hottest and coldest stations of every tally being filled as its parser last published
+// them. Parsers own their tallies, so the dashboard can't look itself, see publishLeaders.
+var leaderboard = struct {
+	hottest, coldest map[*Tally][]leader
+	published        map[*Tally]time.Time
+	m                sync.Mutex
+}{hottest: map[*Tally][]leader{}, coldest: map[*Tally][]leader{}, published: map[*Tally]time.Time{}}
+
+// publishLeaders lists the hottest and coldest stations of tally for the dashboard, at most once every
+// TUI_INTERVAL. Only the goroutine filling tally may call it, between chunks.
+func publishLeaders(tally *Tally) {
+	leaderboard.m.Lock()
+	last := leaderboard.published[tally]
+	leaderboard.m.Unlock()
+	if !last.IsZero() && clock.Since(last) < TUI_INTERVAL {
+		return
+	}
+
+	var hottest, coldest []leader
+	for id, station := range tally.names {
+		r := tally.stat(id)
+		if r.count == 0 {
+			continue
+		}
+		hottest = rankLeader(hottest, leader{station, r.max}, 1)
+		coldest = rankLeader(coldest, leader{station, r.min}, -1)
+	}
+
+	leaderboard.m.Lock()
+	leaderboard.hottest[tally], leaderboard.coldest[tally] = hottest, coldest
+	leaderboard.published[tally] = clock.Now()
+	leaderboard.m.Unlock()
+}
+
+// rankLeader inserts l into leaders, kept sorted by value times sign descending and cut to TUI_LEADERS.
+func rankLeader(leaders []leader, l leader, sign int) []leader {
+	i := sort.Search(len(leaders), func(i int) bool { return sign*leaders[i].value < sign*l.value })
+	if i == TUI_LEADERS {
+		return leaders
+	}
+	leaders = append(leaders[:i], append([]leader{l}, leaders[i:]...)...)
+	return leaders[:min(len(leaders), TUI_LEADERS)]
+}
+
+// mergeLeaders ranks the stations of every tally's leaders, a station listed by several taking its most
+// extreme value.
+func mergeLeaders(byTally map[*Tally][]leader, sign int) []leader {
+	extremes := map[string]int{}
+	for _, leaders := range byTally {
+		for _, l := range leaders {
+			if v, ok := extremes[l.station]; !ok || sign*l.value > sign*v {
+				extremes[l.station] = l.value
+			}
+		}
+	}
+
+	var merged []leader
+	for _, station := range sortedKeys(extremes) {
+		merged = rankLeader(merged, leader{station, extremes[station]}, sign)
+	}
+	return merged
+}
+
+// dashboardFrame is everything one redraw of -tui shows.
+type dashboardFrame struct {
+	elapsed          time.Duration
+	lanes            map[int]laneTiming
+	rates            map[int]float64
+	queues           []string
+	gcCycles         uint64
+	heapLive         uint64
+	heapGoal         uint64
+	hottest, coldest []leader
+}
+
+// gcSamples are the runtime metrics the dashboard shows GC activity with.
+var gcSamples = []string{"/gc/cycles/total:gc-cycles", "/gc/heap/live:bytes", "/gc/heap/goal:bytes"}
+
+// sampleDashboard takes a frame elapsed into the run, rates being MiB/s parsed by each lane since previous.
+func sampleDashboard(elapsed time.Duration, previous dashboardFrame) dashboardFrame {
+	frame := dashboardFrame{elapsed: elapsed, lanes: map[int]laneTiming{}, rates: map[int]float64{}}
+
+	breakdown.m.Lock()
+	for id, l := range breakdown.lanes {
+		frame.lanes[id] = *l
+	}
+	breakdown.m.Unlock()
+
+	if seconds := (elapsed - previous.elapsed).Seconds(); seconds > 0 {
+		for id, l := range frame.lanes {
+			frame.rates[id] = float64(laneBytes(l)-laneBytes(previous.lanes[id])) / (1 << 20) / seconds
+		}
+	}
+
+	watchedQueues.m.Lock()
+	for _, name := range sortedKeys(watchedQueues.depths) {
+		frame.queues = append(frame.queues, name+": "+watchedQueues.depths[name]())
+	}
+	watchedQueues.m.Unlock()
+
+	samples := make([]metrics.Sample, len(gcSamples))
+	for i, name := range gcSamples {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	for i, p := range []*uint64{&frame.gcCycles, &frame.heapLive, &frame.heapGoal} {
+		if samples[i].Value.Kind() == metrics.KindUint64 {
+			*p = samples[i].Value.Uint64()
+		}
+	}
+
+	leaderboard.m.Lock()
+	frame.hottest = mergeLeaders(leaderboard.hottest, 1)
+	frame.coldest = mergeLeaders(leaderboard.coldest, -1)
+	leaderboard.m.Unlock()
+
+	return frame
+}
+
+// laneBytes is what a lane got through: the bytes a worker parsed, or the reader read.
+func laneBytes(l laneTiming) int64 {
+	if l.parsed > 0 {
+		return l.parsed
+	}
+	return l.bytes
+}
+
+// drawDashboard writes frame as lines:
+//
+//	1.2s, 2048.0 MiB parsed
+//	worker 0      410.3 MiB/s   1203 chunks
+//	queue        stdin scheduler: 3 of 16 pending, deques [1 0 2 0], closed false
+//	gc           12 cycles, 45.2 MiB live, 90.1 MiB goal
+//	hottest      Abha 59.2, ...
+func drawDashboard(w io.Writer, frame dashboardFrame) {
+	ids := make([]int, 0, len(frame.lanes))
+	var parsed int64
+	for id, l := range frame.lanes {
+		ids = append(ids, id)
+		parsed += l.parsed
+	}
+	sort.Ints(ids)
+
+	fmt.Fprintf(w, "%v, %.1f MiB parsed\n", frame.elapsed.Round(100*time.Millisecond), float64(parsed)/(1<<20))
+	for _, id := range ids {
+		l := frame.lanes[id]
+		if id == READER_LANE {
+			fmt.Fprintf(w, "%-12s %7.1f MiB/s %6d reads\n", "reader", frame.rates[id], l.reads)
+			continue
+		}
+		fmt.Fprintf(w, "%-12s %7.1f MiB/s %6d chunks\n", fmt.Sprintf("worker %d", id), frame.rates[id], l.chunks)
+	}
+	for _, queue := range frame.queues {
+		fmt.Fprintf(w, "%-12s %s\n", "queue", queue)
+	}
+	fmt.Fprintf(w, "%-12s %d cycles, %.1f MiB live, %.1f MiB goal\n", "gc", frame.gcCycles,
+		float64(frame.heapLive)/(1<<20), float64(frame.heapGoal)/(1<<20))
+	fmt.Fprintf(w, "%-12s %s\n", "hottest", formatLeaders(frame.hottest))
+	fmt.Fprintf(w, "%-12s %s\n", "coldest", formatLeaders(frame.coldest))
+}
+
+func formatLeaders(leaders []leader) string {
+	if len(leaders) == 0 {
+		return "none yet"
+	}
+
+	unit := outputUnit()
+	var b []byte
+	for i, l := range leaders {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(append(b, l.station...), ' ')
+		b = appendValue(b, unit.value(l.value))
+	}
+	return string(b)
+}
+
+// startDashboard redraws the dashboard on out every TUI_INTERVAL, over the previous frame, until the
+// returned func is called, which draws the last frame.
+func startDashboard(out io.Writer) func() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	start := clock.Now()
+
+	go func() {
+		defer close(done)
+
+		bw := bufio.NewWriter(out)
+		var frame dashboardFrame
+		lines := 0
+		for {
+			tick, stopTick := clock.NewTimer(TUI_INTERVAL)
+
+			stopped := false
+			select {
+			case <-stop:
+				stopTick()
+				stopped = true
+			case <-tick:
+			}
+
+			frame = sampleDashboard(clock.Since(start), frame)
+			buf := &bytes.Buffer{}
+			drawDashboard(buf, frame)
+
+			//Up over the previous frame and clear to the end of the screen
+			if lines > 0 {
+				fmt.Fprintf(bw, "\x1b[%dA\x1b[J", lines)
+			}
+			bw.Write(buf.Bytes())
+			bw.Flush()
+			lines = bytes.Count(buf.Bytes(), []byte{'\n'})
+
+			if stopped {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
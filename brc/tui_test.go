@@ -0,0 +1,40 @@
+package brc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTUI publishes the leaders of two tallies, one station in both, and checks the dashboard ranks them
+// together with the station at its most extreme.
+func TestTUI(t *testing.T) {
+	a, b := NewTally(), NewTally()
+	for i, line := range []string{"A;10.0", "B;-5.0", "C;30.0", "D;1.0", "E;2.0", "F;3.0", "G;-1.0", "C;40.0", "D;-20.0", "H;35.5"} {
+		station, temp, _ := strings.Cut(line, ";")
+		[]*Tally{a, b}[i%2].Get([]byte(station)).Add(parseTemp([]byte(temp)))
+	}
+	publishLeaders(a)
+	publishLeaders(b)
+	defer func() {
+		leaderboard.m.Lock()
+		for _, tally := range []*Tally{a, b} {
+			delete(leaderboard.hottest, tally)
+			delete(leaderboard.coldest, tally)
+			delete(leaderboard.published, tally)
+		}
+		leaderboard.m.Unlock()
+	}()
+
+	buf := &bytes.Buffer{}
+	drawDashboard(buf, sampleDashboard(time.Second, dashboardFrame{}))
+	for _, want := range []string{
+		"hottest      C 40.0, H 35.5, A 10.0, F 3.0, E 2.0\n",
+		"coldest      D -20.0, B -5.0, G -1.0, E 2.0, F 3.0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("dashboard %q doesn't list %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package brc
+
+var unitFlag = commandLine.String("unit", "C", "`unit` the aggregates are printed in: C, F or K, converted from the degrees Celsius they are kept in")
+
+// tempUnit converts tenths of a degree Celsius to tenths of another unit as (scale*tenths + offset) / divisor,
+// exactly in integers so a converted value is rounded once, half toward positive infinity like meanTenths.
+// Readings kept to more decimals scale the offset up, and the result is rounded to -precision decimals
+// in the same division.
+type tempUnit struct {
+	scale, offset, divisor int
+}
+
+var tempUnits = map[string]tempUnit{
+	"C": {1, 0, 1},
+	//F = C*9/5 + 32, in tenths 320 = 1600/5
+	"F": {9, 1600, 5},
+	//K = C + 273.15, in tenths 2731.5 = 5463/2
+	"K": {2, 5463, 2},
+}
+
+// outputUnit is the tempUnit of -unit.
+func outputUnit() tempUnit {
+	return tempUnits[*unitFlag]
+}
+
+// value converts a reading, a min or a max, to units of the last -precision decimal.
+func (u tempUnit) value(v int) int {
+	return roundDiv(u.scale*v+u.offset*readingScale/10, u.divisor*outputStep())
+}
+
+// mean converts the mean of count readings summing to sum, from the exact sum rather than the rounded mean.
+func (u tempUnit) mean(sum, count int) int {
+	return roundDiv(u.scale*sum+u.offset*readingScale/10*count, u.divisor*outputStep()*count)
+}
+
+// sum converts the sum of count readings, each of which is offset.
+func (u tempUnit) sum(sum, count int) int {
+	return roundDiv(u.scale*sum+u.offset*readingScale/10*count, u.divisor*outputStep())
+}
+
+// degrees converts a difference of temperatures, such as a standard deviation, which no offset applies to.
+func (u tempUnit) degrees(d float64) float64 {
+	return d * float64(u.scale) / float64(u.divisor)
+}
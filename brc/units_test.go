@@ -0,0 +1,68 @@
+package brc
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// unitCases are aggregates in tenths of a degree Celsius and their output in F and K.
+var unitCases = []struct {
+	min, max, sum, count int
+	f, k                 string
+}{
+	{0, 0, 0, 1, "32.0/32.0/32.0", "273.2/273.2/273.2"},
+	{-400, 1000, 600, 2, "-40.0/86.0/212.0", "233.2/303.2/373.2"},
+	{-5, -5, -5, 1, "31.1/31.1/31.1", "272.7/272.7/272.7"},
+	{0, 1, 1, 2, "32.0/32.1/32.2", "273.2/273.2/273.3"},
+	{-999, 999, 0, 2, "-147.8/32.0/211.8", "173.3/273.2/373.1"},
+	{-178, -177, -355, 2, "0.0/0.1/0.1", "255.4/255.4/255.5"},
+}
+
+// TestUnits prints unitCases in each -unit and checks tempUnit against exact conversions of random aggregates:
+// readings in hundredths of a degree, where both conversions are whole, and means as fractions.
+func TestUnits(t *testing.T) {
+	defer func(unit string) { *unitFlag = unit }(*unitFlag)
+
+	rng := rand.New(rand.NewSource(1))
+
+	for _, c := range unitCases {
+		tally := NewTally()
+		tally.add("A", StationResult{c.min, c.max, c.sum, c.count, -1, math.NaN(), nil, nil, "", -1})
+
+		for unit, want := range map[string]string{"F": c.f, "K": c.k} {
+			*unitFlag = unit
+			buf := &bytes.Buffer{}
+			tally.Print(buf)
+			if want = "{A=" + want + "}\n"; buf.String() != want {
+				t.Fatalf("%d/%d/%d/%d with -unit=%s: got %q, want %q", c.min, c.max, c.sum, c.count, unit, buf.String(), want)
+			}
+		}
+	}
+
+	hundredths := map[string]func(tenths int) int{
+		"C": func(tenths int) int { return tenths * 10 },
+		"F": func(tenths int) int { return tenths*18 + 3200 },
+		"K": func(tenths int) int { return tenths*10 + 27315 },
+	}
+	for unit, toHundredths := range hundredths {
+		u := tempUnits[unit]
+		for range 10000 {
+			tenths := rng.Intn(1999) - 999
+			if got, want := u.value(tenths), int(math.Floor(float64(toHundredths(tenths)+5)/10)); got != want {
+				t.Fatalf("%s -unit=%s: got %s, want %s", formatTenths(tenths), unit, formatTenths(got), formatTenths(want))
+			}
+
+			count := 1 + rng.Intn(1000)
+			sum := tenths * count / 2
+			mean := new(big.Rat).SetFrac64(int64(u.scale*sum+u.offset*count), int64(u.divisor*count))
+			half := new(big.Rat).Add(mean, big.NewRat(1, 2))
+			want := new(big.Int).Div(half.Num(), half.Denom())
+			if got := u.mean(sum, count); int64(got) != want.Int64() {
+				t.Fatalf("mean of %d readings summing to %s -unit=%s: got %s, want %s", count, formatTenths(sum), unit, formatTenths(got), formatTenths(int(want.Int64())))
+			}
+		}
+	}
+}
@@ -0,0 +1,309 @@
+//go:build linux
+
+package brc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// io_uring's syscalls, offsets and opcodes, from linux/io_uring.h. The syscall numbers are the same on every
+// architecture since they were added after the tables were unified.
+const (
+	SYS_IO_URING_SETUP      = 425
+	SYS_IO_URING_ENTER      = 426
+	IORING_OFF_SQ_RING      = 0
+	IORING_OFF_CQ_RING      = 0x8000000
+	IORING_OFF_SQES         = 0x10000000
+	IORING_FEAT_SINGLE_MMAP = 1
+	IORING_ENTER_GETEVENTS  = 1
+	IORING_OP_READ          = 22
+	IORING_SQE_SIZE         = 64
+	IORING_CQE_SIZE         = 16
+)
+
+// URING_QUEUE_DEPTH is how many reads of a chunk each are kept in flight ahead of the parsers.
+const URING_QUEUE_DEPTH = 8
+
+func init() {
+	strategies["uring"] = UringStrategy{}
+}
+
+// UringStrategy streams the file like StreamingStrategy, but with URING_QUEUE_DEPTH chunk sized reads at
+// aligned offsets in flight at once through io_uring, which a fast NVMe drive needs to reach its bandwidth
+// and a single blocking Read loop never gives it. Kernels or sandboxes without io_uring get StreamingStrategy.
+type UringStrategy struct{}
+
+func (UringStrategy) Process(filePtr *os.File, tally *Tally, o *Options) error {
+	r, err := newUringReader(filePtr, o.chunkSize)
+	if err != nil {
+		uringUnavailable.Do(func() { slog.Info("io_uring unavailable, streaming instead", "err", err) })
+		return StreamingStrategy{}.Process(filePtr, tally, o)
+	}
+
+	err = streamInto(r, filePtr.Name(), tally, o)
+	return errors.Join(err, r.err, r.Close())
+}
+
+// uringUnavailable logs the fallback once rather than for every file.
+var uringUnavailable sync.Once
+
+// uringParams is struct io_uring_params, with the sq_off and cq_off offsets into the mapped rings.
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+
+	sqHead, sqTail, sqMask, sqEntriesOff, sqFlags, sqDropped, sqArray, sqResv uint32
+	sqUserAddr                                                                uint64
+
+	cqHead, cqTail, cqMask, cqEntriesOff, cqOverflow, cqCqes, cqFlags, cqResv uint32
+	cqUserAddr                                                                uint64
+}
+
+// uringBlock is one read in flight, or done and not yet copied out.
+type uringBlock struct {
+	buf    []byte
+	offset int64
+	want   int
+	n      int
+	done   bool
+	err    error
+}
+
+// uringReader reads a file in order, keeping reads of the blocks ahead of the one being copied out in flight.
+// Completions come back in any order, each says which block it was by its user_data.
+type uringReader struct {
+	file *os.File
+	fd   int
+	size int64
+
+	sq, cq, sqes []byte
+	params       uringParams
+
+	blocks   []uringBlock
+	head     int
+	next     int64
+	inFlight int
+
+	//Unread bytes of the head block
+	rest []byte
+	err  error
+}
+
+func newUringReader(filePtr *os.File, chunkSize int) (*uringReader, error) {
+	info, err := filePtr.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &uringReader{file: filePtr, size: info.Size()}
+	fd, _, errno := syscall.Syscall(SYS_IO_URING_SETUP, URING_QUEUE_DEPTH, uintptr(unsafe.Pointer(&r.params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	r.fd = int(fd)
+
+	p := &r.params
+	sqSize := int(p.sqArray + p.sqEntries*4)
+	cqSize := int(p.cqCqes + p.cqEntries*IORING_CQE_SIZE)
+
+	//A kernel with one mapping for both rings wants it big enough for either
+	if p.features&IORING_FEAT_SINGLE_MMAP != 0 {
+		sqSize = max(sqSize, cqSize)
+	}
+
+	if r.sq, err = uringMap(r.fd, IORING_OFF_SQ_RING, sqSize); err == nil {
+		r.cq = r.sq
+		if p.features&IORING_FEAT_SINGLE_MMAP == 0 {
+			r.cq, err = uringMap(r.fd, IORING_OFF_CQ_RING, cqSize)
+		}
+	}
+	if err == nil {
+		r.sqes, err = uringMap(r.fd, IORING_OFF_SQES, int(p.sqEntries*IORING_SQE_SIZE))
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	//Reads start at multiples of the block size, so each is as aligned as the chunks are
+	blockSize := max(chunkSize/4096*4096, 4096)
+	r.blocks = make([]uringBlock, URING_QUEUE_DEPTH)
+	for i := range r.blocks {
+		r.blocks[i].buf = make([]byte, blockSize)
+		if err := r.submit(i); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func uringMap(fd int, offset int64, size int) ([]byte, error) {
+	data, err := syscall.Mmap(fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return nil, fmt.Errorf("mapping the io_uring rings: %w", err)
+	}
+	return data, nil
+}
+
+func (r *uringReader) u32(ring []byte, offset uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&ring[offset]))
+}
+
+// submit queues a read of the next block of the file into block i, unless the file has been read to the end.
+func (r *uringReader) submit(i int) error {
+	b := &r.blocks[i]
+	*b = uringBlock{buf: b.buf, offset: r.next, want: int(min(int64(len(b.buf)), r.size-r.next))}
+	if b.want <= 0 {
+		b.done = true
+		return nil
+	}
+	r.next += int64(b.want)
+
+	//This is the only producer, the kernel only ever reads the tail
+	tail := atomic.LoadUint32(r.u32(r.sq, r.params.sqTail))
+	index := tail & *r.u32(r.sq, r.params.sqMask)
+
+	sqe := r.sqes[index*IORING_SQE_SIZE : (index+1)*IORING_SQE_SIZE]
+	clear(sqe)
+	sqe[0] = IORING_OP_READ
+	*(*int32)(unsafe.Pointer(&sqe[4])) = int32(r.file.Fd())
+	*(*uint64)(unsafe.Pointer(&sqe[8])) = uint64(b.offset)
+	*(*uint64)(unsafe.Pointer(&sqe[16])) = uint64(uintptr(unsafe.Pointer(&b.buf[0])))
+	*(*uint32)(unsafe.Pointer(&sqe[24])) = uint32(b.want)
+	*(*uint64)(unsafe.Pointer(&sqe[32])) = uint64(i)
+
+	*r.u32(r.sq, r.params.sqArray+index*4) = index
+	atomic.StoreUint32(r.u32(r.sq, r.params.sqTail), tail+1)
+
+	if _, err := r.enter(1, 0, 0); err != nil {
+		return err
+	}
+	r.inFlight++
+	return nil
+}
+
+func (r *uringReader) enter(submit, wait, flags uint32) (int, error) {
+	for {
+		n, _, errno := syscall.Syscall6(SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(submit), uintptr(wait), uintptr(flags), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, fmt.Errorf("io_uring_enter: %w", errno)
+		}
+		return int(n), nil
+	}
+}
+
+// complete waits for the next completion and marks its block done.
+func (r *uringReader) complete() error {
+	head := atomic.LoadUint32(r.u32(r.cq, r.params.cqHead))
+	for head == atomic.LoadUint32(r.u32(r.cq, r.params.cqTail)) {
+		if _, err := r.enter(0, 1, IORING_ENTER_GETEVENTS); err != nil {
+			return err
+		}
+	}
+
+	at := (head & *r.u32(r.cq, r.params.cqMask)) * IORING_CQE_SIZE
+	cqe := r.cq[r.params.cqCqes+at:]
+	i := *(*uint64)(unsafe.Pointer(&cqe[0]))
+	res := *(*int32)(unsafe.Pointer(&cqe[8]))
+	atomic.StoreUint32(r.u32(r.cq, r.params.cqHead), head+1)
+	r.inFlight--
+
+	b := &r.blocks[i]
+	b.done = true
+	if res < 0 {
+		b.err = fmt.Errorf("read at %d: %w", b.offset, syscall.Errno(-res))
+	} else {
+		b.n = int(res)
+	}
+	return nil
+}
+
+func (r *uringReader) Read(p []byte) (int, error) {
+	read := 0
+	for read < len(p) && r.err == nil {
+		if len(r.rest) == 0 && !r.advance() {
+			break
+		}
+
+		n := copy(p[read:], r.rest)
+		r.rest = r.rest[n:]
+		read += n
+	}
+
+	if read == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+	return read, nil
+}
+
+// advance makes the head block's bytes the rest to read, once the last ones have been copied out it is
+// reused for the next read. It reports false at the end of the file or on an error.
+func (r *uringReader) advance() bool {
+	if r.rest != nil {
+		if r.err = r.submit(r.head); r.err != nil {
+			return false
+		}
+		r.head = (r.head + 1) % len(r.blocks)
+		r.rest = nil
+	}
+
+	b := &r.blocks[r.head]
+	for !b.done {
+		if r.err = r.complete(); r.err != nil {
+			return false
+		}
+	}
+	if r.err = b.err; r.err != nil {
+		return false
+	}
+	if b.want == 0 {
+		return false
+	}
+
+	//A short read leaves a gap the blocks after it don't cover, pread fills it
+	if b.n < b.want {
+		n, err := r.file.ReadAt(b.buf[b.n:b.want], b.offset+int64(b.n))
+		if b.n += n; err != nil {
+			r.err = fmt.Errorf("read at %d: %w", b.offset+int64(b.n), err)
+			return false
+		}
+	}
+
+	r.rest = b.buf[:b.n]
+	return true
+}
+
+// Close waits out the reads still in flight, which write into the blocks until they complete, then releases the ring.
+func (r *uringReader) Close() error {
+	var err error
+	for r.inFlight > 0 && err == nil {
+		err = r.complete()
+	}
+
+	if r.sqes != nil {
+		syscall.Munmap(r.sqes)
+	}
+	if r.cq != nil && unsafe.SliceData(r.cq) != unsafe.SliceData(r.sq) {
+		syscall.Munmap(r.cq)
+	}
+	if r.sq != nil {
+		syscall.Munmap(r.sq)
+	}
+	return errors.Join(err, syscall.Close(r.fd))
+}
@@ -0,0 +1,228 @@
+package brc
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// validateFlags checks every flag combination up front so that a bad invocation fails with
+// an actionable message before any file is opened, instead of deep inside the pipeline.
+func validateFlags() error {
+	var errs []error
+
+	check := func(bad bool, format string, args ...interface{}) {
+		if bad {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, known := strategies[*strategyName]
+	check(!known, "-strategy=%s is unknown, want one of %s", *strategyName, strategyNames())
+	check(*strategyName == "mmap" && !mmapSupported, "-strategy=mmap is not supported on %s, use -strategy=pread instead", runtime.GOOS)
+	_, known = keyFuncs[*keyName]
+	check(!known, "-key=%s is unknown, want one of name, first-token, lower", *keyName)
+	check(*watchdogTimeout < 0, "-watchdog must not be negative, got %v", *watchdogTimeout)
+	check(*watchdogAbort && *watchdogTimeout == 0, "-watchdog-abort needs -watchdog")
+	if _, _, err := parseAggFns(*aggFnsFlag); err != nil {
+		errs = append(errs, err)
+	}
+	check(*aggFnsFlag != AGG_FNS_DEFAULT && *outputFormat != "text", "-agg-fns only shapes -output-format=text, got %s", *outputFormat)
+	check(*groupBy != "station" && *metadataFile == "", "-group-by=%s needs -metadata", *groupBy)
+	if *schemaFlag != "" {
+		if _, err := parseSchema(*schemaFlag); err != nil {
+			errs = append(errs, err)
+		}
+		check(*outputFormat != "text", "-schema needs -output-format=text, the others have a single metric, got %s", *outputFormat)
+	}
+	for _, stat := range strings.Split(*statsFlag, ",") {
+		check(*statsFlag != "" && !slices.Contains(extraStats, strings.TrimSpace(stat)), "-stats: %q is unknown, want some of %s", stat, strings.Join(extraStats, ", "))
+	}
+	if c, err := parseCommentChar(*commentCharFlag); err != nil {
+		errs = append(errs, err)
+	} else {
+		d, _ := parseDelimiter(*fieldDelimiter)
+		check(c != 0 && c == d, "-comment-char and -delimiter are both %q", c)
+	}
+	check(*followInterval <= 0, "-follow-interval must be positive, got %v", *followInterval)
+	check(*follow && *perFile, "-follow only reports the total, drop -per-file")
+	check(*watchInterval <= 0, "-watch-interval must be positive, got %v", *watchInterval)
+	check(*sampleFlag < 0 || *sampleFlag > 1, "-sample must be a fraction between 0 and 1, got %v", *sampleFlag)
+	check(*sampleFlag != 0 && *follow, "-follow reads every appended line, drop -sample")
+	check(*sampleFlag != 0 && *directIO, "-direct-io reads the whole file, drop -sample")
+	check(*watch && *perFile, "-watch only reports the total, drop -per-file")
+	check(*watch && *follow, "-watch and -follow both keep running, pick one")
+	check(*tui && (*watch || *follow), "-tui draws a single pass over the inputs, drop -watch and -follow")
+	check(*repeat < 1, "-repeat must be at least 1, got %d", *repeat)
+	check(*otelEndpoint != "" && !strings.HasPrefix(*otelEndpoint, "http://") && !strings.HasPrefix(*otelEndpoint, "https://"), "-otel-endpoint must be an http or https URL, got %q", *otelEndpoint)
+	check(*otelEndpoint != "" && (*watch || *follow), "-otel-endpoint exports a single run, drop -watch and -follow")
+	check(*repeat > 1 && (*watch || *follow), "-repeat times separate runs, drop -watch and -follow")
+	check(*dropCaches && !adviseSupported, "-drop-caches needs 64 bit Linux")
+	check(*verifySHA256 != "" && !sha256Hex.MatchString(*verifySHA256), "-verify-sha256 must be 64 hex digits, got %q", *verifySHA256)
+	check(*verifyWarn && *verifySHA256 == "", "-verify-warn needs -verify-sha256")
+	check(*verifySHA256 != "" && *strategyName == "pread", "-verify-sha256 hashes the input in order, -strategy=pread reads it in parallel segments")
+	check(*verifySHA256 != "" && (*sampleFlag != 0 || sliced()), "-verify-sha256 hashes the whole input, drop -sample, -offset and -limit")
+	check(*verifySHA256 != "" && *httpRanges > 1, "-verify-sha256 hashes the input in order, drop -http-ranges")
+	check(*verifySHA256 != "" && (*watch || *follow), "-verify-sha256 checks a single read of the input, drop -watch and -follow")
+	for _, pattern := range inputPatterns() {
+		check(*follow && isURL(pattern), "-follow can't tail %s, only local files", pattern)
+		check(*watch && isURL(pattern), "-watch can't watch %s, only local files", pattern)
+		check(*sampleFlag != 0 && isURL(pattern), "-sample reads chunks of local files, %s is a URL", pattern)
+		check(sliced() && isURL(pattern), "-offset and -limit slice local files, %s is a URL", pattern)
+	}
+	if *maxMemory != "" {
+		if _, err := parseBytes(*maxMemory); err != nil {
+			errs = append(errs, fmt.Errorf("-max-memory %w", err))
+		}
+	}
+	if _, err := parseSpan("offset", *offsetFlag); err != nil {
+		errs = append(errs, err)
+	}
+	if limit, err := parseSpan("limit", *limitFlag); err != nil {
+		errs = append(errs, err)
+	} else {
+		check(*limitFlag != "" && limit.n == 0, "-limit must be more than 0, leave it out to parse to the end")
+	}
+	check(sliced() && *sampleFlag != 0, "-sample reads chunks of the whole file, drop -offset and -limit")
+	check(sliced() && *follow, "-follow reads every appended line, drop -offset and -limit")
+	check(sliced() && *directIO, "-direct-io reads the whole file, drop -offset and -limit")
+	check(!contains(logFormats, *logFormat), "-log-format=%s is unknown, want one of %s", *logFormat, strings.Join(logFormats, ", "))
+	check(!contains(invalidUTF8Modes, *invalidUTF8), "-invalid-utf8=%s is unknown, want one of %s", *invalidUTF8, strings.Join(invalidUTF8Modes, ", "))
+	check(*verbose && *quiet, "-v and -quiet contradict each other, give one")
+	if names := strings.Split(*parquetColumns, ","); len(names) != 2 || names[0] == "" || names[1] == "" {
+		check(true, "-parquet-columns=%s wants the station and temperature columns, e.g. station,temperature", *parquetColumns)
+	}
+	_, known = gcProfiles[*gcTuning]
+	check(!known, "-gc-tuning=%s is unknown, want one of %s", *gcTuning, strings.Join(sortedKeys(gcProfiles), ", "))
+	check(*queueDepth < 0, "-queue-depth must not be negative, got %d", *queueDepth)
+	check(*httpRetries < 0, "-http-retries must not be negative, got %d", *httpRetries)
+	check(*httpRanges < 0, "-http-ranges must not be negative, got %d", *httpRanges)
+	check(*partSize <= 0, "-part-size must be positive, got %d", *partSize)
+	_, known = presets[*preset]
+	check(*preset != "" && !known, "-preset=%s is unknown, want one of %s", *preset, strings.Join(sortedKeys(presets), ", "))
+	_, known = numericModes[*numericStations]
+	check(!known, "-numeric-stations=%s is unknown, want one of auto, on, off", *numericStations)
+	check(*officialStations && *numericStations == "on", "-numeric-stations=on asserts every station is an integer, which no official station is")
+	check(*officialStations && *seedDictionary != "", "-official-stations and -seed-dictionary both pick the stations of the perfect hash, pick one")
+	if _, err := regexp.Compile(*matchStations); err != nil {
+		errs = append(errs, fmt.Errorf("-match: %w", err))
+	}
+	if _, err := parseDelimiter(*fieldDelimiter); err != nil {
+		errs = append(errs, err)
+	}
+	_, known = delimiterModes[*duplicateDelimiter]
+	check(!known, "-duplicate-delimiter=%s is unknown, want one of first, last, reject", *duplicateDelimiter)
+	_, known = phaseModes[*phaseFlag]
+	check(!known, "-phase=%s is unknown, want one of all, io, parse", *phaseFlag)
+	check(*phaseFlag != "all" && *strategyName == "naive", "-phase needs a chunked strategy, -strategy=naive parses as it reads")
+	check(*phaseFlag != "all" && (*repeat > 1 || *watch || *follow), "-phase reports the throughput of a single run, drop -repeat, -watch and -follow")
+	check(*workers < 1, "-workers must be at least 1, got %d", *workers)
+	check(*maxStations < 0, "-max-stations must be positive or 0 to disable, got %d", *maxStations)
+	check(!contains(timingFormats, *timingFormat), "-timing-format=%s is unknown, want one of %s", *timingFormat, strings.Join(timingFormats, ", "))
+	check(*chunkLog != "" && *strategyName == "naive", "-chunk-log needs a chunked strategy, -strategy=naive reads line by line")
+	check(*nice < -20 || *nice > 19, "-nice must be between -20 and 19, got %d", *nice)
+	check(*nice != 0 && !resourceNiceSupported, "-nice is not supported on %s", runtime.GOOS)
+	check(*ionice != "" && !resourceIoniceSupported, "-ionice is only supported on Linux")
+	if _, err := parseIonice(*ionice); *ionice != "" && err != nil {
+		errs = append(errs, err)
+	}
+	check(*maxCPUs < 0, "-max-cpus must be positive or 0 to use every CPU, got %d", *maxCPUs)
+	check(flagSet("cpus") && flagSet("max-cpus"), "-cpus and -max-cpus are the same flag, give only one")
+	check(*pinCPUs && *maxCPUs == 0, "-pin-cpus needs -max-cpus to say how many CPUs to pin to")
+	check(*pinCPUs && !resourcePinSupported, "-pin-cpus is only supported on Linux")
+	check(!contains(adviseModes, *advise), "-advise=%s is unknown, want one of %s", *advise, strings.Join(adviseModes, ", "))
+	check(flagSet("advise") && *advise != "off" && !adviseSupported, "-advise is only supported on 64 bit Linux")
+	check(*directIO && !directIOSupported, "-direct-io is only supported on Linux and macOS")
+	check(*directIO && *strategyName != "streaming", "-direct-io reads with -strategy=streaming, got %s", *strategyName)
+	check(!contains(hugePageModes, *hugePages), "-huge-pages=%s is unknown, want one of %s", *hugePages, strings.Join(hugePageModes, ", "))
+	check(*hugePages != "off" && !hugePagesSupported, "-huge-pages is only supported on Linux")
+	check(*hugePages != "off" && *strategyName != "mmap", "-huge-pages maps with -strategy=mmap, got %s", *strategyName)
+	check(*installCompletion != "" && !contains(completionShells, *installCompletion), "-install-completion=%s is unknown, want one of %s", *installCompletion, strings.Join(completionShells, ", "))
+	_, known = reporters[*outputFormat]
+	check(!known, "-output-format=%s is unknown, want one of %s", *outputFormat, strings.Join(sortedKeys(reporters), ", "))
+	check(*perFile && *outputFormat != "text", "-per-file only works with -output-format=text")
+	check(*appendRunID != "" && *outputFile == "", "-append-run-id needs -output to say where to append to")
+	check(*appendRunID != "" && *outputFormat == "text", "-append-run-id needs a format with a run column, the text format has none")
+	check(*appendRunID != "" && *outputFormat == "chart", "-append-run-id needs a format with a run column, the chart has none")
+	check(*chartRows < 0, "-chart-rows must be positive or 0 to draw every station, got %d", *chartRows)
+	check(*appendRunID != "" && *outputFormat == "arrow", "-append-run-id can't append to an Arrow file, it ends in a footer")
+	check(*outputFormat == "sqlite" && *outputFile == "", "-output-format=sqlite needs -output to name the database")
+	check(*outputFormat == "http" && !strings.HasPrefix(*outputFile, "http://") && !strings.HasPrefix(*outputFile, "https://"), "-output-format=http needs -output to be an http:// or https:// URL, got %q", *outputFile)
+	check(*top < 0, "-top must be positive or 0 to print every station, got %d", *top)
+	check(!contains(topOrders, *topBy), "-by=%s is unknown, want one of %s", *topBy, strings.Join(topOrders, ", "))
+	check(flagSet("by") && *top == 0, "-by only orders the -top report, give -top n too")
+	_, known = tempUnits[*unitFlag]
+	check(!known, "-unit=%s is unknown, want one of %s", *unitFlag, strings.Join(sortedKeys(tempUnits), ", "))
+	check(*unitFlag != "C" && strings.Contains(","+aggFnsList(*aggFnsFlag, *statsFlag)+",", ",geomean,"), "-unit=%s can't convert geomean, which isn't kept in a form that converts, give -agg-fns without it", *unitFlag)
+	check(*precisionFlag < 0 || *precisionFlag > MAX_PRECISION, "-precision must be 0 to %d, got %d", MAX_PRECISION, *precisionFlag)
+	check(*reservoirSize < 0, "-reservoir must be positive or 0 to keep no sample, got %d", *reservoirSize)
+	check(*reservoirSize > 0 && !contains([]string{"json", "jsonl", "http"}, *outputFormat), "-reservoir samples are listed in the json, jsonl and http outputs, not %s", *outputFormat)
+	check(*histogramBuckets < 0 || *histogramBuckets > MAX_HISTOGRAM_BUCKETS, "-histogram must be 1 to %d buckets or 0 to keep none, got %d", MAX_HISTOGRAM_BUCKETS, *histogramBuckets)
+	for _, name := range strings.Split(aggFnsList(*aggFnsFlag, *statsFlag), ",") {
+		_, isPercentile := parsePercentile(strings.TrimSpace(name))
+		check(isPercentile && *histogramBuckets == 0, "-agg-fns=%s needs -histogram n to count the readings by", strings.TrimSpace(name))
+	}
+	check(*minCount < 0, "-min-count must be positive or 0 to keep every station, got %d", *minCount)
+	check(!contains(sparseModes, *sparseMode), "-sparse=%s is unknown, want one of %s", *sparseMode, strings.Join(sparseModes, ", "))
+	check(flagSet("sparse") && *minCount == 0, "-sparse says what to do with the stations below -min-count, give -min-count n too")
+	check(flagSparse() && (*outputFormat == "arrow" || *outputFormat == "sqlite"), "-sparse=flag marks stations in the text, csv and json formats, not %s", *outputFormat)
+	check(!contains(sortOrders, *sortBy), "-sort=%s is unknown, want one of %s", *sortBy, strings.Join(sortOrders, ", "))
+	_, known = collationTailorings[*collateLocale]
+	check(*collateLocale != "" && !known, "-collate=%s is unknown, want one of %s", *collateLocale, strings.Join(sortedKeys(collationTailorings), ", "))
+	profiles := map[string]string{}
+	for _, name := range []string{"cpuprofile", "memprofile", "blockprofile", "mutexprofile", "trace"} {
+		file := commandLine.Lookup(name).Value.String()
+		if other, ok := profiles[file]; ok && file != "" {
+			check(true, "-%s and -%s both write to %s, give them different files", other, name, file)
+		}
+		profiles[file] = name
+	}
+	check(*blockprofileRate < 1, "-blockprofile-rate must be at least 1, got %d", *blockprofileRate)
+	check(*mutexprofileFraction < 1, "-mutexprofile-fraction must be at least 1, got %d", *mutexprofileFraction)
+
+	return errors.Join(errs...)
+}
+
+// exitOnInvalidFlags reports every problem found by validateFlags and exits with the same status as a flag parse error.
+func exitOnInvalidFlags() {
+	if err := validateFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "run with -h for usage")
+		os.Exit(2)
+	}
+}
+
+func strategyNames() string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	commandLine.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package brc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log/slog"
+	"regexp"
+)
+
+var verifySHA256 = commandLine.String("verify-sha256", "", "fail the run unless the input's sha256 is `hex`, hashed as the reader goes rather than in a pass of its own, so benchmark runs are known to be over identical data")
+var verifyWarn = commandLine.Bool("verify-warn", false, "only warn when -verify-sha256 doesn't match, reporting the results anyway")
+
+// sha256Hex is a sha256 digest written out in hex, either case.
+var sha256Hex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// verifyInput compares digest, what was hashed of name, with the sha256 o verifies. A mismatch is an error or
+// with -verify-warn a warning, a nil digest isn't being verified. Only readers that go through the file in order
+// hash it, -verify-sha256 is rejected with the others.
+func verifyInput(name string, digest hash.Hash, o *Options) error {
+	if digest == nil {
+		return nil
+	}
+
+	got := hex.EncodeToString(digest.Sum(nil))
+	if got == o.verify {
+		slog.Debug("input verified", "name", name, "sha256", got)
+		return nil
+	}
+
+	if o.verifyWarn {
+		slog.Warn("input doesn't match -verify-sha256", "name", name, "sha256", got, "want", o.verify)
+		return nil
+	}
+	return fmt.Errorf("sha256 is %s, -verify-sha256 wants %s", got, o.verify)
+}
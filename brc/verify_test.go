@@ -0,0 +1,59 @@
+package brc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVerify hashes a random input with every strategy that reads in order, which must pass its own digest
+// and fail, or with -verify-warn only warn about, any other.
+func TestVerify(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var input strings.Builder
+	for range 1 + rng.Intn(5000) {
+		fmt.Fprintf(&input, "S%d;%.1f\n", rng.Intn(50), float64(rng.Intn(1999)-999)/10)
+	}
+	file := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(file, []byte(input.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(input.String()))
+	digest := hex.EncodeToString(sum[:])
+	wrong := strings.Repeat("0", 64)
+
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	for _, name := range []string{"naive", "streaming", "mmap"} {
+		if name == "mmap" && !mmapSupported {
+			continue
+		}
+		for _, c := range []struct {
+			want    string
+			warn    bool
+			matches bool
+		}{{digest, false, true}, {strings.ToUpper(digest), false, true}, {wrong, false, false}, {wrong, true, true}} {
+			o, err := newOptions(WithStrategy(strategies[name]), WithChunkSize(MIN_CHUNK_SIZE), WithVerifySHA256(c.want, c.warn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = processFiles(o, []string{file})
+			if c.matches && err != nil {
+				t.Fatalf("%s: -verify-sha256=%s -verify-warn=%t: %v", name, c.want, c.warn, err)
+			}
+			if !c.matches && (err == nil || !strings.Contains(err.Error(), digest)) {
+				t.Fatalf("%s: -verify-sha256=%s gave %v, want a mismatch naming %s", name, c.want, err, digest)
+			}
+		}
+	}
+}
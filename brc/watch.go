@@ -0,0 +1,132 @@
+package brc
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+var watch = commandLine.Bool("watch", false, "after reporting keep watching the inputs, running the whole aggregation again whenever one is modified, replaced, added or removed")
+var watchInterval = commandLine.Duration("watch-interval", 500*time.Millisecond, "how often -watch checks the inputs, `duration`")
+
+// watcher reruns the aggregation over the inputs whenever they change. A change must hold still for a whole
+// poll before the rerun, so a generator still writing the file isn't read half way.
+type watcher struct {
+	//expand lists the inputs, again on every poll so a glob picks up new files
+	expand func() ([]string, error)
+	report func(Report) error
+
+	//stamps of the inputs as last run, and of the change waiting to settle
+	stamps, pending map[string]os.FileInfo
+
+	//last error listing the inputs, logged once rather than every poll
+	lastErr string
+}
+
+// poll runs the aggregation if the inputs have changed since the last run and stayed as they are since the
+// last poll, or have never been run, reporting whether it did. Only a failing report is returned,
+// inputs that can't be read are logged and waited out.
+func (w *watcher) poll(o *Options) (bool, error) {
+	files, stamps, err := watchStamps(w.expand)
+	if err != nil {
+		if err.Error() != w.lastErr {
+			slog.Warn("can't read the inputs, waiting for them", "err", err)
+			w.lastErr = err.Error()
+		}
+		w.pending = nil
+		return false, nil
+	}
+	w.lastErr = ""
+
+	if w.stamps != nil && sameStamps(stamps, w.stamps) {
+		w.pending = nil
+		return false, nil
+	}
+	if w.stamps != nil && !sameStamps(stamps, w.pending) {
+		w.pending = stamps
+		return false, nil
+	}
+
+	if w.stamps != nil {
+		slog.Info("inputs changed, running again", "files", len(files))
+	}
+	w.stamps, w.pending = stamps, nil
+
+	resetPhases()
+	resetSampling()
+	start := clock.Now()
+	tallies, err := processFiles(o, files)
+	if err != nil {
+		slog.Error("could not process inputs, waiting for them to change", "err", err)
+		return false, nil
+	}
+
+	var groups map[string]string
+	if *groupBy != "station" {
+		if groups, err = readGroupsFile(*metadataFile, *groupBy); err != nil {
+			slog.Error("could not read station metadata, waiting for the inputs to change", "err", err)
+			return false, nil
+		}
+	}
+
+	total := NewTally()
+	for _, tally := range tallies {
+		if groups != nil {
+			tally = tally.Rollup(groups)
+		}
+		total.Merge(tally)
+	}
+
+	if err := w.report(Report{RunID: *appendRunID, Tally: total, Phases: collectPhases(clock.Since(start))}); err != nil {
+		return true, err
+	}
+	writeSampleNote(os.Stderr)
+	return true, nil
+}
+
+// watchStamps lists the inputs and stats each of them.
+func watchStamps(expand func() ([]string, error)) ([]string, map[string]os.FileInfo, error) {
+	files, err := expand()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stamps := make(map[string]os.FileInfo, len(files))
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		stamps[name] = info
+	}
+	return files, stamps, nil
+}
+
+// sameStamps reports whether a and b are the same files, none of them replaced by a new one or modified since.
+func sameStamps(a, b map[string]os.FileInfo) bool {
+	if len(a) != len(b) || a == nil || b == nil {
+		return false
+	}
+
+	for name, info := range a {
+		other, ok := b[name]
+		if !ok || !os.SameFile(info, other) || info.Size() != other.Size() || !info.ModTime().Equal(other.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// runWatch reports the inputs, then again after every change to them. It only returns on an error.
+func runWatch(o *Options) error {
+	w := &watcher{expand: inputFiles, report: reporters[*outputFormat](*outputFile).Report}
+
+	for {
+		if _, err := w.poll(o); err != nil {
+			return err
+		}
+
+		fired, _ := clock.NewTimer(*watchInterval)
+		<-fired
+	}
+}
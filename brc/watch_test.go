@@ -0,0 +1,100 @@
+package brc
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestWatch polls a watcher through a file being modified, replaced by a rename, removed and written again.
+// Each change must be reported once it holds still for a poll, with what a fresh run over the file gives.
+func TestWatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+
+	file := dir + "/watched.txt"
+	var reported []string
+	w := &watcher{
+		expand: func() ([]string, error) { return []string{file}, nil },
+		report: func(r Report) error {
+			buf := &bytes.Buffer{}
+			r.Tally.Print(buf)
+			reported = append(reported, buf.String())
+			return nil
+		},
+	}
+	o, err := newOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//mtimes can be as coarse as a second, so every write moves its file's on by one
+	mtime := time.Now()
+	write := func(name string) error {
+		random, err := writeRandomFiles(rng, dir, 1, rng.Intn(2*BUFFER_SIZE))
+		if err != nil {
+			return err
+		}
+		mtime = mtime.Add(time.Second)
+		return errors.Join(os.Rename(random[0], name), os.Chtimes(name, mtime, mtime))
+	}
+
+	//Each step changes the file, then polls until the watcher reports, which must take exactly polls of them
+	steps := []struct {
+		name   string
+		change func() error
+		polls  int
+	}{
+		{"first run", func() error { return write(file) }, 1},
+		{"unchanged", func() error { return nil }, 0},
+		{"modified", func() error {
+			f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			_, err = f.WriteString("Added;1.5\n")
+			mtime = mtime.Add(time.Second)
+			return errors.Join(err, f.Close(), os.Chtimes(file, mtime, mtime))
+		}, 2},
+		{"replaced", func() error { return write(dir + "/new.txt") }, 0},
+		{"renamed over", func() error { return os.Rename(dir+"/new.txt", file) }, 2},
+		{"removed", func() error { return os.Remove(file) }, 0},
+		{"written again", func() error { return write(file) }, 2},
+	}
+
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		before := len(reported)
+		for i := 0; i < 3; i++ {
+			if _, err := w.poll(o); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if len(reported) > before {
+				if i+1 != step.polls {
+					t.Fatalf("%s: reported after %d polls, want %d", step.name, i+1, step.polls)
+				}
+				break
+			}
+		}
+		if len(reported) == before {
+			if step.polls != 0 {
+				t.Fatalf("%s: not reported after 3 polls", step.name)
+			}
+			continue
+		}
+
+		want, err := runPipeline(StreamingStrategy{}, []string{file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reported[len(reported)-1]; got != want {
+			t.Fatalf("%s: reported %q, a fresh run gives %q", step.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package brc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var watchdogTimeout = commandLine.Duration("watchdog", 0, "dump goroutine stacks and queue depths to stderr when the pipeline makes no progress for `duration` (0 disables)")
+var watchdogAbort = commandLine.Bool("watchdog-abort", false, "exit with status 3 after the -watchdog dump instead of waiting on")
+
+// WATCHDOG_EXIT is the exit status of a run aborted by -watchdog-abort.
+const WATCHDOG_EXIT = 3
+
+// progress is bumped by every read and every parsed chunk. The watchdog only cares whether it moves,
+// a single atomic add per 512kb chunk is lost in the noise.
+var progress atomic.Int64
+
+// watchedQueues describes the depth of every live handoff between readers and parsers, keyed by name.
+var watchedQueues = struct {
+	depths map[string]func() string
+	m      sync.Mutex
+}{depths: map[string]func() string{}}
+
+// watchQueue registers depth under name until the returned func is called.
+func watchQueue(name string, depth func() string) func() {
+	watchedQueues.m.Lock()
+	watchedQueues.depths[name] = depth
+	watchedQueues.m.Unlock()
+
+	return func() {
+		watchedQueues.m.Lock()
+		delete(watchedQueues.depths, name)
+		watchedQueues.m.Unlock()
+	}
+}
+
+// startWatchdog checks progress every quarter of timeout and dumps once per stall to out.
+// The returned func stops it.
+func startWatchdog(timeout time.Duration, abort bool, out io.Writer) func() {
+	stop := make(chan struct{})
+
+	go func() {
+		last, since, dumped := progress.Load(), clock.Now(), false
+
+		for {
+			tick, stopTick := clock.NewTimer(timeout / 4)
+
+			select {
+			case <-stop:
+				stopTick()
+				return
+			case <-tick:
+			}
+
+			if now := progress.Load(); now != last {
+				last, since, dumped = now, clock.Now(), false
+				continue
+			}
+
+			if dumped || clock.Since(since) < timeout {
+				continue
+			}
+
+			dumpStall(out, clock.Since(since))
+			dumped = true
+
+			if abort {
+				os.Exit(WATCHDOG_EXIT)
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// dumpStall writes the queue depths then every goroutine's stack, the same format as a panic.
+func dumpStall(out io.Writer, stalled time.Duration) {
+	fmt.Fprintf(out, "watchdog: no progress for %v\n", stalled.Round(time.Millisecond))
+
+	watchedQueues.m.Lock()
+	names := sortedKeys(watchedQueues.depths)
+	for _, name := range names {
+		fmt.Fprintf(out, "watchdog: %s: %s\n", name, watchedQueues.depths[name]())
+	}
+	watchedQueues.m.Unlock()
+
+	if len(names) == 0 {
+		fmt.Fprintln(out, "watchdog: no queues live")
+	}
+
+	pprof.Lookup("goroutine").WriteTo(out, 2)
+}
@@ -0,0 +1,159 @@
+/*
+Package conformance holds the canonical output rules of the One Billion Row Challenge and a table of
+tricky inputs, so any Go implementation can check itself against the same expectations this one uses.
+
+Values are carried in integer tenths of a degree, the way every fast implementation stores them:
+
+  - min and max are printed as is, the mean is sum/count rounded half toward positive infinity
+  - -0.0 is never printed, a value rounding to zero is always 0.0
+  - stations are ordered the way the Java baseline's TreeMap orders them, by UTF-16 code units
+  - the output is a single line: {name=min/mean/max, name=min/mean/max, ...}
+*/
+package conformance
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// Station is the aggregate of one station, all values in tenths of a degree.
+type Station struct {
+	Name                 string
+	Min, Max, Sum, Count int64
+}
+
+// MeanTenths returns sum/count in tenths, rounded half toward positive infinity.
+func MeanTenths(sum, count int64) int64 {
+	return int64(math.Floor(float64(sum)/float64(count) + 0.5))
+}
+
+// FormatTenths renders a value held in tenths with exactly one fractional digit, never as -0.0.
+func FormatTenths(tenths int64) string {
+	sign := ""
+	if tenths < 0 {
+		sign = "-"
+		tenths = -tenths
+	}
+	return sign + strconv.FormatInt(tenths/10, 10) + "." + strconv.FormatInt(tenths%10, 10)
+}
+
+// Less orders station names by UTF-16 code units, which differs from byte order only for
+// names containing characters above U+FFFF compared against U+E000..U+FFFF.
+func Less(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+
+	return len(ua) < len(ub)
+}
+
+// Sort orders stations canonically.
+func Sort(stations []Station) {
+	sort.Slice(stations, func(i, j int) bool {
+		return Less(stations[i].Name, stations[j].Name)
+	})
+}
+
+// Format renders stations as the canonical result line including the trailing newline.
+// stations is sorted in place.
+func Format(stations []Station) string {
+	Sort(stations)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, s := range stations {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(s.Name)
+		sb.WriteByte('=')
+		sb.WriteString(FormatTenths(s.Min))
+		sb.WriteByte('/')
+		sb.WriteString(FormatTenths(MeanTenths(s.Sum, s.Count)))
+		sb.WriteByte('/')
+		sb.WriteString(FormatTenths(s.Max))
+	}
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// Case is a measurements file and the exact output it must produce.
+type Case struct {
+	Name  string
+	Input string
+	Want  string
+}
+
+// Cases are the inputs implementations most often get wrong.
+var Cases = []Case{
+	{"single reading", "Solo;12.3\n", "{Solo=12.3/12.3/12.3}\n"},
+	{"negative zero", "Zero;-0.0\n", "{Zero=0.0/0.0/0.0}\n"},
+	{"negative mean rounds to zero", "Tiny;-0.1\nTiny;0.0\n", "{Tiny=-0.1/0.0/0.0}\n"},
+	{"negative half rounds up", "Neg;-0.1\nNeg;-0.2\n", "{Neg=-0.2/-0.1/-0.1}\n"},
+	{"positive half rounds up", "Pos;0.1\nPos;0.2\n", "{Pos=0.1/0.2/0.2}\n"},
+	{"mean of thirds", "Third;0.1\nThird;0.1\nThird;0.2\n", "{Third=0.1/0.1/0.2}\n"},
+	{"extremes", "Hot;99.9\nCold;-99.9\nHot;-99.9\nCold;99.9\n", "{Cold=-99.9/0.0/99.9, Hot=-99.9/0.0/99.9}\n"},
+	{"single digit integer part", "Low;-5.5\nLow;5.5\n", "{Low=-5.5/0.0/5.5}\n"},
+	{"case sensitive order", "a;1.0\nB;2.0\n", "{B=2.0/2.0/2.0, a=1.0/1.0/1.0}\n"},
+	{"prefix sorts first", "Ab;1.0\nA;2.0\n", "{A=2.0/2.0/2.0, Ab=1.0/1.0/1.0}\n"},
+	{"multibyte names", "Zürich;1.0\nSão Paulo;2.0\nÜrümqi;3.0\n", "{São Paulo=2.0/2.0/2.0, Zürich=1.0/1.0/1.0, Ürümqi=3.0/3.0/3.0}\n"},
+	{"utf-16 order", "�;1.0\n\U0001F600;2.0\n", "{\U0001F600=2.0/2.0/2.0, �=1.0/1.0/1.0}\n"},
+	{"long name", strings.Repeat("x", 100) + ";1.0\n", "{" + strings.Repeat("x", 100) + "=1.0/1.0/1.0}\n"},
+	{"no trailing newline", "End;1.0\nEnd;2.0", "{End=1.0/1.5/2.0}\n"},
+}
+
+// FormatCase is an aggregate and the result line it must format to.
+type FormatCase struct {
+	Station Station
+	Want    string
+}
+
+// FormatCases exercise the rounding and formatting rules without going through a parser.
+var FormatCases = []FormatCase{
+	{Station{"A", -1, -1, -1, 1}, "{A=-0.1/-0.1/-0.1}\n"},
+	{Station{"A", 0, 0, 0, 1}, "{A=0.0/0.0/0.0}\n"},
+	{Station{"A", -1, 0, -1, 2}, "{A=-0.1/0.0/0.0}\n"},
+	{Station{"A", -2, -1, -3, 2}, "{A=-0.2/-0.1/-0.1}\n"},
+	{Station{"A", -2, -1, -5, 3}, "{A=-0.2/-0.2/-0.1}\n"},
+	{Station{"A", 1, 2, 3, 2}, "{A=0.1/0.2/0.2}\n"},
+	{Station{"A", -999, 999, 0, 2}, "{A=-99.9/0.0/99.9}\n"},
+	{Station{"A", -999, -999, -999 * 3, 3}, "{A=-99.9/-99.9/-99.9}\n"},
+}
+
+// CheckFormat verifies an implementation's formatter against FormatCases.
+func CheckFormat(t testing.TB, format func(stations []Station) string) {
+	t.Helper()
+
+	for _, c := range FormatCases {
+		if got := format([]Station{c.Station}); got != c.Want {
+			t.Errorf("format(%+v) = %q, want %q", c.Station, got, c.Want)
+		}
+	}
+}
+
+// Check feeds every Case to run, which must process input as a measurements file
+// and return exactly what the implementation writes to stdout.
+func Check(t testing.TB, run func(input []byte) (string, error)) {
+	t.Helper()
+
+	for _, c := range Cases {
+		got, err := run([]byte(c.Input))
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+
+		if got != c.Want {
+			t.Errorf("%s: got %q, want %q", c.Name, got, c.Want)
+		}
+	}
+}
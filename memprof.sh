@@ -1,3 +1,3 @@
 #!/usr/bin/bash 
 
-go run main.go -memprofile=mem.prof && go tool pprof -http=:8080 mem.prof
+go run . -memprofile=mem.prof && go tool pprof -http=:8080 mem.prof
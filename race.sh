@@ -0,0 +1,8 @@
+#!/usr/bin/bash
+
+# What CI runs: every test under the race detector, which fails on any unsynchronised access they provoke.
+# TestConcurrentMatchesSerial drives every strategy concurrently against -serial, so this catches the races that matter.
+set -e
+
+go vet ./...
+go test -race -count=1 ./...
@@ -0,0 +1,21 @@
+#!/usr/bin/bash
+
+# Fully static, CGO free binaries for dropping onto benchmark machines.
+# Fixtures and completion templates are embedded, so each binary is the only file needed.
+set -e
+
+version=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+targets="linux/amd64 linux/arm64 darwin/amd64 darwin/arm64 windows/amd64"
+
+mkdir -p dist
+for target in $targets; do
+    os=${target%/*}
+    arch=${target#*/}
+    out=dist/brc-$version-$os-$arch
+    if [ "$os" = windows ]; then
+        out=$out.exe
+    fi
+
+    echo "building $out"
+    CGO_ENABLED=0 GOOS=$os GOARCH=$arch go build -trimpath -tags netgo,osusergo -ldflags="-s -w" -o "$out" .
+done
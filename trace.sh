@@ -0,0 +1,3 @@
+#!/usr/bin/bash 
+
+go run . -trace=trace.out && go tool trace -http=:8080 trace.out